@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/nerdneilsfield/dumper/internal/config"
+	"github.com/nerdneilsfield/dumper/internal/ingest"
+	"github.com/nerdneilsfield/dumper/internal/store"
+)
+
+// runEncrypt encrypts plaintext content left in vaults from before
+// encryption was enabled.
+func runEncrypt(stores store.Stores, cmd config.EncryptCommand) error {
+	if !stores.EncryptionEnabled() {
+		return fmt.Errorf("encryption key is not configured (set ENCRYPTION_KEY)")
+	}
+
+	userIDs, err := targetUsers(stores, cmd.UserID)
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		vault, err := stores.GetVault(userID)
+		if err != nil {
+			return fmt.Errorf("open vault %d: %w", userID, err)
+		}
+		stats, err := vault.EncryptExisting()
+		if err != nil {
+			return fmt.Errorf("encrypt vault %d: %w", userID, err)
+		}
+		slog.Info("encrypted vault", "user_id", userID, "items", stats.Items, "files", stats.Files, "blobs", stats.Blobs)
+	}
+	return nil
+}
+
+// runGC removes orphaned files once and prints what was (or would be) removed.
+func runGC(stores store.Stores, cmd config.GCCommand, grace time.Duration) error {
+	userIDs, err := targetUsers(stores, cmd.UserID)
+	if err != nil {
+		return err
+	}
+
+	opts := store.GCOptions{DryRun: cmd.DryRun, GracePeriod: grace}
+	for _, userID := range userIDs {
+		report, err := store.CollectUserGarbage(stores, userID, opts)
+		if err != nil {
+			return fmt.Errorf("gc user %d: %w", userID, err)
+		}
+		for _, orphan := range report.Orphans {
+			fmt.Printf("%d\t%s\n", userID, orphan)
+		}
+		slog.Info("garbage collection finished",
+			"user_id", userID,
+			"dry_run", cmd.DryRun,
+			"files_scanned", report.FilesScanned,
+			"orphans", len(report.Orphans),
+			"bytes", report.OrphanBytes,
+			"removed", report.Removed,
+			"blob_rows", report.BlobRows,
+		)
+	}
+	return nil
+}
+
+// runReprocess reruns summarisation and tagging for one item, or for every
+// uncategorized (or, with --all, every) item of the target users.
+func runReprocess(pipeline *ingest.Pipeline, stores store.Stores, cmd config.ReprocessCommand) error {
+	ctx := context.Background()
+
+	if cmd.ItemID != "" {
+		if cmd.UserID == 0 {
+			return fmt.Errorf("--item requires --user")
+		}
+		item, err := pipeline.Reprocess(ctx, cmd.UserID, cmd.ItemID)
+		if err != nil {
+			return err
+		}
+		slog.Info("reprocessed item", "user_id", cmd.UserID, "id", item.ID, "title", item.Title)
+		return nil
+	}
+
+	scope := ingest.ReprocessUncategorized
+	if cmd.All {
+		scope = ingest.ReprocessAll
+	}
+
+	userIDs, err := targetUsers(stores, cmd.UserID)
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		vault, err := stores.GetVault(userID)
+		if err != nil {
+			return fmt.Errorf("open vault %d: %w", userID, err)
+		}
+		ids, err := ingest.ReprocessTargets(vault, scope)
+		if err != nil {
+			return fmt.Errorf("list items of %d: %w", userID, err)
+		}
+		var failed int
+		for _, id := range ids {
+			// Keep going: the rest of the vault may still succeed
+			if _, err := pipeline.Reprocess(ctx, userID, id); err != nil {
+				slog.Warn("failed to reprocess item", "user_id", userID, "id", id, "error", err)
+				failed++
+			}
+		}
+		slog.Info("reprocessed vault", "user_id", userID, "scope", scope, "items", len(ids), "failed", failed)
+	}
+	return nil
+}
+
+// targetUsers returns the single requested user, or every user with a vault.
+func targetUsers(stores store.Stores, userID int64) ([]int64, error) {
+	if userID != 0 {
+		return []int64{userID}, nil
+	}
+	ids, err := stores.UserIDs()
+	if err != nil {
+		return nil, fmt.Errorf("list users: %w", err)
+	}
+	return ids, nil
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 
 	"golang.org/x/sync/errgroup"
@@ -16,6 +17,7 @@ import (
 	"github.com/nerdneilsfield/dumper/internal/config"
 	"github.com/nerdneilsfield/dumper/internal/ingest"
 	"github.com/nerdneilsfield/dumper/internal/llm"
+	"github.com/nerdneilsfield/dumper/internal/queue"
 	"github.com/nerdneilsfield/dumper/internal/search"
 	"github.com/nerdneilsfield/dumper/internal/store"
 )
@@ -53,29 +55,88 @@ func run() error {
 	slog.SetDefault(logger)
 
 	// Initialize store manager
-	stores, err := store.NewManager(cfg.DataDir)
+	stores, err := openStores(cfg)
 	if err != nil {
 		return fmt.Errorf("create store manager: %w", err)
 	}
 	defer stores.Close()
 
+	if cfg.EncryptionKey != "" {
+		if err := stores.EnableEncryption(cfg.EncryptionKey, store.SearchIndexMode(cfg.EncryptedSearch)); err != nil {
+			return fmt.Errorf("enable encryption: %w", err)
+		}
+	}
+
 	// Initialize LLM client
-	llmClient := llm.NewClient(cfg.OpenRouterKey, cfg.OpenRouterModel)
+	visionModel := cfg.VisionModel
+	switch visionModel {
+	case "":
+		visionModel = cfg.OpenRouterModel
+	case "none":
+		visionModel = ""
+	}
+	llmClient := llm.NewClient(cfg.OpenRouterKey, cfg.OpenRouterModel, llm.Options{
+		MaxConcurrent: cfg.LLMConcurrency,
+		ChunkSize:     cfg.LLMChunkSize,
+		MaxChunks:     cfg.LLMMaxChunks,
+		VisionModel:   visionModel,
+	})
 
 	// Initialize search client
 	searchClient := search.NewClient()
 
+	// Initialize voice transcription (optional)
+	var transcriber *llm.Transcriber
+	if cfg.TranscribeURL != "" {
+		transcriber = llm.NewTranscriber(cfg.TranscribeURL, cfg.TranscribeKey, cfg.TranscribeModel)
+		transcriber.LimitWith(llmClient)
+	}
+
 	// Initialize processing pipeline
-	pipeline := ingest.NewPipeline(llmClient, searchClient, stores)
+	pipeline := ingest.NewPipeline(llmClient, searchClient, transcriber, stores)
+	if cfg.ArchivePages {
+		pipeline.EnableArchiving()
+	}
+
+	switch cfg.Command {
+	case "encrypt":
+		return runEncrypt(stores, cfg.Encrypt)
+	case "gc":
+		return runGC(stores, cfg.GC, cfg.GCGracePeriod)
+	case "reprocess":
+		if cfg.OpenRouterKey == "" {
+			return fmt.Errorf("OPENROUTER_API_KEY is required to reprocess")
+		}
+		return runReprocess(pipeline, stores, cfg.Reprocess)
+	}
+
+	// Only serving needs the bot and the LLM
+	if cfg.TelegramToken == "" {
+		return fmt.Errorf("TELEGRAM_BOT_TOKEN is required")
+	}
+	if cfg.OpenRouterKey == "" {
+		return fmt.Errorf("OPENROUTER_API_KEY is required")
+	}
+
+	// Initialize durable ingestion queue
+	jobs, err := queue.Open(filepath.Join(cfg.DataDir, "queue.db"), queue.Options{
+		Workers:     cfg.QueueWorkers,
+		MaxAttempts: cfg.QueueMaxAttempts,
+		Cipher:      stores,
+	})
+	if err != nil {
+		return fmt.Errorf("open job queue: %w", err)
+	}
+	defer jobs.Close()
 
 	// Initialize bot
-	tgBot, err := bot.New(cfg.TelegramToken, pipeline, stores, cfg.WebAppURL)
+	tgBot, err := bot.New(cfg.TelegramToken, jobs, stores, cfg.WebAppURL, transcriber != nil)
 	if err != nil {
 		return fmt.Errorf("create bot: %w", err)
 	}
 
 	// Initialize API server
-	apiServer := api.NewServer(stores, cfg.TelegramToken, llmClient)
+	apiServer := api.NewServer(stores, cfg.TelegramToken, llmClient, jobs)
 
 	// Setup graceful shutdown
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
@@ -83,12 +144,55 @@ func run() error {
 
 	g, ctx := errgroup.WithContext(ctx)
 
-	// Run bot
+	// Collect input sources: the bot, the API, feed subscriptions, the inbox
+	// and email
+	inputs := []ingest.InputSource{tgBot, apiServer}
+	if cfg.FeedInterval > 0 {
+		inputs = append(inputs, ingest.NewFeedSource(stores, cfg.FeedInterval))
+	}
+	if cfg.InboxInterval > 0 {
+		inputs = append(inputs, ingest.NewInboxSource(filepath.Join(cfg.DataDir, "inbox"), stores, jobs, cfg.InboxInterval))
+	}
+	if cfg.SMTPAddr != "" {
+		if cfg.EmailDomain == "" {
+			return fmt.Errorf("EMAIL_DOMAIN is required to receive email")
+		}
+		emails := ingest.NewEmailAddresses(stores)
+		inputs = append(inputs, ingest.NewSMTPSource(cfg.SMTPAddr, cfg.EmailDomain, stores, emails))
+		tgBot.EnableEmail(cfg.EmailDomain, emails)
+	}
+	sources := ingest.NewSources(jobs, inputs...)
+
+	// Run input sources
+	g.Go(func() error {
+		return sources.Run(ctx)
+	})
+
+	// Run ingestion workers
 	g.Go(func() error {
-		slog.Info("starting telegram bot")
-		return tgBot.Run(ctx)
+		slog.Info("starting job queue", "workers", cfg.QueueWorkers)
+		return jobs.Run(ctx, pipeline.HandleJob, sources.Ack)
 	})
 
+	// Run scheduled garbage collection
+	if cfg.GCInterval > 0 {
+		g.Go(func() error {
+			slog.Info("starting garbage collector", "interval", cfg.GCInterval)
+			return store.RunGarbageCollector(ctx, stores, cfg.GCInterval, store.GCOptions{GracePeriod: cfg.GCGracePeriod})
+		})
+	}
+
+	// Run scheduled link checks
+	if cfg.LinkCheckInterval > 0 {
+		g.Go(func() error {
+			slog.Info("starting link checker", "interval", cfg.LinkCheckInterval)
+			return ingest.RunLinkChecker(ctx, stores, cfg.LinkCheckInterval, ingest.LinkCheckOptions{
+				MaxAge:    cfg.LinkCheckAge,
+				BatchSize: cfg.LinkCheckBatch,
+			}, tgBot.NotifyDeadLink)
+		})
+	}
+
 	// Run HTTP server
 	g.Go(func() error {
 		addr := fmt.Sprintf(":%d", cfg.HTTPPort)
@@ -109,3 +213,14 @@ func run() error {
 
 	return g.Wait()
 }
+
+// openStores connects the configured storage backend.
+func openStores(cfg *config.Config) (store.Stores, error) {
+	if cfg.StorageBackend == "postgres" {
+		if cfg.PostgresDSN == "" {
+			return nil, fmt.Errorf("POSTGRES_DSN is required for the postgres backend")
+		}
+		return store.NewPGManager(cfg.PostgresDSN, cfg.DataDir)
+	}
+	return store.NewManager(cfg.DataDir)
+}
@@ -5,10 +5,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
+	"mime"
 	"net/http"
+	"net/url"
+	"path"
 	"strconv"
+	"strings"
 
 	"github.com/nerdneilsfield/dumper/internal/export"
+	"github.com/nerdneilsfield/dumper/internal/ingest"
+	"github.com/nerdneilsfield/dumper/internal/store"
 )
 
 func (s *Server) handleListItems(w http.ResponseWriter, r *http.Request) {
@@ -38,6 +45,17 @@ func (s *Server) handleListItems(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Check for link health filter, e.g. link_status=dead
+	if status := r.URL.Query().Get("link_status"); status != "" {
+		items, err := vault.ListItemsByLinkStatus(status, limit, offset)
+		if err != nil {
+			jsonError(w, "failed to list items", http.StatusInternalServerError)
+			return
+		}
+		jsonResponse(w, items)
+		return
+	}
+
 	items, err := vault.ListItems(limit, offset)
 	if err != nil {
 		jsonError(w, "failed to list items", http.StatusInternalServerError)
@@ -70,6 +88,144 @@ func (s *Server) handleGetItem(w http.ResponseWriter, r *http.Request) {
 	jsonResponse(w, item)
 }
 
+// handleGetItemFile serves an item's image or its thumbnail (?size=thumb), or
+// an attached document. Blob-backed files are content-addressed and cached as
+// immutable.
+func (s *Server) handleGetItemFile(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r.Context())
+	itemID := r.PathValue("id")
+
+	size := r.URL.Query().Get("size")
+	if size == "" {
+		size = "full"
+	}
+	if size != "full" && size != "thumb" {
+		jsonError(w, "size must be thumb or full", http.StatusBadRequest)
+		return
+	}
+
+	vault, err := s.stores.GetVault(userID)
+	if err != nil {
+		jsonError(w, "failed to access vault", http.StatusInternalServerError)
+		return
+	}
+
+	item, err := vault.GetItem(itemID)
+	if err != nil {
+		jsonError(w, "failed to get item", http.StatusInternalServerError)
+		return
+	}
+	if item == nil || item.ImagePath == "" {
+		jsonError(w, "file not found", http.StatusNotFound)
+		return
+	}
+
+	imagePath := item.ImagePath
+	contentType := mime.TypeByExtension(path.Ext(imagePath))
+	cacheControl := "private, max-age=86400"
+	var etag string
+
+	if item.ImageHash != "" {
+		blob, err := vault.GetBlob(item.ImageHash)
+		if err != nil {
+			jsonError(w, "failed to get file", http.StatusInternalServerError)
+			return
+		}
+		if blob != nil {
+			imagePath = blob.Path()
+			contentType = blob.MIME
+			etag = `"` + blob.Hash + `"`
+			if size == "thumb" && blob.HasThumb {
+				imagePath = blob.ThumbPath()
+				contentType = "image/jpeg"
+				etag = `"` + blob.Hash + `-thumb"`
+			}
+			cacheControl = "private, max-age=31536000, immutable"
+		}
+	}
+
+	if etag != "" && r.Header.Get("If-None-Match") == etag {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", cacheControl)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	data, err := vault.ReadFile(imagePath)
+	if err != nil {
+		jsonError(w, "file not found", http.StatusNotFound)
+		return
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	w.Write(data)
+}
+
+// archiveCSP lets a page snapshot render its inlined styles and images while
+// keeping it from running scripts or reaching the API on our origin.
+const archiveCSP = "sandbox; default-src 'none'; img-src data: http: https:; style-src 'unsafe-inline' http: https:; " +
+	"font-src data: http: https:; media-src http: https:"
+
+// handleGetItemArchive serves the HTML snapshot saved with a link.
+func (s *Server) handleGetItemArchive(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r.Context())
+	itemID := r.PathValue("id")
+
+	vault, err := s.stores.GetVault(userID)
+	if err != nil {
+		jsonError(w, "failed to access vault", http.StatusInternalServerError)
+		return
+	}
+
+	item, err := vault.GetItem(itemID)
+	if err != nil {
+		jsonError(w, "failed to get item", http.StatusInternalServerError)
+		return
+	}
+	if item == nil || item.ArchiveHash == "" {
+		jsonError(w, "archive not found", http.StatusNotFound)
+		return
+	}
+	blob, err := vault.GetBlob(item.ArchiveHash)
+	if err != nil {
+		jsonError(w, "failed to get archive", http.StatusInternalServerError)
+		return
+	}
+	if blob == nil {
+		jsonError(w, "archive not found", http.StatusNotFound)
+		return
+	}
+
+	etag := `"` + blob.Hash + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	data, err := vault.ReadFile(blob.Path())
+	if err != nil {
+		jsonError(w, "archive not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Content-Security-Policy", archiveCSP)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write(data)
+}
+
 func (s *Server) handleDeleteItem(w http.ResponseWriter, r *http.Request) {
 	userID := getUserID(r.Context())
 	itemID := r.PathValue("id")
@@ -88,6 +244,58 @@ func (s *Server) handleDeleteItem(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// handleReprocessItem queues summarisation and tagging for one item again
+// and answers 202 with the job.
+func (s *Server) handleReprocessItem(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r.Context())
+	itemID := r.PathValue("id")
+
+	vault, err := s.stores.GetVault(userID)
+	if err != nil {
+		jsonError(w, "failed to access vault", http.StatusInternalServerError)
+		return
+	}
+	item, err := vault.GetItem(itemID)
+	if err != nil {
+		jsonError(w, "failed to get item", http.StatusInternalServerError)
+		return
+	}
+	if item == nil {
+		jsonError(w, "item not found", http.StatusNotFound)
+		return
+	}
+
+	s.enqueue(w, ingest.RawContent{Type: ingest.ContentTypeReprocess, UserID: userID, ItemID: item.ID})
+}
+
+// handleArchiveItem queues a snapshot of a link item's page, taken from the
+// Internet Archive when the link is dead, and answers 202 with the job.
+func (s *Server) handleArchiveItem(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r.Context())
+	itemID := r.PathValue("id")
+
+	vault, err := s.stores.GetVault(userID)
+	if err != nil {
+		jsonError(w, "failed to access vault", http.StatusInternalServerError)
+		return
+	}
+	item, err := vault.GetItem(itemID)
+	if err != nil {
+		jsonError(w, "failed to get item", http.StatusInternalServerError)
+		return
+	}
+	if item == nil {
+		jsonError(w, "item not found", http.StatusNotFound)
+		return
+	}
+	if item.URL == "" || (item.Link != nil && item.Link.Status == store.LinkDead && item.Link.WaybackURL == "") {
+		jsonError(w, "no page to archive", http.StatusUnprocessableEntity)
+		return
+	}
+
+	s.enqueue(w, ingest.RawContent{Type: ingest.ContentTypeArchive, UserID: userID, ItemID: item.ID})
+}
+
 func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
 	userID := getUserID(r.Context())
 	query := r.URL.Query().Get("q")
@@ -205,6 +413,9 @@ func (s *Server) handleAsk(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// handleExport exports the vault as an Obsidian zip, or with
+// ?format=bibtex or ?format=csl-json as a bibliography. Bibliographies cover
+// every item, the items tagged ?tag=, or the items listed in ?ids=a,b.
 func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
 	userID := getUserID(r.Context())
 
@@ -214,6 +425,29 @@ func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "obsidian":
+	case export.FormatBibTeX, export.FormatCSLJSON:
+		items, err := exportItems(vault, r.URL.Query())
+		if err != nil {
+			jsonError(w, "failed to list items", http.StatusInternalServerError)
+			return
+		}
+		ext := ".bib"
+		if format == export.FormatCSLJSON {
+			ext = ".json"
+		}
+		w.Header().Set("Content-Type", export.CitationMIME(format))
+		w.Header().Set("Content-Disposition", "attachment; filename=dumper-export"+ext)
+		if err := export.ExportCitations(w, items, format); err != nil {
+			slog.Warn("citation export failed", "user_id", userID, "error", err)
+		}
+		return
+	default:
+		jsonError(w, "unknown format", http.StatusBadRequest)
+		return
+	}
+
 	exporter := export.NewObsidianExporter()
 	reader, err := exporter.Export(vault)
 	if err != nil {
@@ -226,6 +460,84 @@ func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
 	io.Copy(w, reader)
 }
 
+// exportItems returns the items an export query selects.
+func exportItems(vault store.Vault, query url.Values) ([]store.Item, error) {
+	if ids := query.Get("ids"); ids != "" {
+		var items []store.Item
+		for _, id := range strings.Split(ids, ",") {
+			item, err := vault.GetItem(strings.TrimSpace(id))
+			if err != nil {
+				return nil, err
+			}
+			if item != nil {
+				items = append(items, *item)
+			}
+		}
+		return items, nil
+	}
+
+	const page = 500
+	var items []store.Item
+	for offset := 0; ; offset += page {
+		var batch []store.Item
+		var err error
+		if tag := query.Get("tag"); tag != "" {
+			batch, err = vault.ListItemsByTag(tag, page, offset)
+		} else {
+			batch, err = vault.ListItems(page, offset)
+		}
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, batch...)
+		if len(batch) < page {
+			return items, nil
+		}
+	}
+}
+
+// handleGetChanges returns changes after ?since=<seq> for incremental sync.
+// Clients pass the returned next value as since until has_more is false.
+func (s *Server) handleGetChanges(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r.Context())
+
+	var since int64
+	if v := r.URL.Query().Get("since"); v != "" {
+		var err error
+		since, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || since < 0 {
+			jsonError(w, "invalid since", http.StatusBadRequest)
+			return
+		}
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 1000 {
+		limit = 500
+	}
+
+	vault, err := s.stores.GetVault(userID)
+	if err != nil {
+		jsonError(w, "failed to access vault", http.StatusInternalServerError)
+		return
+	}
+
+	changes, err := vault.Changes(since, limit)
+	if err != nil {
+		jsonError(w, "failed to get changes", http.StatusInternalServerError)
+		return
+	}
+
+	next := since
+	if len(changes) > 0 {
+		next = changes[len(changes)-1].Seq
+	}
+	jsonResponse(w, map[string]interface{}{
+		"changes":  changes,
+		"next":     next,
+		"has_more": len(changes) == limit,
+	})
+}
+
 func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 	userID := getUserID(r.Context())
 
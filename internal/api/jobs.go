@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nerdneilsfield/dumper/internal/ingest"
+	"github.com/nerdneilsfield/dumper/internal/queue"
+)
+
+// The API is an input source so jobs it queues are routed back to it. Clients
+// follow them with GET /jobs/{id} rather than being notified.
+var _ ingest.InputSource = (*Server)(nil)
+
+func (s *Server) Name() string {
+	return ingest.APISource
+}
+
+// Run does nothing until ctx is done: handlers queue jobs themselves so they
+// can return their IDs.
+func (s *Server) Run(ctx context.Context, emit ingest.EmitFunc) error {
+	<-ctx.Done()
+	return nil
+}
+
+// Ack does nothing; see handleGetJob.
+func (s *Server) Ack(job *queue.Job) {}
+
+// jobResponse is the state of a queued job. Result is the ID of the item the
+// job saved.
+type jobResponse struct {
+	ID        int64     `json:"id"`
+	Status    string    `json:"status"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error,omitempty"`
+	Result    string    `json:"result,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// enqueue queues content for the pipeline and answers 202 with the job.
+func (s *Server) enqueue(w http.ResponseWriter, raw ingest.RawContent) {
+	job, err := s.jobs.EnqueueFrom(ingest.APISource, raw.UserID, raw, nil)
+	if err != nil {
+		jsonError(w, "failed to queue job", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", "/api/jobs/"+strconv.FormatInt(job.ID, 10))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	jsonResponse(w, jobResponse{ID: job.ID, Status: string(job.Status), CreatedAt: job.CreatedAt})
+}
+
+// handleGetJob reports the progress of a job queued through the API.
+func (s *Server) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r.Context())
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, "job not found", http.StatusNotFound)
+		return
+	}
+
+	job, err := s.jobs.Get(id)
+	if err != nil {
+		jsonError(w, "failed to get job", http.StatusInternalServerError)
+		return
+	}
+	if job == nil || job.UserID != userID {
+		jsonError(w, "job not found", http.StatusNotFound)
+		return
+	}
+
+	jsonResponse(w, jobResponse{
+		ID:        job.ID,
+		Status:    string(job.Status),
+		Attempts:  job.Attempts,
+		LastError: job.LastError,
+		Result:    job.Result,
+		CreatedAt: job.CreatedAt,
+	})
+}
@@ -5,21 +5,24 @@ import (
 	"net/http"
 
 	"github.com/nerdneilsfield/dumper/internal/llm"
+	"github.com/nerdneilsfield/dumper/internal/queue"
 	"github.com/nerdneilsfield/dumper/internal/store"
 )
 
 type Server struct {
-	stores    *store.Manager
+	stores    store.Stores
 	botToken  string
 	llmClient *llm.Client
+	jobs      *queue.Queue
 	mux       *http.ServeMux
 }
 
-func NewServer(stores *store.Manager, botToken string, llmClient *llm.Client) *Server {
+func NewServer(stores store.Stores, botToken string, llmClient *llm.Client, jobs *queue.Queue) *Server {
 	s := &Server{
 		stores:    stores,
 		botToken:  botToken,
 		llmClient: llmClient,
+		jobs:      jobs,
 		mux:       http.NewServeMux(),
 	}
 	s.routes()
@@ -31,12 +34,19 @@ func (s *Server) routes() {
 	api := http.NewServeMux()
 	api.HandleFunc("GET /items", s.handleListItems)
 	api.HandleFunc("GET /items/{id}", s.handleGetItem)
+	api.HandleFunc("GET /items/{id}/image", s.handleGetItemFile)
+	api.HandleFunc("GET /items/{id}/file", s.handleGetItemFile)
+	api.HandleFunc("GET /items/{id}/archive", s.handleGetItemArchive)
+	api.HandleFunc("POST /items/{id}/archive", s.handleArchiveItem)
 	api.HandleFunc("DELETE /items/{id}", s.handleDeleteItem)
+	api.HandleFunc("POST /items/{id}/reprocess", s.handleReprocessItem)
+	api.HandleFunc("GET /jobs/{id}", s.handleGetJob)
 	api.HandleFunc("GET /search", s.handleSearch)
 	api.HandleFunc("GET /tags", s.handleGetTags)
 	api.HandleFunc("GET /graph", s.handleGetGraph)
 	api.HandleFunc("POST /ask", s.handleAsk)
 	api.HandleFunc("GET /export", s.handleExport)
+	api.HandleFunc("GET /changes", s.handleGetChanges)
 	api.HandleFunc("GET /stats", s.handleStats)
 
 	s.mux.Handle("/api/", http.StripPrefix("/api", s.authMiddleware(api)))
@@ -55,7 +65,7 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// CORS headers for Mini App
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-Telegram-Init-Data")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-Telegram-Init-Data, If-None-Match")
 
 	if r.Method == "OPTIONS" {
 		w.WriteHeader(http.StatusOK)
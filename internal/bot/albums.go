@@ -0,0 +1,84 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nerdneilsfield/dumper/internal/i18n"
+	"github.com/nerdneilsfield/dumper/internal/ingest"
+)
+
+// albumWait is how long an album stays open after its latest photo. Telegram
+// delivers an album's photos as separate messages within about a second.
+const albumWait = 2 * time.Second
+
+// album collects the messages of a media group until no more arrive.
+type album struct {
+	msgs  []*tgbotapi.Message
+	timer *time.Timer
+}
+
+// bufferAlbumPhoto adds a photo to its album and restarts the timer that
+// saves the album once it is complete.
+func (b *Bot) bufferAlbumPhoto(ctx context.Context, msg *tgbotapi.Message) {
+	key := fmt.Sprintf("%d:%s", msg.Chat.ID, msg.MediaGroupID)
+
+	b.albumsMu.Lock()
+	defer b.albumsMu.Unlock()
+	a, ok := b.albums[key]
+	if !ok {
+		a = &album{}
+		a.timer = time.AfterFunc(albumWait, func() { b.saveAlbum(ctx, key) })
+		b.albums[key] = a
+	} else {
+		a.timer.Reset(albumWait)
+	}
+	a.msgs = append(a.msgs, msg)
+}
+
+// saveAlbum downloads a complete album and queues it as one capture.
+func (b *Bot) saveAlbum(ctx context.Context, key string) {
+	b.albumsMu.Lock()
+	a := b.albums[key]
+	delete(b.albums, key)
+	b.albumsMu.Unlock()
+	if a == nil || ctx.Err() != nil {
+		return
+	}
+
+	// Updates are handled concurrently, so restore the order they were sent in
+	slices.SortFunc(a.msgs, func(x, y *tgbotapi.Message) int { return x.MessageID - y.MessageID })
+	first := a.msgs[0]
+	if len(a.msgs) == 1 {
+		b.handlePhoto(ctx, first)
+		return
+	}
+
+	l := b.getUserLang(first.From.ID, first.From.LanguageCode)
+	sentMsg, _ := b.sendAndReturn(first.Chat.ID,
+		b.queueStatus(first.From.ID, l, l.Getf(i18n.MsgSavingAlbum, len(a.msgs))))
+
+	raw := ingest.RawContent{
+		Type:     ingest.ContentTypeAlbum,
+		UserID:   first.From.ID,
+		Language: l.Code(),
+		Source:   messageSource(first),
+	}
+	for _, msg := range a.msgs {
+		// Only one photo of an album carries the caption
+		if raw.Caption == "" {
+			raw.Caption = messageMarkdown(msg.Caption, msg.CaptionEntities)
+		}
+		photo := msg.Photo[len(msg.Photo)-1]
+		file, data, ok := b.download(first.Chat.ID, sentMsg.MessageID, photo.FileID, l)
+		if !ok {
+			return
+		}
+		raw.Album = append(raw.Album, ingest.AlbumPhoto{Data: data, Ext: photoExt(file)})
+	}
+
+	b.enqueue(first.Chat.ID, sentMsg.MessageID, raw, l)
+}
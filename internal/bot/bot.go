@@ -5,21 +5,42 @@ import (
 	"database/sql"
 	"fmt"
 	"log/slog"
+	"sync"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/nerdneilsfield/dumper/internal/i18n"
 	"github.com/nerdneilsfield/dumper/internal/ingest"
+	"github.com/nerdneilsfield/dumper/internal/queue"
 	"github.com/nerdneilsfield/dumper/internal/store"
 )
 
+// maxConcurrentUpdates bounds in-flight update handlers. Heavy work is queued,
+// so handlers are short; when all slots are busy polling pauses instead of
+// spawning goroutines without limit.
+const maxConcurrentUpdates = 16
+
+// Bot is the Telegram input source. Captures are emitted to the pipeline;
+// the queue is only consulted for positions and batch progress.
 type Bot struct {
 	api       *tgbotapi.BotAPI
-	pipeline  *ingest.Pipeline
-	stores    *store.Manager
+	jobs      *queue.Queue
+	emit      ingest.EmitFunc // set by Run
+	stores    store.Stores
 	webAppURL string
+	voice     bool // voice transcription is configured
+	feeds     *ingest.FeedReader
+	// emailDomain is the domain of capture addresses; emails is nil when
+	// email capture is off
+	emailDomain string
+	emails      *ingest.EmailAddresses
+
+	albumsMu sync.Mutex
+	albums   map[string]*album // photos of media groups still arriving
+
+	batchMu sync.Mutex // serialises showBatch
 }
 
-func New(token string, pipeline *ingest.Pipeline, stores *store.Manager, webAppURL string) (*Bot, error) {
+func New(token string, jobs *queue.Queue, stores store.Stores, webAppURL string, voice bool) (*Bot, error) {
 	api, err := tgbotapi.NewBotAPI(token)
 	if err != nil {
 		return nil, fmt.Errorf("create bot api: %w", err)
@@ -29,24 +50,51 @@ func New(token string, pipeline *ingest.Pipeline, stores *store.Manager, webAppU
 
 	return &Bot{
 		api:       api,
-		pipeline:  pipeline,
+		jobs:      jobs,
 		stores:    stores,
 		webAppURL: webAppURL,
+		voice:     voice,
+		feeds:     ingest.NewFeedReader(),
+		albums:    make(map[string]*album),
 	}, nil
 }
 
-func (b *Bot) Run(ctx context.Context) error {
+// EnableEmail lets users look up their capture address at domain with
+// /email.
+func (b *Bot) EnableEmail(domain string, emails *ingest.EmailAddresses) {
+	b.emailDomain = domain
+	b.emails = emails
+}
+
+var _ ingest.InputSource = (*Bot)(nil)
+
+func (b *Bot) Name() string {
+	return ingest.TelegramSource
+}
+
+// Run polls Telegram for updates until ctx is done, emitting captures.
+func (b *Bot) Run(ctx context.Context, emit ingest.EmitFunc) error {
+	b.emit = emit
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
 
 	updates := b.api.GetUpdatesChan(u)
+	slots := make(chan struct{}, maxConcurrentUpdates)
 
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		case update := <-updates:
-			go b.handleUpdate(ctx, update)
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			go func() {
+				defer func() { <-slots }()
+				b.handleUpdate(ctx, update)
+			}()
 		}
 	}
 }
@@ -0,0 +1,120 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log/slog"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nerdneilsfield/dumper/internal/i18n"
+	"github.com/nerdneilsfield/dumper/internal/ingest"
+	"github.com/nerdneilsfield/dumper/internal/store"
+)
+
+// maxDeadLinks caps the links listed by /dead.
+const maxDeadLinks = 10
+
+// NotifyDeadLink tells a user that one of their saved links has died and
+// offers to keep its archived copy.
+func (b *Bot) NotifyDeadLink(userID int64, item *store.Item) {
+	l := b.getUserLang(userID, "")
+	text := l.Getf(i18n.MsgDeadLink, html.EscapeString(item.Title), html.EscapeString(item.URL))
+	if note := deadLinkNote(l, item); note != "" {
+		text += "\n\n" + note
+	}
+	b.send(userID, text)
+}
+
+// deadLinkNote says how a dead link's content can still be reached.
+func deadLinkNote(l *i18n.Localizer, item *store.Item) string {
+	switch {
+	case item.Archive != nil:
+		return l.Get(i18n.MsgDeadLinkArchived)
+	case item.Link != nil && item.Link.WaybackURL != "":
+		return l.Getf(i18n.MsgDeadLinkSnapshot, item.ID)
+	default:
+		return ""
+	}
+}
+
+// linkMark flags items whose link was found dead in listings.
+func linkMark(item *store.Item) string {
+	if item.Link != nil && item.Link.Status == store.LinkDead {
+		return "⚠️ "
+	}
+	return ""
+}
+
+// handleDead lists the user's dead links.
+func (b *Bot) handleDead(ctx context.Context, msg *tgbotapi.Message) {
+	l := b.getUserLang(msg.From.ID, msg.From.LanguageCode)
+
+	vault, err := b.stores.GetVault(msg.From.ID)
+	if err != nil {
+		b.send(msg.Chat.ID, l.Get(i18n.MsgFailedVault))
+		return
+	}
+
+	items, err := vault.ListItemsByLinkStatus(store.LinkDead, maxDeadLinks, 0)
+	if err != nil {
+		b.send(msg.Chat.ID, l.Getf(i18n.MsgFailedListItems, err))
+		return
+	}
+	if len(items) == 0 {
+		b.send(msg.Chat.ID, l.Get(i18n.MsgNoDeadLinks))
+		return
+	}
+
+	var text strings.Builder
+	text.WriteString(l.Get(i18n.MsgDeadLinks))
+	for i, item := range items {
+		text.WriteString(fmt.Sprintf("%d. <b>%s</b>\n   %s\n", i+1, html.EscapeString(item.Title), html.EscapeString(item.URL)))
+		if note := deadLinkNote(l, &item); note != "" {
+			text.WriteString(fmt.Sprintf("   %s\n", note))
+		}
+		text.WriteString("\n")
+	}
+
+	b.send(msg.Chat.ID, text.String())
+}
+
+// handleArchive queues a snapshot of a link item's page, taken from the
+// Internet Archive when the link is dead.
+func (b *Bot) handleArchive(ctx context.Context, msg *tgbotapi.Message) {
+	l := b.getUserLang(msg.From.ID, msg.From.LanguageCode)
+	arg := strings.TrimSpace(msg.CommandArguments())
+	if arg == "" {
+		b.send(msg.Chat.ID, l.Get(i18n.MsgArchiveUsage))
+		return
+	}
+
+	vault, err := b.stores.GetVault(msg.From.ID)
+	if err != nil {
+		b.send(msg.Chat.ID, l.Get(i18n.MsgFailedVault))
+		return
+	}
+	item, err := vault.GetItem(arg)
+	if err != nil || item == nil || item.URL == "" {
+		b.send(msg.Chat.ID, l.Get(i18n.MsgItemNotFound))
+		return
+	}
+	if item.Link != nil && item.Link.Status == store.LinkDead && item.Link.WaybackURL == "" {
+		b.send(msg.Chat.ID, l.Get(i18n.MsgNoSnapshot))
+		return
+	}
+
+	sentMsg, _ := b.sendAndReturn(msg.Chat.ID, b.queueStatus(msg.From.ID, l, l.Get(i18n.MsgArchiving)))
+	raw := ingest.RawContent{
+		Type:     ingest.ContentTypeArchive,
+		UserID:   msg.From.ID,
+		Language: l.Code(),
+		ItemID:   item.ID,
+	}
+	reply := jobReply{ChatID: msg.Chat.ID, MessageID: sentMsg.MessageID, Lang: l.Code(), Archive: true}
+	if err := b.emit(raw, reply); err != nil {
+		slog.Error("failed to enqueue archive", "user_id", raw.UserID, "error", err)
+		b.edit(msg.Chat.ID, sentMsg.MessageID, l.Getf(i18n.MsgFailedArchive, err))
+	}
+}
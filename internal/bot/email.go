@@ -0,0 +1,33 @@
+package bot
+
+import (
+	"context"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nerdneilsfield/dumper/internal/i18n"
+)
+
+// handleEmail shows the user's secret capture address. "/email reset"
+// replaces it.
+func (b *Bot) handleEmail(ctx context.Context, msg *tgbotapi.Message) {
+	l := b.getUserLang(msg.From.ID, msg.From.LanguageCode)
+	if b.emails == nil {
+		b.send(msg.Chat.ID, l.Get(i18n.MsgEmailDisabled))
+		return
+	}
+
+	reset := strings.EqualFold(strings.TrimSpace(msg.CommandArguments()), "reset")
+	token, err := b.emails.Token(msg.From.ID, reset)
+	if err != nil {
+		b.send(msg.Chat.ID, l.Getf(i18n.MsgFailedEmail, err))
+		return
+	}
+
+	address := token + "@" + b.emailDomain
+	if reset {
+		b.send(msg.Chat.ID, l.Getf(i18n.MsgEmailReset, address))
+		return
+	}
+	b.send(msg.Chat.ID, l.Getf(i18n.MsgEmailAddress, address))
+}
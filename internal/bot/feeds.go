@@ -0,0 +1,144 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nerdneilsfield/dumper/internal/i18n"
+	"github.com/nerdneilsfield/dumper/internal/ingest"
+	"github.com/nerdneilsfield/dumper/internal/store"
+)
+
+// parseSubscription reads the arguments of /subscribe:
+// <url> [#tag ...] [keyword ...] [-keyword ...] [max=N].
+func parseSubscription(args string) (*store.Feed, bool) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 || !ingest.IsURL(fields[0]) {
+		return nil, false
+	}
+	feed := &store.Feed{URL: fields[0]}
+	for _, f := range fields[1:] {
+		lower := strings.ToLower(f)
+		switch {
+		case strings.HasPrefix(f, "#") && len(f) > 1:
+			feed.Tags = append(feed.Tags, strings.ToLower(f[1:]))
+		case strings.HasPrefix(lower, "max="):
+			n, err := strconv.Atoi(lower[len("max="):])
+			if err != nil || n < 0 {
+				return nil, false
+			}
+			feed.MaxPerDay = n
+		default:
+			feed.Keywords = append(feed.Keywords, lower)
+		}
+	}
+	return feed, true
+}
+
+// handleSubscribe subscribes the user to an RSS or Atom feed.
+func (b *Bot) handleSubscribe(ctx context.Context, msg *tgbotapi.Message) {
+	l := b.getUserLang(msg.From.ID, msg.From.LanguageCode)
+	feed, ok := parseSubscription(msg.CommandArguments())
+	if !ok {
+		b.send(msg.Chat.ID, l.Get(i18n.MsgSubscribeUsage))
+		return
+	}
+
+	vault, err := b.stores.GetVault(msg.From.ID)
+	if err != nil {
+		b.send(msg.Chat.ID, l.Get(i18n.MsgFailedVault))
+		return
+	}
+
+	sentMsg, _ := b.sendAndReturn(msg.Chat.ID, l.Get(i18n.MsgSubscribing))
+	_, err = b.feeds.Subscribe(ctx, vault, feed)
+	switch {
+	case errors.Is(err, ingest.ErrAlreadySubscribed):
+		b.edit(msg.Chat.ID, sentMsg.MessageID, l.Get(i18n.MsgAlreadySubscribed))
+	case errors.Is(err, ingest.ErrNotAFeed):
+		b.edit(msg.Chat.ID, sentMsg.MessageID, l.Get(i18n.MsgNotAFeed))
+	case err != nil:
+		b.edit(msg.Chat.ID, sentMsg.MessageID, l.Getf(i18n.MsgFailedSubscribe, html.EscapeString(err.Error())))
+	default:
+		b.edit(msg.Chat.ID, sentMsg.MessageID, l.Getf(i18n.MsgSubscribed, html.EscapeString(feed.Title)))
+	}
+}
+
+// handleUnsubscribe removes a subscription by ID or feed URL.
+func (b *Bot) handleUnsubscribe(ctx context.Context, msg *tgbotapi.Message) {
+	l := b.getUserLang(msg.From.ID, msg.From.LanguageCode)
+	arg := strings.TrimSpace(msg.CommandArguments())
+	if arg == "" {
+		b.send(msg.Chat.ID, l.Get(i18n.MsgUnsubscribeUsage))
+		return
+	}
+
+	vault, err := b.stores.GetVault(msg.From.ID)
+	if err != nil {
+		b.send(msg.Chat.ID, l.Get(i18n.MsgFailedVault))
+		return
+	}
+	feeds, err := vault.ListFeeds()
+	if err != nil {
+		b.send(msg.Chat.ID, l.Getf(i18n.MsgFailedListItems, err))
+		return
+	}
+	for _, feed := range feeds {
+		if feed.ID != arg && feed.URL != arg {
+			continue
+		}
+		if err := vault.DeleteFeed(feed.ID); err != nil {
+			b.send(msg.Chat.ID, l.Get(i18n.MsgFailedVault))
+			return
+		}
+		b.send(msg.Chat.ID, l.Getf(i18n.MsgUnsubscribed, html.EscapeString(feed.Title)))
+		return
+	}
+	b.send(msg.Chat.ID, l.Get(i18n.MsgFeedNotFound))
+}
+
+// handleFeeds lists the user's subscriptions with their filters.
+func (b *Bot) handleFeeds(ctx context.Context, msg *tgbotapi.Message) {
+	l := b.getUserLang(msg.From.ID, msg.From.LanguageCode)
+
+	vault, err := b.stores.GetVault(msg.From.ID)
+	if err != nil {
+		b.send(msg.Chat.ID, l.Get(i18n.MsgFailedVault))
+		return
+	}
+	feeds, err := vault.ListFeeds()
+	if err != nil {
+		b.send(msg.Chat.ID, l.Getf(i18n.MsgFailedListItems, err))
+		return
+	}
+	if len(feeds) == 0 {
+		b.send(msg.Chat.ID, l.Get(i18n.MsgNoFeeds))
+		return
+	}
+
+	var text strings.Builder
+	text.WriteString(l.Get(i18n.MsgYourFeeds))
+	for i, feed := range feeds {
+		text.WriteString(fmt.Sprintf("%d. <b>%s</b>\n   %s\n   <code>%s</code>\n", i+1, html.EscapeString(feed.Title), html.EscapeString(feed.URL), feed.ID))
+		if len(feed.Tags) > 0 {
+			text.WriteString(fmt.Sprintf("   #%s\n", html.EscapeString(strings.Join(feed.Tags, " #"))))
+		}
+		if len(feed.Keywords) > 0 {
+			text.WriteString("   " + l.Getf(i18n.MsgFeedKeywords, html.EscapeString(strings.Join(feed.Keywords, ", "))) + "\n")
+		}
+		if feed.MaxPerDay > 0 {
+			text.WriteString("   " + l.Getf(i18n.MsgFeedLimit, feed.MaxPerDay) + "\n")
+		}
+		if feed.LastError != "" {
+			text.WriteString("   " + l.Getf(i18n.MsgFeedError, html.EscapeString(feed.LastError)) + "\n")
+		}
+		text.WriteString("\n")
+	}
+
+	b.send(msg.Chat.ID, text.String())
+}
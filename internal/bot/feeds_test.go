@@ -0,0 +1,28 @@
+package bot
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseSubscription(t *testing.T) {
+	feed, ok := parseSubscription("https://example.com/feed.xml #News #go Kubernetes -sponsored max=3")
+	if !ok {
+		t.Fatal("expected arguments to parse")
+	}
+	if feed.URL != "https://example.com/feed.xml" || feed.MaxPerDay != 3 {
+		t.Errorf("unexpected feed: %+v", feed)
+	}
+	if !slices.Equal(feed.Tags, []string{"news", "go"}) {
+		t.Errorf("unexpected tags: %v", feed.Tags)
+	}
+	if !slices.Equal(feed.Keywords, []string{"kubernetes", "-sponsored"}) {
+		t.Errorf("unexpected keywords: %v", feed.Keywords)
+	}
+
+	for _, args := range []string{"", "not-a-url", "https://example.com/feed.xml max=lots"} {
+		if _, ok := parseSubscription(args); ok {
+			t.Errorf("%q: expected failure", args)
+		}
+	}
+}
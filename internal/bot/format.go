@@ -0,0 +1,164 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf16"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nerdneilsfield/dumper/internal/store"
+)
+
+// messageMarkdown renders text with its Telegram formatting entities as
+// Markdown. Entities without a Markdown form, such as underline and spoiler,
+// are dropped.
+func messageMarkdown(text string, entities []tgbotapi.MessageEntity) string {
+	if len(entities) == 0 {
+		return text
+	}
+
+	// Entity offsets count UTF-16 code units
+	units := utf16.Encode([]rune(text))
+	opens := make(map[int][]string)
+	closes := make(map[int][]string)
+	quoted := make([]bool, len(units))
+
+	sorted := make([]tgbotapi.MessageEntity, len(entities))
+	copy(sorted, entities)
+	// Outer entities first so nested markers close in reverse order
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Offset != sorted[j].Offset {
+			return sorted[i].Offset < sorted[j].Offset
+		}
+		return sorted[i].Length > sorted[j].Length
+	})
+
+	for _, e := range sorted {
+		start, end := e.Offset, e.Offset+e.Length
+		if start < 0 || end > len(units) || start >= end {
+			continue
+		}
+		var open, close string
+		switch e.Type {
+		case "bold":
+			open, close = "**", "**"
+		case "italic":
+			open, close = "_", "_"
+		case "strikethrough":
+			open, close = "~~", "~~"
+		case "code":
+			open, close = "`", "`"
+		case "pre":
+			open, close = "```"+e.Language+"\n", "\n```"
+		case "text_link":
+			open, close = "[", "]("+e.URL+")"
+		case "text_mention":
+			if e.User == nil {
+				continue
+			}
+			open, close = "[", "](tg://user?id="+strconv.FormatInt(e.User.ID, 10)+")"
+		case "blockquote", "expandable_blockquote":
+			for i := start; i < end; i++ {
+				quoted[i] = true
+			}
+			open = "> "
+		default:
+			continue
+		}
+		if e.Type != "pre" && e.Type != "blockquote" && e.Type != "expandable_blockquote" {
+			// Markdown emphasis can't start or end with whitespace
+			for start < end && isSpaceUnit(units[start]) {
+				start++
+			}
+			for end > start && isSpaceUnit(units[end-1]) {
+				end--
+			}
+			if start == end {
+				continue
+			}
+		}
+		opens[start] = append(opens[start], open)
+		closes[end] = append([]string{close}, closes[end]...)
+	}
+
+	var sb strings.Builder
+	last := 0
+	flush := func(to int) {
+		for i := last; i < to; i++ {
+			if units[i] == '\n' && quoted[i] && i+1 < len(units) && quoted[i+1] {
+				sb.WriteString("\n> ")
+				continue
+			}
+			// Surrogate pairs are decoded together
+			if utf16.IsSurrogate(rune(units[i])) && i+1 < to {
+				sb.WriteRune(utf16.DecodeRune(rune(units[i]), rune(units[i+1])))
+				i++
+				continue
+			}
+			sb.WriteRune(rune(units[i]))
+		}
+		last = to
+	}
+	for i := 0; i <= len(units); i++ {
+		if len(opens[i]) == 0 && len(closes[i]) == 0 {
+			continue
+		}
+		flush(i)
+		for _, m := range closes[i] {
+			sb.WriteString(m)
+		}
+		for _, m := range opens[i] {
+			sb.WriteString(m)
+		}
+	}
+	flush(len(units))
+	return sb.String()
+}
+
+func isSpaceUnit(u uint16) bool {
+	return unicode.IsSpace(rune(u))
+}
+
+// messageSource describes where a forwarded message was first posted. It
+// returns nil for messages the user wrote themselves.
+func messageSource(msg *tgbotapi.Message) *store.Source {
+	source := store.Source{Kind: store.SourceForward}
+	switch {
+	case msg.ForwardFromChat != nil:
+		chat := msg.ForwardFromChat
+		source.Name = chat.Title
+		source.Username = chat.UserName
+		source.MessageID = msg.ForwardFromMessageID
+		if source.MessageID != 0 {
+			source.URL = postURL(chat, source.MessageID)
+		}
+	case msg.ForwardFrom != nil:
+		source.Name = strings.TrimSpace(msg.ForwardFrom.FirstName + " " + msg.ForwardFrom.LastName)
+		source.Username = msg.ForwardFrom.UserName
+	case msg.ForwardSenderName != "":
+		// The sender hides their account
+		source.Name = msg.ForwardSenderName
+	default:
+		return nil
+	}
+	if msg.ForwardDate != 0 {
+		source.Date = time.Unix(int64(msg.ForwardDate), 0).UTC()
+	}
+	return &source
+}
+
+// postURL links to a channel post: by username for public channels, by
+// internal ID for private ones, which opens only for their members.
+func postURL(chat *tgbotapi.Chat, messageID int) string {
+	if chat.UserName != "" {
+		return fmt.Sprintf("https://t.me/%s/%d", chat.UserName, messageID)
+	}
+	if id, ok := strings.CutPrefix(strconv.FormatInt(chat.ID, 10), "-100"); ok {
+		return fmt.Sprintf("https://t.me/c/%s/%d", id, messageID)
+	}
+	return ""
+}
@@ -0,0 +1,83 @@
+package bot
+
+import (
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/nerdneilsfield/dumper/internal/store"
+)
+
+func TestMessageMarkdown(t *testing.T) {
+	cases := []struct {
+		name     string
+		text     string
+		entities []tgbotapi.MessageEntity
+		want     string
+	}{
+		{
+			name: "nested",
+			text: "bold italic done",
+			entities: []tgbotapi.MessageEntity{
+				{Type: "bold", Offset: 0, Length: 11},
+				{Type: "italic", Offset: 5, Length: 6},
+			},
+			want: "**bold _italic_** done",
+		},
+		{
+			// Offsets count UTF-16 units, so the emoji takes two
+			name:     "utf16",
+			text:     "🎉 party time",
+			entities: []tgbotapi.MessageEntity{{Type: "text_link", Offset: 3, Length: 5, URL: "https://example.com"}},
+			want:     "🎉 [party](https://example.com) time",
+		},
+		{
+			name:     "trailing space",
+			text:     "see this code",
+			entities: []tgbotapi.MessageEntity{{Type: "bold", Offset: 0, Length: 4}, {Type: "code", Offset: 9, Length: 4}},
+			want:     "**see** this `code`",
+		},
+		{
+			name:     "pre",
+			text:     "run:\nls -la",
+			entities: []tgbotapi.MessageEntity{{Type: "pre", Offset: 5, Length: 6, Language: "sh"}},
+			want:     "run:\n```sh\nls -la\n```",
+		},
+		{
+			name:     "blockquote",
+			text:     "one\ntwo\nafter",
+			entities: []tgbotapi.MessageEntity{{Type: "blockquote", Offset: 0, Length: 7}},
+			want:     "> one\n> two\nafter",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := messageMarkdown(tc.text, tc.entities); got != tc.want {
+				t.Fatalf("messageMarkdown mismatch: got %q want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMessageSource(t *testing.T) {
+	msg := &tgbotapi.Message{
+		ForwardFromChat:      &tgbotapi.Chat{ID: -1001234567890, Title: "Private Channel"},
+		ForwardFromMessageID: 7,
+		ForwardDate:          1700000000,
+	}
+	source := messageSource(msg)
+	if source == nil || source.Kind != store.SourceForward || source.URL != "https://t.me/c/1234567890/7" || source.Name != "Private Channel" || source.Date.Unix() != 1700000000 {
+		t.Fatalf("unexpected source: %+v", source)
+	}
+	if messageSource(&tgbotapi.Message{Text: "mine"}) != nil {
+		t.Fatal("expected no source for a message that wasn't forwarded")
+	}
+}
+
+func TestSnippetHTML(t *testing.T) {
+	got := snippetHTML("...a <mark>quokka</mark> & <script>...")
+	if want := "...a <b>quokka</b> &amp; &lt;script&gt;..."; got != want {
+		t.Fatalf("snippetHTML: got %q want %q", got, want)
+	}
+}
@@ -3,8 +3,10 @@ package bot
 import (
 	"context"
 	"fmt"
+	"html"
 	"io"
 	"log/slog"
+	"mime"
 	"net/http"
 	"path"
 	"strings"
@@ -12,6 +14,7 @@ import (
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/nerdneilsfield/dumper/internal/i18n"
 	"github.com/nerdneilsfield/dumper/internal/ingest"
+	"github.com/nerdneilsfield/dumper/internal/store"
 )
 
 func (b *Bot) handleCommand(ctx context.Context, msg *tgbotapi.Message) {
@@ -34,6 +37,20 @@ func (b *Bot) handleCommand(ctx context.Context, msg *tgbotapi.Message) {
 		b.handleStats(ctx, msg)
 	case "lang":
 		b.handleLang(ctx, msg)
+	case "reprocess":
+		b.handleReprocess(ctx, msg)
+	case "dead":
+		b.handleDead(ctx, msg)
+	case "archive":
+		b.handleArchive(ctx, msg)
+	case "subscribe":
+		b.handleSubscribe(ctx, msg)
+	case "unsubscribe":
+		b.handleUnsubscribe(ctx, msg)
+	case "feeds":
+		b.handleFeeds(ctx, msg)
+	case "email":
+		b.handleEmail(ctx, msg)
 	default:
 		l := b.getUserLang(msg.From.ID, msg.From.LanguageCode)
 		b.send(msg.Chat.ID, l.Get(i18n.MsgUnknownCommand))
@@ -64,9 +81,21 @@ func (b *Bot) handleHelp(msg *tgbotapi.Message) {
 func (b *Bot) handleMessage(ctx context.Context, msg *tgbotapi.Message) {
 	// Check for photo FIRST
 	if len(msg.Photo) > 0 {
+		if msg.MediaGroupID != "" {
+			b.bufferAlbumPhoto(ctx, msg)
+			return
+		}
 		b.handlePhoto(ctx, msg)
 		return
 	}
+	if msg.Document != nil {
+		b.handleDocument(ctx, msg)
+		return
+	}
+	if msg.Voice != nil || msg.Audio != nil {
+		b.handleVoice(ctx, msg)
+		return
+	}
 
 	text := strings.TrimSpace(msg.Text)
 	if text == "" {
@@ -75,55 +104,35 @@ func (b *Bot) handleMessage(ctx context.Context, msg *tgbotapi.Message) {
 
 	l := b.getUserLang(msg.From.ID, msg.From.LanguageCode)
 
+	// Notes keep the message's formatting as Markdown
+	note := strings.TrimSpace(messageMarkdown(msg.Text, msg.Entities))
+
+	if urls := messageURLs(msg.Text, msg.Entities); len(urls) > 1 || len(urls) == 1 && urls[0] != text {
+		b.handleLinks(msg, note, urls, l)
+		return
+	}
+
 	var raw ingest.RawContent
 	raw.UserID = msg.From.ID
 	raw.Language = l.Code()
+	raw.Source = messageSource(msg)
 
 	var sentMsg tgbotapi.Message
 	if ingest.IsURL(text) {
-		sentMsg, _ = b.sendAndReturn(msg.Chat.ID, l.Get(i18n.MsgProcessingLink))
+		sentMsg, _ = b.sendAndReturn(msg.Chat.ID, b.queueStatus(msg.From.ID, l, l.Get(i18n.MsgProcessingLink)))
 		raw.Type = ingest.ContentTypeLink
 		raw.URL = text
 	} else if ingest.IsShortTopicMessage(text) {
-		sentMsg, _ = b.sendAndReturn(msg.Chat.ID, l.Getf(i18n.MsgSearching, text))
+		sentMsg, _ = b.sendAndReturn(msg.Chat.ID, b.queueStatus(msg.From.ID, l, l.Getf(i18n.MsgSearching, text)))
 		raw.Type = ingest.ContentTypeSearch
 		raw.Text = text
 	} else {
-		sentMsg, _ = b.sendAndReturn(msg.Chat.ID, l.Get(i18n.MsgProcessingNote))
+		sentMsg, _ = b.sendAndReturn(msg.Chat.ID, b.queueStatus(msg.From.ID, l, l.Get(i18n.MsgProcessingNote)))
 		raw.Type = ingest.ContentTypeNote
-		raw.Text = text
-	}
-
-	item, err := b.pipeline.Process(ctx, raw)
-	if err != nil {
-		b.edit(msg.Chat.ID, sentMsg.MessageID, l.Getf(i18n.MsgFailedProcess, err))
-		return
-	}
-
-	// Format response
-	var tagsStr string
-	if len(item.Tags) > 0 {
-		tagsStr = "#" + strings.Join(item.Tags, " #")
+		raw.Text = note
 	}
 
-	response := fmt.Sprintf(`%s
-
-<b>%s</b>
-
-%s
-
-%s`, l.Get(i18n.MsgSaved), item.Title, item.Summary, tagsStr)
-
-	if b.webAppURL != "" {
-		keyboard := tgbotapi.NewInlineKeyboardMarkup(
-			tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonURL(l.Get(i18n.MsgViewInApp), b.webAppURL+"?item="+item.ID),
-			),
-		)
-		b.editWithKeyboard(msg.Chat.ID, sentMsg.MessageID, response, keyboard)
-	} else {
-		b.edit(msg.Chat.ID, sentMsg.MessageID, response)
-	}
+	b.enqueue(msg.Chat.ID, sentMsg.MessageID, raw, l)
 }
 
 func (b *Bot) handlePhoto(ctx context.Context, msg *tgbotapi.Message) {
@@ -133,76 +142,32 @@ func (b *Bot) handlePhoto(ctx context.Context, msg *tgbotapi.Message) {
 	photos := msg.Photo
 	photo := photos[len(photos)-1]
 
-	sentMsg, _ := b.sendAndReturn(msg.Chat.ID, l.Get(i18n.MsgSavingImage))
+	sentMsg, _ := b.sendAndReturn(msg.Chat.ID, b.queueStatus(msg.From.ID, l, l.Get(i18n.MsgSavingImage)))
 
-	// Get file info from Telegram
-	fileConfig := tgbotapi.FileConfig{FileID: photo.FileID}
-	file, err := b.api.GetFile(fileConfig)
-	if err != nil {
-		b.edit(msg.Chat.ID, sentMsg.MessageID, l.Getf(i18n.MsgFailedFileInfo, err))
+	file, imageData, ok := b.download(msg.Chat.ID, sentMsg.MessageID, photo.FileID, l)
+	if !ok {
 		return
 	}
 
-	// Download file
-	fileURL := file.Link(b.api.Token)
-	resp, err := http.Get(fileURL)
-	if err != nil {
-		b.edit(msg.Chat.ID, sentMsg.MessageID, l.Getf(i18n.MsgFailedDownload, err))
-		return
-	}
-	defer resp.Body.Close()
-
-	imageData, err := io.ReadAll(resp.Body)
-	if err != nil {
-		b.edit(msg.Chat.ID, sentMsg.MessageID, l.Getf(i18n.MsgFailedReadImage, err))
-		return
-	}
-
-	// Determine file extension
-	ext := "jpg" // default
-	if filePath := file.FilePath; filePath != "" {
-		if e := path.Ext(filePath); e != "" {
-			ext = strings.TrimPrefix(e, ".")
-		}
-	}
-
 	raw := ingest.RawContent{
 		Type:      ingest.ContentTypeImage,
 		UserID:    msg.From.ID,
 		ImageData: imageData,
-		ImageExt:  ext,
-		Caption:   msg.Caption,
+		ImageExt:  photoExt(file),
+		Caption:   messageMarkdown(msg.Caption, msg.CaptionEntities),
 		Language:  l.Code(),
+		Source:    messageSource(msg),
 	}
 
-	item, err := b.pipeline.Process(ctx, raw)
-	if err != nil {
-		b.edit(msg.Chat.ID, sentMsg.MessageID, l.Getf(i18n.MsgFailedSaveImage, err))
-		return
-	}
+	b.enqueue(msg.Chat.ID, sentMsg.MessageID, raw, l)
+}
 
-	// Format response
-	var tagsStr string
-	if len(item.Tags) > 0 {
-		tagsStr = "#" + strings.Join(item.Tags, " #")
-	}
-
-	response := fmt.Sprintf(`%s
-
-<b>%s</b>
-
-%s`, l.Get(i18n.MsgImageSaved), item.Title, tagsStr)
-
-	if b.webAppURL != "" {
-		keyboard := tgbotapi.NewInlineKeyboardMarkup(
-			tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonURL(l.Get(i18n.MsgViewInApp), b.webAppURL+"?item="+item.ID),
-			),
-		)
-		b.editWithKeyboard(msg.Chat.ID, sentMsg.MessageID, response, keyboard)
-	} else {
-		b.edit(msg.Chat.ID, sentMsg.MessageID, response)
+// photoExt returns the extension of a downloaded photo, jpg by default.
+func photoExt(file tgbotapi.File) string {
+	if e := path.Ext(file.FilePath); e != "" {
+		return strings.TrimPrefix(e, ".")
 	}
+	return "jpg"
 }
 
 func (b *Bot) handleSearch(ctx context.Context, msg *tgbotapi.Message) {
@@ -232,13 +197,13 @@ func (b *Bot) handleSearch(ctx context.Context, msg *tgbotapi.Message) {
 	}
 
 	var text strings.Builder
-	text.WriteString(l.Getf(i18n.MsgSearchFor, query))
+	text.WriteString(l.Getf(i18n.MsgSearchFor, html.EscapeString(query)))
 	text.WriteString("\n\n")
 
 	for i, r := range results {
-		text.WriteString(fmt.Sprintf("%d. <b>%s</b>\n", i+1, r.Item.Title))
+		text.WriteString(fmt.Sprintf("%d. %s<b>%s</b>\n", i+1, linkMark(&r.Item), html.EscapeString(r.Item.Title)))
 		if r.Snippet != "" {
-			text.WriteString(fmt.Sprintf("   %s\n", r.Snippet))
+			text.WriteString(fmt.Sprintf("   %s\n", snippetHTML(r.Snippet)))
 		}
 		text.WriteString("\n")
 	}
@@ -246,6 +211,13 @@ func (b *Bot) handleSearch(ctx context.Context, msg *tgbotapi.Message) {
 	b.send(msg.Chat.ID, text.String())
 }
 
+// snippetHTML escapes a search snippet for Telegram, which has no <mark>
+// tag, showing the matches in bold instead.
+func snippetHTML(snippet string) string {
+	snippet = html.EscapeString(snippet)
+	return strings.NewReplacer("&lt;mark&gt;", "<b>", "&lt;/mark&gt;", "</b>").Replace(snippet)
+}
+
 func (b *Bot) handleRecent(ctx context.Context, msg *tgbotapi.Message) {
 	l := b.getUserLang(msg.From.ID, msg.From.LanguageCode)
 
@@ -270,9 +242,9 @@ func (b *Bot) handleRecent(ctx context.Context, msg *tgbotapi.Message) {
 	text.WriteString(l.Get(i18n.MsgRecentItems))
 
 	for i, item := range items {
-		text.WriteString(fmt.Sprintf("%d. <b>%s</b>\n", i+1, item.Title))
+		text.WriteString(fmt.Sprintf("%d. %s<b>%s</b>\n", i+1, linkMark(&item), html.EscapeString(item.Title)))
 		if len(item.Tags) > 0 {
-			text.WriteString(fmt.Sprintf("   #%s\n", strings.Join(item.Tags, " #")))
+			text.WriteString(fmt.Sprintf("   #%s\n", html.EscapeString(strings.Join(item.Tags, " #"))))
 		}
 		text.WriteString("\n")
 	}
@@ -382,3 +354,168 @@ func (b *Bot) handleLang(ctx context.Context, msg *tgbotapi.Message) {
 	newLocalizer := i18n.New(string(newLang))
 	b.send(msg.Chat.ID, newLocalizer.Get(i18n.MsgLangChanged))
 }
+
+// handleReprocess queues items for summarisation and tagging again: one item
+// by ID, "all" for the whole vault, or the uncategorized items by default.
+func (b *Bot) handleReprocess(ctx context.Context, msg *tgbotapi.Message) {
+	l := b.getUserLang(msg.From.ID, msg.From.LanguageCode)
+	arg := strings.TrimSpace(msg.CommandArguments())
+
+	vault, err := b.stores.GetVault(msg.From.ID)
+	if err != nil {
+		b.send(msg.Chat.ID, l.Get(i18n.MsgFailedVault))
+		return
+	}
+
+	raw := ingest.RawContent{
+		Type:     ingest.ContentTypeReprocess,
+		UserID:   msg.From.ID,
+		Language: l.Code(),
+	}
+
+	if arg != "" && arg != string(ingest.ReprocessAll) {
+		item, err := vault.GetItem(arg)
+		if err != nil || item == nil {
+			b.send(msg.Chat.ID, l.Get(i18n.MsgItemNotFound))
+			return
+		}
+		raw.ItemID = item.ID
+		sentMsg, _ := b.sendAndReturn(msg.Chat.ID, b.queueStatus(msg.From.ID, l, l.Get(i18n.MsgReprocessing)))
+		b.enqueue(msg.Chat.ID, sentMsg.MessageID, raw, l)
+		return
+	}
+
+	scope := ingest.ReprocessUncategorized
+	if arg == string(ingest.ReprocessAll) {
+		scope = ingest.ReprocessAll
+	}
+	ids, err := ingest.ReprocessTargets(vault, scope)
+	if err != nil {
+		b.send(msg.Chat.ID, l.Getf(i18n.MsgFailedReprocess, err))
+		return
+	}
+	if len(ids) == 0 {
+		b.send(msg.Chat.ID, l.Get(i18n.MsgNoReprocess))
+		return
+	}
+
+	// Batch jobs run silently; only the count is reported
+	for _, id := range ids {
+		raw.ItemID = id
+		if err := b.emit(raw, nil); err != nil {
+			slog.Error("failed to enqueue reprocess", "user_id", raw.UserID, "item_id", id, "error", err)
+			b.send(msg.Chat.ID, l.Getf(i18n.MsgFailedReprocess, err))
+			return
+		}
+	}
+	b.send(msg.Chat.ID, l.Getf(i18n.MsgReprocessQueued, len(ids)))
+}
+
+// handleDocument saves an uploaded document in any format ingest can parse.
+func (b *Bot) handleDocument(ctx context.Context, msg *tgbotapi.Message) {
+	l := b.getUserLang(msg.From.ID, msg.From.LanguageCode)
+	doc := msg.Document
+
+	if !ingest.SupportsDocument(doc.MimeType, doc.FileName) {
+		b.send(msg.Chat.ID, l.Get(i18n.MsgUnsupportedDocument))
+		return
+	}
+	if doc.FileSize > store.MaxBlobSize {
+		b.send(msg.Chat.ID, l.Getf(i18n.MsgFileTooLarge, store.MaxBlobSize>>20))
+		return
+	}
+
+	sentMsg, _ := b.sendAndReturn(msg.Chat.ID, b.queueStatus(msg.From.ID, l, l.Get(i18n.MsgSavingDocument)))
+
+	_, data, ok := b.download(msg.Chat.ID, sentMsg.MessageID, doc.FileID, l)
+	if !ok {
+		return
+	}
+
+	raw := ingest.RawContent{
+		Type:     ingest.ContentTypeDocument,
+		UserID:   msg.From.ID,
+		FileData: data,
+		FileName: doc.FileName,
+		FileMIME: doc.MimeType,
+		Caption:  messageMarkdown(msg.Caption, msg.CaptionEntities),
+		Language: l.Code(),
+		Source:   messageSource(msg),
+	}
+
+	b.enqueue(msg.Chat.ID, sentMsg.MessageID, raw, l)
+}
+
+// handleVoice transcribes a voice message or audio file into a note.
+func (b *Bot) handleVoice(ctx context.Context, msg *tgbotapi.Message) {
+	l := b.getUserLang(msg.From.ID, msg.From.LanguageCode)
+	if !b.voice {
+		b.send(msg.Chat.ID, l.Get(i18n.MsgVoiceDisabled))
+		return
+	}
+
+	var fileID, fileName, mimeType string
+	var size int
+	if msg.Voice != nil {
+		// Voice messages are Ogg Opus and carry no filename
+		fileID, fileName, mimeType, size = msg.Voice.FileID, "voice.ogg", msg.Voice.MimeType, msg.Voice.FileSize
+	} else {
+		fileID, fileName, mimeType, size = msg.Audio.FileID, msg.Audio.FileName, msg.Audio.MimeType, msg.Audio.FileSize
+		if fileName == "" {
+			fileName = "audio.mp3"
+			if exts, _ := mime.ExtensionsByType(mimeType); len(exts) > 0 {
+				fileName = "audio" + exts[0]
+			}
+		}
+	}
+	if size > store.MaxBlobSize {
+		b.send(msg.Chat.ID, l.Getf(i18n.MsgFileTooLarge, store.MaxBlobSize>>20))
+		return
+	}
+
+	sentMsg, _ := b.sendAndReturn(msg.Chat.ID, b.queueStatus(msg.From.ID, l, l.Get(i18n.MsgTranscribing)))
+
+	_, data, ok := b.download(msg.Chat.ID, sentMsg.MessageID, fileID, l)
+	if !ok {
+		return
+	}
+
+	raw := ingest.RawContent{
+		Type:     ingest.ContentTypeVoice,
+		UserID:   msg.From.ID,
+		FileData: data,
+		FileName: fileName,
+		FileMIME: mimeType,
+		Caption:  messageMarkdown(msg.Caption, msg.CaptionEntities),
+		Language: l.Code(),
+		Source:   messageSource(msg),
+	}
+
+	b.enqueue(msg.Chat.ID, sentMsg.MessageID, raw, l)
+}
+
+// download fetches a Telegram file. On failure it reports the error in the
+// status message and returns ok=false.
+func (b *Bot) download(chatID int64, messageID int, fileID string, l *i18n.Localizer) (file tgbotapi.File, data []byte, ok bool) {
+	// Get file info from Telegram
+	file, err := b.api.GetFile(tgbotapi.FileConfig{FileID: fileID})
+	if err != nil {
+		b.edit(chatID, messageID, l.Getf(i18n.MsgFailedFileInfo, err))
+		return file, nil, false
+	}
+
+	// Download file
+	resp, err := http.Get(file.Link(b.api.Token))
+	if err != nil {
+		b.edit(chatID, messageID, l.Getf(i18n.MsgFailedDownload, err))
+		return file, nil, false
+	}
+	defer resp.Body.Close()
+
+	data, err = io.ReadAll(resp.Body)
+	if err != nil {
+		b.edit(chatID, messageID, l.Getf(i18n.MsgFailedReadFile, err))
+		return file, nil, false
+	}
+	return file, data, true
+}
@@ -0,0 +1,148 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"log/slog"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nerdneilsfield/dumper/internal/i18n"
+	"github.com/nerdneilsfield/dumper/internal/ingest"
+	"github.com/nerdneilsfield/dumper/internal/queue"
+	"github.com/nerdneilsfield/dumper/internal/store"
+)
+
+// jobReply identifies the status message to edit when a queued capture
+// finishes.
+type jobReply struct {
+	ChatID    int64  `json:"chat_id"`
+	MessageID int    `json:"message_id"`
+	Lang      string `json:"lang"`
+	Image     bool   `json:"image,omitempty"`
+	// Archive marks a snapshot job for an existing item
+	Archive bool `json:"archive,omitempty"`
+	// Batch labels the jobs sharing this status message, in enqueue order.
+	Batch []string `json:"batch,omitempty"`
+}
+
+// enqueue queues raw for processing. The status message is edited once the
+// job completes or finally fails.
+func (b *Bot) enqueue(chatID int64, messageID int, raw ingest.RawContent, l *i18n.Localizer) {
+	reply := jobReply{
+		ChatID:    chatID,
+		MessageID: messageID,
+		Lang:      l.Code(),
+		Image:     raw.Type == ingest.ContentTypeImage || raw.Type == ingest.ContentTypeAlbum,
+	}
+	if err := b.emit(raw, reply); err != nil {
+		slog.Error("failed to enqueue capture", "user_id", raw.UserID, "error", err)
+		b.edit(chatID, messageID, l.Getf(failedMessage(reply), err))
+	}
+}
+
+// queueStatus appends the user's queue position to a status message when
+// other captures are waiting ahead of theirs.
+func (b *Bot) queueStatus(userID int64, l *i18n.Localizer, status string) string {
+	ahead, err := b.jobs.Ahead(userID)
+	if err != nil {
+		slog.Warn("failed to get queue position", "user_id", userID, "error", err)
+		return status
+	}
+	if ahead == 0 {
+		return status
+	}
+	return status + "\n" + l.Getf(i18n.MsgQueuePosition, ahead)
+}
+
+// Ack reports a finished capture job by editing its status message.
+func (b *Bot) Ack(job *queue.Job) {
+	var reply jobReply
+	if len(job.Reply) == 0 {
+		return
+	}
+	if err := json.Unmarshal(job.Reply, &reply); err != nil {
+		slog.Error("failed to decode job reply", "job_id", job.ID, "error", err)
+		return
+	}
+	l := i18n.New(reply.Lang)
+
+	if len(reply.Batch) > 0 {
+		b.showBatch(job, reply, l)
+		return
+	}
+
+	if job.Status == queue.StatusFailed {
+		b.edit(reply.ChatID, reply.MessageID, l.Getf(failedMessage(reply), job.LastError))
+		return
+	}
+
+	vault, err := b.stores.GetVault(job.UserID)
+	if err != nil {
+		b.edit(reply.ChatID, reply.MessageID, l.Get(i18n.MsgFailedVault))
+		return
+	}
+	item, err := vault.GetItem(job.Result)
+	if err != nil || item == nil {
+		slog.Error("failed to load processed item", "job_id", job.ID, "item_id", job.Result, "error", err)
+		return
+	}
+	if reply.Archive {
+		b.edit(reply.ChatID, reply.MessageID, l.Getf(i18n.MsgArchiveSaved, html.EscapeString(item.Title)))
+		return
+	}
+	b.showSaved(reply, l, item)
+}
+
+// showSaved replaces the status message with a summary of the saved item.
+func (b *Bot) showSaved(reply jobReply, l *i18n.Localizer, item *store.Item) {
+	// Titles, summaries and tags come from pages, feeds and emails
+	title, summary := html.EscapeString(item.Title), html.EscapeString(item.Summary)
+	var tagsStr string
+	if len(item.Tags) > 0 {
+		tagsStr = html.EscapeString("#" + strings.Join(item.Tags, " #"))
+	}
+
+	var response string
+	if reply.Image && item.Summary == "" {
+		response = fmt.Sprintf(`%s
+
+<b>%s</b>
+
+%s`, l.Get(i18n.MsgImageSaved), title, tagsStr)
+	} else {
+		saved := l.Get(i18n.MsgSaved)
+		if reply.Image {
+			saved = l.Get(i18n.MsgImageSaved)
+		}
+		response = fmt.Sprintf(`%s
+
+<b>%s</b>
+
+%s
+
+%s`, saved, title, summary, tagsStr)
+	}
+
+	if b.webAppURL != "" {
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonURL(l.Get(i18n.MsgViewInApp), b.webAppURL+"?item="+item.ID),
+			),
+		)
+		b.editWithKeyboard(reply.ChatID, reply.MessageID, response, keyboard)
+	} else {
+		b.edit(reply.ChatID, reply.MessageID, response)
+	}
+}
+
+func failedMessage(reply jobReply) i18n.MsgKey {
+	if reply.Archive {
+		return i18n.MsgFailedArchive
+	}
+	if reply.Image {
+		return i18n.MsgFailedSaveImage
+	}
+	return i18n.MsgFailedProcess
+}
@@ -0,0 +1,151 @@
+package bot
+
+import (
+	"html"
+	"log/slog"
+	"strings"
+	"unicode/utf16"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nerdneilsfield/dumper/internal/i18n"
+	"github.com/nerdneilsfield/dumper/internal/ingest"
+	"github.com/nerdneilsfield/dumper/internal/queue"
+)
+
+// noteMinWords is how many words besides its links make a message worth
+// saving as a note as well.
+const noteMinWords = 6
+
+// messageURLs returns the links in a message: those Telegram detected,
+// including text links with hidden targets, then any others in the text.
+func messageURLs(text string, entities []tgbotapi.MessageEntity) []string {
+	var urls []string
+	seen := make(map[string]struct{})
+	add := func(u string) {
+		if _, ok := seen[u]; !ok {
+			seen[u] = struct{}{}
+			urls = append(urls, u)
+		}
+	}
+
+	// Entity offsets count UTF-16 code units
+	units := utf16.Encode([]rune(text))
+	for _, e := range entities {
+		switch e.Type {
+		case "url":
+			if e.Offset < 0 || e.Length <= 0 || e.Offset+e.Length > len(units) {
+				continue
+			}
+			u := string(utf16.Decode(units[e.Offset : e.Offset+e.Length]))
+			if !ingest.IsURL(u) {
+				u = "https://" + u
+			}
+			add(u)
+		case "text_link":
+			if ingest.IsURL(e.URL) {
+				add(e.URL)
+			}
+		}
+	}
+	for _, u := range ingest.ExtractURLs(text) {
+		add(u)
+	}
+	return urls
+}
+
+// handleLinks saves every link in a message as its own item, and the text as
+// a note when there is more to it than the links. One reply reports them all.
+func (b *Bot) handleLinks(msg *tgbotapi.Message, text string, urls []string, l *i18n.Localizer) {
+	rest := text
+	for _, u := range urls {
+		rest = strings.ReplaceAll(rest, u, "")
+	}
+	withNote := len(strings.Fields(rest)) >= noteMinWords
+
+	var raws []ingest.RawContent
+	var labels []string
+	if withNote {
+		raws = append(raws, ingest.RawContent{Type: ingest.ContentTypeNote, Text: text})
+		labels = append(labels, l.Get(i18n.MsgBatchNote))
+	}
+	for _, u := range urls {
+		// The message is kept as the link's content if the page can't be read
+		raws = append(raws, ingest.RawContent{Type: ingest.ContentTypeLink, URL: u, Text: text})
+		labels = append(labels, u)
+	}
+
+	status := l.Getf(i18n.MsgProcessingLinks, len(urls))
+	if len(raws) == 1 {
+		status = l.Get(i18n.MsgProcessingLink)
+	}
+	sentMsg, _ := b.sendAndReturn(msg.Chat.ID, b.queueStatus(msg.From.ID, l, status))
+
+	for i := range raws {
+		raws[i].UserID = msg.From.ID
+		raws[i].Language = l.Code()
+		raws[i].Source = messageSource(msg)
+	}
+	if len(raws) == 1 {
+		b.enqueue(msg.Chat.ID, sentMsg.MessageID, raws[0], l)
+		return
+	}
+
+	// Jobs sharing this reply are reported together by showBatch
+	reply := jobReply{
+		ChatID:    msg.Chat.ID,
+		MessageID: sentMsg.MessageID,
+		Lang:      l.Code(),
+		Batch:     labels,
+	}
+	for _, raw := range raws {
+		if err := b.emit(raw, reply); err != nil {
+			slog.Error("failed to enqueue capture", "user_id", raw.UserID, "error", err)
+			b.edit(msg.Chat.ID, sentMsg.MessageID, l.Getf(i18n.MsgFailedProcess, err))
+			return
+		}
+	}
+}
+
+// showBatch edits a batch's status message with the state of every job in
+// it: saved items by title, failures with their error, the rest as pending.
+func (b *Bot) showBatch(job *queue.Job, reply jobReply, l *i18n.Localizer) {
+	// Serialised so the last job to finish always renders the final state
+	b.batchMu.Lock()
+	defer b.batchMu.Unlock()
+
+	jobs, err := b.jobs.Related(job)
+	if err != nil {
+		slog.Error("failed to load batch jobs", "job_id", job.ID, "error", err)
+		return
+	}
+	vault, err := b.stores.GetVault(job.UserID)
+	if err != nil {
+		b.edit(reply.ChatID, reply.MessageID, l.Get(i18n.MsgFailedVault))
+		return
+	}
+
+	finished := 0
+	lines := make([]string, 0, len(jobs))
+	for i, related := range jobs {
+		var label string
+		if i < len(reply.Batch) {
+			label = html.EscapeString(reply.Batch[i])
+		}
+		switch related.Status {
+		case queue.StatusDone:
+			finished++
+			if item, err := vault.GetItem(related.Result); err == nil && item != nil {
+				label = "<b>" + html.EscapeString(item.Title) + "</b>"
+			}
+			lines = append(lines, "✅ "+label)
+		case queue.StatusFailed:
+			finished++
+			lines = append(lines, "❌ "+label+": "+html.EscapeString(related.LastError))
+		default:
+			lines = append(lines, "⏳ "+label)
+		}
+	}
+
+	text := l.Getf(i18n.MsgBatchStatus, finished, len(jobs)) + "\n\n" + strings.Join(lines, "\n")
+	b.edit(reply.ChatID, reply.MessageID, text)
+}
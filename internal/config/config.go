@@ -1,24 +1,78 @@
 package config
 
 import (
+	"time"
+
 	"github.com/jessevdk/go-flags"
 )
 
 type Config struct {
-	TelegramToken   string `long:"telegram-token" env:"TELEGRAM_BOT_TOKEN" description:"Telegram bot token" required:"true"`
-	OpenRouterKey   string `long:"openrouter-key" env:"OPENROUTER_API_KEY" description:"OpenRouter API key" required:"true"`
-	DataDir         string `long:"data-dir" env:"DATA_DIR" default:"./data" description:"Data directory for SQLite databases"`
-	HTTPPort        int    `long:"http-port" env:"HTTP_PORT" default:"8080" description:"HTTP server port"`
-	LogLevel        string `long:"log-level" env:"LOG_LEVEL" default:"info" description:"Log level: debug|info|warn|error"`
-	OpenRouterModel string `long:"openrouter-model" env:"OPENROUTER_MODEL" default:"anthropic/claude-3-haiku" description:"OpenRouter model ID"`
-	WebAppURL       string `long:"webapp-url" env:"WEBAPP_URL" description:"Telegram Mini App URL"`
+	TelegramToken     string        `long:"telegram-token" env:"TELEGRAM_BOT_TOKEN" description:"Telegram bot token (required to serve)"`
+	OpenRouterKey     string        `long:"openrouter-key" env:"OPENROUTER_API_KEY" description:"OpenRouter API key (required to serve and reprocess)"`
+	DataDir           string        `long:"data-dir" env:"DATA_DIR" default:"./data" description:"Data directory for SQLite databases"`
+	HTTPPort          int           `long:"http-port" env:"HTTP_PORT" default:"8080" description:"HTTP server port"`
+	LogLevel          string        `long:"log-level" env:"LOG_LEVEL" default:"info" description:"Log level: debug|info|warn|error"`
+	OpenRouterModel   string        `long:"openrouter-model" env:"OPENROUTER_MODEL" default:"anthropic/claude-3-haiku" description:"OpenRouter model ID"`
+	WebAppURL         string        `long:"webapp-url" env:"WEBAPP_URL" description:"Telegram Mini App URL"`
+	EncryptionKey     string        `long:"encryption-key" env:"ENCRYPTION_KEY" description:"Master key for encrypting vault content at rest (empty disables encryption)"`
+	EncryptedSearch   string        `long:"encrypted-search" env:"ENCRYPTED_SEARCH" default:"index" choice:"index" choice:"off" description:"Search over encrypted content: index keeps an unencrypted token index, off only searches titles"`
+	GCInterval        time.Duration `long:"gc-interval" env:"GC_INTERVAL" default:"0" description:"Run orphaned file garbage collection at this interval (0 disables)"`
+	GCGracePeriod     time.Duration `long:"gc-grace-period" env:"GC_GRACE_PERIOD" default:"1h" description:"Never collect files younger than this"`
+	QueueWorkers      int           `long:"queue-workers" env:"QUEUE_WORKERS" default:"4" description:"Captures processed concurrently across all users"`
+	QueueMaxAttempts  int           `long:"queue-max-attempts" env:"QUEUE_MAX_ATTEMPTS" default:"5" description:"Attempts before a capture is reported as failed"`
+	LLMConcurrency    int           `long:"llm-concurrency" env:"LLM_CONCURRENCY" default:"4" description:"Maximum concurrent LLM requests (0 for unlimited)"`
+	LLMChunkSize      int           `long:"llm-chunk-size" env:"LLM_CHUNK_SIZE" default:"8000" description:"Content longer than this many bytes is summarised in chunks"`
+	LLMMaxChunks      int           `long:"llm-max-chunks" env:"LLM_MAX_CHUNKS" default:"12" description:"Maximum chunks summarised per item"`
+	VisionModel       string        `long:"vision-model" env:"VISION_MODEL" description:"Vision-capable model for describing images (empty uses the OpenRouter model, none disables)"`
+	TranscribeURL     string        `long:"transcribe-url" env:"TRANSCRIBE_URL" description:"OpenAI-compatible API base URL for voice transcription, e.g. https://api.openai.com/v1 (empty disables)"`
+	TranscribeKey     string        `long:"transcribe-key" env:"TRANSCRIBE_API_KEY" description:"API key for the transcription API"`
+	TranscribeModel   string        `long:"transcribe-model" env:"TRANSCRIBE_MODEL" default:"whisper-1" description:"Transcription model ID"`
+	StorageBackend    string        `long:"storage-backend" env:"STORAGE_BACKEND" default:"sqlite" choice:"sqlite" choice:"postgres" description:"Database backend for vaults"`
+	PostgresDSN       string        `long:"postgres-dsn" env:"POSTGRES_DSN" description:"PostgreSQL connection string (required for the postgres backend)"`
+	ArchivePages      bool          `long:"archive-pages" env:"ARCHIVE_PAGES" description:"Keep a self-contained HTML snapshot of every saved web page"`
+	LinkCheckInterval time.Duration `long:"link-check-interval" env:"LINK_CHECK_INTERVAL" default:"0" description:"Re-check saved links for rot at this interval (0 disables)"`
+	LinkCheckAge      time.Duration `long:"link-check-age" env:"LINK_CHECK_AGE" default:"168h" description:"Check each saved link at most this often"`
+	LinkCheckBatch    int           `long:"link-check-batch" env:"LINK_CHECK_BATCH" default:"200" description:"Links checked per user in each run"`
+	FeedInterval      time.Duration `long:"feed-interval" env:"FEED_INTERVAL" default:"30m" description:"Poll RSS and Atom subscriptions at this interval (0 disables)"`
+	InboxInterval     time.Duration `long:"inbox-interval" env:"INBOX_INTERVAL" default:"10s" description:"Scan <data-dir>/inbox/<user ID>/ for dropped files at this interval (0 disables)"`
+	SMTPAddr          string        `long:"smtp-addr" env:"SMTP_ADDR" description:"Receive email for capture addresses on this address, e.g. :2525 (empty disables)"`
+	EmailDomain       string        `long:"email-domain" env:"EMAIL_DOMAIN" description:"Domain of the capture addresses; its MX must reach SMTP_ADDR"`
+
+	Encrypt   EncryptCommand   `command:"encrypt" description:"Encrypt existing plaintext vault content and files, then exit"`
+	GC        GCCommand        `command:"gc" description:"Remove orphaned image files, then exit"`
+	Reprocess ReprocessCommand `command:"reprocess" description:"Rerun summarisation and tagging for stored items, then exit"`
+
+	// Command is the name of the subcommand being run, empty for the server.
+	Command string `no-flag:"true"`
+}
+
+// EncryptCommand migrates existing vaults to encryption at rest.
+type EncryptCommand struct {
+	UserID int64 `long:"user" description:"Only encrypt this user's vault (default: all users)"`
+}
+
+// GCCommand runs orphaned file garbage collection once.
+type GCCommand struct {
+	UserID int64 `long:"user" description:"Only collect this user's vault (default: all users)"`
+	DryRun bool  `long:"dry-run" description:"Report orphaned files without deleting them"`
+}
+
+// ReprocessCommand reruns the LLM over stored items.
+type ReprocessCommand struct {
+	UserID int64  `long:"user" description:"Only reprocess this user's vault (default: all users)"`
+	ItemID string `long:"item" description:"Only reprocess this item (requires --user)"`
+	All    bool   `long:"all" description:"Reprocess every item, not just uncategorized ones"`
 }
 
 func Load() (*Config, error) {
 	cfg := &Config{}
 	parser := flags.NewParser(cfg, flags.Default)
+	parser.SubcommandsOptional = true
 	if _, err := parser.Parse(); err != nil {
 		return nil, err
 	}
+	if parser.Active != nil {
+		cfg.Command = parser.Active.Name
+	}
 	return cfg, nil
 }
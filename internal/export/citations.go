@@ -0,0 +1,277 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/nerdneilsfield/dumper/internal/store"
+)
+
+// Citation formats accepted by ExportCitations.
+const (
+	FormatBibTeX  = "bibtex"
+	FormatCSLJSON = "csl-json"
+)
+
+// CitationMIME returns the content type of a citation format.
+func CitationMIME(format string) string {
+	if format == FormatBibTeX {
+		return "application/x-bibtex; charset=utf-8"
+	}
+	return "application/vnd.citationstyles.csl+json"
+}
+
+// ExportCitations writes items as a bibliography. Papers keep their
+// authors, venue and identifiers; other items are cited as web pages.
+func ExportCitations(w io.Writer, items []store.Item, format string) error {
+	switch format {
+	case FormatBibTeX:
+		return writeBibTeX(w, items)
+	case FormatCSLJSON:
+		return writeCSLJSON(w, items)
+	default:
+		return fmt.Errorf("unknown citation format %q", format)
+	}
+}
+
+// citation is the subset of an item's metadata a bibliography needs.
+type citation struct {
+	item    store.Item
+	paper   bool
+	authors []string
+	venue   string
+	year    int
+	month   int
+	doi     string
+	arxivID string
+}
+
+func newCitation(item store.Item) citation {
+	c := citation{item: item, year: item.CreatedAt.Year()}
+	d := item.Structured
+	if d == nil {
+		return c
+	}
+	c.paper = d.Type == store.SchemaPaper
+	c.authors = d.Authors
+	if len(c.authors) == 0 && d.Author != "" {
+		c.authors = []string{d.Author}
+	}
+	c.venue, c.doi, c.arxivID = d.Venue, d.DOI, d.ArXivID
+	if d.Year > 0 {
+		c.year = d.Year
+		// Published is 2017-06-12 or 2017/06/12
+		if parts := strings.FieldsFunc(d.Published, func(r rune) bool { return !unicode.IsDigit(r) }); len(parts) > 1 {
+			c.month, _ = strconv.Atoi(parts[1])
+		}
+	}
+	return c
+}
+
+func newCitations(items []store.Item) []citation {
+	citations := make([]citation, len(items))
+	for i, item := range items {
+		citations[i] = newCitation(item)
+	}
+	return citations
+}
+
+var nonKeyChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// key builds a citation key such as vaswani2017attention.
+func (c citation) key() string {
+	var author, word string
+	if len(c.authors) > 0 {
+		// "Vaswani, Ashish" or "Ashish Vaswani"
+		author, _, _ = strings.Cut(c.authors[0], ",")
+		if fields := strings.Fields(author); len(fields) > 0 && !strings.Contains(c.authors[0], ",") {
+			author = fields[len(fields)-1]
+		}
+	}
+	for _, w := range strings.Fields(c.item.Title) {
+		if w = nonKeyChars.ReplaceAllString(asciiLower(w), ""); len(w) > 3 {
+			word = w
+			break
+		}
+	}
+	author = nonKeyChars.ReplaceAllString(asciiLower(author), "")
+	if author == "" && word == "" {
+		return "item" + nonKeyChars.ReplaceAllString(c.item.ID, "")
+	}
+	return author + fmt.Sprint(c.year) + word
+}
+
+// citationKeys returns a unique key for each item, disambiguated the way
+// BibTeX styles do: smith2020a, smith2020b.
+func citationKeys(citations []citation) []string {
+	keys := make([]string, len(citations))
+	count := make(map[string]int)
+	for i, c := range citations {
+		keys[i] = c.key()
+		count[keys[i]]++
+	}
+	next := make(map[string]int)
+	for i, key := range keys {
+		if count[key] > 1 {
+			keys[i] = key + string(rune('a'+next[key]%26))
+			next[key]++
+		}
+	}
+	return keys
+}
+
+// asciiLower lowercases s and drops accents and other non-ASCII letters.
+func asciiLower(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, s)
+}
+
+func writeBibTeX(w io.Writer, items []store.Item) error {
+	citations := newCitations(items)
+	for i, key := range citationKeys(citations) {
+		c, item := citations[i], items[i]
+
+		entryType := "misc"
+		if c.paper && c.venue != "" {
+			entryType = "article"
+		}
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "@%s{%s,\n", entryType, key)
+		field := func(name, value string) {
+			if value != "" {
+				fmt.Fprintf(&sb, "  %s = {%s},\n", name, bibEscape(value))
+			}
+		}
+		field("title", item.Title)
+		field("author", strings.Join(c.authors, " and "))
+		field("journal", c.venue)
+		if c.year > 0 {
+			field("year", fmt.Sprint(c.year))
+		}
+		field("doi", c.doi)
+		if c.arxivID != "" {
+			field("eprint", c.arxivID)
+			field("archiveprefix", "arXiv")
+		}
+		field("url", item.URL)
+		if d := item.Structured; c.paper && d != nil {
+			field("abstract", d.Description)
+		}
+		if len(item.Tags) > 0 {
+			field("keywords", strings.Join(item.Tags, ", "))
+		}
+		sb.WriteString("}\n\n")
+
+		if _, err := io.WriteString(w, sb.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bibEscape escapes the characters BibTeX treats specially in braced values.
+func bibEscape(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	return strings.NewReplacer(
+		`\`, `\textbackslash{}`,
+		"{", `\{`,
+		"}", `\}`,
+		"&", `\&`,
+		"%", `\%`,
+		"$", `\$`,
+		"#", `\#`,
+		"_", `\_`,
+	).Replace(s)
+}
+
+// cslItem is an entry of a CSL-JSON bibliography, as read by Zotero and
+// citeproc processors.
+type cslItem struct {
+	ID             string    `json:"id"`
+	Type           string    `json:"type"`
+	Title          string    `json:"title"`
+	Author         []cslName `json:"author,omitempty"`
+	ContainerTitle string    `json:"container-title,omitempty"`
+	Issued         *cslDate  `json:"issued,omitempty"`
+	Accessed       *cslDate  `json:"accessed,omitempty"`
+	DOI            string    `json:"DOI,omitempty"`
+	URL            string    `json:"URL,omitempty"`
+	Number         string    `json:"number,omitempty"`
+	Publisher      string    `json:"publisher,omitempty"`
+	Abstract       string    `json:"abstract,omitempty"`
+	Keyword        string    `json:"keyword,omitempty"`
+}
+
+type cslName struct {
+	Family  string `json:"family,omitempty"`
+	Given   string `json:"given,omitempty"`
+	Literal string `json:"literal,omitempty"`
+}
+
+type cslDate struct {
+	DateParts [][]int `json:"date-parts"`
+}
+
+func writeCSLJSON(w io.Writer, items []store.Item) error {
+	citations := newCitations(items)
+	entries := make([]cslItem, 0, len(items))
+	for i, key := range citationKeys(citations) {
+		c, item := citations[i], items[i]
+		entry := cslItem{
+			ID:    key,
+			Type:  "webpage",
+			Title: item.Title,
+			DOI:   c.doi,
+			URL:   item.URL,
+			Accessed: &cslDate{DateParts: [][]int{{
+				item.CreatedAt.Year(), int(item.CreatedAt.Month()), item.CreatedAt.Day(),
+			}}},
+		}
+		for _, a := range c.authors {
+			entry.Author = append(entry.Author, cslAuthor(a))
+		}
+		if c.paper {
+			entry.Type = "article-journal"
+			entry.ContainerTitle = c.venue
+			entry.Abstract = item.Structured.Description
+			// Preprints have no journal yet
+			if c.arxivID != "" && c.venue == "" {
+				entry.Type = "article"
+				entry.Number = "arXiv:" + c.arxivID
+				entry.Publisher = "arXiv"
+			}
+			if c.year > 0 {
+				parts := []int{c.year}
+				if c.month > 0 {
+					parts = append(parts, c.month)
+				}
+				entry.Issued = &cslDate{DateParts: [][]int{parts}}
+			}
+		}
+		if len(item.Tags) > 0 {
+			entry.Keyword = strings.Join(item.Tags, ", ")
+		}
+		entries = append(entries, entry)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(entries)
+}
+
+// cslAuthor splits "Family, Given" names; others are kept whole.
+func cslAuthor(name string) cslName {
+	if family, given, ok := strings.Cut(name, ","); ok {
+		return cslName{Family: strings.TrimSpace(family), Given: strings.TrimSpace(given)}
+	}
+	return cslName{Literal: name}
+}
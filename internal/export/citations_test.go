@@ -0,0 +1,105 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nerdneilsfield/dumper/internal/store"
+)
+
+func citationItems() []store.Item {
+	created := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	return []store.Item{
+		{
+			ID:    "1",
+			Title: "Attention Is All You Need",
+			URL:   "https://arxiv.org/abs/1706.03762",
+			Structured: &store.StructuredData{
+				Type:        store.SchemaPaper,
+				Authors:     []string{"Vaswani, Ashish", "Shazeer, Noam"},
+				Year:        2017,
+				Published:   "2017/06/12",
+				ArXivID:     "1706.03762",
+				Description: "The Transformer & friends.",
+			},
+			Tags:      []string{"ml"},
+			CreatedAt: created,
+		},
+		{
+			ID:    "2",
+			Title: "Deep learning",
+			URL:   "https://doi.org/10.1038/nature14539",
+			Structured: &store.StructuredData{
+				Type:    store.SchemaPaper,
+				Authors: []string{"LeCun, Yann"},
+				Venue:   "Nature",
+				Year:    2015,
+				DOI:     "10.1038/nature14539",
+			},
+			CreatedAt: created,
+		},
+		{ID: "3", Title: "Go 1.23 is released", URL: "https://go.dev/blog/go1.23", CreatedAt: created},
+		{ID: "4", Title: "Go 1.23 is released", URL: "https://example.com/mirror", CreatedAt: created},
+	}
+}
+
+func TestExportBibTeX(t *testing.T) {
+	var buf bytes.Buffer
+	if err := ExportCitations(&buf, citationItems(), FormatBibTeX); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"@misc{vaswani2017attention,",
+		"  author = {Vaswani, Ashish and Shazeer, Noam},",
+		"  eprint = {1706.03762},",
+		"  abstract = {The Transformer \\& friends.},",
+		"@article{lecun2015deep,",
+		"  journal = {Nature},",
+		"  doi = {10.1038/nature14539},",
+		// Keys are unique even without authors
+		"@misc{2024releaseda,",
+		"@misc{2024releasedb,",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("bibtex missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestExportCSLJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := ExportCitations(&buf, citationItems(), FormatCSLJSON); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	var entries []cslItem
+	if err := json.Unmarshal(buf.Bytes(), &entries); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("got %d entries", len(entries))
+	}
+
+	preprint, article, page := entries[0], entries[1], entries[2]
+	if preprint.Type != "article" || preprint.Number != "arXiv:1706.03762" || preprint.Author[0] != (cslName{Family: "Vaswani", Given: "Ashish"}) {
+		t.Errorf("unexpected preprint %+v", preprint)
+	}
+	if got := preprint.Issued.DateParts[0]; len(got) != 2 || got[0] != 2017 || got[1] != 6 {
+		t.Errorf("unexpected issued %v", got)
+	}
+	if article.Type != "article-journal" || article.ContainerTitle != "Nature" || article.DOI != "10.1038/nature14539" {
+		t.Errorf("unexpected article %+v", article)
+	}
+	if page.Type != "webpage" || page.Issued != nil || page.Accessed == nil {
+		t.Errorf("unexpected web page %+v", page)
+	}
+}
+
+func TestExportCitationsUnknownFormat(t *testing.T) {
+	if err := ExportCitations(&bytes.Buffer{}, nil, "ris"); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -17,7 +17,7 @@ func NewObsidianExporter() *ObsidianExporter {
 	return &ObsidianExporter{}
 }
 
-func (e *ObsidianExporter) Export(vault *store.VaultStore) (io.Reader, error) {
+func (e *ObsidianExporter) Export(vault store.Vault) (io.Reader, error) {
 	items, relationships, err := vault.GetGraph()
 	if err != nil {
 		return nil, fmt.Errorf("get graph: %w", err)
@@ -42,8 +42,21 @@ func (e *ObsidianExporter) Export(vault *store.VaultStore) (io.Reader, error) {
 	zw := zip.NewWriter(buf)
 
 	for _, item := range items {
-		content := e.itemToMarkdown(item, relMap[item.ID], titleMap)
-		filename := fmt.Sprintf("notes/%s.md", sanitizeFilename(item.Title))
+		name := sanitizeFilename(item.Title)
+
+		// Page snapshots go next to the notes that link to them
+		var archive string
+		if data := readArchive(vault, item); data != nil {
+			archive = fmt.Sprintf("archives/%s.html", name)
+			f, err := zw.Create(archive)
+			if err != nil {
+				return nil, err
+			}
+			f.Write(data)
+		}
+
+		content := e.itemToMarkdown(item, relMap[item.ID], titleMap, archive)
+		filename := fmt.Sprintf("notes/%s.md", name)
 
 		f, err := zw.Create(filename)
 		if err != nil {
@@ -59,7 +72,24 @@ func (e *ObsidianExporter) Export(vault *store.VaultStore) (io.Reader, error) {
 	return buf, nil
 }
 
-func (e *ObsidianExporter) itemToMarkdown(item store.Item, relatedIDs []string, titleMap map[string]string) string {
+// readArchive returns an item's page snapshot, or nil if it has none or the
+// snapshot cannot be read.
+func readArchive(vault store.Vault, item store.Item) []byte {
+	if item.ArchiveHash == "" {
+		return nil
+	}
+	blob, err := vault.GetBlob(item.ArchiveHash)
+	if err != nil || blob == nil {
+		return nil
+	}
+	data, err := vault.ReadFile(blob.Path())
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+func (e *ObsidianExporter) itemToMarkdown(item store.Item, relatedIDs []string, titleMap map[string]string, archive string) string {
 	var sb strings.Builder
 
 	// YAML frontmatter
@@ -69,6 +99,20 @@ func (e *ObsidianExporter) itemToMarkdown(item store.Item, relatedIDs []string,
 	if item.URL != "" {
 		sb.WriteString(fmt.Sprintf("url: \"%s\"\n", item.URL))
 	}
+	if src := item.Source; src != nil {
+		if src.Name != "" {
+			sb.WriteString(fmt.Sprintf("source: %q\n", src.Name))
+		}
+		if src.URL != "" {
+			sb.WriteString(fmt.Sprintf("source_url: \"%s\"\n", src.URL))
+		}
+		if !src.Date.IsZero() {
+			sb.WriteString(fmt.Sprintf("source_date: %s\n", src.Date.Format(time.RFC3339)))
+		}
+	}
+	if item.Structured != nil && item.Structured.Type != "" {
+		sb.WriteString(fmt.Sprintf("schema: %s\n", item.Structured.Type))
+	}
 	sb.WriteString(fmt.Sprintf("created: %s\n", item.CreatedAt.Format(time.RFC3339)))
 	if len(item.Tags) > 0 {
 		sb.WriteString(fmt.Sprintf("tags: [%s]\n", strings.Join(item.Tags, ", ")))
@@ -83,10 +127,33 @@ func (e *ObsidianExporter) itemToMarkdown(item store.Item, relatedIDs []string,
 		sb.WriteString(fmt.Sprintf("> %s\n\n", item.Summary))
 	}
 
+	// Typed sections such as a recipe's ingredients or a product's price
+	if sections := item.Structured.Markdown(); sections != "" {
+		sb.WriteString(sections)
+		sb.WriteString("\n\n")
+	}
+
 	// Source link
 	if item.URL != "" {
 		sb.WriteString(fmt.Sprintf("**Source:** [%s](%s)\n\n", item.URL, item.URL))
 	}
+	if archive != "" {
+		sb.WriteString(fmt.Sprintf("**Archived copy:** [snapshot](<../%s>)\n\n", archive))
+	}
+
+	// Origin of a forwarded, feed or email message
+	if src := item.Source; src != nil {
+		label := sourceLabel(src.Kind)
+		name := src.Name
+		if name == "" {
+			name = src.URL
+		}
+		if src.URL != "" {
+			sb.WriteString(fmt.Sprintf("**%s:** [%s](%s)\n\n", label, name, src.URL))
+		} else if name != "" {
+			sb.WriteString(fmt.Sprintf("**%s:** %s\n\n", label, name))
+		}
+	}
 
 	// Content
 	if item.Content != "" {
@@ -108,6 +175,18 @@ func (e *ObsidianExporter) itemToMarkdown(item store.Item, relatedIDs []string,
 	return sb.String()
 }
 
+// sourceLabel names the origin of an item by how it was captured.
+func sourceLabel(kind store.SourceKind) string {
+	switch kind {
+	case store.SourceFeed:
+		return "From feed"
+	case store.SourceEmail:
+		return "Emailed by"
+	default:
+		return "Forwarded from"
+	}
+}
+
 func sanitizeFilename(s string) string {
 	// Replace invalid filename characters
 	replacer := strings.NewReplacer(
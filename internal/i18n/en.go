@@ -9,6 +9,8 @@ I help you capture and organize knowledge from the web.
 <b>How to use:</b>
 • Send me any link - I'll extract, summarize, and tag it
 • Send me text notes - I'll categorize them too
+• Send me voice messages - I'll transcribe them into notes
+• Send me documents (PDF, EPUB, DOCX, Markdown, text, HTML) - I'll read and summarize them
 • Use /search to find saved items
 • Use /recent to see your latest items
 • Use /tags to see all your tags
@@ -22,18 +24,32 @@ All your data is stored privately and can be exported to Obsidian.`,
 /tags - List all your tags
 /stats - Show vault statistics
 /export - Export to Obsidian format
+/reprocess [id|all] - Re-summarise uncategorized items, one item, or everything
+/dead - List saved links that no longer load
+/archive [id] - Keep a snapshot of a link's page
+/subscribe [url] - Save new entries of an RSS or Atom feed
+/unsubscribe [id] - Stop following a feed
+/feeds - List your feed subscriptions
+/email - Show your address for forwarding emails
 /app - Open Mini App (if configured)
 /lang - Change language (en/ru)
 
 <b>Saving content:</b>
-Just send me any URL or text message!`,
+Just send me any URL, text message, photo or document!`,
 
 	MsgUnknownCommand: "Unknown command. Use /help to see available commands.",
 
 	// Processing status
 	MsgProcessingLink:   "⏳ Processing link...",
+	MsgProcessingLinks:  "⏳ Processing links (%d)...",
+	MsgBatchStatus:      "<b>Saved %d of %d</b>",
+	MsgBatchNote:        "Note",
 	MsgProcessingNote:   "⏳ Processing note...",
 	MsgSavingImage:      "📷 Saving image...",
+	MsgSavingAlbum:      "📷 Saving album (%d photos)...",
+	MsgSavingDocument:   "📄 Saving document...",
+	MsgTranscribing:     "🎙 Transcribing...",
+	MsgQueuePosition:    "🕒 Queued: %d ahead of yours",
 	MsgSearching:        "🔍 Searching: <b>%s</b>...",
 	MsgSearchUsage:      "Usage: /search [query]\nExample: /search golang concurrency",
 	MsgRecentItems:      "📚 <b>Recent items:</b>\n\n",
@@ -44,28 +60,68 @@ Just send me any URL or text message!`,
 	MsgAppNotConfigured: "Mini App is not configured. Set WEBAPP_URL environment variable.",
 	MsgOpenMiniApp:      "Open the Mini App to browse, search, and visualize your knowledge:",
 	MsgExportComingSoon: "Export feature coming soon! Use the API endpoint /api/export for now.",
+	MsgReprocessing:     "🔄 Reprocessing...",
+	MsgReprocessQueued:  "🔄 Queued %d items for reprocessing.",
+	MsgArchiving:        "🗄 Archiving page...",
+	MsgArchiveUsage:     "Usage: /archive [id]\nUse /dead to find links worth archiving.",
+	MsgDeadLinks:        "⚠️ <b>Dead links:</b>\n\n",
+
+	// Link health
+	MsgDeadLink:         "⚠️ <b>A saved link no longer loads</b>\n\n<b>%s</b>\n%s",
+	MsgDeadLinkSnapshot: "The Internet Archive has a copy. Send /archive %s to keep it in your vault.",
+	MsgDeadLinkArchived: "Your saved snapshot is still available.",
+
+	// Feeds
+	MsgSubscribeUsage:    "Usage: /subscribe [url] [#tag ...] [keyword ...] [-keyword ...] [max=N]\nExample: /subscribe https://go.dev/blog/feed.atom #golang generics max=3\n\nKeywords keep only matching entries, -keywords drop them, max limits entries saved per day.",
+	MsgSubscribing:       "⏳ Looking for the feed...",
+	MsgSubscribed:        "✅ Subscribed to <b>%s</b>\nNew entries will be saved as they appear.",
+	MsgAlreadySubscribed: "You're already subscribed to this feed.",
+	MsgNotAFeed:          "No RSS or Atom feed found at this address.",
+	MsgUnsubscribeUsage:  "Usage: /unsubscribe [id|url]\nUse /feeds to see your subscriptions.",
+	MsgUnsubscribed:      "✅ Unsubscribed from <b>%s</b>",
+	MsgFeedNotFound:      "Subscription not found.",
+	MsgYourFeeds:         "📰 <b>Your feeds:</b>\n\n",
+	MsgNoFeeds:           "No feed subscriptions yet. Use /subscribe to add one.",
+	MsgFeedKeywords:      "Keywords: %s",
+	MsgFeedLimit:         "At most %d per day",
+	MsgFeedError:         "⚠️ Last poll failed: %s",
+	MsgEmailAddress:      "📧 Forward or send emails to <code>%s</code> to save them.\n\nKeep this address secret: anyone who knows it can add to your vault. Use /email reset to replace it.",
+	MsgEmailReset:        "✅ Your old address no longer works. New address: <code>%s</code>",
+	MsgEmailDisabled:     "Email capture isn't enabled on this server.",
 
 	// Success messages
-	MsgSaved:      "✅ <b>Saved!</b>",
-	MsgImageSaved: "✅ <b>Image saved!</b>",
+	MsgSaved:        "✅ <b>Saved!</b>",
+	MsgImageSaved:   "✅ <b>Image saved!</b>",
+	MsgArchiveSaved: "🗄 <b>Snapshot saved!</b>\n\n<b>%s</b>",
 
 	// Empty states
-	MsgNoResults: "No results found.",
-	MsgNoItems:   "No items saved yet. Send me a link or note to get started!",
-	MsgNoTags:    "No tags yet.",
-	MsgSearchFor: `🔍 <b>Results for "%s":</b>`,
+	MsgNoResults:    "No results found.",
+	MsgNoItems:      "No items saved yet. Send me a link or note to get started!",
+	MsgNoTags:       "No tags yet.",
+	MsgNoReprocess:  "Nothing to reprocess.",
+	MsgItemNotFound: "Item not found.",
+	MsgNoDeadLinks:  "No dead links found.",
+	MsgNoSnapshot:   "No archived copy of this link was found.",
+	MsgSearchFor:    `🔍 <b>Results for "%s":</b>`,
 
 	// Errors
-	MsgFailedProcess:   "❌ Failed to process: %v",
-	MsgFailedVault:     "❌ Failed to access your vault",
-	MsgFailedSearch:    "❌ Search failed: %v",
-	MsgFailedListItems: "❌ Failed to list items: %v",
-	MsgFailedGetTags:   "❌ Failed to get tags: %v",
-	MsgFailedGetStats:  "❌ Failed to get stats: %v",
-	MsgFailedFileInfo:  "❌ Failed to get file info: %v",
-	MsgFailedDownload:  "❌ Failed to download image: %v",
-	MsgFailedReadImage: "❌ Failed to read image: %v",
-	MsgFailedSaveImage: "❌ Failed to save image: %v",
+	MsgFailedProcess:       "❌ Failed to process: %v",
+	MsgFailedVault:         "❌ Failed to access your vault",
+	MsgFailedSearch:        "❌ Search failed: %v",
+	MsgFailedListItems:     "❌ Failed to list items: %v",
+	MsgFailedGetTags:       "❌ Failed to get tags: %v",
+	MsgFailedGetStats:      "❌ Failed to get stats: %v",
+	MsgFailedFileInfo:      "❌ Failed to get file info: %v",
+	MsgFailedDownload:      "❌ Failed to download file: %v",
+	MsgFailedReadFile:      "❌ Failed to read file: %v",
+	MsgFileTooLarge:        "❌ File is too large (max %d MB)",
+	MsgUnsupportedDocument: "This file type isn't supported. Send PDF, EPUB, DOCX, Markdown, plain text or HTML.",
+	MsgVoiceDisabled:       "Voice transcription isn't enabled on this server.",
+	MsgFailedSaveImage:     "❌ Failed to save image: %v",
+	MsgFailedReprocess:     "❌ Failed to queue reprocessing: %v",
+	MsgFailedArchive:       "❌ Failed to archive page: %v",
+	MsgFailedSubscribe:     "❌ Failed to subscribe: %v",
+	MsgFailedEmail:         "❌ Failed to get your email address: %v",
 
 	// Language
 	MsgLangCurrent: "🌐 Current language: <b>English</b>\n\nUse /lang ru to switch to Russian.",
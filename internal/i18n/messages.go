@@ -13,7 +13,14 @@ const (
 	// Processing status
 	MsgProcessingLink  MsgKey = "processing_link"
 	MsgProcessingNote  MsgKey = "processing_note"
+	MsgProcessingLinks MsgKey = "processing_links"
+	MsgBatchStatus     MsgKey = "batch_status"
+	MsgBatchNote       MsgKey = "batch_note"
 	MsgSavingImage     MsgKey = "saving_image"
+	MsgSavingAlbum     MsgKey = "saving_album"
+	MsgSavingDocument  MsgKey = "saving_document"
+	MsgTranscribing    MsgKey = "transcribing"
+	MsgQueuePosition   MsgKey = "queue_position"
 	MsgSearching       MsgKey = "searching"
 	MsgSearchUsage     MsgKey = "search_usage"
 	MsgRecentItems     MsgKey = "recent_items"
@@ -24,15 +31,48 @@ const (
 	MsgAppNotConfigured MsgKey = "app_not_configured"
 	MsgOpenMiniApp     MsgKey = "open_mini_app"
 	MsgExportComingSoon MsgKey = "export_coming_soon"
+	MsgReprocessing     MsgKey = "reprocessing"
+	MsgReprocessQueued  MsgKey = "reprocess_queued"
+	MsgArchiving        MsgKey = "archiving"
+	MsgArchiveUsage     MsgKey = "archive_usage"
+	MsgDeadLinks        MsgKey = "dead_links"
+
+	// Link health
+	MsgDeadLink         MsgKey = "dead_link"
+	MsgDeadLinkSnapshot MsgKey = "dead_link_snapshot"
+	MsgDeadLinkArchived MsgKey = "dead_link_archived"
+
+	// Feeds
+	MsgSubscribeUsage    MsgKey = "subscribe_usage"
+	MsgSubscribing       MsgKey = "subscribing"
+	MsgSubscribed        MsgKey = "subscribed"
+	MsgAlreadySubscribed MsgKey = "already_subscribed"
+	MsgNotAFeed          MsgKey = "not_a_feed"
+	MsgUnsubscribeUsage  MsgKey = "unsubscribe_usage"
+	MsgUnsubscribed      MsgKey = "unsubscribed"
+	MsgFeedNotFound      MsgKey = "feed_not_found"
+	MsgYourFeeds         MsgKey = "your_feeds"
+	MsgNoFeeds           MsgKey = "no_feeds"
+	MsgFeedKeywords      MsgKey = "feed_keywords"
+	MsgFeedLimit         MsgKey = "feed_limit"
+	MsgFeedError         MsgKey = "feed_error"
+	MsgEmailAddress      MsgKey = "email_address"
+	MsgEmailReset        MsgKey = "email_reset"
+	MsgEmailDisabled     MsgKey = "email_disabled"
 
 	// Success messages
 	MsgSaved      MsgKey = "saved"
 	MsgImageSaved MsgKey = "image_saved"
+	MsgArchiveSaved MsgKey = "archive_saved"
 
 	// Empty states
 	MsgNoResults  MsgKey = "no_results"
 	MsgNoItems    MsgKey = "no_items"
 	MsgNoTags     MsgKey = "no_tags"
+	MsgNoReprocess MsgKey = "no_reprocess"
+	MsgItemNotFound MsgKey = "item_not_found"
+	MsgNoDeadLinks MsgKey = "no_dead_links"
+	MsgNoSnapshot  MsgKey = "no_snapshot"
 	MsgSearchFor  MsgKey = "search_for"
 
 	// Errors
@@ -44,8 +84,15 @@ const (
 	MsgFailedGetStats  MsgKey = "failed_get_stats"
 	MsgFailedFileInfo  MsgKey = "failed_file_info"
 	MsgFailedDownload  MsgKey = "failed_download"
-	MsgFailedReadImage MsgKey = "failed_read_image"
+	MsgFailedReadFile  MsgKey = "failed_read_file"
+	MsgFileTooLarge    MsgKey = "file_too_large"
+	MsgUnsupportedDocument MsgKey = "unsupported_document"
+	MsgVoiceDisabled   MsgKey = "voice_disabled"
 	MsgFailedSaveImage MsgKey = "failed_save_image"
+	MsgFailedReprocess MsgKey = "failed_reprocess"
+	MsgFailedArchive   MsgKey = "failed_archive"
+	MsgFailedSubscribe MsgKey = "failed_subscribe"
+	MsgFailedEmail     MsgKey = "failed_email"
 
 	// Language
 	MsgLangCurrent MsgKey = "lang_current"
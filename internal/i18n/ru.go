@@ -9,6 +9,8 @@ var ru = map[MsgKey]string{
 <b>Как использовать:</b>
 • Отправьте мне любую ссылку - я извлеку контент, создам резюме и теги
 • Отправьте текстовые заметки - я тоже их категоризирую
+• Отправьте голосовые сообщения - я расшифрую их в заметки
+• Отправьте документы (PDF, EPUB, DOCX, Markdown, текст, HTML) - я прочитаю их и создам резюме
 • Используйте /search для поиска сохранённых записей
 • Используйте /recent для просмотра последних записей
 • Используйте /tags для просмотра всех тегов
@@ -22,18 +24,32 @@ var ru = map[MsgKey]string{
 /tags - Список всех тегов
 /stats - Статистика хранилища
 /export - Экспорт в формат Obsidian
+/reprocess [id|all] - Заново обработать записи без категории, одну запись или все
+/dead - Сохранённые ссылки, которые больше не открываются
+/archive [id] - Сохранить снимок страницы по ссылке
+/subscribe [url] - Сохранять новые записи RSS- или Atom-ленты
+/unsubscribe [id] - Отписаться от ленты
+/feeds - Список подписок на ленты
+/email - Адрес для пересылки писем
 /app - Открыть Mini App (если настроен)
 /lang - Сменить язык (en/ru)
 
 <b>Сохранение контента:</b>
-Просто отправьте мне любую ссылку или текстовое сообщение!`,
+Просто отправьте мне любую ссылку, текстовое сообщение, фото или документ!`,
 
 	MsgUnknownCommand: "Неизвестная команда. Используйте /help для просмотра доступных команд.",
 
 	// Processing status
 	MsgProcessingLink:   "⏳ Обрабатываю ссылку...",
+	MsgProcessingLinks:  "⏳ Обрабатываю ссылки (%d)...",
+	MsgBatchStatus:      "<b>Сохранено %d из %d</b>",
+	MsgBatchNote:        "Заметка",
 	MsgProcessingNote:   "⏳ Обрабатываю заметку...",
 	MsgSavingImage:      "📷 Сохраняю изображение...",
+	MsgSavingAlbum:      "📷 Сохраняю альбом (фото: %d)...",
+	MsgSavingDocument:   "📄 Сохраняю документ...",
+	MsgTranscribing:     "🎙 Расшифровываю...",
+	MsgQueuePosition:    "🕒 В очереди перед вами: %d",
 	MsgSearching:        "🔍 Ищу: <b>%s</b>...",
 	MsgSearchUsage:      "Использование: /search [запрос]\nПример: /search golang concurrency",
 	MsgRecentItems:      "📚 <b>Последние записи:</b>\n\n",
@@ -44,28 +60,68 @@ var ru = map[MsgKey]string{
 	MsgAppNotConfigured: "Mini App не настроен. Установите переменную окружения WEBAPP_URL.",
 	MsgOpenMiniApp:      "Откройте Mini App для просмотра, поиска и визуализации ваших знаний:",
 	MsgExportComingSoon: "Функция экспорта скоро появится! Пока используйте API /api/export.",
+	MsgReprocessing:     "🔄 Обрабатываю заново...",
+	MsgReprocessQueued:  "🔄 Поставлено в очередь на повторную обработку: %d",
+	MsgArchiving:        "🗄 Архивирую страницу...",
+	MsgArchiveUsage:     "Использование: /archive [id]\nИспользуйте /dead, чтобы найти ссылки для архивации.",
+	MsgDeadLinks:        "⚠️ <b>Недоступные ссылки:</b>\n\n",
+
+	// Link health
+	MsgDeadLink:         "⚠️ <b>Сохранённая ссылка больше не открывается</b>\n\n<b>%s</b>\n%s",
+	MsgDeadLinkSnapshot: "В Internet Archive есть копия. Отправьте /archive %s, чтобы сохранить её в хранилище.",
+	MsgDeadLinkArchived: "Сохранённый снимок страницы по-прежнему доступен.",
+
+	// Feeds
+	MsgSubscribeUsage:    "Использование: /subscribe [url] [#тег ...] [слово ...] [-слово ...] [max=N]\nПример: /subscribe https://go.dev/blog/feed.atom #golang generics max=3\n\nСлова оставляют только подходящие записи, -слова исключают их, max ограничивает число записей в день.",
+	MsgSubscribing:       "⏳ Ищу ленту...",
+	MsgSubscribed:        "✅ Подписка на <b>%s</b> оформлена\nНовые записи будут сохраняться по мере появления.",
+	MsgAlreadySubscribed: "Вы уже подписаны на эту ленту.",
+	MsgNotAFeed:          "По этому адресу не найдено RSS- или Atom-ленты.",
+	MsgUnsubscribeUsage:  "Использование: /unsubscribe [id|url]\nИспользуйте /feeds, чтобы увидеть подписки.",
+	MsgUnsubscribed:      "✅ Подписка на <b>%s</b> отменена",
+	MsgFeedNotFound:      "Подписка не найдена.",
+	MsgYourFeeds:         "📰 <b>Ваши ленты:</b>\n\n",
+	MsgNoFeeds:           "Подписок пока нет. Используйте /subscribe, чтобы добавить ленту.",
+	MsgFeedKeywords:      "Слова: %s",
+	MsgFeedLimit:         "Не больше %d в день",
+	MsgFeedError:         "⚠️ Последняя проверка не удалась: %s",
+	MsgEmailAddress:      "📧 Пересылайте или отправляйте письма на <code>%s</code>, чтобы сохранить их.\n\nДержите адрес в секрете: любой, кто его знает, может добавлять записи в ваше хранилище. Используйте /email reset, чтобы заменить его.",
+	MsgEmailReset:        "✅ Старый адрес больше не работает. Новый адрес: <code>%s</code>",
+	MsgEmailDisabled:     "Сохранение писем не включено на этом сервере.",
 
 	// Success messages
-	MsgSaved:      "✅ <b>Сохранено!</b>",
-	MsgImageSaved: "✅ <b>Изображение сохранено!</b>",
+	MsgSaved:        "✅ <b>Сохранено!</b>",
+	MsgImageSaved:   "✅ <b>Изображение сохранено!</b>",
+	MsgArchiveSaved: "🗄 <b>Снимок сохранён!</b>\n\n<b>%s</b>",
 
 	// Empty states
-	MsgNoResults: "Ничего не найдено.",
-	MsgNoItems:   "Пока нет сохранённых записей. Отправьте мне ссылку или заметку!",
-	MsgNoTags:    "Пока нет тегов.",
-	MsgSearchFor: `🔍 <b>Результаты по запросу "%s":</b>`,
+	MsgNoResults:    "Ничего не найдено.",
+	MsgNoItems:      "Пока нет сохранённых записей. Отправьте мне ссылку или заметку!",
+	MsgNoTags:       "Пока нет тегов.",
+	MsgNoReprocess:  "Нечего обрабатывать заново.",
+	MsgItemNotFound: "Запись не найдена.",
+	MsgNoDeadLinks:  "Недоступных ссылок не найдено.",
+	MsgNoSnapshot:   "Архивная копия этой ссылки не найдена.",
+	MsgSearchFor:    `🔍 <b>Результаты по запросу "%s":</b>`,
 
 	// Errors
-	MsgFailedProcess:   "❌ Ошибка обработки: %v",
-	MsgFailedVault:     "❌ Не удалось получить доступ к хранилищу",
-	MsgFailedSearch:    "❌ Ошибка поиска: %v",
-	MsgFailedListItems: "❌ Не удалось получить список записей: %v",
-	MsgFailedGetTags:   "❌ Не удалось получить теги: %v",
-	MsgFailedGetStats:  "❌ Не удалось получить статистику: %v",
-	MsgFailedFileInfo:  "❌ Не удалось получить информацию о файле: %v",
-	MsgFailedDownload:  "❌ Не удалось скачать изображение: %v",
-	MsgFailedReadImage: "❌ Не удалось прочитать изображение: %v",
-	MsgFailedSaveImage: "❌ Не удалось сохранить изображение: %v",
+	MsgFailedProcess:       "❌ Ошибка обработки: %v",
+	MsgFailedVault:         "❌ Не удалось получить доступ к хранилищу",
+	MsgFailedSearch:        "❌ Ошибка поиска: %v",
+	MsgFailedListItems:     "❌ Не удалось получить список записей: %v",
+	MsgFailedGetTags:       "❌ Не удалось получить теги: %v",
+	MsgFailedGetStats:      "❌ Не удалось получить статистику: %v",
+	MsgFailedFileInfo:      "❌ Не удалось получить информацию о файле: %v",
+	MsgFailedDownload:      "❌ Не удалось скачать файл: %v",
+	MsgFailedReadFile:      "❌ Не удалось прочитать файл: %v",
+	MsgFileTooLarge:        "❌ Файл слишком большой (максимум %d МБ)",
+	MsgUnsupportedDocument: "Этот тип файлов не поддерживается. Отправьте PDF, EPUB, DOCX, Markdown, текст или HTML.",
+	MsgVoiceDisabled:       "Расшифровка голосовых сообщений не включена на этом сервере.",
+	MsgFailedSaveImage:     "❌ Не удалось сохранить изображение: %v",
+	MsgFailedReprocess:     "❌ Не удалось поставить в очередь: %v",
+	MsgFailedArchive:       "❌ Не удалось архивировать страницу: %v",
+	MsgFailedSubscribe:     "❌ Не удалось подписаться: %v",
+	MsgFailedEmail:         "❌ Не удалось получить адрес для писем: %v",
 
 	// Language
 	MsgLangCurrent: "🌐 Текущий язык: <b>Русский</b>\n\nИспользуйте /lang en для переключения на английский.",
@@ -0,0 +1,65 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/nerdneilsfield/dumper/internal/llm"
+	"github.com/nerdneilsfield/dumper/internal/store"
+)
+
+func TestProcessAlbum(t *testing.T) {
+	manager, err := store.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = manager.Close()
+	})
+
+	var photos []AlbumPhoto
+	for i := range 3 {
+		img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+		img.Set(0, 0, color.RGBA{R: uint8(i), A: 255})
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			t.Fatalf("encode png: %v", err)
+		}
+		photos = append(photos, AlbumPhoto{Data: buf.Bytes(), Ext: "png"})
+	}
+
+	// Without a vision model or caption no LLM request is made
+	pipeline := NewPipeline(llm.NewClient("key", "model", llm.Options{}), nil, nil, manager)
+	item, err := pipeline.Process(context.Background(), RawContent{Type: ContentTypeAlbum, UserID: 1, Album: photos})
+	if err != nil {
+		t.Fatalf("process album: %v", err)
+	}
+
+	vault, err := manager.GetVault(1)
+	if err != nil {
+		t.Fatalf("failed to get vault: %v", err)
+	}
+	if count, _ := vault.ItemCount(); count != 3 {
+		t.Fatalf("expected album item and 2 children, got %d items", count)
+	}
+	rels, err := vault.GetRelationships(item.ID)
+	if err != nil {
+		t.Fatalf("get relationships: %v", err)
+	}
+	if len(rels) != 2 {
+		t.Fatalf("expected 2 album links, got %+v", rels)
+	}
+	for _, rel := range rels {
+		child, err := vault.GetItem(rel.TargetID)
+		if err != nil || child == nil {
+			t.Fatalf("get child: %v", err)
+		}
+		if rel.RelationType != "album" || child.ImageHash == item.ImageHash || child.Title != "Image (2/3)" && child.Title != "Image (3/3)" {
+			t.Fatalf("unexpected child %+v via %+v", child, rel)
+		}
+	}
+}
@@ -0,0 +1,380 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nerdneilsfield/dumper/internal/store"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// maxArchiveResource caps each stylesheet, image or font inlined into a
+// snapshot. Larger resources keep their original URL.
+const maxArchiveResource = 2 << 20
+
+// ErrNoSnapshot is returned when an item has no page that can be archived.
+var ErrNoSnapshot = errors.New("no page to archive")
+
+// fetchTypedFunc downloads a URL, returning its body and Content-Type.
+type fetchTypedFunc func(ctx context.Context, url string) ([]byte, string, error)
+
+// Archive turns a downloaded page into a self-contained HTML snapshot:
+// stylesheets and images are inlined, scripts and frames removed and links
+// made absolute, so the copy renders without the original site.
+func (e *Extractor) Archive(ctx context.Context, page []byte, pageURL string) ([]byte, error) {
+	return archivePage(ctx, e.fetch, page, pageURL)
+}
+
+// AttachArchive stores a snapshot of a link item's page and attaches it to
+// the item. Dead links are archived from their Wayback Machine copy.
+func (p *Pipeline) AttachArchive(ctx context.Context, userID int64, itemID string) (*store.Item, error) {
+	vault, err := p.stores.GetVault(userID)
+	if err != nil {
+		return nil, fmt.Errorf("get vault: %w", err)
+	}
+	item, err := vault.GetItem(itemID)
+	if err != nil {
+		return nil, fmt.Errorf("get item: %w", err)
+	}
+	if item == nil {
+		return nil, fmt.Errorf("archive %s: %w", itemID, ErrItemNotFound)
+	}
+
+	pageURL := item.URL
+	if item.Link != nil && item.Link.Status == store.LinkDead {
+		if item.Link.WaybackURL == "" {
+			return nil, fmt.Errorf("archive %s: %w", itemID, ErrNoSnapshot)
+		}
+		pageURL = waybackFrameURL(item.Link.WaybackURL)
+	}
+	if pageURL == "" {
+		return nil, fmt.Errorf("archive %s: %w", itemID, ErrNoSnapshot)
+	}
+
+	page, contentType, err := p.extractor.fetch(ctx, pageURL)
+	if err != nil {
+		return nil, err
+	}
+	if mimeType := sniffContentType(contentType, page); mimeType != "text/html" && mimeType != "application/xhtml+xml" {
+		return nil, fmt.Errorf("archive %s: %w: %s", itemID, ErrNoSnapshot, mimeType)
+	}
+	snapshot, err := p.extractor.Archive(ctx, page, pageURL)
+	if err != nil {
+		return nil, err
+	}
+	blob, err := vault.PutFile(snapshot, "html", store.ArchiveMIME)
+	if err != nil {
+		return nil, fmt.Errorf("store archive: %w", err)
+	}
+	if err := vault.SetArchive(item.ID, blob.Hash); err != nil {
+		return nil, err
+	}
+	slog.Info("attached page archive", "id", item.ID, "url", pageURL, "hash", blob.Hash, "size", blob.Size)
+	return vault.GetItem(item.ID)
+}
+
+func archivePage(ctx context.Context, fetch fetchTypedFunc, page []byte, pageURL string) ([]byte, error) {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse url: %w", err)
+	}
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return nil, fmt.Errorf("parse html: %w", err)
+	}
+	if baseTag := findFirst(doc, byTag("base")); baseTag != nil {
+		if href, err := base.Parse(attr(baseTag, "href")); err == nil {
+			base = href
+		}
+	}
+
+	a := &archiver{
+		ctx:    ctx,
+		fetch:  fetch,
+		budget: store.MaxBlobSize - len(page),
+		cache:  make(map[string]string),
+	}
+	a.rewrite(doc, base)
+
+	// The snapshot is served from our origin, so declare its encoding and
+	// where it came from
+	if head := findFirst(doc, byTag("head")); head != nil {
+		for _, meta := range findAll(head, byTag("meta")) {
+			if attr(meta, "charset") != "" || strings.EqualFold(attr(meta, "http-equiv"), "content-type") {
+				meta.Parent.RemoveChild(meta)
+			}
+		}
+		head.InsertBefore(&html.Node{
+			Type:     html.ElementNode,
+			Data:     "meta",
+			DataAtom: atom.Meta,
+			Attr:     []html.Attribute{{Key: "charset", Val: "utf-8"}},
+		}, head.FirstChild)
+		head.InsertBefore(&html.Node{
+			Type: html.CommentNode,
+			Data: fmt.Sprintf(" Archived from %s on %s ", pageURL, time.Now().UTC().Format(time.RFC3339)),
+		}, head.FirstChild)
+	}
+
+	var buf bytes.Buffer
+	if err := html.Render(&buf, doc); err != nil {
+		return nil, fmt.Errorf("render snapshot: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// archiver inlines a page's resources within a size budget shared by the
+// whole snapshot.
+type archiver struct {
+	ctx    context.Context
+	fetch  fetchTypedFunc
+	budget int
+	cache  map[string]string // resource URL to data URI
+}
+
+// removedTags are elements that run code or load other pages.
+var removedTags = map[string]bool{
+	"script": true, "noscript": true, "iframe": true, "frame": true, "frameset": true,
+	"object": true, "embed": true, "applet": true, "base": true, "template": true,
+}
+
+func (a *archiver) rewrite(n *html.Node, base *url.URL) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.ElementNode && a.rewriteElement(c, base) {
+			n.RemoveChild(c)
+		} else {
+			a.rewrite(c, base)
+		}
+		c = next
+	}
+}
+
+// rewriteElement makes one element self-contained. It reports whether the
+// element should be removed.
+func (a *archiver) rewriteElement(n *html.Node, base *url.URL) bool {
+	if removedTags[n.Data] {
+		return true
+	}
+
+	// Event handlers and javascript: links would run on our origin
+	attrs := n.Attr[:0]
+	for _, at := range n.Attr {
+		if strings.HasPrefix(strings.ToLower(at.Key), "on") ||
+			strings.HasPrefix(strings.ToLower(strings.TrimSpace(at.Val)), "javascript:") {
+			continue
+		}
+		attrs = append(attrs, at)
+	}
+	n.Attr = attrs
+
+	switch n.Data {
+	case "meta":
+		// Refreshes would navigate away from the snapshot
+		return strings.EqualFold(attr(n, "http-equiv"), "refresh") ||
+			strings.EqualFold(attr(n, "http-equiv"), "content-security-policy")
+	case "link":
+		if !hasToken(attr(n, "rel"), "stylesheet") {
+			return true
+		}
+		css, cssURL, ok := a.stylesheet(base, attr(n, "href"))
+		if !ok {
+			setAttr(n, "href", resolveRef(base, attr(n, "href")))
+			return false
+		}
+		style := &html.Node{Type: html.ElementNode, Data: "style", DataAtom: atom.Style}
+		if media := attr(n, "media"); media != "" {
+			style.Attr = []html.Attribute{{Key: "media", Val: media}}
+		}
+		style.AppendChild(&html.Node{Type: html.TextNode, Data: a.rewriteCSS(css, cssURL)})
+		n.Parent.InsertBefore(style, n)
+		return true
+	case "style":
+		if n.FirstChild != nil && n.FirstChild.Type == html.TextNode {
+			n.FirstChild.Data = a.rewriteCSS(n.FirstChild.Data, base)
+		}
+	case "img":
+		// Lazy-loading pages keep the real image in a data attribute
+		src := attr(n, "src")
+		for _, key := range []string{"data-src", "data-lazy-src", "data-original"} {
+			if lazy := attr(n, key); lazy != "" && (src == "" || strings.HasPrefix(src, "data:")) {
+				src = lazy
+			}
+		}
+		delAttr(n, "srcset")
+		delAttr(n, "sizes")
+		delAttr(n, "loading")
+		setAttr(n, "src", a.inline(base, src, "image/"))
+	case "source":
+		if n.Parent != nil && n.Parent.Data == "picture" {
+			// The <img> fallback is inlined instead
+			return true
+		}
+		setAttr(n, "src", resolveRef(base, attr(n, "src")))
+	case "a", "area":
+		if href := attr(n, "href"); href != "" && !strings.HasPrefix(href, "#") {
+			setAttr(n, "href", resolveRef(base, href))
+		}
+	case "form":
+		setAttr(n, "action", resolveRef(base, attr(n, "action")))
+	case "video", "audio", "track":
+		setAttr(n, "src", resolveRef(base, attr(n, "src")))
+		setAttr(n, "poster", a.inline(base, attr(n, "poster"), "image/"))
+	}
+
+	if style := attr(n, "style"); style != "" {
+		setAttr(n, "style", a.rewriteCSS(style, base))
+	}
+	return false
+}
+
+// stylesheet downloads a linked stylesheet.
+func (a *archiver) stylesheet(base *url.URL, href string) (string, *url.URL, bool) {
+	ref, err := base.Parse(strings.TrimSpace(href))
+	if err != nil || (ref.Scheme != "http" && ref.Scheme != "https") {
+		return "", nil, false
+	}
+	body, _, ok := a.download(ref.String())
+	if !ok {
+		return "", nil, false
+	}
+	return string(body), ref, true
+}
+
+var cssURLPattern = regexp.MustCompile(`url\(\s*(['"]?)([^'")]*)['"]?\s*\)`)
+
+// cssImportPattern matches @import rules that name the stylesheet without url().
+var cssImportPattern = regexp.MustCompile(`@import\s+(['"])([^'"]+)['"]`)
+
+// rewriteCSS inlines the images and fonts a stylesheet references. Imports
+// are made absolute rather than followed.
+func (a *archiver) rewriteCSS(css string, base *url.URL) string {
+	css = cssImportPattern.ReplaceAllStringFunc(css, func(m string) string {
+		sub := cssImportPattern.FindStringSubmatch(m)
+		return "@import " + cssString(resolveRef(base, sub[2]))
+	})
+	return cssURLPattern.ReplaceAllStringFunc(css, func(m string) string {
+		ref := cssURLPattern.FindStringSubmatch(m)[2]
+		if ref == "" || strings.HasPrefix(ref, "data:") || strings.HasPrefix(ref, "#") {
+			return m
+		}
+		return "url(" + cssString(a.inline(base, ref, "image/", "font/", "application/font", "application/x-font", "application/vnd.ms-fontobject")) + ")"
+	})
+}
+
+// cssString quotes a URL for use in CSS.
+func cssString(s string) string {
+	return `"` + strings.NewReplacer(`"`, "%22", `\`, "%5C", "\n", "").Replace(s) + `"`
+}
+
+// inline returns a resource as a data URI when it is one of the accepted
+// types and fits the budget, and otherwise its absolute URL.
+func (a *archiver) inline(base *url.URL, ref string, types ...string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || strings.HasPrefix(ref, "data:") {
+		return ref
+	}
+	abs := resolveRef(base, ref)
+	if uri, ok := a.cache[abs]; ok {
+		return uri
+	}
+	if !strings.HasPrefix(abs, "http://") && !strings.HasPrefix(abs, "https://") {
+		return abs
+	}
+
+	body, mimeType, ok := a.download(abs)
+	if !ok || !hasAnyPrefix(mimeType, types) {
+		a.cache[abs] = abs
+		return abs
+	}
+	uri := "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(body)
+	if len(uri) > a.budget {
+		a.cache[abs] = abs
+		return abs
+	}
+	a.budget -= len(uri)
+	a.cache[abs] = uri
+	return uri
+}
+
+// download fetches a resource if it fits the per-resource limit.
+func (a *archiver) download(rawURL string) ([]byte, string, bool) {
+	if a.budget <= 0 {
+		return nil, "", false
+	}
+	body, contentType, err := a.fetch(a.ctx, rawURL)
+	if err != nil {
+		slog.Debug("archive resource failed", "url", rawURL, "error", err)
+		return nil, "", false
+	}
+	if len(body) > maxArchiveResource {
+		return nil, "", false
+	}
+	return body, sniffContentType(contentType, body), true
+}
+
+// resolveRef makes a reference absolute, leaving it unchanged if it does not
+// parse.
+func resolveRef(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	u, err := base.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return u.String()
+}
+
+func hasToken(list, token string) bool {
+	for _, t := range strings.Fields(list) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// setAttr sets an attribute, removing it when val is empty.
+func setAttr(n *html.Node, key, val string) {
+	if val == "" {
+		delAttr(n, key)
+		return
+	}
+	for i, at := range n.Attr {
+		if at.Key == key {
+			n.Attr[i].Val = val
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: val})
+}
+
+func delAttr(n *html.Node, key string) {
+	attrs := n.Attr[:0]
+	for _, at := range n.Attr {
+		if at.Key != key {
+			attrs = append(attrs, at)
+		}
+	}
+	n.Attr = attrs
+}
@@ -0,0 +1,69 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestArchivePage(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	resources := map[string]struct {
+		body, contentType string
+	}{
+		"https://example.com/css/site.css": {"body { background: url(../img/bg.png) } @import 'print.css';", "text/css"},
+		"https://example.com/img/bg.png":   {string(png), "image/png"},
+		"https://example.com/logo.png":     {string(png), ""},
+		"https://example.com/lazy.png":     {string(png), "image/png"},
+	}
+	fetch := func(_ context.Context, u string) ([]byte, string, error) {
+		r, ok := resources[u]
+		if !ok {
+			return nil, "", fmt.Errorf("unexpected fetch of %s", u)
+		}
+		return []byte(r.body), r.contentType, nil
+	}
+
+	page := `<!DOCTYPE html><html><head>
+<meta charset="iso-8859-1">
+<meta http-equiv="refresh" content="0; url=/elsewhere">
+<link rel="stylesheet" href="/css/site.css">
+<link rel="preload" href="/font.woff2">
+<script src="/app.js"></script>
+</head><body onload="track()">
+<img src="logo.png" srcset="logo@2x.png 2x">
+<img src="data:image/gif;base64,R0lGOD" data-src="/lazy.png">
+<a href="/about">About</a> <a href="javascript:alert(1)">Bad</a> <a href="#top">Top</a>
+<iframe src="https://ads.example.net"></iframe>
+<pre><code>fmt.Println("kept")</code></pre>
+</body></html>`
+
+	snapshot, err := archivePage(context.Background(), fetch, []byte(page), "https://example.com/posts/1")
+	if err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	out := string(snapshot)
+
+	for _, want := range []string{
+		`<meta charset="utf-8"/>`,
+		"Archived from https://example.com/posts/1",
+		`<style>body { background: url("data:image/png;base64,`,
+		`@import "https://example.com/css/print.css"`,
+		`<img src="data:image/png;base64,`,
+		`href="https://example.com/about"`,
+		`href="#top"`,
+		`fmt.Println(&#34;kept&#34;)`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("snapshot missing %q:\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{
+		"<script", "<iframe", "onload", "javascript:", "srcset", "refresh", "iso-8859-1", "preload", "R0lGOD",
+	} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("snapshot should not contain %q:\n%s", unwanted, out)
+		}
+	}
+}
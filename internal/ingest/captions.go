@@ -0,0 +1,151 @@
+package ingest
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+var (
+	captionTagPattern   = regexp.MustCompile(`<[^>]*>`)
+	captionBlockPattern = regexp.MustCompile(`\r?\n\s*\r?\n`)
+)
+
+// parseCaptions returns the spoken text of a caption track in WebVTT, SRT
+// or YouTube timedtext XML, or of a plain text transcript, one line per cue. Cues repeated by rolling
+// captions are kept once.
+func parseCaptions(data []byte) (string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	trimmed := bytes.TrimSpace(data)
+
+	var lines []string
+	if bytes.HasPrefix(trimmed, []byte("<")) {
+		var err error
+		if lines, err = timedTextLines(trimmed); err != nil {
+			return "", err
+		}
+	} else if bytes.Contains(trimmed, []byte("-->")) || bytes.HasPrefix(trimmed, []byte("WEBVTT")) {
+		lines = cueLines(string(trimmed))
+	} else {
+		// A plain text transcript
+		lines = strings.Split(string(trimmed), "\n")
+	}
+
+	var out []string
+	for _, line := range lines {
+		line = strings.Join(strings.Fields(html.UnescapeString(line)), " ")
+		if line == "" || len(out) > 0 && out[len(out)-1] == line {
+			continue
+		}
+		out = append(out, line)
+	}
+	if len(out) == 0 {
+		return "", fmt.Errorf("caption track has no text")
+	}
+	return strings.Join(out, "\n"), nil
+}
+
+// cueLines reads the text lines of WebVTT and SRT cues, skipping headers,
+// cue numbers, timings and notes.
+func cueLines(text string) []string {
+	var lines []string
+	for _, block := range captionBlockPattern.Split(text, -1) {
+		blockLines := strings.Split(strings.ReplaceAll(block, "\r\n", "\n"), "\n")
+		first := strings.TrimSpace(blockLines[0])
+		if strings.HasPrefix(first, "WEBVTT") || strings.HasPrefix(first, "NOTE") ||
+			strings.HasPrefix(first, "STYLE") || strings.HasPrefix(first, "REGION") {
+			continue
+		}
+		inCue := false
+		for _, line := range blockLines {
+			if strings.Contains(line, "-->") {
+				inCue = true
+				continue
+			}
+			if inCue {
+				lines = append(lines, captionTagPattern.ReplaceAllString(line, ""))
+			}
+		}
+	}
+	return lines
+}
+
+// timedTextLines reads YouTube timedtext, where each <text> (format 1) or
+// <p> (format 3) element is a cue.
+func timedTextLines(data []byte) ([]string, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	dec.Entity = xml.HTMLEntity
+
+	var lines []string
+	var current strings.Builder
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse timedtext: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "text" || t.Name.Local == "p" {
+				depth++
+			}
+		case xml.EndElement:
+			if (t.Name.Local == "text" || t.Name.Local == "p") && depth > 0 {
+				depth--
+				if depth == 0 {
+					lines = append(lines, current.String())
+					current.Reset()
+				}
+			}
+		case xml.CharData:
+			if depth > 0 {
+				current.Write(t)
+			}
+		}
+	}
+	return lines, nil
+}
+
+var isoDurationPattern = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// parseISODuration reads an ISO 8601 duration such as PT1H2M3S.
+func parseISODuration(s string) (time.Duration, bool) {
+	m := isoDurationPattern.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(s)))
+	if m == nil || s == "P" || s == "PT" {
+		return 0, false
+	}
+	var d time.Duration
+	units := []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second}
+	for i, unit := range units {
+		if m[i+1] == "" {
+			continue
+		}
+		n, err := strconv.ParseFloat(m[i+1], 64)
+		if err != nil {
+			return 0, false
+		}
+		d += time.Duration(n * float64(unit))
+	}
+	return d, true
+}
+
+// formatDuration renders a media length as 1:02:03 or 4:13.
+func formatDuration(d time.Duration) string {
+	total := int(d.Round(time.Second) / time.Second)
+	h, m, s := total/3600, total/60%60, total%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
@@ -0,0 +1,219 @@
+package ingest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/go-shiori/go-readability"
+	"golang.org/x/net/html"
+)
+
+// ParsedDocument is the text and metadata a DocumentParser extracts.
+type ParsedDocument struct {
+	Title string   // from the document itself, empty if unknown
+	Text  string   // plain text, paragraphs separated by blank lines
+	Tags  []string // explicit tags written in the document
+	// Note marks text the user wrote themselves, such as Markdown. It is kept
+	// as the item content so wikilinks build graph edges, like a sent note.
+	Note bool
+}
+
+// DocumentParser extracts text from one file format.
+type DocumentParser func(data []byte) (*ParsedDocument, error)
+
+type documentFormat struct {
+	mime  string
+	ext   string // stored file extension, without the dot
+	parse DocumentParser
+}
+
+var (
+	documentFormatsMu sync.RWMutex
+	documentsByMIME   = make(map[string]documentFormat)
+	documentsByExt    = make(map[string]documentFormat)
+)
+
+// RegisterDocumentParser makes parse handle files of mimeType and the given
+// extensions (with leading dot). Later registrations replace earlier ones.
+func RegisterDocumentParser(mimeType string, exts []string, parse DocumentParser) {
+	documentFormatsMu.Lock()
+	defer documentFormatsMu.Unlock()
+	format := documentFormat{mime: mimeType, parse: parse}
+	if len(exts) > 0 {
+		format.ext = strings.TrimPrefix(strings.ToLower(exts[0]), ".")
+	}
+	documentsByMIME[mimeType] = format
+	for _, ext := range exts {
+		documentsByExt[strings.ToLower(ext)] = format
+	}
+}
+
+// lookupDocumentParser finds the parser for a file. The extension wins over
+// the reported MIME type, which senders often get wrong for text formats;
+// the sniffed type is the last resort. It returns the canonical MIME type.
+func lookupDocumentParser(mimeType, name string, data []byte) (string, DocumentParser, bool) {
+	documentFormatsMu.RLock()
+	defer documentFormatsMu.RUnlock()
+	if format, ok := documentsByExt[strings.ToLower(path.Ext(name))]; ok {
+		return format.mime, format.parse, true
+	}
+	if format, ok := documentsByMIME[sniffContentType(mimeType, data)]; ok {
+		return format.mime, format.parse, true
+	}
+	return "", nil, false
+}
+
+// SupportsDocument reports whether a file can be parsed, judging by its name
+// and reported media type only, before it is downloaded.
+func SupportsDocument(mimeType, name string) bool {
+	documentFormatsMu.RLock()
+	defer documentFormatsMu.RUnlock()
+	if _, ok := documentsByExt[strings.ToLower(path.Ext(name))]; ok {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(mimeType)
+	_, ok := documentsByMIME[strings.ToLower(mediaType)]
+	return ok
+}
+
+// documentExt returns the file extension for a document media type.
+func documentExt(mimeType string) string {
+	documentFormatsMu.RLock()
+	defer documentFormatsMu.RUnlock()
+	format, ok := documentsByMIME[mimeType]
+	if ok && format.ext != "" {
+		return format.ext
+	}
+	if exts, _ := mime.ExtensionsByType(mimeType); len(exts) > 0 {
+		return exts[0]
+	}
+	return "bin"
+}
+
+func init() {
+	RegisterDocumentParser("application/pdf", []string{".pdf"}, parsePDF)
+	RegisterDocumentParser("text/markdown", []string{".md", ".markdown"}, parseMarkdown)
+	RegisterDocumentParser("text/plain", []string{".txt", ".text"}, parsePlainText)
+	RegisterDocumentParser("text/html", []string{".html", ".htm"}, parseHTML)
+	RegisterDocumentParser("application/epub+zip", []string{".epub"}, parseEPUB)
+	RegisterDocumentParser("application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		[]string{".docx"}, parseDOCX)
+}
+
+func parseMarkdown(data []byte) (*ParsedDocument, error) {
+	text, err := decodeText(data)
+	if err != nil {
+		return nil, err
+	}
+	return &ParsedDocument{
+		Title: extractTitleFromNote(text),
+		Text:  text,
+		Tags:  extractHashTags(text),
+		Note:  true,
+	}, nil
+}
+
+func parsePlainText(data []byte) (*ParsedDocument, error) {
+	text, err := decodeText(data)
+	if err != nil {
+		return nil, err
+	}
+	return &ParsedDocument{Text: text, Note: true}, nil
+}
+
+// decodeText validates UTF-8 text and strips a byte order mark.
+func decodeText(data []byte) (string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		return "", fmt.Errorf("text is not valid UTF-8")
+	}
+	text := strings.TrimSpace(strings.ReplaceAll(string(data), "\r\n", "\n"))
+	if text == "" {
+		return "", fmt.Errorf("document is empty")
+	}
+	return text, nil
+}
+
+// parseHTML reads a saved web page, preferring the readable article and
+// falling back to all visible text.
+func parseHTML(data []byte) (*ParsedDocument, error) {
+	if article, err := readability.FromReader(bytes.NewReader(data), &url.URL{}); err == nil &&
+		strings.TrimSpace(article.TextContent) != "" {
+		return &ParsedDocument{Title: article.Title, Text: strings.TrimSpace(article.TextContent)}, nil
+	}
+	title, text, err := htmlText(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if text == "" {
+		return nil, fmt.Errorf("document is empty")
+	}
+	return &ParsedDocument{Title: title, Text: text}, nil
+}
+
+// blockElements end a paragraph in htmlText.
+var blockElements = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true, "section": true, "article": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"blockquote": true, "pre": true, "table": true, "ul": true, "ol": true,
+}
+
+// htmlText returns the <title> and the visible text of an HTML document,
+// one paragraph per block element.
+func htmlText(r io.Reader) (title, text string, err error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return "", "", fmt.Errorf("parse html: %w", err)
+	}
+	title, text = nodeText(doc)
+	return title, text, nil
+}
+
+// nodeText returns the <title> and the visible text under n, one paragraph
+// per block element.
+func nodeText(n *html.Node) (title, text string) {
+	var paragraphs []string
+	var current strings.Builder
+	endParagraph := func() {
+		if p := strings.Join(strings.Fields(current.String()), " "); p != "" {
+			paragraphs = append(paragraphs, p)
+		}
+		current.Reset()
+	}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "script", "style", "noscript", "template":
+				return
+			case "title":
+				if title == "" && n.FirstChild != nil {
+					title = strings.TrimSpace(n.FirstChild.Data)
+				}
+				return
+			}
+		}
+		if n.Type == html.TextNode {
+			current.WriteString(n.Data)
+			current.WriteByte(' ')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if n.Type == html.ElementNode && blockElements[n.Data] {
+			endParagraph()
+		}
+	}
+	walk(n)
+	endParagraph()
+
+	return title, strings.Join(paragraphs, "\n\n")
+}
@@ -0,0 +1,151 @@
+package ingest
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// buildZip writes files into a zip archive in the given order.
+func buildZip(t *testing.T, files ...[2]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.Create(f[0])
+		if err != nil {
+			t.Fatalf("create %s: %v", f[0], err)
+		}
+		w.Write([]byte(f[1]))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("close zip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestLookupDocumentParser(t *testing.T) {
+	cases := []struct {
+		mime, name string
+		data       []byte
+		want       string
+	}{
+		{"application/octet-stream", "notes.md", []byte("# Notes"), "text/markdown"},
+		{"text/plain", "README.markdown", []byte("hi"), "text/markdown"},
+		{"", "paper", buildPDF("", "x"), "application/pdf"},
+		{"application/zip", "book.EPUB", nil, "application/epub+zip"},
+		{"text/plain; charset=utf-8", "", []byte("hi"), "text/plain"},
+	}
+	for _, tc := range cases {
+		got, _, ok := lookupDocumentParser(tc.mime, tc.name, tc.data)
+		if !ok || got != tc.want {
+			t.Fatalf("lookup(%q, %q) = %q, %v; want %q", tc.mime, tc.name, got, ok, tc.want)
+		}
+	}
+	if _, _, ok := lookupDocumentParser("application/zip", "archive.zip", []byte("PK")); ok {
+		t.Fatal("expected no parser for plain zip")
+	}
+}
+
+func TestSupportsDocument(t *testing.T) {
+	if !SupportsDocument("", "Thesis.DOCX") || !SupportsDocument("application/epub+zip", "book") {
+		t.Fatal("expected supported document")
+	}
+	if SupportsDocument("application/zip", "photos.zip") || SupportsDocument("", "") {
+		t.Fatal("expected unsupported document")
+	}
+}
+
+func TestParseMarkdown(t *testing.T) {
+	doc, err := parseMarkdown([]byte("\xef\xbb\xbf# Reading list\r\n\r\nSee [[Go Notes]] #books #to-read\r\n"))
+	if err != nil {
+		t.Fatalf("parse markdown: %v", err)
+	}
+	if doc.Title != "Reading list" || !doc.Note {
+		t.Fatalf("unexpected document: %+v", doc)
+	}
+	if want := []string{"books", "to-read"}; !reflect.DeepEqual(doc.Tags, want) {
+		t.Fatalf("tags: got %v want %v", doc.Tags, want)
+	}
+	if got := extractWikiLinkTargets(doc.Text); !reflect.DeepEqual(got, []string{"go notes"}) {
+		t.Fatalf("wikilinks lost: %v", got)
+	}
+
+	if _, err := parseMarkdown([]byte("\xff\xfe")); err == nil {
+		t.Fatal("expected error for invalid UTF-8")
+	}
+}
+
+func TestParseHTML(t *testing.T) {
+	page := `<html><head><title>Saved page</title><style>p{}</style></head>
+<body><h1>Heading</h1><p>First <b>bold</b> paragraph.</p><script>x()</script><p>Second.</p></body></html>`
+	title, text, err := htmlText(bytes.NewReader([]byte(page)))
+	if err != nil {
+		t.Fatalf("html text: %v", err)
+	}
+	if title != "Saved page" || text != "Heading\n\nFirst bold paragraph.\n\nSecond." {
+		t.Fatalf("unexpected html text: %q / %q", title, text)
+	}
+
+	doc, err := parseHTML([]byte(page))
+	if err != nil {
+		t.Fatalf("parse html: %v", err)
+	}
+	if doc.Title != "Saved page" || doc.Text == "" {
+		t.Fatalf("unexpected document: %+v", doc)
+	}
+}
+
+func TestParseDOCX(t *testing.T) {
+	data := buildZip(t,
+		[2]string{"word/document.xml", `<?xml version="1.0"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+<w:p><w:r><w:t>Quarterly</w:t></w:r><w:r><w:t xml:space="preserve"> report</w:t></w:r></w:p>
+<w:p></w:p>
+<w:p><w:r><w:t>Revenue</w:t><w:tab/><w:t>up</w:t></w:r></w:p>
+</w:body></w:document>`},
+		[2]string{"docProps/core.xml", `<?xml version="1.0"?>
+<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>Q3 Report</dc:title></cp:coreProperties>`},
+	)
+
+	doc, err := parseDOCX(data)
+	if err != nil {
+		t.Fatalf("parse docx: %v", err)
+	}
+	if doc.Title != "Q3 Report" || doc.Text != "Quarterly report\n\nRevenue\tup" {
+		t.Fatalf("unexpected document: %+v", doc)
+	}
+
+	if _, err := parseDOCX(buildZip(t, [2]string{"other.xml", "<a/>"})); err == nil {
+		t.Fatal("expected error without word/document.xml")
+	}
+}
+
+func TestParseEPUB(t *testing.T) {
+	data := buildZip(t,
+		[2]string{"mimetype", "application/epub+zip"},
+		[2]string{"META-INF/container.xml", `<?xml version="1.0"?>
+<container xmlns="urn:oasis:names:tc:opendocument:xmlns:container" version="1.0">
+<rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles></container>`},
+		[2]string{"OEBPS/content.opf", `<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>A Short Book</dc:title></metadata>
+<manifest>
+<item id="c2" href="text/chapter%202.xhtml" media-type="application/xhtml+xml"/>
+<item id="c1" href="text/one.xhtml" media-type="application/xhtml+xml"/>
+<item id="css" href="style.css" media-type="text/css"/>
+</manifest>
+<spine><itemref idref="c1"/><itemref idref="css"/><itemref idref="c2"/></spine></package>`},
+		[2]string{"OEBPS/text/one.xhtml", `<html><body><h1>Chapter One</h1><p>It begins.</p></body></html>`},
+		[2]string{"OEBPS/text/chapter 2.xhtml", `<html><body><p>It ends.</p></body></html>`},
+	)
+
+	doc, err := parseEPUB(data)
+	if err != nil {
+		t.Fatalf("parse epub: %v", err)
+	}
+	if doc.Title != "A Short Book" || doc.Text != "Chapter One\n\nIt begins.\n\nIt ends." {
+		t.Fatalf("unexpected document: %+v", doc)
+	}
+}
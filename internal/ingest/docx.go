@@ -0,0 +1,76 @@
+package ingest
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// parseDOCX reads the body text of a Word document, one paragraph per
+// <w:p>, with the title from its core properties.
+func parseDOCX(data []byte) (*ParsedDocument, error) {
+	zr, err := openZip(data)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := readZipFile(zr, "word/document.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	var paragraphs []string
+	var current strings.Builder
+	inText := false
+	decoder := xml.NewDecoder(bytes.NewReader(raw))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse document: %w", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				current.WriteByte('\t')
+			case "br", "cr":
+				current.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				if p := strings.TrimSpace(current.String()); p != "" {
+					paragraphs = append(paragraphs, p)
+				}
+				current.Reset()
+			}
+		case xml.CharData:
+			if inText {
+				current.Write(t)
+			}
+		}
+	}
+	if len(paragraphs) == 0 {
+		return nil, fmt.Errorf("document has no text")
+	}
+
+	doc := &ParsedDocument{Text: strings.Join(paragraphs, "\n\n")}
+	// Core properties are optional
+	if raw, err := readZipFile(zr, "docProps/core.xml"); err == nil {
+		var core struct {
+			Title string `xml:"title"`
+		}
+		if xml.Unmarshal(raw, &core) == nil {
+			doc.Title = strings.TrimSpace(core.Title)
+		}
+	}
+	return doc, nil
+}
@@ -0,0 +1,317 @@
+package ingest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nerdneilsfield/dumper/internal/store"
+	"golang.org/x/net/html/charset"
+)
+
+// emailTokenSetting holds the secret local part of a user's capture address.
+const emailTokenSetting = "email_token"
+
+// maxMIMEDepth bounds the nesting of multipart bodies.
+const maxMIMEDepth = 10
+
+// Email is a received message reduced to what is saved from it.
+type Email struct {
+	From    *mail.Address
+	Subject string
+	Date    time.Time
+	// Text is the plain text body, or the text of the HTML body when the
+	// message has no plain part.
+	Text        string
+	Attachments []EmailAttachment
+}
+
+// EmailAttachment is a file attached to an email.
+type EmailAttachment struct {
+	Name string
+	MIME string
+	Data []byte
+}
+
+var wordDecoder = &mime.WordDecoder{CharsetReader: charset.NewReaderLabel}
+
+// ParseEmail reads an RFC 5322 message with its MIME parts.
+func ParseEmail(r io.Reader) (*Email, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, fmt.Errorf("read message: %w", err)
+	}
+
+	e := &Email{}
+	if e.Subject, err = wordDecoder.DecodeHeader(msg.Header.Get("Subject")); err != nil {
+		e.Subject = msg.Header.Get("Subject")
+	}
+	e.Subject = strings.Join(strings.Fields(e.Subject), " ")
+	parser := mail.AddressParser{WordDecoder: wordDecoder}
+	if from, err := parser.Parse(msg.Header.Get("From")); err == nil {
+		e.From = from
+	}
+	if date, err := msg.Header.Date(); err == nil {
+		e.Date = date.UTC()
+	}
+
+	var body emailBody
+	header := textproto.MIMEHeader(msg.Header)
+	if err := body.readPart(header, msg.Body, 0); err != nil {
+		return nil, err
+	}
+	e.Text = body.plain
+	if strings.TrimSpace(e.Text) == "" && body.html != "" {
+		if _, text, err := htmlText(strings.NewReader(body.html)); err == nil {
+			e.Text = text
+		}
+	}
+	e.Text = strings.TrimSpace(e.Text)
+	e.Attachments = body.attachments
+	return e, nil
+}
+
+// emailBody collects the parts of a message while walking its MIME tree.
+type emailBody struct {
+	plain, html string
+	attachments []EmailAttachment
+}
+
+// readPart reads one MIME part, descending into multipart bodies.
+func (b *emailBody) readPart(header textproto.MIMEHeader, body io.Reader, depth int) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		if depth >= maxMIMEDepth {
+			return errors.New("MIME parts nested too deeply")
+		}
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("read MIME part: %w", err)
+			}
+			if err := b.readPart(part.Header, part, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := io.ReadAll(transferDecoder(header.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return fmt.Errorf("decode MIME part: %w", err)
+	}
+
+	disposition, dispParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	name := dispParams["filename"]
+	if name == "" {
+		name = params["name"]
+	}
+	if decoded, err := wordDecoder.DecodeHeader(name); err == nil {
+		name = decoded
+	}
+	switch {
+	case disposition == "attachment" || (name != "" && header.Get("Content-ID") == ""):
+		b.attachments = append(b.attachments, EmailAttachment{Name: name, MIME: mediaType, Data: data})
+	case header.Get("Content-ID") != "":
+		// Inline resource of the HTML body, such as a logo
+	case mediaType == "text/plain" && b.plain == "":
+		b.plain = decodeCharset(data, params["charset"])
+	case mediaType == "text/html" && b.html == "":
+		b.html = decodeCharset(data, params["charset"])
+	}
+	return nil
+}
+
+func transferDecoder(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, &newlineStripper{r: r})
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	default:
+		return r
+	}
+}
+
+// newlineStripper drops the line breaks base64 bodies are wrapped with.
+type newlineStripper struct{ r io.Reader }
+
+func (s *newlineStripper) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	kept := 0
+	for _, c := range p[:n] {
+		if c != '\r' && c != '\n' {
+			p[kept] = c
+			kept++
+		}
+	}
+	return kept, err
+}
+
+// decodeCharset converts text in a declared charset to UTF-8.
+func decodeCharset(data []byte, label string) string {
+	if label == "" || strings.EqualFold(label, "utf-8") || strings.EqualFold(label, "us-ascii") {
+		return string(data)
+	}
+	r, err := charset.NewReaderLabel(label, bytes.NewReader(data))
+	if err != nil {
+		return string(data)
+	}
+	text, err := io.ReadAll(r)
+	if err != nil {
+		return string(data)
+	}
+	return string(text)
+}
+
+// Contents returns what is saved from an email: its body as a note, or as
+// a link when the body is just a URL, and each attachment ingest can read.
+// All carry the sender and date as their source.
+func (e *Email) Contents() []RawContent {
+	source := &store.Source{Kind: store.SourceEmail, Date: e.Date}
+	if e.From != nil {
+		source.Name = e.From.Address
+		if e.From.Name != "" {
+			source.Name = e.From.Name + " <" + e.From.Address + ">"
+		}
+	}
+
+	var raws []RawContent
+	switch text := e.Text; {
+	case IsURL(text) && len(strings.Fields(text)) == 1:
+		raws = append(raws, RawContent{Type: ContentTypeLink, URL: text})
+	case text != "":
+		if e.Subject != "" {
+			text = "# " + e.Subject + "\n\n" + text
+		}
+		raws = append(raws, RawContent{Type: ContentTypeNote, Text: text})
+	case len(e.Attachments) == 0 && e.Subject != "":
+		raws = append(raws, RawContent{Type: ContentTypeNote, Text: "# " + e.Subject})
+	}
+
+	for _, a := range e.Attachments {
+		raw, err := fileContent(a.Name, a.Data)
+		if err != nil {
+			slog.Debug("skipping email attachment", "name", a.Name, "error", err)
+			continue
+		}
+		if raw.FileMIME == "" && raw.FileData != nil {
+			raw.FileMIME = a.MIME
+		}
+		raw.Caption = e.Subject
+		raws = append(raws, raw)
+	}
+
+	for i := range raws {
+		raws[i].Source = source
+	}
+	return raws
+}
+
+// EmailAddresses maps the secret local parts of capture addresses to their
+// users. It reads every vault once, on first use, and then tracks the tokens
+// handed out by Token, so a recipient is looked up without opening vaults.
+type EmailAddresses struct {
+	stores store.Stores
+
+	mu     sync.Mutex
+	users  map[string]int64 // token to user, nil until loaded
+	tokens map[int64]string
+}
+
+func NewEmailAddresses(stores store.Stores) *EmailAddresses {
+	return &EmailAddresses{stores: stores}
+}
+
+// Token returns the secret local part of the user's capture address,
+// creating it on first use. reset replaces it, retiring the old address.
+func (a *EmailAddresses) Token(userID int64, reset bool) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.load(); err != nil {
+		return "", err
+	}
+	if token := a.tokens[userID]; token != "" && !reset {
+		return token, nil
+	}
+
+	vault, err := a.stores.GetVault(userID)
+	if err != nil {
+		return "", fmt.Errorf("get vault: %w", err)
+	}
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+	if err := vault.SetSetting(emailTokenSetting, token); err != nil {
+		return "", fmt.Errorf("save email token: %w", err)
+	}
+	delete(a.users, a.tokens[userID])
+	a.users[token] = userID
+	a.tokens[userID] = token
+	return token, nil
+}
+
+// Lookup returns the user whose capture address has the given local part.
+func (a *EmailAddresses) Lookup(token string) (int64, bool, error) {
+	if token == "" {
+		return 0, false, nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.load(); err != nil {
+		return 0, false, err
+	}
+	userID, ok := a.users[strings.ToLower(token)]
+	return userID, ok, nil
+}
+
+// load reads every user's token the first time it is called.
+func (a *EmailAddresses) load() error {
+	if a.users != nil {
+		return nil
+	}
+	userIDs, err := a.stores.UserIDs()
+	if err != nil {
+		return err
+	}
+	users, tokens := make(map[string]int64), make(map[int64]string)
+	for _, userID := range userIDs {
+		vault, err := a.stores.GetVault(userID)
+		if err != nil {
+			return err
+		}
+		token, err := vault.GetSetting(emailTokenSetting)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("get email token: %w", err)
+		}
+		if token != "" {
+			users[strings.ToLower(token)] = userID
+			tokens[userID] = token
+		}
+	}
+	a.users, a.tokens = users, tokens
+	return nil
+}
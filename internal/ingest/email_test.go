@@ -0,0 +1,264 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nerdneilsfield/dumper/internal/store"
+)
+
+const testEmail = "From: =?UTF-8?Q?Ann=C3=A9e?= <news@example.com>\r\n" +
+	"To: inbox@dumper.test\r\n" +
+	"Subject: =?UTF-8?B?V2Vla2x5IGRpZ2VzdA==?=\r\n" +
+	"Date: Tue, 10 Mar 2026 08:30:00 +0100\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=outer\r\n" +
+	"\r\n" +
+	"--outer\r\n" +
+	"Content-Type: multipart/related; boundary=related\r\n" +
+	"\r\n" +
+	"--related\r\n" +
+	"Content-Type: multipart/alternative; boundary=alt\r\n" +
+	"\r\n" +
+	"--alt\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"\r\n" +
+	"Caf=C3=A9 news this week.\r\n" +
+	"--alt\r\n" +
+	"Content-Type: text/html; charset=utf-8\r\n" +
+	"\r\n" +
+	"<p>Caf&eacute; news <b>this</b> week.</p>\r\n" +
+	"--alt--\r\n" +
+	"--related\r\n" +
+	"Content-Type: image/png; name=logo.png\r\n" +
+	"Content-ID: <logo>\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"iVBORw0KGgo=\r\n" +
+	"--related--\r\n" +
+	"--outer\r\n" +
+	"Content-Type: application/pdf\r\n" +
+	"Content-Disposition: attachment; filename=\"report.pdf\"\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"JVBERi0x\r\n" +
+	"LjQK\r\n" +
+	"--outer--\r\n"
+
+func TestParseEmail(t *testing.T) {
+	e, err := ParseEmail(strings.NewReader(testEmail))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if e.Subject != "Weekly digest" || e.From == nil || e.From.Name != "Année" || e.From.Address != "news@example.com" {
+		t.Errorf("unexpected headers: %q from %+v", e.Subject, e.From)
+	}
+	if e.Date.Hour() != 7 {
+		t.Errorf("unexpected date: %v", e.Date)
+	}
+	if e.Text != "Café news this week." {
+		t.Errorf("unexpected text: %q", e.Text)
+	}
+	// The inline logo belongs to the HTML body and is not an attachment
+	if len(e.Attachments) != 1 || e.Attachments[0].Name != "report.pdf" || string(e.Attachments[0].Data) != "%PDF-1.4\n" {
+		t.Fatalf("unexpected attachments: %+v", e.Attachments)
+	}
+
+	raws := e.Contents()
+	if len(raws) != 2 {
+		t.Fatalf("expected a note and a document, got %+v", raws)
+	}
+	if raws[0].Type != ContentTypeNote || raws[0].Text != "# Weekly digest\n\nCafé news this week." {
+		t.Errorf("unexpected note: %+v", raws[0])
+	}
+	if raws[1].Type != ContentTypeDocument || raws[1].FileName != "report.pdf" || raws[1].Caption != "Weekly digest" {
+		t.Errorf("unexpected document: %+v", raws[1])
+	}
+	for _, raw := range raws {
+		if raw.Source == nil || raw.Source.Kind != store.SourceEmail || raw.Source.Name != "Année <news@example.com>" {
+			t.Errorf("unexpected source: %+v", raw.Source)
+		}
+	}
+}
+
+func TestParseEmailHTMLOnly(t *testing.T) {
+	e, err := ParseEmail(strings.NewReader("From: a@example.com\r\n" +
+		"Subject: Link\r\n" +
+		"Content-Type: text/html; charset=iso-8859-1\r\n" +
+		"\r\n" +
+		"<html><body><p>https://example.com/article</p></body></html>\r\n"))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	raws := e.Contents()
+	if len(raws) != 1 || raws[0].Type != ContentTypeLink || raws[0].URL != "https://example.com/article" {
+		t.Fatalf("expected a link, got %+v", raws)
+	}
+}
+
+func TestSMTPSource(t *testing.T) {
+	manager, err := store.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create manager: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = manager.Close()
+	})
+	if _, err := manager.GetVault(7); err != nil {
+		t.Fatalf("get vault: %v", err)
+	}
+	addresses := NewEmailAddresses(manager)
+	token, err := addresses.Token(7, false)
+	if err != nil {
+		t.Fatalf("email token: %v", err)
+	}
+	if again, _ := addresses.Token(7, false); again != token {
+		t.Errorf("token changed: %s, %s", token, again)
+	}
+	// A fresh index finds the token saved in the vault
+	if userID, ok, err := NewEmailAddresses(manager).Lookup(strings.ToUpper(token)); err != nil || !ok || userID != 7 {
+		t.Errorf("lookup: %d %v %v", userID, ok, err)
+	}
+	reset, err := addresses.Token(7, true)
+	if err != nil || reset == token {
+		t.Fatalf("reset token: %s %v", reset, err)
+	}
+	if _, ok, _ := addresses.Lookup(token); ok {
+		t.Error("old token still resolves after reset")
+	}
+	token = reset
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	source := NewSMTPSource("", "dumper.test", manager, addresses)
+	var mu sync.Mutex
+	var emitted []RawContent
+	done := make(chan error, 1)
+	go func() {
+		done <- source.Serve(ctx, ln, func(raw RawContent, reply any) error {
+			mu.Lock()
+			defer mu.Unlock()
+			emitted = append(emitted, raw)
+			return nil
+		})
+	}()
+
+	addr := ln.Addr().String()
+	err = smtp.SendMail(addr, nil, "news@example.com", []string{token + "@dumper.test"}, []byte(testEmail))
+	if err != nil {
+		t.Fatalf("send mail: %v", err)
+	}
+	for _, to := range []string{"wrong@dumper.test", token + "@elsewhere.test"} {
+		if err := smtp.SendMail(addr, nil, "news@example.com", []string{to}, []byte(testEmail)); err == nil {
+			t.Errorf("%s: expected the recipient to be rejected", to)
+		}
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("serve: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not stop")
+	}
+
+	if len(emitted) != 2 {
+		t.Fatalf("expected 2 contents, got %d", len(emitted))
+	}
+	for _, raw := range emitted {
+		if raw.UserID != 7 || raw.Language != "en" {
+			t.Errorf("unexpected content: %+v", raw)
+		}
+	}
+}
+
+func TestSMTPLineLimit(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	source := NewSMTPSource("", "dumper.test", nil, nil)
+	go source.Serve(ctx, ln, func(raw RawContent, reply any) error { return nil })
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	tp := textproto.NewConn(conn)
+	if _, _, err := tp.ReadResponse(220); err != nil {
+		t.Fatalf("greeting: %v", err)
+	}
+	if err := tp.PrintfLine("HELO %s", strings.Repeat("a", 2*maxSMTPLine)); err != nil {
+		t.Fatal(err)
+	}
+	if code, _, _ := tp.ReadResponse(0); code != 500 {
+		t.Fatalf("expected 500 for an overlong line, got %d", code)
+	}
+	if _, err := tp.ReadLine(); err == nil {
+		t.Fatal("expected the connection to be closed")
+	}
+}
+
+func TestSMTPPartialDelivery(t *testing.T) {
+	manager, err := store.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create manager: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = manager.Close()
+	})
+	addresses := NewEmailAddresses(manager)
+	var to []string
+	for _, userID := range []int64{1, 2} {
+		token, err := addresses.Token(userID, false)
+		if err != nil {
+			t.Fatalf("email token: %v", err)
+		}
+		to = append(to, token+"@dumper.test")
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var mu sync.Mutex
+	failing := map[int64]bool{2: true}
+	go NewSMTPSource("", "dumper.test", manager, addresses).Serve(ctx, ln, func(raw RawContent, reply any) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if failing[raw.UserID] {
+			return errors.New("queue unavailable")
+		}
+		return nil
+	})
+
+	// User 1 got the message, so it is accepted rather than retried
+	if err := smtp.SendMail(ln.Addr().String(), nil, "news@example.com", to, []byte(testEmail)); err != nil {
+		t.Fatalf("expected the message to be accepted: %v", err)
+	}
+	mu.Lock()
+	failing[1] = true
+	mu.Unlock()
+	if err := smtp.SendMail(ln.Addr().String(), nil, "news@example.com", to, []byte(testEmail)); err == nil {
+		t.Fatal("expected a temporary failure when nothing was queued")
+	}
+}
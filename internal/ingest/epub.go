@@ -0,0 +1,128 @@
+package ingest
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// maxUnpackedSize caps each file read from a zip-based document, so a small
+// upload can't expand into gigabytes.
+const maxUnpackedSize = 64 << 20
+
+// openZip opens a zip-based document held in memory.
+func openZip(data []byte) (*zip.Reader, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("open archive: %w", err)
+	}
+	return zr, nil
+}
+
+// readZipFile returns the contents of name, or an error if it is missing.
+func readZipFile(zr *zip.Reader, name string) ([]byte, error) {
+	f, err := zr.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", name, err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxUnpackedSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", name, err)
+	}
+	if len(data) > maxUnpackedSize {
+		return nil, fmt.Errorf("%s is too large", name)
+	}
+	return data, nil
+}
+
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath string `xml:"full-path,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+type epubPackage struct {
+	Title    []string `xml:"metadata>title"`
+	Manifest []struct {
+		ID        string `xml:"id,attr"`
+		Href      string `xml:"href,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"manifest>item"`
+	Spine []struct {
+		IDRef string `xml:"idref,attr"`
+	} `xml:"spine>itemref"`
+}
+
+// parseEPUB reads the chapters of an EPUB in reading order.
+func parseEPUB(data []byte) (*ParsedDocument, error) {
+	zr, err := openZip(data)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := readZipFile(zr, "META-INF/container.xml")
+	if err != nil {
+		return nil, err
+	}
+	var container epubContainer
+	if err := xml.Unmarshal(raw, &container); err != nil {
+		return nil, fmt.Errorf("parse container: %w", err)
+	}
+	if len(container.Rootfiles) == 0 {
+		return nil, fmt.Errorf("epub has no package file")
+	}
+	opfPath := container.Rootfiles[0].FullPath
+
+	raw, err = readZipFile(zr, opfPath)
+	if err != nil {
+		return nil, err
+	}
+	var pkg epubPackage
+	if err := xml.Unmarshal(raw, &pkg); err != nil {
+		return nil, fmt.Errorf("parse package: %w", err)
+	}
+
+	hrefs := make(map[string]string, len(pkg.Manifest))
+	for _, item := range pkg.Manifest {
+		if strings.Contains(item.MediaType, "html") {
+			hrefs[item.ID] = item.Href
+		}
+	}
+
+	var chapters []string
+	for _, ref := range pkg.Spine {
+		href, ok := hrefs[ref.IDRef]
+		if !ok {
+			continue
+		}
+		if unescaped, err := url.PathUnescape(href); err == nil {
+			href = unescaped
+		}
+		raw, err := readZipFile(zr, path.Join(path.Dir(opfPath), href))
+		if err != nil {
+			return nil, err
+		}
+		_, text, err := htmlText(bytes.NewReader(raw))
+		if err != nil {
+			return nil, fmt.Errorf("chapter %s: %w", href, err)
+		}
+		if text != "" {
+			chapters = append(chapters, text)
+		}
+	}
+	if len(chapters) == 0 {
+		return nil, fmt.Errorf("epub has no readable chapters")
+	}
+
+	doc := &ParsedDocument{Text: strings.Join(chapters, "\n\n")}
+	if len(pkg.Title) > 0 {
+		doc.Title = strings.TrimSpace(pkg.Title[0])
+	}
+	return doc, nil
+}
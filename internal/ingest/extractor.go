@@ -1,14 +1,23 @@
 package ingest
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
+	"log/slog"
+	"mime"
 	"net/http"
 	"net/url"
+	"path"
+	"regexp"
 	"strings"
 	"time"
 
 	"github.com/go-shiori/go-readability"
+	"github.com/nerdneilsfield/dumper/internal/llm"
+	"github.com/nerdneilsfield/dumper/internal/store"
+	"golang.org/x/net/html"
 )
 
 type Extractor struct {
@@ -23,6 +32,9 @@ func NewExtractor() *Extractor {
 	}
 }
 
+// maxFetchSize caps downloaded pages and documents.
+const maxFetchSize = store.MaxBlobSize
+
 type ExtractedContent struct {
 	URL      string
 	Title    string
@@ -30,6 +42,18 @@ type ExtractedContent struct {
 	Excerpt  string
 	SiteName string
 	Favicon  string
+	// Document holds the downloaded file when the URL points at a document
+	// such as a PDF rather than a web page.
+	Document []byte
+	MIME     string
+	// Structured holds the page's schema.org and OpenGraph metadata
+	Structured *store.StructuredData
+	// Kind describes the content to the LLM, e.g. "video transcript";
+	// empty means a web article
+	Kind string
+	// Page holds the downloaded HTML, fetched from PageURL, for archiving
+	Page    []byte
+	PageURL string
 }
 
 func (e *Extractor) Extract(ctx context.Context, rawURL string) (*ExtractedContent, error) {
@@ -38,39 +62,232 @@ func (e *Extractor) Extract(ctx context.Context, rawURL string) (*ExtractedConte
 		return nil, fmt.Errorf("parse url: %w", err)
 	}
 
+	site := lookupSiteExtractor(parsed)
+	if site != nil && site.API != nil {
+		content, err := site.API(ctx, e.get, parsed)
+		if err == nil && strings.TrimSpace(content.Content) != "" {
+			content.URL = rawURL
+			if content.SiteName == "" {
+				content.SiteName = site.Name
+			}
+			if content.Excerpt == "" {
+				content.Excerpt = excerpt(content.Content)
+			}
+			return content, nil
+		}
+		slog.Debug("site api failed, fetching the page", "site", site.Name, "url", rawURL, "error", err)
+	}
+	fetchURL := rawURL
+	if site != nil && site.FetchURL != nil {
+		fetchURL = site.FetchURL(parsed)
+	}
+
+	body, contentType, err := e.fetch(ctx, fetchURL)
+	if err != nil {
+		return nil, err
+	}
+
+	favicon := fmt.Sprintf("%s://%s/favicon.ico", parsed.Scheme, parsed.Host)
+
+	mimeType := sniffContentType(contentType, body)
+	// Text formats read fine as pages; binary documents are kept as files
+	if docType, parse, ok := lookupDocumentParser(mimeType, parsed.Path, body); ok && !isTextType(mimeType) && !isTextType(docType) {
+		doc, err := parse(body)
+		if err != nil {
+			return nil, err
+		}
+		if doc.Title == "" {
+			doc.Title = fileTitle(path.Base(parsed.Path))
+		}
+		return &ExtractedContent{
+			URL:      rawURL,
+			Title:    doc.Title,
+			Content:  doc.Text,
+			Excerpt:  excerpt(doc.Text),
+			SiteName: parsed.Host,
+			Favicon:  favicon,
+			Document: body,
+			MIME:     docType,
+		}, nil
+	}
+
+	switch {
+	case isTextType(mimeType):
+		doc, err := html.Parse(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("parse html: %w", err)
+		}
+		// Read before readability, which rewrites the document
+		structured := extractStructuredData(doc)
+
+		var content *ExtractedContent
+		if site != nil {
+			if content, err = extractSite(site, doc, parsed); err != nil {
+				slog.Debug("site extractor failed, using readability", "site", site.Name, "url", rawURL, "error", err)
+			} else if site.Follow != nil {
+				// Linked resources only add to the content
+				if err := site.Follow(ctx, e.get, doc, parsed, content); err != nil {
+					slog.Warn("site extractor could not follow links", "site", site.Name, "url", rawURL, "error", err)
+				}
+			}
+		}
+		if content == nil {
+			article, err := readability.FromDocument(doc, parsed)
+			if err != nil {
+				return nil, fmt.Errorf("parse content: %w", err)
+			}
+			content = &ExtractedContent{
+				Title:    article.Title,
+				Content:  article.TextContent,
+				Excerpt:  article.Excerpt,
+				SiteName: article.SiteName,
+			}
+		}
+		content.URL = rawURL
+		content.Favicon = favicon
+		if mimeType == "text/html" || mimeType == "application/xhtml+xml" {
+			content.Page, content.PageURL = body, fetchURL
+		}
+		// Site extractors may describe the page better than its own metadata
+		if content.Structured == nil {
+			content.Structured = structured
+			// Readability drops lists like ingredients; keep them for the summary
+			if sections := structured.Markdown(); sections != "" {
+				content.Content = strings.TrimSpace(content.Content + "\n\n" + sections)
+			}
+		}
+		if content.Structured != nil && content.Title == "" {
+			content.Title = content.Structured.Name
+		}
+		return content, nil
+	default:
+		return nil, fmt.Errorf("unsupported content type: %s", mimeType)
+	}
+}
+
+// fetch downloads a URL, returning its body and Content-Type header.
+func (e *Extractor) fetch(ctx context.Context, rawURL string) (body []byte, contentType string, err error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("create request: %w", err)
+		return nil, "", fmt.Errorf("create request: %w", err)
 	}
 	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; Dumper/1.0; +https://github.com/dumper)")
-	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,application/pdf;q=0.9,*/*;q=0.8")
 
 	resp, err := e.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("fetch url: %w", err)
+		return nil, "", fmt.Errorf("fetch url: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("bad status: %d", resp.StatusCode)
+		return nil, "", fmt.Errorf("bad status: %d", resp.StatusCode)
 	}
 
-	article, err := readability.FromReader(resp.Body, parsed)
+	body, err = io.ReadAll(io.LimitReader(resp.Body, maxFetchSize+1))
 	if err != nil {
-		return nil, fmt.Errorf("parse content: %w", err)
+		return nil, "", fmt.Errorf("read body: %w", err)
 	}
+	if len(body) > maxFetchSize {
+		return nil, "", fmt.Errorf("response too large (max %d bytes)", maxFetchSize)
+	}
+	return body, resp.Header.Get("Content-Type"), nil
+}
 
-	return &ExtractedContent{
-		URL:      rawURL,
-		Title:    article.Title,
-		Content:  article.TextContent,
-		Excerpt:  article.Excerpt,
-		SiteName: article.SiteName,
-		Favicon:  fmt.Sprintf("%s://%s/favicon.ico", parsed.Scheme, parsed.Host),
-	}, nil
+// get downloads a URL for site extractors following links from a page.
+func (e *Extractor) get(ctx context.Context, rawURL string) ([]byte, error) {
+	body, _, err := e.fetch(ctx, rawURL)
+	return body, err
+}
+
+// extractSite runs a site extractor on a downloaded page.
+func extractSite(site *SiteExtractor, doc *html.Node, u *url.URL) (*ExtractedContent, error) {
+	content, err := site.Extract(doc, u)
+	if err != nil {
+		return nil, err
+	}
+	if content == nil || strings.TrimSpace(content.Content) == "" {
+		return nil, errNoSiteContent
+	}
+	if content.SiteName == "" {
+		content.SiteName = site.Name
+	}
+	if content.Excerpt == "" {
+		content.Excerpt = excerpt(content.Content)
+	}
+	return content, nil
+}
+
+// isTextType reports whether a media type is a web page or other text.
+func isTextType(mimeType string) bool {
+	return mimeType == "application/xhtml+xml" || strings.HasPrefix(mimeType, "text/") || strings.HasSuffix(mimeType, "xml")
+}
+
+// sniffContentType returns the media type of a response, trusting the
+// Content-Type header unless it is missing or generic.
+func sniffContentType(header string, body []byte) string {
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil || mediaType == "application/octet-stream" || mediaType == "binary/octet-stream" {
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(body))
+	}
+	return strings.ToLower(mediaType)
+}
+
+// excerpt returns the start of a document's text on one line.
+func excerpt(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if len(text) <= 300 {
+		return text
+	}
+	return llm.Truncate(text, 300) + "..."
+}
+
+// fileTitle turns a filename into a readable title.
+func fileTitle(name string) string {
+	name = strings.TrimSuffix(name, path.Ext(name))
+	name = strings.NewReplacer("_", " ", "-", " ").Replace(name)
+	if name = strings.TrimSpace(name); name == "" || name == "." || name == "/" {
+		return "Document"
+	}
+	return name
 }
 
 func IsURL(s string) bool {
 	s = strings.TrimSpace(s)
 	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
 }
+
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"'\x60]+`)
+
+// ExtractURLs returns the distinct http(s) URLs in text, in order of first
+// appearance. Punctuation ending a sentence is not taken as part of a URL.
+func ExtractURLs(text string) []string {
+	var urls []string
+	seen := make(map[string]struct{})
+	for _, u := range urlPattern.FindAllString(text, -1) {
+		u = trimURLPunctuation(u)
+		if _, ok := seen[u]; ok || len(u) <= len("https://") {
+			continue
+		}
+		seen[u] = struct{}{}
+		urls = append(urls, u)
+	}
+	return urls
+}
+
+// trimURLPunctuation drops trailing punctuation, keeping closing brackets
+// that belong to the URL, as in https://en.wikipedia.org/wiki/Go_(game).
+func trimURLPunctuation(u string) string {
+	for u != "" {
+		last := u[len(u)-1]
+		switch {
+		case strings.IndexByte(".,;:!?*", last) >= 0:
+		case last == ')' && strings.Count(u, "(") < strings.Count(u, ")"):
+		case last == ']' && strings.Count(u, "[") < strings.Count(u, "]"):
+		default:
+			return u
+		}
+		u = u[:len(u)-1]
+	}
+	return u
+}
@@ -0,0 +1,503 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nerdneilsfield/dumper/internal/queue"
+	"github.com/nerdneilsfield/dumper/internal/store"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+// ErrNotAFeed is returned when a URL is neither a feed nor a page that
+// advertises one.
+var ErrNotAFeed = errors.New("no RSS or Atom feed found")
+
+// ErrAlreadySubscribed is returned when subscribing to a feed twice.
+var ErrAlreadySubscribed = errors.New("already subscribed")
+
+// maxFeedSize caps downloaded feed documents.
+const maxFeedSize = 10 << 20
+
+// ParsedFeed is an RSS or Atom document.
+type ParsedFeed struct {
+	Title   string
+	Entries []FeedEntry // in document order, usually newest first
+}
+
+// FeedEntry is an item of an RSS feed or an entry of an Atom feed.
+type FeedEntry struct {
+	GUID      string
+	URL       string
+	Title     string
+	Summary   string // plain text
+	Published time.Time
+}
+
+// xmlFeed decodes RSS 2.0, RSS 1.0 (RDF) and Atom documents alike.
+type xmlFeed struct {
+	XMLName xml.Name
+	Channel struct {
+		Title string    `xml:"title"`
+		Items []xmlItem `xml:"item"`
+	} `xml:"channel"`
+	Items   []xmlItem `xml:"item"` // RSS 1.0 keeps items outside the channel
+	Title   string    `xml:"title"`
+	Entries []xmlItem `xml:"entry"`
+}
+
+// xmlItem holds the fields of both RSS items and Atom entries.
+type xmlItem struct {
+	Title       string    `xml:"title"`
+	Links       []xmlLink `xml:"link"`
+	GUID        string    `xml:"guid"`
+	ID          string    `xml:"id"`
+	Description string    `xml:"description"`
+	Summary     string    `xml:"summary"`
+	Content     string    `xml:"content"`
+	PubDate     string    `xml:"pubDate"`
+	Date        string    `xml:"date"` // Dublin Core
+	Published   string    `xml:"published"`
+	Updated     string    `xml:"updated"`
+}
+
+// xmlLink is an RSS <link>URL</link> or an Atom <link href="URL"/>.
+type xmlLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Text string `xml:",chardata"`
+}
+
+// ParseFeed reads an RSS or Atom document. Relative entry links are resolved
+// against base.
+func ParseFeed(data []byte, base *url.URL) (*ParsedFeed, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.CharsetReader = charset.NewReaderLabel
+	dec.Strict = false
+	dec.Entity = xml.HTMLEntity
+
+	var doc xmlFeed
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotAFeed, err)
+	}
+
+	feed := &ParsedFeed{}
+	var items []xmlItem
+	switch strings.ToLower(doc.XMLName.Local) {
+	case "rss":
+		feed.Title, items = doc.Channel.Title, doc.Channel.Items
+	case "rdf":
+		feed.Title, items = doc.Channel.Title, doc.Items
+	case "feed":
+		feed.Title, items = doc.Title, doc.Entries
+	default:
+		return nil, ErrNotAFeed
+	}
+	feed.Title = strings.TrimSpace(fragmentText(feed.Title))
+
+	for _, it := range items {
+		e := FeedEntry{
+			Title:     strings.TrimSpace(fragmentText(it.Title)),
+			URL:       resolveRef(base, it.link()),
+			Published: parseFeedDate(it.PubDate, it.Date, it.Published, it.Updated),
+		}
+		e.GUID = firstNonEmpty(strings.TrimSpace(it.GUID), strings.TrimSpace(it.ID), e.URL, e.Title)
+		if e.URL == "" || e.GUID == "" {
+			continue
+		}
+		e.Summary = excerpt(fragmentText(firstNonEmpty(it.Description, it.Summary, it.Content)))
+		feed.Entries = append(feed.Entries, e)
+	}
+	return feed, nil
+}
+
+// link returns the entry's web page: the RSS link, or the Atom alternate link.
+func (it xmlItem) link() string {
+	for _, l := range it.Links {
+		if text := strings.TrimSpace(l.Text); text != "" {
+			return text
+		}
+		if l.Href != "" && (l.Rel == "" || l.Rel == "alternate") {
+			return l.Href
+		}
+	}
+	// Some RSS feeds only link entries through a permalink guid
+	if strings.HasPrefix(it.GUID, "http://") || strings.HasPrefix(it.GUID, "https://") {
+		return strings.TrimSpace(it.GUID)
+	}
+	return ""
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+var feedDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"Mon, 02 Jan 06 15:04:05 -0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// parseFeedDate parses the first date in the formats feeds use in practice.
+func parseFeedDate(values ...string) time.Time {
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		for _, layout := range feedDateLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// fragmentText returns the text of an HTML fragment such as an RSS description.
+func fragmentText(s string) string {
+	if !strings.Contains(s, "<") && !strings.Contains(s, "&") {
+		return s
+	}
+	doc, err := html.Parse(strings.NewReader(s))
+	if err != nil {
+		return s
+	}
+	return textOf(doc)
+}
+
+// feedEntryMatches applies a subscription's keyword filter to an entry.
+func feedEntryMatches(feed *store.Feed, e FeedEntry) bool {
+	text := strings.ToLower(e.Title + " " + e.Summary)
+	included, wantIncluded := false, false
+	for _, kw := range feed.Keywords {
+		kw = strings.ToLower(strings.TrimSpace(kw))
+		if exclude, ok := strings.CutPrefix(kw, "-"); ok {
+			if exclude != "" && strings.Contains(text, exclude) {
+				return false
+			}
+			continue
+		}
+		if kw == "" {
+			continue
+		}
+		wantIncluded = true
+		if strings.Contains(text, kw) {
+			included = true
+		}
+	}
+	return included || !wantIncluded
+}
+
+// FeedReader downloads RSS and Atom feeds.
+type FeedReader struct {
+	client *http.Client
+}
+
+func NewFeedReader() *FeedReader {
+	return &FeedReader{client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Fetch downloads a subscribed feed, updating its title and cache
+// validators. It returns nil when the feed has not changed since the last
+// poll.
+func (r *FeedReader) Fetch(ctx context.Context, feed *store.Feed) (*ParsedFeed, error) {
+	doc, err := r.download(ctx, feed)
+	if err != nil || doc == nil {
+		return nil, err
+	}
+	parsed, err := ParseFeed(doc.body, doc.url)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Title != "" {
+		feed.Title = parsed.Title
+	}
+	feed.ETag, feed.LastModified = doc.etag, doc.lastModified
+	return parsed, nil
+}
+
+// feedDocument is a downloaded feed or web page.
+type feedDocument struct {
+	body               []byte
+	url                *url.URL // after redirects
+	etag, lastModified string
+}
+
+// download fetches feed.URL, conditionally when the feed has cache
+// validators. It returns nil when the feed has not changed.
+func (r *FeedReader) download(ctx context.Context, feed *store.Feed) (*feedDocument, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feed.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; Dumper/1.0; +https://github.com/dumper)")
+	req.Header.Set("Accept", "application/rss+xml,application/atom+xml,application/xml;q=0.9,text/xml;q=0.9,*/*;q=0.8")
+	if feed.ETag != "" {
+		req.Header.Set("If-None-Match", feed.ETag)
+	}
+	if feed.LastModified != "" {
+		req.Header.Set("If-Modified-Since", feed.LastModified)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch feed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bad status: %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("read feed: %w", err)
+	}
+	if len(body) > maxFeedSize {
+		return nil, fmt.Errorf("feed too large (max %d bytes)", maxFeedSize)
+	}
+	return &feedDocument{
+		body:         body,
+		url:          resp.Request.URL,
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
+
+// Discover finds the feed at rawURL: either the URL itself or the feed a
+// web page advertises with <link rel="alternate">.
+func (r *FeedReader) Discover(ctx context.Context, rawURL string) (*store.Feed, *ParsedFeed, error) {
+	feed := &store.Feed{URL: rawURL}
+	doc, err := r.download(ctx, feed)
+	if err != nil {
+		return nil, nil, err
+	}
+	parsed, err := ParseFeed(doc.body, doc.url)
+	if err == nil {
+		feed.Title = parsed.Title
+		feed.ETag, feed.LastModified = doc.etag, doc.lastModified
+		return feed, parsed, nil
+	}
+	if !errors.Is(err, ErrNotAFeed) {
+		return nil, nil, err
+	}
+
+	page, err := html.Parse(bytes.NewReader(doc.body))
+	if err != nil {
+		return nil, nil, ErrNotAFeed
+	}
+	link := findFirst(page, func(n *html.Node) bool {
+		t := strings.ToLower(attr(n, "type"))
+		return n.Data == "link" && hasToken(attr(n, "rel"), "alternate") &&
+			(t == "application/rss+xml" || t == "application/atom+xml") && attr(n, "href") != ""
+	})
+	if link == nil {
+		return nil, nil, ErrNotAFeed
+	}
+	feed = &store.Feed{URL: resolveRef(doc.url, attr(link, "href"))}
+	if parsed, err = r.Fetch(ctx, feed); err != nil {
+		return nil, nil, err
+	}
+	return feed, parsed, nil
+}
+
+// Subscribe adds a subscription to the feed at feed.URL, which may be a page
+// advertising its feed. Entries already in the feed are marked seen, so only
+// later ones are saved.
+func (r *FeedReader) Subscribe(ctx context.Context, vault store.Vault, feed *store.Feed) (*ParsedFeed, error) {
+	found, parsed, err := r.Discover(ctx, feed.URL)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := vault.ListFeeds()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range existing {
+		if f.URL == found.URL {
+			return nil, ErrAlreadySubscribed
+		}
+	}
+	feed.URL, feed.Title = found.URL, found.Title
+	feed.ETag, feed.LastModified = found.ETag, found.LastModified
+	if feed.Title == "" {
+		feed.Title = feed.URL
+	}
+	feed.LastPolledAt = time.Now()
+	if err := vault.CreateFeed(feed); err != nil {
+		return nil, err
+	}
+	if err := vault.UpdateFeedState(feed); err != nil {
+		return nil, err
+	}
+	for _, e := range parsed.Entries {
+		if _, err := vault.AddFeedEntry(feed.ID, e.GUID, false); err != nil {
+			return nil, err
+		}
+	}
+	return parsed, nil
+}
+
+// FeedSource polls every user's feed subscriptions and emits their new
+// entries as links.
+type FeedSource struct {
+	stores   store.Stores
+	reader   *FeedReader
+	interval time.Duration
+}
+
+var _ InputSource = (*FeedSource)(nil)
+
+func NewFeedSource(stores store.Stores, interval time.Duration) *FeedSource {
+	return &FeedSource{stores: stores, reader: NewFeedReader(), interval: interval}
+}
+
+func (s *FeedSource) Name() string {
+	return "feeds"
+}
+
+// Ack does nothing: feed entries are saved silently.
+func (s *FeedSource) Ack(job *queue.Job) {}
+
+// Run polls all subscriptions every interval until ctx is done.
+func (s *FeedSource) Run(ctx context.Context, emit EmitFunc) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			userIDs, err := s.stores.UserIDs()
+			if err != nil {
+				slog.Error("feed poll failed", "error", err)
+				continue
+			}
+			for _, userID := range userIDs {
+				if err := s.pollUser(ctx, userID, emit); err != nil {
+					if ctx.Err() != nil {
+						return nil
+					}
+					slog.Error("feed poll failed", "user_id", userID, "error", err)
+				}
+			}
+		}
+	}
+}
+
+func (s *FeedSource) pollUser(ctx context.Context, userID int64, emit EmitFunc) error {
+	vault, err := s.stores.GetVault(userID)
+	if err != nil {
+		return fmt.Errorf("get vault: %w", err)
+	}
+	feeds, err := vault.ListFeeds()
+	if err != nil {
+		return err
+	}
+	if len(feeds) == 0 {
+		return nil
+	}
+	lang, err := userLanguage(vault)
+	if err != nil {
+		return err
+	}
+
+	for i := range feeds {
+		saved, err := s.PollFeed(ctx, vault, userID, lang, &feeds[i], emit)
+		if err != nil {
+			return err
+		}
+		if saved > 0 {
+			slog.Info("feed polled", "user_id", userID, "feed", feeds[i].URL, "saved", saved)
+		}
+	}
+	return nil
+}
+
+// PollFeed emits a feed's new entries that pass its filters, oldest first,
+// and returns how many were emitted. Fetch failures are recorded on the feed
+// rather than returned.
+func (s *FeedSource) PollFeed(ctx context.Context, vault store.Vault, userID int64, lang string, feed *store.Feed, emit EmitFunc) (int, error) {
+	parsed, err := s.reader.Fetch(ctx, feed)
+	feed.LastPolledAt = time.Now()
+	feed.LastError = ""
+	if err != nil {
+		feed.LastError = err.Error()
+		slog.Warn("failed to fetch feed", "user_id", userID, "feed", feed.URL, "error", err)
+	}
+	if err := vault.UpdateFeedState(feed); err != nil {
+		return 0, fmt.Errorf("update feed: %w", err)
+	}
+	if parsed == nil {
+		return 0, nil
+	}
+
+	today, err := vault.FeedCaptures(feed.ID, time.Now().Add(-24*time.Hour))
+	if err != nil {
+		return 0, fmt.Errorf("count feed captures: %w", err)
+	}
+	saved := 0
+	for i := len(parsed.Entries) - 1; i >= 0; i-- {
+		e := parsed.Entries[i]
+		if !feedEntryMatches(feed, e) {
+			if _, err := vault.AddFeedEntry(feed.ID, e.GUID, false); err != nil {
+				return saved, err
+			}
+			continue
+		}
+		// Entries over the daily limit stay unseen until the next day
+		if feed.MaxPerDay > 0 && today >= feed.MaxPerDay {
+			continue
+		}
+		isNew, err := vault.AddFeedEntry(feed.ID, e.GUID, true)
+		if err != nil {
+			return saved, err
+		}
+		if !isNew {
+			continue
+		}
+		err = emit(RawContent{
+			Type:     ContentTypeLink,
+			URL:      e.URL,
+			UserID:   userID,
+			Language: lang,
+			Tags:     feed.Tags,
+			Source:   &store.Source{Kind: store.SourceFeed, Name: feed.Title, FeedID: feed.ID, Date: e.Published},
+		}, nil)
+		if err != nil {
+			// Unmark the entry so the next poll tries it again
+			if rmErr := vault.RemoveFeedEntry(feed.ID, e.GUID); rmErr != nil {
+				slog.Warn("failed to unmark feed entry", "feed", feed.URL, "guid", e.GUID, "error", rmErr)
+			}
+			return saved, fmt.Errorf("emit entry: %w", err)
+		}
+		today++
+		saved++
+	}
+	return saved, nil
+}
@@ -0,0 +1,173 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nerdneilsfield/dumper/internal/store"
+)
+
+const testRSS = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"><channel>
+<title>Example Blog</title>
+%s
+</channel></rss>`
+
+func rssItem(n int) string {
+	return fmt.Sprintf(`<item><title>Post %d</title><link>/posts/%d</link><guid>post-%d</guid>
+<description>&lt;p&gt;About Go %d&lt;/p&gt;</description><pubDate>Mon, 0%d Jan 2024 10:00:00 GMT</pubDate></item>`, n, n, n, n, n)
+}
+
+func TestParseFeed(t *testing.T) {
+	base, _ := url.Parse("https://example.com/feed.xml")
+
+	rss, err := ParseFeed([]byte(fmt.Sprintf(testRSS, rssItem(2)+rssItem(1))), base)
+	if err != nil {
+		t.Fatalf("parse rss: %v", err)
+	}
+	if rss.Title != "Example Blog" || len(rss.Entries) != 2 {
+		t.Fatalf("unexpected rss feed: %+v", rss)
+	}
+	e := rss.Entries[0]
+	if e.GUID != "post-2" || e.URL != "https://example.com/posts/2" || e.Summary != "About Go 2" || e.Published.Day() != 2 {
+		t.Errorf("unexpected rss entry: %+v", e)
+	}
+
+	atom, err := ParseFeed([]byte(`<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom"><title>Atom Blog</title>
+<entry><id>urn:1</id><title>Hello</title>
+<link rel="alternate" type="text/html" href="https://example.org/hello"/>
+<updated>2024-03-01T12:00:00Z</updated><summary>Hi there</summary></entry>
+</feed>`), base)
+	if err != nil {
+		t.Fatalf("parse atom: %v", err)
+	}
+	if atom.Title != "Atom Blog" || len(atom.Entries) != 1 {
+		t.Fatalf("unexpected atom feed: %+v", atom)
+	}
+	if e := atom.Entries[0]; e.GUID != "urn:1" || e.URL != "https://example.org/hello" || e.Published.Month() != 3 {
+		t.Errorf("unexpected atom entry: %+v", e)
+	}
+
+	if _, err := ParseFeed([]byte("<html><body>not a feed</body></html>"), base); !errors.Is(err, ErrNotAFeed) {
+		t.Errorf("expected ErrNotAFeed, got %v", err)
+	}
+}
+
+func TestFeedEntryMatches(t *testing.T) {
+	entry := FeedEntry{Title: "Go 1.25 released", Summary: "Release notes for the new version"}
+	cases := []struct {
+		keywords []string
+		want     bool
+	}{
+		{nil, true},
+		{[]string{"go"}, true},
+		{[]string{"rust"}, false},
+		{[]string{"rust", "release"}, true},
+		{[]string{"-notes"}, false},
+		{[]string{"go", "-notes"}, false},
+		{[]string{"-sponsored"}, true},
+	}
+	for _, c := range cases {
+		feed := &store.Feed{Keywords: c.keywords}
+		if got := feedEntryMatches(feed, entry); got != c.want {
+			t.Errorf("keywords %v: got %v, want %v", c.keywords, got, c.want)
+		}
+	}
+}
+
+func TestPollFeed(t *testing.T) {
+	var mu sync.Mutex
+	items := rssItem(1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprintf(w, testRSS, items)
+	}))
+	defer srv.Close()
+
+	manager, err := store.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create manager: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = manager.Close()
+	})
+	vault, err := manager.GetVault(1)
+	if err != nil {
+		t.Fatalf("get vault: %v", err)
+	}
+
+	reader := &FeedReader{client: srv.Client()}
+	source := &FeedSource{stores: manager, reader: reader}
+	ctx := context.Background()
+
+	feed := &store.Feed{URL: srv.URL + "/feed.xml", Tags: []string{"blogs"}, MaxPerDay: 2}
+	if _, err := reader.Subscribe(ctx, vault, feed); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if feed.Title != "Example Blog" {
+		t.Errorf("unexpected title: %q", feed.Title)
+	}
+	if _, err := reader.Subscribe(ctx, vault, &store.Feed{URL: feed.URL}); !errors.Is(err, ErrAlreadySubscribed) {
+		t.Errorf("expected ErrAlreadySubscribed, got %v", err)
+	}
+
+	var emitted []RawContent
+	emit := func(raw RawContent, reply any) error {
+		emitted = append(emitted, raw)
+		return nil
+	}
+
+	// Entries present when subscribing are not saved
+	if n, err := source.PollFeed(ctx, vault, 1, "en", feed, emit); err != nil || n != 0 {
+		t.Fatalf("first poll: saved %d, err %v", n, err)
+	}
+
+	mu.Lock()
+	items = rssItem(4) + rssItem(3) + rssItem(2) + rssItem(1)
+	mu.Unlock()
+	n, err := source.PollFeed(ctx, vault, 1, "en", feed, emit)
+	if err != nil || n != 2 {
+		t.Fatalf("second poll: saved %d, err %v", n, err)
+	}
+	if emitted[0].URL != srv.URL+"/posts/2" || emitted[1].URL != srv.URL+"/posts/3" {
+		t.Errorf("entries not emitted oldest first: %s, %s", emitted[0].URL, emitted[1].URL)
+	}
+	if raw := emitted[0]; raw.Type != ContentTypeLink || strings.Join(raw.Tags, ",") != "blogs" ||
+		raw.Source == nil || raw.Source.Kind != store.SourceFeed || raw.Source.Name != "Example Blog" || raw.Source.FeedID != feed.ID || raw.UserID != 1 {
+		t.Errorf("unexpected emitted content: %+v", raw)
+	}
+
+	// The daily limit is reached, so the last entry waits
+	if n, err := source.PollFeed(ctx, vault, 1, "en", feed, emit); err != nil || n != 0 {
+		t.Fatalf("third poll: saved %d, err %v", n, err)
+	}
+
+	// An entry that could not be queued is polled again
+	feed.MaxPerDay = 0
+	failing := func(raw RawContent, reply any) error { return errors.New("queue closed") }
+	if _, err := source.PollFeed(ctx, vault, 1, "en", feed, failing); err == nil {
+		t.Fatal("expected emit error")
+	}
+	emitted = nil
+	if n, err := source.PollFeed(ctx, vault, 1, "en", feed, emit); err != nil || n != 1 || emitted[0].URL != srv.URL+"/posts/4" {
+		t.Fatalf("retry poll: saved %d, err %v, %+v", n, err, emitted)
+	}
+
+	feeds, err := vault.ListFeeds()
+	if err != nil || len(feeds) != 1 {
+		t.Fatalf("list feeds: %v, %v", feeds, err)
+	}
+	if feeds[0].LastPolledAt.IsZero() || feeds[0].LastError != "" {
+		t.Errorf("unexpected feed state: %+v", feeds[0])
+	}
+}
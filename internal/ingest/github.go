@@ -0,0 +1,52 @@
+package ingest
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// githubExtractor reads a repository's description, topics and README.
+var githubExtractor = SiteExtractor{
+	Name:  "GitHub",
+	Hosts: []string{"github.com"},
+	Match: func(u *url.URL) bool {
+		// Repository home pages only: /owner/repo
+		return len(pathSegments(u)) == 2
+	},
+	Extract: extractGitHub,
+}
+
+func extractGitHub(doc *html.Node, u *url.URL) (*ExtractedContent, error) {
+	readme := textOf(findFirst(doc, byTag("article", "markdown-body")))
+	if readme == "" {
+		return nil, errNoSiteContent
+	}
+
+	segments := pathSegments(u)
+	repo := segments[0] + "/" + segments[1]
+
+	var parts []string
+	description := metaContent(doc, "og:description")
+	// GitHub fills in boilerplate for repositories without a description
+	if description != "" && !strings.HasPrefix(description, "Contribute to ") {
+		parts = append(parts, description)
+	}
+	var topics []string
+	for _, a := range findAll(doc, byTag("a", "topic-tag")) {
+		if topic := textOf(a); topic != "" {
+			topics = append(topics, topic)
+		}
+	}
+	if len(topics) > 0 {
+		parts = append(parts, "Topics: "+strings.Join(topics, ", "))
+	}
+	parts = append(parts, "README:\n\n"+readme)
+
+	return &ExtractedContent{
+		Title:   repo,
+		Content: strings.Join(parts, "\n\n"),
+		Excerpt: description,
+	}, nil
+}
@@ -0,0 +1,64 @@
+package ingest
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// maxThreadComments caps the comments kept from a discussion thread.
+const maxThreadComments = 10
+
+// hackerNewsExtractor reads a Hacker News story with its top comments.
+var hackerNewsExtractor = SiteExtractor{
+	Name:  "Hacker News",
+	Hosts: []string{"news.ycombinator.com"},
+	Match: func(u *url.URL) bool {
+		return u.Path == "/item" && u.Query().Get("id") != ""
+	},
+	Extract: extractHackerNews,
+}
+
+func extractHackerNews(doc *html.Node, _ *url.URL) (*ExtractedContent, error) {
+	titleLine := findFirst(doc, byTag("span", "titleline"))
+	link := findFirst(titleLine, byTag("a"))
+	title := textOf(link)
+	if title == "" {
+		return nil, errNoSiteContent
+	}
+
+	var parts []string
+	if href := attr(link, "href"); strings.HasPrefix(href, "http") {
+		parts = append(parts, "Link: "+href)
+	}
+	if text := textOf(findFirst(doc, byClass("toptext"))); text != "" {
+		parts = append(parts, text)
+	}
+
+	// Comments are listed in rank order; replies are indented
+	var comments []string
+	for _, row := range findAll(doc, byTag("tr", "athing", "comtr")) {
+		if attr(findFirst(row, byTag("td", "ind")), "indent") != "0" {
+			continue
+		}
+		text := textOf(findFirst(row, byClass("commtext")))
+		if text == "" {
+			continue
+		}
+		author := textOf(findFirst(row, byTag("a", "hnuser")))
+		comments = append(comments, fmt.Sprintf("%s: %s", author, text))
+		if len(comments) == maxThreadComments {
+			break
+		}
+	}
+	if len(comments) > 0 {
+		parts = append(parts, "Top comments:\n\n"+strings.Join(comments, "\n\n"))
+	}
+
+	return &ExtractedContent{
+		Title:   title,
+		Content: strings.Join(parts, "\n\n"),
+	}, nil
+}
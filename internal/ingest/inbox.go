@@ -0,0 +1,325 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nerdneilsfield/dumper/internal/queue"
+	"github.com/nerdneilsfield/dumper/internal/store"
+)
+
+const (
+	// inboxSettle is how long a file must go unmodified before it is picked
+	// up, so files still being copied in are left alone.
+	inboxSettle = 5 * time.Second
+	// inboxProcessing holds files whose jobs are queued, one directory each.
+	inboxProcessing = ".processing"
+	// inboxFailed receives files that could not be saved, each with a
+	// .error file next to it.
+	inboxFailed = "failed"
+)
+
+// inboxImageExts are the image formats saved as image items.
+var inboxImageExts = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true}
+
+// inboxReply locates a queued file for Ack.
+type inboxReply struct {
+	Path string `json:"path"`
+}
+
+// InboxSource ingests files dropped into <dir>/<user ID>/. Files are moved
+// aside while they are processed, then deleted once saved or moved to the
+// user's failed/ directory.
+type InboxSource struct {
+	dir      string
+	stores   store.Stores
+	jobs     *queue.Queue // looked up to settle claims left by a restart
+	interval time.Duration
+	settle   time.Duration
+}
+
+var _ InputSource = (*InboxSource)(nil)
+
+func NewInboxSource(dir string, stores store.Stores, jobs *queue.Queue, interval time.Duration) *InboxSource {
+	return &InboxSource{dir: dir, stores: stores, jobs: jobs, interval: interval, settle: inboxSettle}
+}
+
+func (s *InboxSource) Name() string {
+	return "inbox"
+}
+
+// Run settles files claimed before a restart, then scans the inbox every
+// interval until ctx is done.
+func (s *InboxSource) Run(ctx context.Context, emit EmitFunc) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("create inbox dir: %w", err)
+	}
+	if err := s.Recover(); err != nil {
+		slog.Error("failed to recover inbox files", "error", err)
+	}
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if _, err := s.Scan(ctx, emit); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				slog.Error("inbox scan failed", "error", err)
+			}
+		}
+	}
+}
+
+// Scan emits every settled file in the users' inbox directories and returns
+// how many were queued. Directories not named by a user ID are ignored.
+func (s *InboxSource) Scan(ctx context.Context, emit EmitFunc) (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, fmt.Errorf("read inbox: %w", err)
+	}
+	queued := 0
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return queued, ctx.Err()
+		}
+		userID, err := strconv.ParseInt(entry.Name(), 10, 64)
+		if !entry.IsDir() || err != nil || userID <= 0 {
+			continue
+		}
+		n, err := s.scanUser(userID, filepath.Join(s.dir, entry.Name()), emit)
+		queued += n
+		if err != nil {
+			slog.Error("inbox scan failed", "user_id", userID, "error", err)
+		}
+	}
+	return queued, nil
+}
+
+func (s *InboxSource) scanUser(userID int64, dir string, emit EmitFunc) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	lang := ""
+	queued := 0
+	for _, entry := range entries {
+		// Hidden files include editors' temporary files and our own state
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < s.settle {
+			continue
+		}
+
+		if lang == "" {
+			vault, err := s.stores.GetVault(userID)
+			if err != nil {
+				return queued, fmt.Errorf("get vault: %w", err)
+			}
+			if lang, err = userLanguage(vault); err != nil {
+				return queued, err
+			}
+		}
+
+		path, err := s.claim(dir, entry.Name())
+		if err != nil {
+			return queued, fmt.Errorf("claim %s: %w", entry.Name(), err)
+		}
+		raw, err := inboxContent(path)
+		if err != nil {
+			s.fail(path, err)
+			continue
+		}
+		raw.UserID, raw.Language = userID, lang
+		if err := emit(raw, inboxReply{Path: path}); err != nil {
+			// Put the file back to be picked up by the next scan
+			_ = os.Rename(path, filepath.Join(dir, entry.Name()))
+			_ = os.Remove(filepath.Dir(path))
+			return queued, fmt.Errorf("emit %s: %w", entry.Name(), err)
+		}
+		queued++
+		slog.Info("inbox file queued", "user_id", userID, "file", entry.Name())
+	}
+	return queued, nil
+}
+
+// claim moves a file into a directory of its own under .processing, so it
+// is not picked up again, and returns its new path.
+func (s *InboxSource) claim(dir, name string) (string, error) {
+	claimDir := filepath.Join(dir, inboxProcessing, strconv.FormatInt(time.Now().UnixNano(), 10))
+	if err := os.MkdirAll(claimDir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(claimDir, name)
+	if err := os.Rename(filepath.Join(dir, name), path); err != nil {
+		_ = os.Remove(claimDir)
+		return "", err
+	}
+	return path, nil
+}
+
+// Recover settles files left under .processing by a previous run. A file
+// whose job was never queued goes back to the inbox, and one whose job
+// finished without being acknowledged is acknowledged now. Files with a job
+// still waiting are left to it.
+func (s *InboxSource) Recover() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("read inbox: %w", err)
+	}
+	for _, entry := range entries {
+		userID, err := strconv.ParseInt(entry.Name(), 10, 64)
+		if !entry.IsDir() || err != nil || userID <= 0 {
+			continue
+		}
+		dir := filepath.Join(s.dir, entry.Name())
+		claims, err := os.ReadDir(filepath.Join(dir, inboxProcessing))
+		if err != nil {
+			continue
+		}
+		for _, claim := range claims {
+			claimDir := filepath.Join(dir, inboxProcessing, claim.Name())
+			files, err := os.ReadDir(claimDir)
+			if err != nil {
+				continue
+			}
+			for _, file := range files {
+				if err := s.recoverFile(dir, filepath.Join(claimDir, file.Name())); err != nil {
+					slog.Error("failed to recover inbox file", "path", file.Name(), "error", err)
+				}
+			}
+			// Only removed once empty
+			_ = os.Remove(claimDir)
+		}
+	}
+	return nil
+}
+
+func (s *InboxSource) recoverFile(dir, path string) error {
+	reply, err := json.Marshal(inboxReply{Path: path})
+	if err != nil {
+		return err
+	}
+	jobs, err := s.jobs.Related(&queue.Job{Reply: reply})
+	if err != nil {
+		return err
+	}
+	if len(jobs) == 0 {
+		dest := filepath.Join(dir, filepath.Base(path))
+		if _, err := os.Stat(dest); err == nil {
+			return fmt.Errorf("a newer %s is in the inbox", filepath.Base(path))
+		}
+		slog.Info("inbox file returned", "file", filepath.Base(path))
+		return os.Rename(path, dest)
+	}
+	job := jobs[len(jobs)-1]
+	if job.Status == queue.StatusDone || job.Status == queue.StatusFailed {
+		s.Ack(&job)
+	}
+	return nil
+}
+
+// Ack deletes a saved file, or moves a failed one to failed/ with its error.
+func (s *InboxSource) Ack(job *queue.Job) {
+	var reply inboxReply
+	if len(job.Reply) == 0 {
+		return
+	}
+	if err := json.Unmarshal(job.Reply, &reply); err != nil || reply.Path == "" {
+		slog.Error("failed to decode inbox reply", "job_id", job.ID, "error", err)
+		return
+	}
+	if job.Status == queue.StatusFailed {
+		s.fail(reply.Path, errors.New(job.LastError))
+		return
+	}
+	if err := os.RemoveAll(filepath.Dir(reply.Path)); err != nil {
+		slog.Error("failed to remove inbox file", "path", reply.Path, "error", err)
+	}
+}
+
+// fail moves a claimed file to its user's failed/ directory and writes the
+// error next to it.
+func (s *InboxSource) fail(path string, cause error) {
+	claimDir := filepath.Dir(path)
+	failedDir := filepath.Join(filepath.Dir(filepath.Dir(claimDir)), inboxFailed)
+	dest := filepath.Join(failedDir, filepath.Base(path))
+	slog.Warn("inbox file failed", "file", filepath.Base(path), "error", cause)
+
+	if err := os.MkdirAll(failedDir, 0755); err != nil {
+		slog.Error("failed to create inbox failed dir", "error", err)
+		return
+	}
+	if err := os.Rename(path, dest); err != nil {
+		slog.Error("failed to move inbox file", "path", path, "error", err)
+		return
+	}
+	if err := os.WriteFile(dest+".error", []byte(cause.Error()+"\n"), 0644); err != nil {
+		slog.Error("failed to write inbox error", "path", dest, "error", err)
+	}
+	_ = os.Remove(claimDir)
+}
+
+// inboxContent reads a dropped file as the content it holds.
+func inboxContent(path string) (RawContent, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return RawContent{}, err
+	}
+	if info.Size() > store.MaxBlobSize {
+		return RawContent{}, fmt.Errorf("file too large: %d MB max", store.MaxBlobSize>>20)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return RawContent{}, err
+	}
+	return fileContent(filepath.Base(path), data)
+}
+
+// fileContent maps a file to the content it holds: a link from a URL
+// shortcut or a text file holding just a URL, a note from other text, an
+// image, audio to transcribe, or a document.
+func fileContent(name string, data []byte) (RawContent, error) {
+	ext := strings.ToLower(filepath.Ext(name))
+	mimeType := mime.TypeByExtension(ext)
+	switch {
+	case ext == ".url" || ext == ".webloc":
+		urls := ExtractURLs(string(data))
+		if len(urls) == 0 {
+			return RawContent{}, errors.New("no URL in shortcut")
+		}
+		return RawContent{Type: ContentTypeLink, URL: urls[0]}, nil
+	case ext == ".txt" || ext == ".md":
+		text := strings.TrimSpace(string(data))
+		if text == "" {
+			return RawContent{}, errors.New("empty file")
+		}
+		if IsURL(text) && len(strings.Fields(text)) == 1 {
+			return RawContent{Type: ContentTypeLink, URL: text}, nil
+		}
+		return RawContent{Type: ContentTypeNote, Text: text}, nil
+	case inboxImageExts[ext]:
+		return RawContent{Type: ContentTypeImage, ImageData: data, ImageExt: strings.TrimPrefix(ext, ".")}, nil
+	case strings.HasPrefix(mimeType, "audio/"):
+		return RawContent{Type: ContentTypeVoice, FileData: data, FileName: name, FileMIME: mimeType}, nil
+	case SupportsDocument(mimeType, name):
+		return RawContent{Type: ContentTypeDocument, FileData: data, FileName: name, FileMIME: mimeType}, nil
+	default:
+		return RawContent{}, fmt.Errorf("unsupported file type: %s", ext)
+	}
+}
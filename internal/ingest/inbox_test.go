@@ -0,0 +1,197 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/nerdneilsfield/dumper/internal/queue"
+	"github.com/nerdneilsfield/dumper/internal/store"
+)
+
+func TestInboxContent(t *testing.T) {
+	dir := t.TempDir()
+	cases := []struct {
+		name, data string
+		want       ContentType
+	}{
+		{"link.txt", " https://example.com/post \n", ContentTypeLink},
+		{"shortcut.url", "[InternetShortcut]\r\nURL=https://example.com/a\r\n", ContentTypeLink},
+		{"idea.md", "# Idea\n\nSee https://example.com for more", ContentTypeNote},
+		{"photo.JPG", "\xff\xd8\xff", ContentTypeImage},
+		{"memo.mp3", "ID3", ContentTypeVoice},
+		{"paper.pdf", "%PDF-1.4", ContentTypeDocument},
+	}
+	for _, c := range cases {
+		path := filepath.Join(dir, c.name)
+		if err := os.WriteFile(path, []byte(c.data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		raw, err := inboxContent(path)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if raw.Type != c.want {
+			t.Errorf("%s: got %s, want %s", c.name, raw.Type, c.want)
+		}
+	}
+
+	for _, name := range []string{"empty.txt", "tool.exe"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(" "), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := inboxContent(path); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestInboxSource(t *testing.T) {
+	dataDir := t.TempDir()
+	manager, err := store.NewManager(dataDir)
+	if err != nil {
+		t.Fatalf("create manager: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = manager.Close()
+	})
+
+	inbox := filepath.Join(dataDir, "inbox")
+	userDir := filepath.Join(inbox, "42")
+	for _, dir := range []string{userDir, filepath.Join(inbox, "not-a-user")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, data := range map[string]string{
+		"link.txt":           "https://example.com/post",
+		"note.md":            "Remember the milk",
+		".partial.txt":       "still copying",
+		"../not-a-user/a.md": "ignored",
+	} {
+		if err := os.WriteFile(filepath.Join(userDir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	source := NewInboxSource(inbox, manager, nil, 0)
+	source.settle = 0
+	jobs := map[string]*queue.Job{}
+	emit := func(raw RawContent, reply any) error {
+		if raw.UserID != 42 || raw.Language != "en" {
+			t.Errorf("unexpected content: %+v", raw)
+		}
+		data, _ := json.Marshal(reply)
+		key := raw.URL + raw.Text
+		jobs[key] = &queue.Job{UserID: raw.UserID, Source: source.Name(), Reply: data}
+		return nil
+	}
+
+	n, err := source.Scan(context.Background(), emit)
+	if err != nil || n != 2 {
+		t.Fatalf("scan: queued %d, err %v", n, err)
+	}
+	// Claimed files are not picked up twice
+	if n, _ := source.Scan(context.Background(), emit); n != 0 {
+		t.Fatalf("second scan queued %d files", n)
+	}
+	for _, path := range []string{filepath.Join(userDir, ".partial.txt"), filepath.Join(inbox, "not-a-user", "a.md")} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("file should be left alone: %v", err)
+		}
+	}
+
+	done := jobs["https://example.com/post"]
+	done.Status = queue.StatusDone
+	source.Ack(done)
+	failed := jobs["Remember the milk"]
+	failed.Status, failed.LastError = queue.StatusFailed, "llm unavailable"
+	source.Ack(failed)
+
+	entries, err := os.ReadDir(filepath.Join(userDir, inboxProcessing))
+	if err != nil || len(entries) != 0 {
+		t.Errorf("processing dir not emptied: %v, %v", entries, err)
+	}
+	if _, err := os.Stat(filepath.Join(userDir, inboxFailed, "note.md")); err != nil {
+		t.Errorf("failed file not kept: %v", err)
+	}
+	msg, err := os.ReadFile(filepath.Join(userDir, inboxFailed, "note.md.error"))
+	if err != nil || string(msg) != "llm unavailable\n" {
+		t.Errorf("unexpected error file: %q, %v", msg, err)
+	}
+}
+
+type ackRecorder struct {
+	name string
+	jobs []*queue.Job
+}
+
+func (r *ackRecorder) Name() string                                 { return r.name }
+func (r *ackRecorder) Run(ctx context.Context, emit EmitFunc) error { return nil }
+func (r *ackRecorder) Ack(job *queue.Job)                           { r.jobs = append(r.jobs, job) }
+
+func TestSourcesAck(t *testing.T) {
+	telegram := &ackRecorder{name: TelegramSource}
+	inbox := &ackRecorder{name: "inbox"}
+	sources := NewSources(nil, telegram, inbox)
+
+	sources.Ack(&queue.Job{ID: 1, Source: "inbox"})
+	// Jobs queued before sources were recorded belong to the bot
+	sources.Ack(&queue.Job{ID: 2})
+	sources.Ack(&queue.Job{ID: 3, Source: "gone"})
+
+	if len(inbox.jobs) != 1 || inbox.jobs[0].ID != 1 {
+		t.Errorf("inbox acks: %+v", inbox.jobs)
+	}
+	if len(telegram.jobs) != 1 || telegram.jobs[0].ID != 2 {
+		t.Errorf("telegram acks: %+v", telegram.jobs)
+	}
+}
+
+func TestInboxRecover(t *testing.T) {
+	dataDir := t.TempDir()
+	jobs, err := queue.Open(filepath.Join(dataDir, "queue.db"), queue.Options{})
+	if err != nil {
+		t.Fatalf("open queue: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = jobs.Close()
+	})
+
+	inbox := filepath.Join(dataDir, "inbox")
+	userDir := filepath.Join(inbox, "42")
+	source := NewInboxSource(inbox, nil, jobs, 0)
+	// Two files claimed before a crash: one was queued, the other not
+	var claimed []string
+	for i, name := range []string{"queued.md", "lost.md"} {
+		path := filepath.Join(userDir, inboxProcessing, strconv.Itoa(i), name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("note"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		claimed = append(claimed, path)
+	}
+	if _, err := jobs.EnqueueFrom(source.Name(), 42, "payload", inboxReply{Path: claimed[0]}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	if err := source.Recover(); err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	if _, err := os.Stat(claimed[0]); err != nil {
+		t.Errorf("queued file should be left to its job: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(userDir, "lost.md")); err != nil {
+		t.Errorf("unqueued file not returned to the inbox: %v", err)
+	}
+	if _, err := os.Stat(filepath.Dir(claimed[1])); !os.IsNotExist(err) {
+		t.Errorf("empty claim dir kept: %v", err)
+	}
+}
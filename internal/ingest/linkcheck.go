@@ -0,0 +1,235 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nerdneilsfield/dumper/internal/store"
+)
+
+// deadAfterFailures is how many consecutive failed checks turn an
+// unreachable link dead. Missing pages (404, 410) are dead at once.
+const deadAfterFailures = 3
+
+// waybackAvailableURL is the Internet Archive's snapshot lookup API.
+const waybackAvailableURL = "https://archive.org/wayback/available"
+
+// LinkCheckOptions controls a link check run.
+type LinkCheckOptions struct {
+	// MaxAge is how long a check result is trusted before the link is
+	// checked again
+	MaxAge time.Duration
+	// BatchSize caps the links checked per user in one run
+	BatchSize int
+}
+
+// DeadLinkFunc is told about an item whose link has just been found dead.
+type DeadLinkFunc func(userID int64, item *store.Item)
+
+// LinkChecker re-checks saved URLs and finds archived copies of dead ones.
+type LinkChecker struct {
+	client  *http.Client
+	wayback string
+}
+
+func NewLinkChecker() *LinkChecker {
+	return &LinkChecker{
+		client:  &http.Client{Timeout: 20 * time.Second},
+		wayback: waybackAvailableURL,
+	}
+}
+
+// Check requests rawURL and returns its health, carrying the failure count
+// and dead-since time over from the previous check.
+func (c *LinkChecker) Check(ctx context.Context, rawURL string, prev *store.LinkHealth) *store.LinkHealth {
+	if prev == nil {
+		prev = &store.LinkHealth{}
+	}
+	h := &store.LinkHealth{CheckedAt: time.Now().UTC()}
+
+	code, finalURL, err := c.request(ctx, http.MethodHead, rawURL)
+	// Some servers refuse or mishandle HEAD
+	if err != nil || code == http.StatusMethodNotAllowed || code == http.StatusNotImplemented ||
+		code == http.StatusForbidden || code == http.StatusBadRequest || code == http.StatusNotFound {
+		code, finalURL, err = c.request(ctx, http.MethodGet, rawURL)
+	}
+	h.StatusCode = code
+
+	switch {
+	case err != nil:
+		h.Error = err.Error()
+		h.Failures = prev.Failures + 1
+	case code == http.StatusNotFound || code == http.StatusGone:
+		h.Error = http.StatusText(code)
+		h.Failures = max(prev.Failures+1, deadAfterFailures)
+	case code >= 500:
+		h.Error = http.StatusText(code)
+		h.Failures = prev.Failures + 1
+	default:
+		// Other client errors such as 401 or 429 mean the site is up
+		// but turned the checker away
+		h.Status = store.LinkOK
+		if strings.TrimSuffix(finalURL, "/") != strings.TrimSuffix(rawURL, "/") {
+			h.Status = store.LinkRedirected
+			h.FinalURL = finalURL
+		}
+		return h
+	}
+
+	h.Status = store.LinkUnreachable
+	if h.Failures >= deadAfterFailures {
+		h.Status = store.LinkDead
+		h.DeadSince, h.WaybackURL = prev.DeadSince, prev.WaybackURL
+		if h.DeadSince.IsZero() {
+			h.DeadSince = h.CheckedAt
+		}
+		if h.WaybackURL == "" {
+			if h.WaybackURL, err = c.waybackSnapshot(ctx, rawURL); err != nil {
+				slog.Debug("wayback lookup failed", "url", rawURL, "error", err)
+			}
+		}
+	}
+	return h
+}
+
+// request returns the status code and the URL redirects ended at.
+func (c *LinkChecker) request(ctx context.Context, method, rawURL string) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return 0, "", fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; Dumper/1.0; +https://github.com/dumper)")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	// Only the status matters; drain a little so the connection is reused
+	_, _ = io.CopyN(io.Discard, resp.Body, 64<<10)
+	return resp.StatusCode, resp.Request.URL.String(), nil
+}
+
+// waybackSnapshot returns the URL of the Internet Archive's closest copy of
+// rawURL, or "" when it has none.
+func (c *LinkChecker) waybackSnapshot(ctx context.Context, rawURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.wayback+"?url="+url.QueryEscape(rawURL), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("bad status: %d", resp.StatusCode)
+	}
+
+	var result struct {
+		ArchivedSnapshots struct {
+			Closest struct {
+				Available bool   `json:"available"`
+				URL       string `json:"url"`
+				Status    string `json:"status"`
+			} `json:"closest"`
+		} `json:"archived_snapshots"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil {
+		return "", fmt.Errorf("decode response: %w", err)
+	}
+	closest := result.ArchivedSnapshots.Closest
+	if !closest.Available || closest.Status != "200" {
+		return "", nil
+	}
+	return strings.Replace(closest.URL, "http://", "https://", 1), nil
+}
+
+var waybackTimestamp = regexp.MustCompile(`^(https?://web\.archive\.org/web/\d+)/`)
+
+// waybackFrameURL returns the form of a Wayback Machine URL that serves the
+// archived page without the archive's toolbar, with its resources still
+// pointing into the archive.
+func waybackFrameURL(snapshot string) string {
+	return waybackTimestamp.ReplaceAllString(snapshot, "${1}if_/")
+}
+
+// CheckLinks checks the user's link items that are due and records the
+// results. Items found dead by this run are passed to onDead.
+func (c *LinkChecker) CheckLinks(ctx context.Context, vault store.Vault, opts LinkCheckOptions, onDead func(*store.Item)) (checked int, err error) {
+	items, err := vault.LinksToCheck(time.Now().Add(-opts.MaxAge), opts.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("list links: %w", err)
+	}
+	for i := range items {
+		if ctx.Err() != nil {
+			return checked, ctx.Err()
+		}
+		item := &items[i]
+		health := c.Check(ctx, item.URL, item.Link)
+		wasDead := item.Link != nil && item.Link.Status == store.LinkDead
+		if err := vault.SetLinkHealth(item.ID, health); err != nil {
+			return checked, fmt.Errorf("save link health of %s: %w", item.ID, err)
+		}
+		checked++
+		item.Link = health
+		if health.Status == store.LinkDead && !wasDead && onDead != nil {
+			onDead(item)
+		}
+	}
+	return checked, nil
+}
+
+// RunLinkChecker checks every user's saved links every interval until ctx is
+// done, passing newly dead links to notify.
+func RunLinkChecker(ctx context.Context, stores store.Stores, interval time.Duration, opts LinkCheckOptions, notify DeadLinkFunc) error {
+	checker := NewLinkChecker()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			userIDs, err := stores.UserIDs()
+			if err != nil {
+				slog.Error("link check failed", "error", err)
+				continue
+			}
+			for _, userID := range userIDs {
+				vault, err := stores.GetVault(userID)
+				if err != nil {
+					slog.Error("link check failed", "user_id", userID, "error", err)
+					continue
+				}
+				dead := 0
+				checked, err := checker.CheckLinks(ctx, vault, opts, func(item *store.Item) {
+					dead++
+					if notify != nil {
+						notify(userID, item)
+					}
+				})
+				if errors.Is(err, context.Canceled) {
+					return nil
+				}
+				if err != nil {
+					slog.Error("link check failed", "user_id", userID, "error", err)
+				}
+				if checked > 0 {
+					slog.Info("links checked", "user_id", userID, "checked", checked, "dead", dead)
+				}
+			}
+		}
+	}
+}
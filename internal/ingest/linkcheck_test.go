@@ -0,0 +1,89 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nerdneilsfield/dumper/internal/store"
+)
+
+func TestLinkCheckerCheck(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gone", http.StatusGone)
+	})
+	mux.HandleFunc("/nohead", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/broken", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	mux.HandleFunc("/wayback/available", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"archived_snapshots":{"closest":{"available":true,"status":"200",
+			"url":"http://web.archive.org/web/20200101000000/%s","timestamp":"20200101000000"}}}`, r.URL.Query().Get("url"))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	checker := &LinkChecker{client: srv.Client(), wayback: srv.URL + "/wayback/available"}
+	ctx := context.Background()
+
+	if h := checker.Check(ctx, srv.URL+"/ok", nil); h.Status != store.LinkOK || h.StatusCode != 200 {
+		t.Errorf("ok: %+v", h)
+	}
+	if h := checker.Check(ctx, srv.URL+"/nohead", nil); h.Status != store.LinkOK {
+		t.Errorf("nohead: %+v", h)
+	}
+	if h := checker.Check(ctx, srv.URL+"/moved", nil); h.Status != store.LinkRedirected || h.FinalURL != srv.URL+"/ok" {
+		t.Errorf("moved: %+v", h)
+	}
+
+	gone := checker.Check(ctx, srv.URL+"/gone", nil)
+	if gone.Status != store.LinkDead || gone.StatusCode != 410 || gone.DeadSince.IsZero() {
+		t.Errorf("gone: %+v", gone)
+	}
+	if want := "https://web.archive.org/web/20200101000000/" + srv.URL + "/gone"; gone.WaybackURL != want {
+		t.Errorf("wayback url = %q, want %q", gone.WaybackURL, want)
+	}
+
+	// Server errors are retried before the link is declared dead
+	var h *store.LinkHealth
+	for i := 1; i <= deadAfterFailures; i++ {
+		h = checker.Check(ctx, srv.URL+"/broken", h)
+		want := store.LinkUnreachable
+		if i == deadAfterFailures {
+			want = store.LinkDead
+		}
+		if h.Status != want || h.Failures != i {
+			t.Fatalf("check %d: %+v", i, h)
+		}
+	}
+
+	// A dead link keeps the day it died
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	h.DeadSince = since
+	if h = checker.Check(ctx, srv.URL+"/broken", h); !h.DeadSince.Equal(since) {
+		t.Errorf("dead since = %v", h.DeadSince)
+	}
+	// and recovers when it answers again
+	if h = checker.Check(ctx, srv.URL+"/ok", h); h.Status != store.LinkOK || h.Failures != 0 || !h.DeadSince.IsZero() {
+		t.Errorf("recovered: %+v", h)
+	}
+}
+
+func TestWaybackFrameURL(t *testing.T) {
+	got := waybackFrameURL("https://web.archive.org/web/20200101000000/https://example.com/a")
+	if want := "https://web.archive.org/web/20200101000000if_/https://example.com/a"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
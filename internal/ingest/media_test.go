@@ -0,0 +1,148 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// fixtureGet serves recorded responses by URL.
+func fixtureGet(fixtures map[string]string) FetchFunc {
+	return func(_ context.Context, u string) ([]byte, error) {
+		name, ok := fixtures[u]
+		if !ok {
+			return nil, fmt.Errorf("unexpected fetch of %s", u)
+		}
+		return os.ReadFile(filepath.Join("testdata", "sites", name))
+	}
+}
+
+// extractFixture runs the extractor for rawURL, including Follow, on a
+// recorded page.
+func extractFixture(t *testing.T, rawURL, fixture string, get FetchFunc) *ExtractedContent {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("parse url: %v", err)
+	}
+	site := lookupSiteExtractor(u)
+	if site == nil {
+		t.Fatalf("no extractor for %s", rawURL)
+	}
+	body, err := os.ReadFile(filepath.Join("testdata", "sites", fixture))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("parse fixture: %v", err)
+	}
+	content, err := extractSite(site, doc, u)
+	if err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	if site.Follow != nil {
+		if err := site.Follow(context.Background(), get, doc, u, content); err != nil {
+			t.Fatalf("follow: %v", err)
+		}
+	}
+	return content
+}
+
+func TestYouTubeExtractor(t *testing.T) {
+	get := fixtureGet(map[string]string{
+		// Uploaded captions are preferred over the automatic track
+		"https://www.youtube.com/api/timedtext?v=oV9rvDllKEg&lang=en": "youtube_captions.xml",
+	})
+	content := extractFixture(t, "https://youtu.be/oV9rvDllKEg", "youtube.html", get)
+
+	if content.Title != "Rob Pike - 'Concurrency Is Not Parallelism'" || content.Kind != "video transcript" {
+		t.Fatalf("unexpected title/kind: %q / %q", content.Title, content.Kind)
+	}
+	for _, want := range []string{
+		"Channel: gotalks",
+		"Duration: 31:36",
+		"Slides: https://go.dev/talks/2012/waza.slide",
+		"Transcript:\nIf you're looking at programming languages\nyou hear a lot about concurrency.\nConcurrency is not parallelism.",
+	} {
+		if !strings.Contains(content.Content, want) {
+			t.Errorf("content missing %q:\n%s", want, content.Content)
+		}
+	}
+	if strings.Count(content.Content, "Concurrency is not parallelism.") != 1 {
+		t.Errorf("repeated caption kept:\n%s", content.Content)
+	}
+	if strings.Contains(content.Content, "Before you continue") {
+		t.Errorf("consent banner in content:\n%s", content.Content)
+	}
+}
+
+func TestPodcastExtractor(t *testing.T) {
+	get := fixtureGet(map[string]string{
+		"https://podcasts.apple.com/transcripts/gotime-300.vtt": "podcast.vtt",
+	})
+	content := extractFixture(t, "https://podcasts.apple.com/us/podcast/300/id1?i=1000", "podcast.html", get)
+
+	if content.Title != "#300 Go in production" || content.SiteName != "Go Time: Golang, Software Engineering" {
+		t.Fatalf("unexpected title/site: %q / %q", content.Title, content.SiteName)
+	}
+	for _, want := range []string{
+		"Duration: 1:12:05",
+		"Published: 2024-01-18",
+		"observability, graceful shutdown",
+		"Transcript:\nWelcome to Go Time, episode three hundred.\nToday we're talking about Go in production.",
+	} {
+		if !strings.Contains(content.Content, want) {
+			t.Errorf("content missing %q:\n%s", want, content.Content)
+		}
+	}
+	if content.Kind != "podcast transcript" {
+		t.Fatalf("unexpected kind %q", content.Kind)
+	}
+}
+
+func TestParseCaptions(t *testing.T) {
+	srt := "1\r\n00:00:01,000 --> 00:00:02,000\r\nHello <i>there</i>\r\n\r\n2\r\n00:00:02,000 --> 00:00:03,000\r\nGeneral Kenobi\r\n"
+	if got, err := parseCaptions([]byte(srt)); err != nil || got != "Hello there\nGeneral Kenobi" {
+		t.Fatalf("srt: got %q, %v", got, err)
+	}
+	timedText := `<transcript><text start="0" dur="1">it&amp;#39;s</text><text start="1" dur="1">fine</text></transcript>`
+	if got, err := parseCaptions([]byte(timedText)); err != nil || got != "it's\nfine" {
+		t.Fatalf("timedtext: got %q, %v", got, err)
+	}
+	if _, err := parseCaptions([]byte("WEBVTT\n\n")); err == nil {
+		t.Fatal("expected error for a track without cues")
+	}
+}
+
+func TestMediaLinks(t *testing.T) {
+	for _, tc := range []struct {
+		url string
+		id  string
+	}{
+		{"https://www.youtube.com/watch?v=abc123&t=42", "abc123"},
+		{"https://youtu.be/abc123?si=x", "abc123"},
+		{"https://m.youtube.com/shorts/abc123", "abc123"},
+		{"https://www.youtube.com/@gotalks", ""},
+	} {
+		u, _ := url.Parse(tc.url)
+		if got := youtubeVideoID(u); got != tc.id {
+			t.Errorf("%s: video id %q, want %q", tc.url, got, tc.id)
+		}
+	}
+
+	if d, ok := parseISODuration("PT1H2M3.5S"); !ok || d != time.Hour+2*time.Minute+3500*time.Millisecond {
+		t.Errorf("unexpected duration %v %v", d, ok)
+	}
+	if u, _ := url.Parse("https://open.spotify.com/track/1"); lookupSiteExtractor(u) != nil {
+		t.Error("spotify tracks should not use the podcast extractor")
+	}
+}
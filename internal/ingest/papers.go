@@ -0,0 +1,239 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/nerdneilsfield/dumper/internal/store"
+	"golang.org/x/net/html"
+)
+
+// arxivExtractor reads a paper's metadata from its arXiv abstract page. Links
+// to the PDF also keep the paper itself.
+var arxivExtractor = SiteExtractor{
+	Name:  "arXiv",
+	Hosts: []string{"arxiv.org", "export.arxiv.org"},
+	Match: func(u *url.URL) bool {
+		return arxivID(u) != ""
+	},
+	FetchURL: func(u *url.URL) string {
+		return "https://arxiv.org/abs/" + arxivID(u)
+	},
+	Extract: func(doc *html.Node, u *url.URL) (*ExtractedContent, error) {
+		content, err := extractPaper(doc, u)
+		if err == nil && content.Structured.ArXivID == "" {
+			content.Structured.ArXivID = arxivID(u)
+		}
+		return content, err
+	},
+	Follow: followArXivPDF,
+}
+
+// doiExtractor looks DOIs up in Crossref, falling back to the citation
+// metadata on the publisher's page the DOI resolves to.
+var doiExtractor = SiteExtractor{
+	Name:  "DOI",
+	Hosts: []string{"doi.org", "dx.doi.org"},
+	Match: func(u *url.URL) bool {
+		return doiFromPath(u) != ""
+	},
+	API:     crossrefPaper,
+	Extract: extractPaper,
+}
+
+// arxivID returns the paper an arXiv link points at, e.g. 1706.03762v5 or
+// hep-th/9901001, or "".
+func arxivID(u *url.URL) string {
+	segments := pathSegments(u)
+	if len(segments) < 2 {
+		return ""
+	}
+	switch segments[0] {
+	case "abs", "pdf", "html":
+		return strings.TrimSuffix(strings.Join(segments[1:], "/"), ".pdf")
+	}
+	return ""
+}
+
+var doiPattern = regexp.MustCompile(`^10\.\d{4,9}/\S+$`)
+
+func doiFromPath(u *url.URL) string {
+	doi := strings.TrimPrefix(u.Path, "/")
+	if !doiPattern.MatchString(doi) {
+		return ""
+	}
+	return doi
+}
+
+// extractPaper reads the Highwire Press citation_* tags that arXiv and most
+// publishers put on article pages.
+func extractPaper(doc *html.Node, _ *url.URL) (*ExtractedContent, error) {
+	data := &store.StructuredData{
+		Type:        store.SchemaPaper,
+		Name:        metaContent(doc, "citation_title"),
+		Description: metaContent(doc, "citation_abstract"),
+		DOI:         metaContent(doc, "citation_doi"),
+		ArXivID:     metaContent(doc, "citation_arxiv_id"),
+	}
+	if data.Name == "" {
+		return nil, errNoSiteContent
+	}
+	for _, meta := range findAll(doc, byTag("meta")) {
+		if attr(meta, "name") == "citation_author" {
+			if author := strings.TrimSpace(attr(meta, "content")); author != "" {
+				data.Authors = append(data.Authors, author)
+			}
+		}
+	}
+	for _, key := range []string{"citation_journal_title", "citation_conference_title", "citation_inbook_title"} {
+		if data.Venue = metaContent(doc, key); data.Venue != "" {
+			break
+		}
+	}
+	for _, key := range []string{"citation_publication_date", "citation_date", "citation_online_date"} {
+		if date := metaContent(doc, key); date != "" {
+			data.Published = date
+			data.Year, _ = strconv.Atoi(date[:min(4, len(date))])
+			break
+		}
+	}
+	if data.Description == "" {
+		// arXiv keeps the abstract in the page body
+		abstract := textOf(findFirst(doc, byTag("blockquote", "abstract")))
+		data.Description = strings.TrimSpace(strings.TrimPrefix(abstract, "Abstract:"))
+	}
+	if data.Description == "" {
+		data.Description = metaContent(doc, "description")
+	}
+
+	return paperContent(data), nil
+}
+
+// followArXivPDF downloads the paper when the link pointed at its PDF.
+func followArXivPDF(ctx context.Context, get FetchFunc, doc *html.Node, u *url.URL, content *ExtractedContent) error {
+	if !strings.HasPrefix(u.Path, "/pdf/") {
+		return nil
+	}
+	pdfURL := metaContent(doc, "citation_pdf_url")
+	if pdfURL == "" {
+		pdfURL = "https://arxiv.org/pdf/" + arxivID(u)
+	}
+	data, err := get(ctx, pdfURL)
+	if err != nil {
+		return fmt.Errorf("fetch pdf: %w", err)
+	}
+	parsed, err := parsePDF(data)
+	if err != nil {
+		return err
+	}
+	content.Document = data
+	content.MIME = "application/pdf"
+	content.Content = strings.TrimSpace(content.Content + "\n\nFull text:\n" + parsed.Text)
+	return nil
+}
+
+// crossrefWork is the part of a Crossref works response read here.
+type crossrefWork struct {
+	Message struct {
+		DOI            string   `json:"DOI"`
+		Type           string   `json:"type"`
+		Title          []string `json:"title"`
+		ContainerTitle []string `json:"container-title"`
+		Publisher      string   `json:"publisher"`
+		Abstract       string   `json:"abstract"`
+		Author         []struct {
+			Given  string `json:"given"`
+			Family string `json:"family"`
+			Name   string `json:"name"` // organisations
+		} `json:"author"`
+		Issued struct {
+			DateParts [][]int `json:"date-parts"`
+		} `json:"issued"`
+	} `json:"message"`
+}
+
+// crossrefPaper reads a DOI's metadata from the Crossref API.
+func crossrefPaper(ctx context.Context, get FetchFunc, u *url.URL) (*ExtractedContent, error) {
+	doi := doiFromPath(u)
+	body, err := get(ctx, "https://api.crossref.org/works/"+url.PathEscape(doi))
+	if err != nil {
+		return nil, fmt.Errorf("crossref: %w", err)
+	}
+	var work crossrefWork
+	if err := json.Unmarshal(body, &work); err != nil {
+		return nil, fmt.Errorf("decode crossref: %w", err)
+	}
+
+	msg := work.Message
+	if len(msg.Title) == 0 {
+		return nil, errNoSiteContent
+	}
+	data := &store.StructuredData{
+		Type: store.SchemaPaper,
+		Name: strings.Join(strings.Fields(msg.Title[0]), " "),
+		DOI:  msg.DOI,
+	}
+	if data.DOI == "" {
+		data.DOI = doi
+	}
+	for _, a := range msg.Author {
+		switch {
+		case a.Family != "" && a.Given != "":
+			data.Authors = append(data.Authors, a.Family+", "+a.Given)
+		case a.Family != "":
+			data.Authors = append(data.Authors, a.Family)
+		case a.Name != "":
+			data.Authors = append(data.Authors, a.Name)
+		}
+	}
+	if len(msg.ContainerTitle) > 0 {
+		data.Venue = msg.ContainerTitle[0]
+	}
+	if parts := msg.Issued.DateParts; len(parts) > 0 && len(parts[0]) > 0 {
+		data.Year = parts[0][0]
+		date := make([]string, len(parts[0]))
+		for i, p := range parts[0] {
+			date[i] = fmt.Sprintf("%02d", p)
+		}
+		data.Published = strings.Join(date, "-")
+	}
+	if msg.Abstract != "" {
+		// Abstracts are JATS XML
+		if doc, err := html.Parse(strings.NewReader(msg.Abstract)); err == nil {
+			data.Description = strings.TrimSpace(strings.TrimPrefix(textOf(doc), "Abstract"))
+		}
+	}
+
+	content := paperContent(data)
+	content.SiteName = msg.Publisher
+	return content, nil
+}
+
+// paperContent describes a paper for the LLM and keeps its metadata.
+func paperContent(data *store.StructuredData) *ExtractedContent {
+	var sb strings.Builder
+	if len(data.Authors) > 0 {
+		fmt.Fprintf(&sb, "Authors: %s\n", strings.Join(data.Authors, "; "))
+	}
+	if data.Venue != "" {
+		fmt.Fprintf(&sb, "Venue: %s\n", data.Venue)
+	}
+	if data.Year > 0 {
+		fmt.Fprintf(&sb, "Year: %d\n", data.Year)
+	}
+	if data.Description != "" {
+		fmt.Fprintf(&sb, "\nAbstract:\n%s\n", data.Description)
+	}
+	return &ExtractedContent{
+		Title:      data.Name,
+		Content:    strings.TrimSpace(sb.String()),
+		Excerpt:    excerpt(data.Description),
+		Structured: data,
+		Kind:       "academic paper",
+	}
+}
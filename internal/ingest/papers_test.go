@@ -0,0 +1,86 @@
+package ingest
+
+import (
+	"context"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/nerdneilsfield/dumper/internal/store"
+)
+
+func TestArXivExtractor(t *testing.T) {
+	content := extractFixture(t, "https://arxiv.org/abs/1706.03762v5", "arxiv.html", nil)
+
+	data := content.Structured
+	if content.Title != "Attention Is All You Need" || data == nil || data.Type != store.SchemaPaper {
+		t.Fatalf("unexpected title/structured: %q / %+v", content.Title, data)
+	}
+	if !slices.Equal(data.Authors, []string{"Vaswani, Ashish", "Shazeer, Noam", "Parmar, Niki"}) {
+		t.Errorf("unexpected authors %q", data.Authors)
+	}
+	if data.Year != 2017 || data.ArXivID != "1706.03762" {
+		t.Errorf("unexpected year/id: %d / %q", data.Year, data.ArXivID)
+	}
+	for _, want := range []string{
+		"Authors: Vaswani, Ashish; Shazeer, Noam; Parmar, Niki",
+		"Abstract:\nThe dominant sequence transduction models",
+	} {
+		if !strings.Contains(content.Content, want) {
+			t.Errorf("content missing %q:\n%s", want, content.Content)
+		}
+	}
+}
+
+func TestArXivLinks(t *testing.T) {
+	cases := map[string]string{
+		"https://arxiv.org/abs/1706.03762":            "https://arxiv.org/abs/1706.03762",
+		"https://arxiv.org/pdf/1706.03762v5.pdf":      "https://arxiv.org/abs/1706.03762v5",
+		"https://export.arxiv.org/abs/hep-th/9901001": "https://arxiv.org/abs/hep-th/9901001",
+	}
+	for raw, fetch := range cases {
+		u, _ := url.Parse(raw)
+		site := lookupSiteExtractor(u)
+		if site == nil || site.Name != "arXiv" {
+			t.Errorf("%s: expected arXiv extractor, got %v", raw, site)
+			continue
+		}
+		if got := site.FetchURL(u); got != fetch {
+			t.Errorf("%s: fetch url %q, want %q", raw, got, fetch)
+		}
+	}
+	u, _ := url.Parse("https://arxiv.org/list/cs.CL/recent")
+	if site := lookupSiteExtractor(u); site != nil {
+		t.Errorf("listing matched %s extractor", site.Name)
+	}
+}
+
+func TestCrossrefPaper(t *testing.T) {
+	get := fixtureGet(map[string]string{
+		"https://api.crossref.org/works/10.1038%2Fnature14539": "crossref.json",
+	})
+	u, _ := url.Parse("https://doi.org/10.1038/nature14539")
+	site := lookupSiteExtractor(u)
+	if site == nil || site.Name != "DOI" {
+		t.Fatalf("expected DOI extractor, got %v", site)
+	}
+	content, err := site.API(context.Background(), get, u)
+	if err != nil {
+		t.Fatalf("crossref: %v", err)
+	}
+
+	data := content.Structured
+	if content.Title != "Deep learning" || content.SiteName != "Springer Science and Business Media LLC" {
+		t.Fatalf("unexpected title/site: %q / %q", content.Title, content.SiteName)
+	}
+	if data.DOI != "10.1038/nature14539" || data.Venue != "Nature" || data.Year != 2015 || data.Published != "2015-05-27" {
+		t.Errorf("unexpected metadata %+v", data)
+	}
+	if !slices.Equal(data.Authors, []string{"LeCun, Yann", "Bengio, Yoshua", "Hinton, Geoffrey"}) {
+		t.Errorf("unexpected authors %q", data.Authors)
+	}
+	if !strings.HasPrefix(data.Description, "Deep learning allows computational models") {
+		t.Errorf("unexpected abstract %q", data.Description)
+	}
+}
@@ -0,0 +1,45 @@
+package ingest
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// parsePDF returns the text of a PDF, one paragraph per page, and the title
+// from its metadata.
+func parsePDF(data []byte) (doc *ParsedDocument, err error) {
+	// The parser panics on some malformed files
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("parse pdf: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("open pdf: %w", err)
+	}
+
+	var pages []string
+	for i := 1; i <= reader.NumPage(); i++ {
+		// Font resource names are per page, so each page parses its own
+		pageText, err := reader.Page(i).GetPlainText(nil)
+		if err != nil {
+			return nil, fmt.Errorf("read page %d: %w", i, err)
+		}
+		if pageText = strings.TrimSpace(pageText); pageText != "" {
+			pages = append(pages, pageText)
+		}
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("pdf has no extractable text")
+	}
+
+	return &ParsedDocument{
+		Title: strings.TrimSpace(reader.Trailer().Key("Info").Key("Title").Text()),
+		Text:  strings.Join(pages, "\n\n"),
+	}, nil
+}
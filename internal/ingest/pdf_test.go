@@ -0,0 +1,87 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// buildPDF writes a minimal PDF with one line of Helvetica text per page.
+func buildPDF(title string, pages ...string) []byte {
+	var objects []string
+	objects = append(objects, "<< /Type /Catalog /Pages 2 0 R >>")
+	var kids []string
+	for i := range pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 4+2*i))
+	}
+	objects = append(objects, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	objects = append(objects, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>")
+	for i, text := range pages {
+		stream := fmt.Sprintf("BT /F1 12 Tf 72 720 Td (%s) Tj ET", text)
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", 5+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(stream), stream))
+	}
+	infoRef := ""
+	if title != "" {
+		objects = append(objects, fmt.Sprintf("<< /Title (%s) >>", title))
+		infoRef = fmt.Sprintf(" /Info %d 0 R", len(objects))
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R%s >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, infoRef, xref)
+	return buf.Bytes()
+}
+
+func TestParsePDF(t *testing.T) {
+	doc, err := parsePDF(buildPDF("Paper title", "First page", "Second page"))
+	if err != nil {
+		t.Fatalf("parse pdf: %v", err)
+	}
+	if doc.Title != "Paper title" {
+		t.Fatalf("unexpected title %q", doc.Title)
+	}
+	if doc.Text != "First page\n\nSecond page" {
+		t.Fatalf("unexpected text %q", doc.Text)
+	}
+
+	if _, err := parsePDF([]byte("not a pdf")); err == nil {
+		t.Fatal("expected error for invalid pdf")
+	}
+}
+
+func TestExtractSniffsPDF(t *testing.T) {
+	doc := buildPDF("", "Linked paper")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Servers often label downloads as generic binary
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(doc)
+	}))
+	defer server.Close()
+
+	extracted, err := NewExtractor().Extract(context.Background(), server.URL+"/papers/attention_is_all.pdf")
+	if err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	if extracted.MIME != "application/pdf" || !bytes.Equal(extracted.Document, doc) {
+		t.Fatalf("expected pdf document, got %q", extracted.MIME)
+	}
+	if extracted.Title != "attention is all" || extracted.Content != "Linked paper" {
+		t.Fatalf("unexpected extraction: %q / %q", extracted.Title, extracted.Content)
+	}
+}
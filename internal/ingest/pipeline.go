@@ -2,15 +2,17 @@ package ingest
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
-	"os"
-	"path/filepath"
+	"path"
 	"regexp"
+	"slices"
 	"strings"
 
-	"github.com/google/uuid"
 	"github.com/nerdneilsfield/dumper/internal/llm"
+	"github.com/nerdneilsfield/dumper/internal/queue"
 	"github.com/nerdneilsfield/dumper/internal/search"
 	"github.com/nerdneilsfield/dumper/internal/store"
 )
@@ -19,18 +21,31 @@ type Pipeline struct {
 	extractor    *Extractor
 	llmClient    *llm.Client
 	searchClient *search.Client
-	stores       *store.Manager
+	transcriber  *llm.Transcriber // nil when voice transcription is disabled
+	stores       store.Stores
+	archivePages bool
 }
 
-func NewPipeline(llmClient *llm.Client, searchClient *search.Client, stores *store.Manager) *Pipeline {
+// ErrTranscriptionDisabled is returned for audio when no transcription API
+// is configured.
+var ErrTranscriptionDisabled = errors.New("voice transcription is not configured")
+
+func NewPipeline(llmClient *llm.Client, searchClient *search.Client, transcriber *llm.Transcriber, stores store.Stores) *Pipeline {
 	return &Pipeline{
 		extractor:    NewExtractor(),
 		llmClient:    llmClient,
 		searchClient: searchClient,
+		transcriber:  transcriber,
 		stores:       stores,
 	}
 }
 
+// EnableArchiving keeps a self-contained HTML snapshot of every saved web
+// page alongside its link item.
+func (p *Pipeline) EnableArchiving() {
+	p.archivePages = true
+}
+
 func (p *Pipeline) Process(ctx context.Context, raw RawContent) (*store.Item, error) {
 	vault, err := p.stores.GetVault(raw.UserID)
 	if err != nil {
@@ -41,16 +56,23 @@ func (p *Pipeline) Process(ctx context.Context, raw RawContent) (*store.Item, er
 	existingTags, _ := vault.GetAllTags()
 
 	var item *store.Item
+	var children []*store.Item
 
 	switch raw.Type {
 	case ContentTypeLink:
-		item, err = p.processLink(ctx, raw, existingTags)
+		item, err = p.processLink(ctx, vault, raw, existingTags)
 	case ContentTypeNote:
 		item, err = p.processNote(ctx, raw, existingTags)
 	case ContentTypeImage:
-		item, err = p.processImage(ctx, raw, existingTags)
+		item, err = p.processImage(ctx, vault, raw, existingTags)
+	case ContentTypeAlbum:
+		item, children, err = p.processAlbum(ctx, vault, raw, existingTags)
 	case ContentTypeSearch:
 		item, err = p.processSearch(ctx, raw, existingTags)
+	case ContentTypeDocument:
+		item, err = p.processDocument(ctx, vault, raw, existingTags)
+	case ContentTypeVoice:
+		item, err = p.processVoice(ctx, vault, raw, existingTags)
 	default:
 		return nil, fmt.Errorf("unknown content type: %s", raw.Type)
 	}
@@ -58,6 +80,10 @@ func (p *Pipeline) Process(ctx context.Context, raw RawContent) (*store.Item, er
 	if err != nil {
 		return nil, err
 	}
+	item.Source = raw.Source
+	if len(raw.Tags) > 0 {
+		item.Tags = mergeTags(raw.Tags, item.Tags)
+	}
 
 	if err := vault.CreateItem(item); err != nil {
 		return nil, fmt.Errorf("save item: %w", err)
@@ -68,10 +94,46 @@ func (p *Pipeline) Process(ctx context.Context, raw RawContent) (*store.Item, er
 	// Find and create relationships with existing items (best-effort)
 	p.findAndCreateRelationships(ctx, vault, item)
 
+	saveAlbumChildren(vault, item, children)
+
 	return item, nil
 }
 
-func (p *Pipeline) processLink(ctx context.Context, raw RawContent, existingTags []string) (*store.Item, error) {
+// HandleJob processes a queued RawContent and returns the new or reprocessed
+// item's ID.
+func (p *Pipeline) HandleJob(ctx context.Context, job *queue.Job) (string, error) {
+	var raw RawContent
+	if err := json.Unmarshal(job.Payload, &raw); err != nil {
+		return "", queue.Permanent(fmt.Errorf("decode job: %w", err))
+	}
+	if raw.Type == ContentTypeReprocess {
+		item, err := p.Reprocess(ctx, raw.UserID, raw.ItemID)
+		if errors.Is(err, ErrItemNotFound) {
+			return "", queue.Permanent(err)
+		}
+		if err != nil {
+			return "", err
+		}
+		return item.ID, nil
+	}
+	if raw.Type == ContentTypeArchive {
+		item, err := p.AttachArchive(ctx, raw.UserID, raw.ItemID)
+		if errors.Is(err, ErrItemNotFound) || errors.Is(err, ErrNoSnapshot) {
+			return "", queue.Permanent(err)
+		}
+		if err != nil {
+			return "", err
+		}
+		return item.ID, nil
+	}
+	item, err := p.Process(ctx, raw)
+	if err != nil {
+		return "", err
+	}
+	return item.ID, nil
+}
+
+func (p *Pipeline) processLink(ctx context.Context, vault store.Vault, raw RawContent, existingTags []string) (*store.Item, error) {
 	extracted, err := p.extractor.Extract(ctx, raw.URL)
 	if err != nil {
 		slog.Warn("extraction failed, using basic info", "url", raw.URL, "error", err)
@@ -85,29 +147,68 @@ func (p *Pipeline) processLink(ctx context.Context, raw RawContent, existingTags
 		}, nil
 	}
 
+	if extracted.Document != nil {
+		doc := &ParsedDocument{Title: extracted.Title, Text: extracted.Content}
+		item, err := p.documentItem(ctx, vault, extracted.Document, extracted.MIME, doc, raw.Language, existingTags)
+		if err != nil {
+			return nil, err
+		}
+		item.URL = raw.URL
+		return item, nil
+	}
+
+	item := &store.Item{
+		Type:        store.ItemTypeLink,
+		URL:         raw.URL,
+		Title:       extracted.Title,
+		Content:     extracted.Excerpt,
+		RawContent:  extracted.Content,
+		Structured:  extracted.Structured,
+		ArchiveHash: p.archivePage(ctx, vault, extracted),
+		ContentKind: extracted.Kind,
+		Tags:        []string{"uncategorized"},
+	}
+
 	// Process with LLM
-	processed, err := p.llmClient.ProcessContent(ctx, "web article", extracted.Content, raw.Language, existingTags)
+	processed, err := p.llmClient.ProcessContent(ctx, linkKind(item.ContentKind), extracted.Content, raw.Language, existingTags)
 	if err != nil {
 		slog.Warn("LLM processing failed", "error", err)
-		return &store.Item{
-			Type:       store.ItemTypeLink,
-			URL:        raw.URL,
-			Title:      extracted.Title,
-			Content:    extracted.Excerpt,
-			RawContent: extracted.Content,
-			Tags:       []string{"uncategorized"},
-		}, nil
+		return item, nil
 	}
 
-	return &store.Item{
-		Type:       store.ItemTypeLink,
-		URL:        raw.URL,
-		Title:      processed.Title,
-		Summary:    processed.Summary,
-		Content:    extracted.Excerpt,
-		RawContent: extracted.Content,
-		Tags:       processed.Tags,
-	}, nil
+	item.Title = processed.Title
+	item.Summary = processed.Summary
+	item.Tags = processed.Tags
+	return item, nil
+}
+
+// linkKind describes a link's extracted text to the LLM.
+func linkKind(kind string) string {
+	if kind == "" {
+		return "web article"
+	}
+	return kind
+}
+
+// archivePage stores a snapshot of the extracted page when archiving is
+// enabled and returns its blob hash, or "" when the snapshot fails, so the
+// link is saved without one.
+func (p *Pipeline) archivePage(ctx context.Context, vault store.Vault, extracted *ExtractedContent) string {
+	if !p.archivePages || extracted.Page == nil {
+		return ""
+	}
+	snapshot, err := p.extractor.Archive(ctx, extracted.Page, extracted.PageURL)
+	if err != nil {
+		slog.Warn("failed to archive page", "url", extracted.URL, "error", err)
+		return ""
+	}
+	blob, err := vault.PutFile(snapshot, "html", store.ArchiveMIME)
+	if err != nil {
+		slog.Warn("failed to store page archive", "url", extracted.URL, "error", err)
+		return ""
+	}
+	slog.Info("archived page", "url", extracted.URL, "hash", blob.Hash, "size", blob.Size)
+	return blob.Hash
 }
 
 func (p *Pipeline) processNote(ctx context.Context, raw RawContent, existingTags []string) (*store.Item, error) {
@@ -122,7 +223,7 @@ func (p *Pipeline) processNote(ctx context.Context, raw RawContent, existingTags
 		if title == "" {
 			title = raw.Text
 			if len(title) > 50 {
-				title = title[:50] + "..."
+				title = llm.Truncate(title, 50) + "..."
 			}
 		}
 		return &store.Item{
@@ -147,69 +248,242 @@ func (p *Pipeline) processNote(ctx context.Context, raw RawContent, existingTags
 	}, nil
 }
 
-func (p *Pipeline) processImage(ctx context.Context, raw RawContent, existingTags []string) (*store.Item, error) {
-	itemID := uuid.NewString()
-
-	// Create images directory under user folder
-	userDir := p.stores.UserDir(raw.UserID)
-	imagesDir := filepath.Join(userDir, "images")
-	if err := os.MkdirAll(imagesDir, 0755); err != nil {
-		return nil, fmt.Errorf("create images dir: %w", err)
+func (p *Pipeline) processImage(ctx context.Context, vault store.Vault, raw RawContent, existingTags []string) (*store.Item, error) {
+	// Store image in the content-addressed blob store (deduplicated, with thumbnail)
+	blob, err := vault.PutImage(raw.ImageData, raw.ImageExt)
+	if err != nil {
+		return nil, fmt.Errorf("store image: %w", err)
 	}
 
-	// Write image file
-	imagePath := fmt.Sprintf("images/%s.%s", itemID, raw.ImageExt)
-	fullPath := filepath.Join(userDir, imagePath)
-	if err := os.WriteFile(fullPath, raw.ImageData, 0644); err != nil {
-		return nil, fmt.Errorf("write image: %w", err)
-	}
+	slog.Info("saved image", "hash", blob.Hash, "path", blob.Path(), "size", blob.Size,
+		"width", blob.Width, "height", blob.Height)
 
-	slog.Info("saved image", "id", itemID, "path", imagePath, "size", len(raw.ImageData))
+	return p.imageItem(ctx, [][]byte{raw.ImageData}, blob, raw, existingTags), nil
+}
 
-	// If caption exists, process through LLM
-	if raw.Caption != "" {
-		explicitTags := extractHashTags(raw.Caption)
+// processAlbum saves photos sent together as one album. The first photo
+// becomes the item, described from all photos with the shared caption; the
+// rest are returned as children to save linked to it.
+func (p *Pipeline) processAlbum(ctx context.Context, vault store.Vault, raw RawContent, existingTags []string) (*store.Item, []*store.Item, error) {
+	if len(raw.Album) == 0 {
+		return nil, nil, queue.Permanent(errors.New("empty album"))
+	}
 
-		processed, err := p.llmClient.ProcessContent(ctx, "note with image", raw.Caption, raw.Language, existingTags)
+	images := make([][]byte, len(raw.Album))
+	blobs := make([]*store.Blob, len(raw.Album))
+	for i, photo := range raw.Album {
+		blob, err := vault.PutImage(photo.Data, photo.Ext)
 		if err != nil {
-			slog.Warn("LLM processing failed for image caption", "error", err)
-			// Fallback: use caption as-is
-			title := raw.Caption
-			if len(title) > 100 {
-				title = title[:100] + "..."
-			}
-			return &store.Item{
-				ID:        itemID,
-				Type:      store.ItemTypeImage,
-				Title:     title,
-				Content:   raw.Caption,
-				ImagePath: imagePath,
-				Tags:      mergeTags([]string{"image", "uncategorized"}, explicitTags),
-			}, nil
+			return nil, nil, fmt.Errorf("store image %d: %w", i+1, err)
 		}
+		images[i] = photo.Data
+		blobs[i] = blob
+	}
 
-		// Ensure "image" tag is always present
-		tags := mergeTags(processed.Tags, append(explicitTags, "image"))
+	item := p.imageItem(ctx, images, blobs[0], raw, existingTags)
 
-		return &store.Item{
-			ID:        itemID,
+	var children []*store.Item
+	for i, blob := range blobs[1:] {
+		children = append(children, &store.Item{
 			Type:      store.ItemTypeImage,
-			Title:     processed.Title,
-			Summary:   processed.Summary,
-			Content:   raw.Caption,
-			ImagePath: imagePath,
-			Tags:      tags,
-		}, nil
+			Title:     fmt.Sprintf("%s (%d/%d)", item.Title, i+2, len(blobs)),
+			ImagePath: blob.Path(),
+			ImageHash: blob.Hash,
+			Tags:      item.Tags,
+		})
+	}
+	slog.Info("saved album", "images", len(blobs))
+	return item, children, nil
+}
+
+// saveAlbumChildren creates an album's other photos as items linked from
+// the album item. A photo that cannot be saved is logged and left out.
+func saveAlbumChildren(vault store.Vault, album *store.Item, children []*store.Item) {
+	for _, child := range children {
+		if err := vault.CreateItem(child); err != nil {
+			slog.Warn("failed to save album photo", "album_id", album.ID, "error", err)
+			continue
+		}
+		rel := &store.Relationship{
+			SourceID:     album.ID,
+			TargetID:     child.ID,
+			RelationType: "album",
+			Strength:     1,
+		}
+		if err := vault.CreateRelationship(rel); err != nil {
+			slog.Warn("failed to link album photo", "album_id", album.ID, "error", err)
+		}
 	}
+}
 
-	// No caption: save image with minimal metadata
-	return &store.Item{
-		ID:        itemID,
+// imageItem builds the item for stored images: described by the vision model
+// when available, otherwise summarised from the caption.
+func (p *Pipeline) imageItem(ctx context.Context, images [][]byte, blob *store.Blob, raw RawContent, existingTags []string) *store.Item {
+	item := &store.Item{
 		Type:      store.ItemTypeImage,
-		Title:     "Image",
-		ImagePath: imagePath,
-		Tags:      []string{"image"},
-	}, nil
+		ImagePath: blob.Path(),
+		ImageHash: blob.Hash,
+	}
+
+	// Describe the image itself when a vision model is available
+	desc, err := p.llmClient.DescribeImages(ctx, images, raw.Caption, raw.Language, existingTags)
+	if err == nil {
+		applyImageDescription(item, desc, raw.Caption)
+		return item
+	}
+	if !errors.Is(err, llm.ErrNoVision) {
+		slog.Warn("vision processing failed, falling back to caption", "error", err)
+	}
+
+	// No caption: save image with minimal metadata
+	if raw.Caption == "" {
+		item.Title = "Image"
+		item.Tags = []string{"image"}
+		return item
+	}
+
+	explicitTags := extractHashTags(raw.Caption)
+	item.Content = raw.Caption
+
+	processed, err := p.llmClient.ProcessContent(ctx, "note with image", raw.Caption, raw.Language, existingTags)
+	if err != nil {
+		slog.Warn("LLM processing failed for image caption", "error", err)
+		// Fallback: use caption as-is
+		item.Title = raw.Caption
+		if len(item.Title) > 100 {
+			item.Title = llm.Truncate(item.Title, 100) + "..."
+		}
+		item.Tags = mergeTags([]string{"image", "uncategorized"}, explicitTags)
+		return item
+	}
+
+	item.Title = processed.Title
+	item.Summary = processed.Summary
+	// Ensure "image" tag is always present
+	item.Tags = mergeTags(processed.Tags, append(explicitTags, "image"))
+	return item
+}
+
+// applyImageDescription fills an image item from a vision model's
+// description. Text read from the image is kept as raw content and appended
+// to the caption, so full-text search finds it.
+func applyImageDescription(item *store.Item, desc *llm.ImageDescription, caption string) {
+	item.Title = desc.Title
+	if item.Title == "" {
+		item.Title = "Image"
+	}
+	item.Summary = desc.Description
+	item.Content = caption
+	item.RawContent = desc.Text
+	if desc.Text != "" {
+		item.Content = strings.TrimSpace(caption + "\n\n" + desc.Text)
+	}
+	item.Tags = mergeTags(desc.Tags, append(extractHashTags(caption), "image"))
+}
+
+// imageCaption recovers the user's caption from an image item's content.
+func imageCaption(content, ocrText string) string {
+	if ocrText == "" {
+		return content
+	}
+	return strings.TrimSpace(strings.TrimSuffix(content, ocrText))
+}
+
+func (p *Pipeline) processDocument(ctx context.Context, vault store.Vault, raw RawContent, existingTags []string) (*store.Item, error) {
+	mimeType, parse, ok := lookupDocumentParser(raw.FileMIME, raw.FileName, raw.FileData)
+	if !ok {
+		return nil, queue.Permanent(fmt.Errorf("unsupported document type: %s", sniffContentType(raw.FileMIME, raw.FileData)))
+	}
+
+	doc, err := parse(raw.FileData)
+	if err != nil {
+		// Retrying will not make the file readable
+		return nil, queue.Permanent(err)
+	}
+	if doc.Title == "" && !doc.Note {
+		doc.Title = fileTitle(raw.FileName)
+	}
+
+	item, err := p.documentItem(ctx, vault, raw.FileData, mimeType, doc, raw.Language, existingTags)
+	if err != nil {
+		return nil, err
+	}
+	item.Tags = mergeTags(item.Tags, extractHashTags(raw.Caption))
+	return item, nil
+}
+
+// documentItem stores a downloaded or uploaded document in the blob store and
+// summarises its text. Notes keep their full text as content, so wikilinks
+// relate them like notes sent as messages, and their own title wins.
+func (p *Pipeline) documentItem(ctx context.Context, vault store.Vault, data []byte, mimeType string, doc *ParsedDocument, lang string, existingTags []string) (*store.Item, error) {
+	blob, err := vault.PutFile(data, documentExt(mimeType), mimeType)
+	if err != nil {
+		return nil, fmt.Errorf("store document: %w", err)
+	}
+
+	item := &store.Item{
+		Type:       store.ItemTypeDocument,
+		Title:      doc.Title,
+		Content:    excerpt(doc.Text),
+		RawContent: doc.Text,
+		ImagePath:  blob.Path(),
+		ImageHash:  blob.Hash,
+	}
+	if doc.Note {
+		item.Content = doc.Text
+		item.RawContent = ""
+	}
+
+	processed, err := p.llmClient.ProcessContent(ctx, "document", doc.Text, lang, existingTags)
+	if err != nil {
+		slog.Warn("LLM processing failed for document", "error", err)
+		if item.Title == "" {
+			item.Title = doc.Text
+			if len(item.Title) > 50 {
+				item.Title = llm.Truncate(item.Title, 50) + "..."
+			}
+		}
+		item.Tags = mergeTags([]string{"document", "uncategorized"}, doc.Tags)
+		return item, nil
+	}
+
+	// A note without a heading takes the LLM title, like processNote
+	if !doc.Note || item.Title == "" {
+		item.Title = processed.Title
+	}
+	item.Summary = processed.Summary
+	item.Tags = mergeTags(processed.Tags, doc.Tags)
+	return item, nil
+}
+
+// processVoice transcribes a voice message or audio file and saves the
+// transcript as a note, keeping the audio as the item's file.
+func (p *Pipeline) processVoice(ctx context.Context, vault store.Vault, raw RawContent, existingTags []string) (*store.Item, error) {
+	if p.transcriber == nil {
+		return nil, queue.Permanent(ErrTranscriptionDisabled)
+	}
+
+	transcript, err := p.transcriber.Transcribe(ctx, raw.FileData, raw.FileName)
+	if err != nil {
+		return nil, fmt.Errorf("transcribe: %w", err)
+	}
+	if transcript == "" {
+		return nil, queue.Permanent(errors.New("no speech recognised"))
+	}
+
+	blob, err := vault.PutFile(raw.FileData, path.Ext(raw.FileName), raw.FileMIME)
+	if err != nil {
+		return nil, fmt.Errorf("store audio: %w", err)
+	}
+
+	item, err := p.processNote(ctx, RawContent{Text: transcript, Language: raw.Language}, existingTags)
+	if err != nil {
+		return nil, err
+	}
+	item.ImagePath = blob.Path()
+	item.ImageHash = blob.Hash
+	item.Tags = mergeTags(item.Tags, append(extractHashTags(raw.Caption), "voice"))
+	return item, nil
 }
 
 func (p *Pipeline) processSearch(ctx context.Context, raw RawContent, existingTags []string) (*store.Item, error) {
@@ -260,7 +534,7 @@ func (p *Pipeline) processSearch(ctx context.Context, raw RawContent, existingTa
 
 // findAndCreateRelationships finds related items and creates graph edges.
 // This is best-effort; failures are logged but never fail ingestion.
-func (p *Pipeline) findAndCreateRelationships(ctx context.Context, vault *store.VaultStore, item *store.Item) {
+func (p *Pipeline) findAndCreateRelationships(ctx context.Context, vault store.Vault, item *store.Item) {
 	// Fetch recent items (limit 1000 for graph generation)
 	allItems, err := vault.ListItems(1000, 0)
 	if err != nil {
@@ -331,6 +605,34 @@ func (p *Pipeline) findAndCreateRelationships(ctx context.Context, vault *store.
 		}
 	}
 
+	// Link notes to saved pages they mention by URL, in either direction
+	mentioned := make(map[string]struct{})
+	for _, u := range ExtractURLs(item.Content) {
+		mentioned[u] = struct{}{}
+	}
+	for _, other := range allItems {
+		if _, linked := linkedIDs[other.ID]; linked || other.ID == item.ID {
+			continue
+		}
+		rel := &store.Relationship{RelationType: "link", Strength: 1.0}
+		if _, ok := mentioned[other.URL]; ok && other.URL != "" {
+			rel.SourceID, rel.TargetID = item.ID, other.ID
+		} else if item.URL != "" && slices.Contains(ExtractURLs(other.Content), item.URL) {
+			rel.SourceID, rel.TargetID = other.ID, item.ID
+		} else {
+			continue
+		}
+		if err := vault.CreateRelationship(rel); err != nil {
+			slog.Warn("failed to create url relationship",
+				"source", rel.SourceID,
+				"target", rel.TargetID,
+				"error", err)
+			continue
+		}
+		linkedIDs[other.ID] = struct{}{}
+		created++
+	}
+
 	// Create shared tag relationships (skip pairs already connected by explicit links)
 	newTags := filterGraphTags(normalizeTags(item.Tags))
 	if len(newTags) > 0 {
@@ -0,0 +1,128 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// podcastExtractor reads podcast episode pages from their schema.org data
+// and OpenGraph tags, with a transcript when the page links one.
+var podcastExtractor = SiteExtractor{
+	Name:  "Podcast",
+	Hosts: []string{"podcasts.apple.com", "open.spotify.com", "overcast.fm", "pca.st", "castbox.fm"},
+	Match: func(u *url.URL) bool {
+		// Spotify also serves music; keep to episodes there
+		return !strings.HasSuffix(u.Hostname(), "spotify.com") || strings.HasPrefix(u.Path, "/episode/")
+	},
+	Extract: extractPodcast,
+	Follow:  followPodcastTranscript,
+}
+
+// episodeTypes are the schema.org types describing a single episode.
+var episodeTypes = map[string]bool{
+	"PodcastEpisode": true, "Episode": true, "RadioEpisode": true,
+	"AudioObject": true, "VideoObject": true,
+}
+
+func extractPodcast(doc *html.Node, _ *url.URL) (*ExtractedContent, error) {
+	var title, show, description, published, transcript string
+	var duration time.Duration
+	for _, script := range findAll(doc, func(n *html.Node) bool {
+		return n.Data == "script" && attr(n, "type") == "application/ld+json"
+	}) {
+		if script.FirstChild == nil {
+			continue
+		}
+		for _, obj := range jsonLDObjects(script.FirstChild.Data) {
+			if !matchesSchemaType(obj, func(t string) bool { return episodeTypes[t] }) {
+				continue
+			}
+			title = ldString(obj["name"])
+			description = ldString(obj["description"])
+			published = ldString(obj["datePublished"])
+			show = ldString(obj["partOfSeries"])
+			transcript = ldString(obj["transcript"])
+			duration, _ = parseISODuration(ldString(obj["duration"]))
+			if duration == 0 {
+				// Some pages put the length on the attached audio
+				for _, media := range ldObjects(obj["associatedMedia"]) {
+					duration, _ = parseISODuration(ldString(media["duration"]))
+				}
+			}
+			break
+		}
+		if title != "" {
+			break
+		}
+	}
+
+	if title == "" {
+		title = metaContent(doc, "og:title")
+	}
+	if description == "" {
+		description = metaContent(doc, "og:description")
+	}
+	if show == "" {
+		show = metaContent(doc, "og:site_name")
+	}
+	if duration == 0 {
+		if d, ok := parseISODuration(metaContent(doc, "music:duration")); ok {
+			duration = d
+		}
+	}
+	if title == "" {
+		return nil, errNoSiteContent
+	}
+
+	content := &ExtractedContent{
+		Title:    title,
+		Content:  mediaContent(show, duration, published, description),
+		Excerpt:  excerpt(description),
+		SiteName: show,
+		Kind:     "podcast episode",
+	}
+	if transcript != "" {
+		addTranscript(content, transcript, "podcast transcript")
+	}
+	return content, nil
+}
+
+// followPodcastTranscript adds a transcript linked from the page as a
+// caption <track> or a <link rel="transcript">.
+func followPodcastTranscript(ctx context.Context, get FetchFunc, doc *html.Node, u *url.URL, content *ExtractedContent) error {
+	if content.Kind == "podcast transcript" {
+		return nil
+	}
+	link := findFirst(doc, func(n *html.Node) bool {
+		kind := attr(n, "kind")
+		return n.Data == "track" && (kind == "captions" || kind == "subtitles" || kind == "") && attr(n, "src") != "" ||
+			n.Data == "link" && attr(n, "rel") == "transcript" && attr(n, "href") != ""
+	})
+	if link == nil {
+		return nil
+	}
+	src := attr(link, "src")
+	if src == "" {
+		src = attr(link, "href")
+	}
+	ref, err := url.Parse(src)
+	if err != nil {
+		return fmt.Errorf("parse transcript url: %w", err)
+	}
+
+	data, err := get(ctx, u.ResolveReference(ref).String())
+	if err != nil {
+		return fmt.Errorf("fetch transcript: %w", err)
+	}
+	transcript, err := parseCaptions(data)
+	if err != nil {
+		return err
+	}
+	addTranscript(content, transcript, "podcast transcript")
+	return nil
+}
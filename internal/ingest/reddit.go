@@ -0,0 +1,75 @@
+package ingest
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// redditExtractor reads a Reddit post with its top comments from the old
+// interface, which is served as plain HTML.
+var redditExtractor = SiteExtractor{
+	Name:  "Reddit",
+	Hosts: []string{"*.reddit.com", "redd.it"},
+	Match: func(u *url.URL) bool {
+		// /r/<subreddit>/comments/<id>/...
+		segments := pathSegments(u)
+		return len(segments) >= 4 && segments[0] == "r" && segments[2] == "comments"
+	},
+	FetchURL: func(u *url.URL) string {
+		old := *u
+		old.Host = "old.reddit.com"
+		return old.String()
+	},
+	Extract: extractReddit,
+}
+
+func extractReddit(doc *html.Node, _ *url.URL) (*ExtractedContent, error) {
+	post := findFirst(doc, byTag("div", "thing", "link"))
+	titleLink := findFirst(post, byTag("a", "title"))
+	title := textOf(titleLink)
+	if title == "" {
+		return nil, errNoSiteContent
+	}
+
+	var parts []string
+	// Link posts point away from the thread
+	if href := attr(titleLink, "href"); strings.HasPrefix(href, "http") {
+		parts = append(parts, "Link: "+href)
+	}
+	if text := textOf(findFirst(findFirst(post, byClass("usertext-body")), byClass("md"))); text != "" {
+		parts = append(parts, text)
+	}
+
+	var comments []string
+	if listing := findFirst(doc, byClass("sitetable", "nestedlisting")); listing != nil {
+		// Direct children of the listing are top-level comments, best first
+		for c := listing.FirstChild; c != nil && len(comments) < maxThreadComments; c = c.NextSibling {
+			if c.Type != html.ElementNode || !byClass("thing", "comment")(c) {
+				continue
+			}
+			entry := findFirst(c, byClass("entry"))
+			text := textOf(findFirst(findFirst(entry, byClass("usertext-body")), byClass("md")))
+			if text == "" {
+				continue
+			}
+			author := textOf(findFirst(entry, byTag("a", "author")))
+			comments = append(comments, fmt.Sprintf("%s: %s", author, text))
+		}
+	}
+	if len(comments) > 0 {
+		parts = append(parts, "Top comments:\n\n"+strings.Join(comments, "\n\n"))
+	}
+
+	siteName := "Reddit"
+	if sub := attr(post, "data-subreddit"); sub != "" {
+		siteName = "r/" + sub
+	}
+	return &ExtractedContent{
+		Title:    title,
+		Content:  strings.Join(parts, "\n\n"),
+		SiteName: siteName,
+	}, nil
+}
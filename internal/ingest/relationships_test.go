@@ -26,6 +26,15 @@ func TestExtractHashTags(t *testing.T) {
 	}
 }
 
+func TestExtractURLs(t *testing.T) {
+	input := "Read https://example.com/a, then (see https://en.wikipedia.org/wiki/Go_(language)) and https://example.com/a again."
+	got := ExtractURLs(input)
+	want := []string{"https://example.com/a", "https://en.wikipedia.org/wiki/Go_(language)"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ExtractURLs mismatch: got %v want %v", got, want)
+	}
+}
+
 func TestExtractTitleFromNote(t *testing.T) {
 	cases := []struct {
 		name  string
@@ -137,14 +146,11 @@ func hasRelationship(relationships []store.Relationship, sourceID, targetID, rel
 	return false
 }
 
-func hasTagBetween(vault *store.VaultStore, sourceID, targetID string) bool {
-	var count int
-	err := vault.DB().QueryRow(
-		`SELECT COUNT(*) FROM relationships WHERE relation_type = 'tag' AND ((source_id = ? AND target_id = ?) OR (source_id = ? AND target_id = ?))`,
-		sourceID, targetID, targetID, sourceID,
-	).Scan(&count)
+func hasTagBetween(vault store.Vault, sourceID, targetID string) bool {
+	relationships, err := vault.GetRelationships(sourceID)
 	if err != nil {
 		return true
 	}
-	return count > 0
+	return hasRelationship(relationships, sourceID, targetID, "tag") ||
+		hasRelationship(relationships, targetID, sourceID, "tag")
 }
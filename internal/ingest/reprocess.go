@@ -0,0 +1,257 @@
+package ingest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/nerdneilsfield/dumper/internal/llm"
+	"github.com/nerdneilsfield/dumper/internal/store"
+)
+
+// ErrItemNotFound is returned when reprocessing an item that does not exist.
+var ErrItemNotFound = errors.New("item not found")
+
+// ReprocessScope selects the items a batch reprocess covers.
+type ReprocessScope string
+
+const (
+	// ReprocessUncategorized covers items saved while the LLM was unavailable
+	ReprocessUncategorized ReprocessScope = "uncategorized"
+	// ReprocessAll covers the whole vault
+	ReprocessAll ReprocessScope = "all"
+)
+
+// uncategorizedTag marks items saved by the LLM fallbacks.
+const uncategorizedTag = "uncategorized"
+
+// typeTags are added by the pipeline for the kind of content saved rather
+// than by the LLM, so reprocessing keeps them.
+var typeTags = map[string]struct{}{
+	"image":    {},
+	"document": {},
+	"voice":    {},
+	"search":   {},
+}
+
+// ReprocessTargets returns the IDs of the items in scope. IDs are collected
+// up front because reprocessing removes items from the uncategorized tag.
+func ReprocessTargets(vault store.Vault, scope ReprocessScope) ([]string, error) {
+	const page = 500
+	var ids []string
+	for offset := 0; ; offset += page {
+		var items []store.Item
+		var err error
+		switch scope {
+		case ReprocessUncategorized:
+			items, err = vault.ListItemsByTag(uncategorizedTag, page, offset)
+		case ReprocessAll:
+			items, err = vault.ListItems(page, offset)
+		default:
+			return nil, fmt.Errorf("unknown reprocess scope: %s", scope)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("list items: %w", err)
+		}
+		for _, item := range items {
+			ids = append(ids, item.ID)
+		}
+		if len(items) < page {
+			return ids, nil
+		}
+	}
+}
+
+// Reprocess reruns summarisation and tagging for a stored item from its raw
+// content. Explicit titles, #tags in notes and captions, type tags and the
+// default tags of the feed the item came from are kept. The item is left
+// untouched when the LLM call fails.
+func (p *Pipeline) Reprocess(ctx context.Context, userID int64, itemID string) (*store.Item, error) {
+	vault, err := p.stores.GetVault(userID)
+	if err != nil {
+		return nil, fmt.Errorf("get vault: %w", err)
+	}
+	item, err := vault.GetItem(itemID)
+	if err != nil {
+		return nil, fmt.Errorf("get item: %w", err)
+	}
+	if item == nil {
+		return nil, fmt.Errorf("reprocess %s: %w", itemID, ErrItemNotFound)
+	}
+
+	lang, err := vault.GetSetting("language")
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("get language: %w", err)
+	}
+	if lang == "" {
+		lang = "en"
+	}
+
+	// Fetch existing tags for LLM context (ignore error, empty list is fine)
+	existingTags, _ := vault.GetAllTags()
+	kept := keptTags(vault, item)
+
+	switch item.Type {
+	case store.ItemTypeLink:
+		err = p.reprocessExtracted(ctx, vault, item, linkKind(item.ContentKind), lang, existingTags)
+	case store.ItemTypeDocument:
+		err = p.reprocessExtracted(ctx, vault, item, "document", lang, existingTags)
+	case store.ItemTypeNote:
+		err = p.reprocessText(ctx, item, "note", lang, existingTags)
+	case store.ItemTypeImage:
+		var skip bool
+		skip, err = p.reprocessImage(ctx, vault, item, lang, existingTags)
+		if skip {
+			return item, nil
+		}
+	case store.ItemTypeSearch:
+		err = p.reprocessSearch(ctx, item, lang, existingTags)
+	default:
+		return nil, fmt.Errorf("cannot reprocess item type: %s", item.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	item.Tags = mergeTags(item.Tags, kept)
+
+	if err := vault.UpdateItem(item); err != nil {
+		return nil, fmt.Errorf("update item: %w", err)
+	}
+
+	slog.Info("reprocessed item", "id", item.ID, "title", item.Title, "tags", item.Tags)
+
+	p.findAndCreateRelationships(ctx, vault, item)
+
+	return item, nil
+}
+
+// keptTags returns the tags of item that did not come from the LLM: type
+// tags, #tags in its content and the tags of the feed that saved it.
+func keptTags(vault store.Vault, item *store.Item) []string {
+	var kept []string
+	for _, tag := range item.Tags {
+		if _, ok := typeTags[tag]; ok {
+			kept = append(kept, tag)
+		}
+	}
+	kept = mergeTags(kept, extractHashTags(item.Content))
+
+	// Feeds rename themselves, so entries are matched by subscription
+	src := item.Source
+	if src == nil || src.Kind != store.SourceFeed || src.FeedID == "" {
+		return kept
+	}
+	// Feed tags the user removed from the item stay removed
+	feeds, _ := vault.ListFeeds()
+	for _, feed := range feeds {
+		if feed.ID != src.FeedID {
+			continue
+		}
+		for _, tag := range feed.Tags {
+			if slices.Contains(item.Tags, normalizeTag(tag)) {
+				kept = mergeTags(kept, []string{tag})
+			}
+		}
+	}
+	return kept
+}
+
+// reprocessExtracted summarises the text extracted from a page or document.
+func (p *Pipeline) reprocessExtracted(ctx context.Context, vault store.Vault, item *store.Item, contentType, lang string, existingTags []string) error {
+	text, err := vault.GetRawContent(item.ID)
+	if err != nil {
+		return fmt.Errorf("get raw content: %w", err)
+	}
+	if text == "" && item.URL != "" {
+		// Extraction failed at capture time; try the page again
+		if extracted, err := p.extractor.Extract(ctx, item.URL); err == nil {
+			text = extracted.Content
+			item.Content = extracted.Excerpt
+			if item.Type == store.ItemTypeLink {
+				item.ContentKind = extracted.Kind
+				contentType = linkKind(extracted.Kind)
+			}
+		} else {
+			slog.Warn("extraction failed again", "url", item.URL, "error", err)
+		}
+	}
+	if text == "" {
+		if item.Content == "" {
+			return fmt.Errorf("item %s has no content to reprocess", item.ID)
+		}
+		// Notes uploaded as files keep their text, title and tags in the content
+		return p.reprocessText(ctx, item, contentType, lang, existingTags)
+	}
+
+	processed, err := p.llmClient.ProcessContent(ctx, contentType, text, lang, existingTags)
+	if err != nil {
+		return fmt.Errorf("llm: %w", err)
+	}
+	item.Title = processed.Title
+	item.Summary = processed.Summary
+	item.Tags = processed.Tags
+	return nil
+}
+
+// reprocessText summarises a note or image caption stored in item.Content.
+func (p *Pipeline) reprocessText(ctx context.Context, item *store.Item, contentType, lang string, existingTags []string) error {
+	processed, err := p.llmClient.ProcessContent(ctx, contentType, item.Content, lang, existingTags)
+	if err != nil {
+		return fmt.Errorf("llm: %w", err)
+	}
+	item.Title = processed.Title
+	if explicit := extractTitleFromNote(item.Content); explicit != "" {
+		item.Title = explicit
+	}
+	item.Summary = processed.Summary
+	item.Tags = mergeTags(processed.Tags, extractHashTags(item.Content))
+	return nil
+}
+
+// reprocessImage describes a stored image again with the vision model,
+// falling back to its caption. skip is set when there is nothing to redo.
+func (p *Pipeline) reprocessImage(ctx context.Context, vault store.Vault, item *store.Item, lang string, existingTags []string) (skip bool, err error) {
+	ocrText, err := vault.GetRawContent(item.ID)
+	if err != nil {
+		return false, fmt.Errorf("get raw content: %w", err)
+	}
+	caption := imageCaption(item.Content, ocrText)
+
+	if data, err := vault.ReadFile(item.ImagePath); err != nil {
+		slog.Warn("failed to read image for reprocessing", "id", item.ID, "error", err)
+	} else if desc, err := p.llmClient.DescribeImage(ctx, data, caption, lang, existingTags); err == nil {
+		applyImageDescription(item, desc, caption)
+		return false, nil
+	} else if !errors.Is(err, llm.ErrNoVision) {
+		slog.Warn("vision processing failed, falling back to caption", "id", item.ID, "error", err)
+	}
+
+	if caption == "" {
+		// Nothing to summarise without a caption
+		return true, nil
+	}
+	// Summarise the caption alone, keeping previously read text searchable
+	content := item.Content
+	item.Content = caption
+	err = p.reprocessText(ctx, item, "note with image", lang, existingTags)
+	item.Content = content
+	if err != nil {
+		return false, err
+	}
+	item.Tags = mergeTags(item.Tags, []string{"image"})
+	return false, nil
+}
+
+func (p *Pipeline) reprocessSearch(ctx context.Context, item *store.Item, lang string, existingTags []string) error {
+	processed, err := p.llmClient.SummarizeSearchResults(ctx, item.Title, item.Content, lang, existingTags)
+	if err != nil {
+		return fmt.Errorf("llm: %w", err)
+	}
+	item.Title = processed.Title
+	item.Summary = processed.Summary
+	item.Tags = processed.Tags
+	return nil
+}
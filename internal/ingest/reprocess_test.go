@@ -0,0 +1,115 @@
+package ingest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nerdneilsfield/dumper/internal/llm"
+	"github.com/nerdneilsfield/dumper/internal/store"
+)
+
+func TestReprocessTargets(t *testing.T) {
+	manager, err := store.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = manager.Close()
+	})
+	vault, err := manager.GetVault(1)
+	if err != nil {
+		t.Fatalf("failed to get vault: %v", err)
+	}
+
+	fallback := &store.Item{Type: store.ItemTypeNote, Title: "Fallback", Tags: []string{"uncategorized"}}
+	tagged := &store.Item{Type: store.ItemTypeNote, Title: "Tagged", Tags: []string{"go"}}
+	for _, item := range []*store.Item{fallback, tagged} {
+		if err := vault.CreateItem(item); err != nil {
+			t.Fatalf("failed to create item: %v", err)
+		}
+	}
+
+	ids, err := ReprocessTargets(vault, ReprocessUncategorized)
+	if err != nil || len(ids) != 1 || ids[0] != fallback.ID {
+		t.Fatalf("unexpected uncategorized targets: %v %v", ids, err)
+	}
+	ids, err = ReprocessTargets(vault, ReprocessAll)
+	if err != nil || len(ids) != 2 {
+		t.Fatalf("unexpected targets for whole vault: %v %v", ids, err)
+	}
+	if _, err := ReprocessTargets(vault, "bogus"); err == nil {
+		t.Fatal("expected error for unknown scope")
+	}
+}
+
+func TestImageDescriptionSearchable(t *testing.T) {
+	manager, err := store.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = manager.Close()
+	})
+	vault, err := manager.GetVault(1)
+	if err != nil {
+		t.Fatalf("failed to get vault: %v", err)
+	}
+
+	item := &store.Item{Type: store.ItemTypeImage}
+	applyImageDescription(item, &llm.ImageDescription{
+		Title: "Whiteboard", Description: "Sprint plan.", Tags: []string{"planning"}, Text: "Ship quokka release",
+	}, "from standup #work")
+	if err := vault.CreateItem(item); err != nil {
+		t.Fatalf("failed to create item: %v", err)
+	}
+
+	results, err := vault.Search("quokka", 10)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("expected image found by its text: %v %v", results, err)
+	}
+	if want := []string{"planning", "work", "image"}; !reflect.DeepEqual(item.Tags, want) {
+		t.Fatalf("tags: got %v want %v", item.Tags, want)
+	}
+	raw, err := vault.GetRawContent(item.ID)
+	if err != nil {
+		t.Fatalf("get raw content: %v", err)
+	}
+	if got := imageCaption(item.Content, raw); got != "from standup #work" {
+		t.Fatalf("caption not recovered: %q", got)
+	}
+}
+
+func TestKeptTags(t *testing.T) {
+	manager, err := store.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = manager.Close()
+	})
+	vault, err := manager.GetVault(1)
+	if err != nil {
+		t.Fatalf("failed to get vault: %v", err)
+	}
+	feed := &store.Feed{URL: "https://example.com/feed", Title: "Example", Tags: []string{"Reading", "news"}}
+	if err := vault.CreateFeed(feed); err != nil {
+		t.Fatalf("failed to create feed: %v", err)
+	}
+
+	item := &store.Item{
+		Type:    store.ItemTypeDocument,
+		Content: "Notes from #standup",
+		Source:  &store.Source{Kind: store.SourceFeed, Name: "Old title", FeedID: feed.ID},
+		// news was removed by the user, go came from the LLM
+		Tags: []string{"go", "document", "uncategorized", "reading", "standup"},
+	}
+	if want, got := []string{"document", "standup", "reading"}, keptTags(vault, item); !reflect.DeepEqual(got, want) {
+		t.Fatalf("kept tags: got %v want %v", got, want)
+	}
+
+	// A forward named like the feed keeps only its own tags
+	item.Source = &store.Source{Kind: store.SourceForward, Name: "Example"}
+	if want, got := []string{"document", "standup"}, keptTags(vault, item); !reflect.DeepEqual(got, want) {
+		t.Fatalf("kept tags of forward: got %v want %v", got, want)
+	}
+}
@@ -0,0 +1,206 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+// errNoSiteContent is returned by site extractors when a page lacks the
+// markup they expect, so readability is tried instead.
+var errNoSiteContent = errors.New("no site content found")
+
+// SiteExtractor turns pages of one site into structured content where
+// readability does poorly, such as repositories and discussion threads.
+type SiteExtractor struct {
+	Name string
+	// Hosts the extractor handles. "*.example.com" matches subdomains of
+	// example.com as well as example.com itself.
+	Hosts []string
+	// Match optionally narrows the pages handled, e.g. to question pages
+	Match func(u *url.URL) bool
+	// API optionally reads the content from a service instead of the page.
+	// Errors fall back to downloading the page.
+	API func(ctx context.Context, get FetchFunc, u *url.URL) (*ExtractedContent, error)
+	// FetchURL optionally returns a different URL to download, such as a
+	// lighter view of the same page
+	FetchURL func(u *url.URL) string
+	// Extract reads the downloaded page. Errors fall back to readability.
+	Extract func(doc *html.Node, u *url.URL) (*ExtractedContent, error)
+	// Follow optionally downloads resources the page links to, such as a
+	// video's captions, and adds them to the extracted content
+	Follow func(ctx context.Context, get FetchFunc, doc *html.Node, u *url.URL, content *ExtractedContent) error
+}
+
+// FetchFunc downloads a URL.
+type FetchFunc func(ctx context.Context, url string) ([]byte, error)
+
+var (
+	siteExtractorsMu sync.RWMutex
+	siteExtractors   []*SiteExtractor
+)
+
+// RegisterSiteExtractor adds an extractor for the sites it names. Later
+// registrations take precedence, so built-in extractors can be replaced.
+func RegisterSiteExtractor(ex SiteExtractor) {
+	siteExtractorsMu.Lock()
+	defer siteExtractorsMu.Unlock()
+	siteExtractors = append([]*SiteExtractor{&ex}, siteExtractors...)
+}
+
+// lookupSiteExtractor returns the extractor for a URL, or nil.
+func lookupSiteExtractor(u *url.URL) *SiteExtractor {
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+
+	siteExtractorsMu.RLock()
+	defer siteExtractorsMu.RUnlock()
+	for _, ex := range siteExtractors {
+		if !matchesHost(ex.Hosts, host) {
+			continue
+		}
+		if ex.Match == nil || ex.Match(u) {
+			return ex
+		}
+	}
+	return nil
+}
+
+func matchesHost(patterns []string, host string) bool {
+	for _, p := range patterns {
+		if domain, ok := strings.CutPrefix(p, "*."); ok {
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				return true
+			}
+		} else if host == p {
+			return true
+		}
+	}
+	return false
+}
+
+// pathSegments splits a URL path into its non-empty segments.
+func pathSegments(u *url.URL) []string {
+	var segments []string
+	for _, s := range strings.Split(u.Path, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
+
+func init() {
+	RegisterSiteExtractor(githubExtractor)
+	RegisterSiteExtractor(redditExtractor)
+	RegisterSiteExtractor(hackerNewsExtractor)
+	RegisterSiteExtractor(stackExchangeExtractor)
+	RegisterSiteExtractor(telegramExtractor)
+	RegisterSiteExtractor(youtubeExtractor)
+	RegisterSiteExtractor(podcastExtractor)
+	RegisterSiteExtractor(arxivExtractor)
+	RegisterSiteExtractor(doiExtractor)
+}
+
+// HTML helpers shared by the site extractors
+
+// findAll returns the elements under n, in document order, that match.
+func findAll(n *html.Node, match func(*html.Node) bool) []*html.Node {
+	var found []*html.Node
+	var walk func(*html.Node)
+	walk = func(c *html.Node) {
+		if c.Type == html.ElementNode && match(c) {
+			found = append(found, c)
+		}
+		for child := c.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c)
+	}
+	return found
+}
+
+// findFirst returns the first element under n that matches, or nil.
+func findFirst(n *html.Node, match func(*html.Node) bool) *html.Node {
+	if n == nil {
+		return nil
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && match(c) {
+			return c
+		}
+		if found := findFirst(c, match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// byClass matches elements with all of the given classes.
+func byClass(classes ...string) func(*html.Node) bool {
+	return func(n *html.Node) bool {
+		have := strings.Fields(attr(n, "class"))
+		for _, want := range classes {
+			found := false
+			for _, c := range have {
+				if c == want {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// byTag matches elements by tag name and, optionally, classes.
+func byTag(tag string, classes ...string) func(*html.Node) bool {
+	hasClasses := byClass(classes...)
+	return func(n *html.Node) bool {
+		return n.Data == tag && hasClasses(n)
+	}
+}
+
+// byID matches the element with the given id.
+func byID(id string) func(*html.Node) bool {
+	return func(n *html.Node) bool {
+		return attr(n, "id") == id
+	}
+}
+
+func attr(n *html.Node, key string) string {
+	if n == nil {
+		return ""
+	}
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// textOf returns the visible text under n, or "" for nil.
+func textOf(n *html.Node) string {
+	if n == nil {
+		return ""
+	}
+	_, text := nodeText(n)
+	return text
+}
+
+// metaContent returns the content of a <meta> tag by name or property.
+func metaContent(doc *html.Node, key string) string {
+	meta := findFirst(doc, func(n *html.Node) bool {
+		return n.Data == "meta" && (attr(n, "name") == key || attr(n, "property") == key)
+	})
+	return strings.TrimSpace(attr(meta, "content"))
+}
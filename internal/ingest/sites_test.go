@@ -0,0 +1,155 @@
+package ingest
+
+import (
+	"bytes"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestSiteExtractors(t *testing.T) {
+	cases := []struct {
+		url      string
+		fixture  string
+		site     string
+		title    string
+		contains []string
+		excludes []string
+	}{
+		{
+			url:     "https://github.com/golang/groupcache",
+			fixture: "github.html",
+			site:    "GitHub",
+			title:   "golang/groupcache",
+			contains: []string{
+				"intended as a replacement for memcached",
+				"Topics: go, cache",
+				"README:",
+				"shards by key",
+			},
+			excludes: []string{"Sign in", "© GitHub"},
+		},
+		{
+			url:     "https://news.ycombinator.com/item?id=41000001",
+			fixture: "hackernews.html",
+			site:    "Hacker News",
+			title:   "Show HN: A tiny Lisp in 200 lines",
+			contains: []string{
+				"Link: https://example.com/tiny-lisp",
+				"I wrote this to learn how eval works.",
+				"alice: Lovely code.",
+				"carol: Have you tried adding tail calls?",
+			},
+			excludes: []string{"nested reply"},
+		},
+		{
+			url:     "https://www.reddit.com/r/golang/comments/1abcde/whats_your_favourite_go_library/",
+			fixture: "reddit.html",
+			site:    "r/golang",
+			title:   "What's your favourite Go library nobody talks about?",
+			contains: []string{
+				"It makes fan-out so much easier.",
+				"nil_pointer: samber/lo",
+				"tester: go-cmp",
+			},
+			excludes: []string{"Nested reply", "Link: "},
+		},
+		{
+			url:     "https://stackoverflow.com/questions/19239449/how-do-i-reverse-a-slice",
+			fixture: "stackoverflow.html",
+			site:    "Stack Exchange",
+			title:   "How do I reverse a slice?",
+			contains: []string{
+				"Question:",
+				"reverse it in place",
+				"Tags: go, slice",
+				"Accepted answer:",
+				"slices.Reverse(s)",
+			},
+			excludes: []string{"Swap from both ends", "Which Go version?"},
+		},
+		{
+			url:      "https://t.me/golangnews/1234",
+			fixture:  "telegram.html",
+			site:     "Go News",
+			title:    "Go 1.23 is out",
+			contains: []string{"Range over functions", "go.dev/doc/go1.23"},
+			excludes: []string{"views"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.fixture, func(t *testing.T) {
+			u, err := url.Parse(tc.url)
+			if err != nil {
+				t.Fatalf("parse url: %v", err)
+			}
+			site := lookupSiteExtractor(u)
+			if site == nil {
+				t.Fatalf("no extractor for %s", tc.url)
+			}
+			body, err := os.ReadFile(filepath.Join("testdata", "sites", tc.fixture))
+			if err != nil {
+				t.Fatalf("read fixture: %v", err)
+			}
+			doc, err := html.Parse(bytes.NewReader(body))
+			if err != nil {
+				t.Fatalf("parse fixture: %v", err)
+			}
+			content, err := extractSite(site, doc, u)
+			if err != nil {
+				t.Fatalf("extract: %v", err)
+			}
+			if content.Title != tc.title || content.SiteName != tc.site {
+				t.Fatalf("unexpected title/site: %q / %q", content.Title, content.SiteName)
+			}
+			for _, want := range tc.contains {
+				if !strings.Contains(content.Content, want) {
+					t.Errorf("content missing %q:\n%s", want, content.Content)
+				}
+			}
+			for _, unwanted := range tc.excludes {
+				if strings.Contains(content.Content, unwanted) {
+					t.Errorf("content should not contain %q:\n%s", unwanted, content.Content)
+				}
+			}
+		})
+	}
+}
+
+func TestLookupSiteExtractor(t *testing.T) {
+	cases := []struct {
+		url   string
+		site  string
+		fetch string
+	}{
+		{url: "https://github.com/golang/go/issues/1"},
+		{url: "https://example.com/golang/go"},
+		{url: "https://t.me/c/123/45"},
+		{url: "https://unix.stackexchange.com/questions/1/x", site: "Stack Exchange"},
+		{url: "https://reddit.com/r/go/comments/abc/t/", site: "Reddit", fetch: "https://old.reddit.com/r/go/comments/abc/t/"},
+		{url: "https://t.me/golangnews/1234?single", site: "Telegram", fetch: "https://t.me/golangnews/1234?embed=1&mode=tme"},
+	}
+
+	for _, tc := range cases {
+		u, _ := url.Parse(tc.url)
+		site := lookupSiteExtractor(u)
+		if tc.site == "" {
+			if site != nil {
+				t.Errorf("%s: expected no extractor, got %s", tc.url, site.Name)
+			}
+			continue
+		}
+		if site == nil || site.Name != tc.site {
+			t.Errorf("%s: expected %s extractor, got %v", tc.url, tc.site, site)
+			continue
+		}
+		if tc.fetch != "" && site.FetchURL(u) != tc.fetch {
+			t.Errorf("%s: fetch url %q, want %q", tc.url, site.FetchURL(u), tc.fetch)
+		}
+	}
+}
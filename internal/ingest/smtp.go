@@ -0,0 +1,416 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+
+	"github.com/nerdneilsfield/dumper/internal/queue"
+	"github.com/nerdneilsfield/dumper/internal/store"
+)
+
+const (
+	// maxEmailSize caps a message, attachments included.
+	maxEmailSize = 32 << 20
+	// maxEmailBuffered caps the message data held in memory across all
+	// sessions, counting both the raw message and its parsed copy.
+	maxEmailBuffered = 128 << 20
+	// maxRecipients caps the recipients of one message.
+	maxRecipients = 20
+	// maxSMTPSessions caps concurrent connections.
+	maxSMTPSessions = 32
+	// smtpTimeout is how long a client may take over each command.
+	smtpTimeout = 5 * time.Minute
+	// maxSMTPLine caps a command line, CRLF included (RFC 5321 4.5.3.1.4).
+	maxSMTPLine = 1000
+)
+
+var errSMTPLineTooLong = errors.New("smtp line too long")
+
+// SMTPSource receives email for users' secret capture addresses,
+// <token>@<domain>, and saves the body and attachments of each message. It
+// speaks just enough SMTP to accept mail relayed by a mail server or sent
+// straight to it, without TLS or authentication.
+type SMTPSource struct {
+	addr      string
+	domain    string
+	stores    store.Stores
+	addresses *EmailAddresses
+	buffers   *semaphore.Weighted // bytes of message data held in memory
+}
+
+var _ InputSource = (*SMTPSource)(nil)
+
+func NewSMTPSource(addr, domain string, stores store.Stores, addresses *EmailAddresses) *SMTPSource {
+	return &SMTPSource{
+		addr:      addr,
+		domain:    strings.ToLower(domain),
+		stores:    stores,
+		addresses: addresses,
+		buffers:   semaphore.NewWeighted(maxEmailBuffered),
+	}
+}
+
+func (s *SMTPSource) Name() string {
+	return "email"
+}
+
+// Ack does nothing: the sender has already been told the message was
+// accepted.
+func (s *SMTPSource) Ack(job *queue.Job) {}
+
+// Run accepts connections until ctx is done.
+func (s *SMTPSource) Run(ctx context.Context, emit EmitFunc) error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("listen for smtp: %w", err)
+	}
+	return s.Serve(ctx, ln, emit)
+}
+
+// Serve accepts connections on ln until ctx is done, then closes it and
+// waits for open sessions to end.
+func (s *SMTPSource) Serve(ctx context.Context, ln net.Listener, emit EmitFunc) error {
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	slots := make(chan struct{}, maxSMTPSessions)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("accept smtp connection: %w", err)
+		}
+		wg.Add(1)
+		select {
+		case slots <- struct{}{}:
+		default:
+			go func() {
+				defer wg.Done()
+				defer conn.Close()
+				_ = conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+				fmt.Fprintf(conn, "421 Too many connections, try again later\r\n")
+			}()
+			continue
+		}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			defer conn.Close()
+			// Closing the connection on shutdown ends a session mid-command
+			stop := context.AfterFunc(ctx, func() { conn.Close() })
+			defer stop()
+			s.serveConn(conn, emit)
+		}()
+	}
+}
+
+// smtpSession is the state of one SMTP transaction.
+type smtpSession struct {
+	from  string
+	users []int64
+}
+
+func (s *SMTPSource) serveConn(conn net.Conn, emit EmitFunc) {
+	lines := &smtpLineReader{r: conn, max: maxSMTPLine}
+	tp := textproto.NewConn(struct {
+		io.Reader
+		io.WriteCloser
+	}{lines, conn})
+	hostname := s.domain
+	if hostname == "" {
+		hostname = "localhost"
+	}
+	reply := func(format string, args ...any) bool {
+		_ = conn.SetWriteDeadline(time.Now().Add(smtpTimeout))
+		return tp.PrintfLine(format, args...) == nil
+	}
+
+	if !reply("220 %s ESMTP Dumper", hostname) {
+		return
+	}
+	var session *smtpSession
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(smtpTimeout))
+		line, err := tp.ReadLine()
+		// bufio hands back the truncated line before the error, so check
+		// the reader itself
+		if lines.tooLong {
+			reply("500 Line too long")
+			return
+		}
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		arg = strings.TrimSpace(arg)
+
+		var ok bool
+		switch strings.ToUpper(verb) {
+		case "HELO":
+			session = nil
+			ok = reply("250 %s", hostname)
+		case "EHLO":
+			session = nil
+			ok = reply("250-%s\r\n250-8BITMIME\r\n250-PIPELINING\r\n250 SIZE %d", hostname, maxEmailSize)
+		case "MAIL":
+			from, params, found := cutSMTPPath(arg, "FROM:")
+			switch {
+			case !found:
+				ok = reply("501 Syntax: MAIL FROM:<address>")
+			case smtpSizeTooLarge(params):
+				ok = reply("552 Message too large")
+			default:
+				session = &smtpSession{from: from}
+				ok = reply("250 OK")
+			}
+		case "RCPT":
+			ok = s.recipient(session, arg, reply)
+		case "DATA":
+			if session == nil || len(session.users) == 0 {
+				ok = reply("503 Need RCPT first")
+				break
+			}
+			if !reply("354 End data with <CR><LF>.<CR><LF>") {
+				return
+			}
+			// Message lines are bounded by maxEmailSize instead
+			lines.max = 0
+			ok = s.data(session, tp, emit, reply)
+			lines.max = maxSMTPLine
+			session = nil
+		case "RSET":
+			session = nil
+			ok = reply("250 OK")
+		case "NOOP":
+			ok = reply("250 OK")
+		case "VRFY":
+			ok = reply("252 Cannot verify user")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			ok = reply("502 Command not implemented")
+		}
+		if !ok {
+			return
+		}
+	}
+}
+
+// recipient handles RCPT TO, accepting only known capture addresses.
+func (s *SMTPSource) recipient(session *smtpSession, arg string, reply func(string, ...any) bool) bool {
+	if session == nil {
+		return reply("503 Need MAIL first")
+	}
+	to, _, found := cutSMTPPath(arg, "TO:")
+	if !found {
+		return reply("501 Syntax: RCPT TO:<address>")
+	}
+	if len(session.users) >= maxRecipients {
+		return reply("452 Too many recipients")
+	}
+	userID, ok, err := s.lookup(to)
+	if err != nil {
+		slog.Error("failed to look up email recipient", "error", err)
+		return reply("451 Temporary failure, try again later")
+	}
+	if !ok {
+		return reply("550 No such user")
+	}
+	for _, id := range session.users {
+		if id == userID {
+			return reply("250 OK")
+		}
+	}
+	session.users = append(session.users, userID)
+	return reply("250 OK")
+}
+
+// lookup returns the user a recipient address belongs to.
+func (s *SMTPSource) lookup(address string) (int64, bool, error) {
+	local, domain, found := strings.Cut(address, "@")
+	if !found || (s.domain != "" && !strings.EqualFold(domain, s.domain)) {
+		return 0, false, nil
+	}
+	return s.addresses.Lookup(local)
+}
+
+// data reads a message after DATA and emits its contents for every
+// recipient.
+func (s *SMTPSource) data(session *smtpSession, tp *textproto.Conn, emit EmitFunc, reply func(string, ...any) bool) bool {
+	buf := &smtpBuffer{budget: s.buffers}
+	defer buf.release()
+	body := tp.DotReader()
+	n, err := io.Copy(buf, io.LimitReader(body, maxEmailSize+1))
+	if err != nil && !errors.Is(err, errSMTPBusy) {
+		return false
+	}
+	if err != nil || n > maxEmailSize {
+		// Read the rest so the client sees the error, not a dropped connection
+		if _, err := io.Copy(io.Discard, body); err != nil {
+			return false
+		}
+		if n > maxEmailSize {
+			return reply("552 Message too large")
+		}
+		return reply("452 Too much mail in progress, try again later")
+	}
+	// Parsing copies the message once more
+	if !buf.reserve(buf.data.Len()) {
+		return reply("452 Too much mail in progress, try again later")
+	}
+
+	email, err := ParseEmail(&buf.data)
+	if err != nil {
+		slog.Warn("failed to parse email", "from", session.from, "error", err)
+		return reply("554 Malformed message")
+	}
+	raws := email.Contents()
+	if len(raws) == 0 {
+		return reply("554 Nothing to save in message")
+	}
+	// Once anything is queued the message is accepted: a retry would
+	// deliver it again to the recipients that already have it
+	delivered := 0
+	for _, userID := range session.users {
+		n, err := s.deliver(userID, raws, emit)
+		delivered += n
+		if err != nil {
+			slog.Error("failed to save email", "user_id", userID, "error", err)
+			continue
+		}
+		slog.Info("email received", "user_id", userID, "subject", email.Subject, "items", len(raws))
+	}
+	if delivered == 0 {
+		return reply("451 Temporary failure, try again later")
+	}
+	return reply("250 OK")
+}
+
+// deliver emits a message's contents for a user and returns how many were
+// queued.
+func (s *SMTPSource) deliver(userID int64, raws []RawContent, emit EmitFunc) (int, error) {
+	vault, err := s.stores.GetVault(userID)
+	if err != nil {
+		return 0, fmt.Errorf("get vault: %w", err)
+	}
+	lang, err := userLanguage(vault)
+	if err != nil {
+		return 0, err
+	}
+	for i, raw := range raws {
+		raw.UserID, raw.Language = userID, lang
+		if err := emit(raw, nil); err != nil {
+			return i, err
+		}
+	}
+	return len(raws), nil
+}
+
+var errSMTPBusy = errors.New("too much mail in progress")
+
+// smtpBuffer holds message data against the source's memory budget.
+type smtpBuffer struct {
+	data   bytes.Buffer
+	budget *semaphore.Weighted
+	held   int64
+}
+
+// reserve takes n bytes from the budget, failing when it is used up.
+func (b *smtpBuffer) reserve(n int) bool {
+	if !b.budget.TryAcquire(int64(n)) {
+		return false
+	}
+	b.held += int64(n)
+	return true
+}
+
+func (b *smtpBuffer) Write(p []byte) (int, error) {
+	if !b.reserve(len(p)) {
+		return 0, errSMTPBusy
+	}
+	return b.data.Write(p)
+}
+
+// release returns everything held to the budget.
+func (b *smtpBuffer) release() {
+	b.budget.Release(b.held)
+	b.held = 0
+}
+
+// smtpLineReader fails once a line grows past max octets, so a client
+// cannot make ReadLine buffer without bound. A max of 0 turns the check off.
+type smtpLineReader struct {
+	r    io.Reader
+	max  int
+	line int // octets read since the last line feed
+	// tooLong is set once a line has gone past max
+	tooLong bool
+}
+
+func (l *smtpLineReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	for i, c := range p[:n] {
+		if c == '\n' {
+			l.line = 0
+		} else {
+			l.line++
+		}
+		// Stop at the overlong line; ReadLine may still return its start
+		if l.max > 0 && l.line >= l.max {
+			l.tooLong = true
+			return i, errSMTPLineTooLong
+		}
+	}
+	return n, err
+}
+
+// cutSMTPPath splits the argument of MAIL or RCPT, "FROM:<addr> PARAMS",
+// into the address and its parameters.
+func cutSMTPPath(arg, prefix string) (address, params string, found bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", "", false
+	}
+	rest := strings.TrimSpace(arg[len(prefix):])
+	path, params, _ := strings.Cut(rest, " ")
+	path = strings.TrimSuffix(strings.TrimPrefix(path, "<"), ">")
+	if path != "" {
+		// Reduce "Name <addr>" style paths sent by lax clients to the address
+		if addr, err := mail.ParseAddress(path); err == nil {
+			path = addr.Address
+		}
+	}
+	return path, params, true
+}
+
+// smtpSizeTooLarge reports whether MAIL's SIZE= parameter exceeds the limit.
+func smtpSizeTooLarge(params string) bool {
+	for _, p := range strings.Fields(params) {
+		key, value, _ := strings.Cut(p, "=")
+		if !strings.EqualFold(key, "SIZE") {
+			continue
+		}
+		size, err := strconv.ParseInt(value, 10, 64)
+		return err == nil && size > maxEmailSize
+	}
+	return false
+}
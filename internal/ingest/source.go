@@ -1,5 +1,24 @@
 package ingest
 
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/nerdneilsfield/dumper/internal/queue"
+	"github.com/nerdneilsfield/dumper/internal/store"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// TelegramSource is the name of the Telegram bot's input source.
+	TelegramSource = "telegram"
+	// APISource is the name of the HTTP API's input source.
+	APISource = "api"
+)
+
 type ContentType string
 
 const (
@@ -7,6 +26,16 @@ const (
 	ContentTypeNote   ContentType = "note"
 	ContentTypeImage  ContentType = "image"
 	ContentTypeSearch ContentType = "search"
+	// ContentTypeAlbum is several photos sent together as one message
+	ContentTypeAlbum ContentType = "album"
+	// ContentTypeDocument is an uploaded file such as a PDF
+	ContentTypeDocument ContentType = "document"
+	// ContentTypeVoice is a voice message or audio file to transcribe
+	ContentTypeVoice ContentType = "voice"
+	// ContentTypeReprocess reruns summarisation for an existing item
+	ContentTypeReprocess ContentType = "reprocess"
+	// ContentTypeArchive attaches a page snapshot to an existing link item
+	ContentTypeArchive ContentType = "archive"
 )
 
 type RawContent struct {
@@ -14,12 +43,98 @@ type RawContent struct {
 	URL       string // for links
 	Text      string // raw text or note content
 	UserID    int64
-	ImageData []byte // raw image bytes (for images)
-	ImageExt  string // file extension: jpg, png, etc.
-	Caption   string // optional Telegram caption
-	Language  string // user's preferred language code (e.g., "en", "ru")
+	ImageData []byte        // raw image bytes (for images)
+	ImageExt  string        // file extension: jpg, png, etc.
+	Caption   string        // optional Telegram caption
+	Album     []AlbumPhoto  // photos of an album, in order
+	FileData  []byte        // raw file bytes (for documents and audio)
+	FileName  string        // original filename
+	FileMIME  string        // file media type as reported by the sender
+	Language  string        // user's preferred language code (e.g., "en", "ru")
+	ItemID    string        // item to reprocess or archive
+	Tags      []string      // added to the item's own tags, e.g. a feed's defaults
+	Source    *store.Source // origin of a forwarded message
+}
+
+// AlbumPhoto is one photo of an album.
+type AlbumPhoto struct {
+	Data []byte
+	Ext  string
 }
 
+// EmitFunc queues captured content for the pipeline. reply is opaque data,
+// marshaled as JSON, that comes back to the source's Ack with the outcome;
+// it may be nil.
+type EmitFunc func(raw RawContent, reply any) error
+
+// InputSource is a capture channel such as the Telegram bot, feed
+// subscriptions or the inbox directory. Run starts it and emits content until
+// ctx is done, which stops it. Ack is called with each emitted job once it is
+// done or has finally failed; job.Reply holds the reply given to emit.
 type InputSource interface {
 	Name() string
+	Run(ctx context.Context, emit EmitFunc) error
+	Ack(job *queue.Job)
+}
+
+// userLanguage returns the user's preferred language code for content a
+// source captures on its own, English by default.
+func userLanguage(vault store.Vault) (string, error) {
+	lang, err := vault.GetSetting("language")
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("get language: %w", err)
+	}
+	if lang == "" {
+		lang = "en"
+	}
+	return lang, nil
+}
+
+// Sources runs input sources against the job queue and routes the outcome of
+// each job back to the source that emitted it.
+type Sources struct {
+	jobs    *queue.Queue
+	sources map[string]InputSource
+}
+
+func NewSources(jobs *queue.Queue, sources ...InputSource) *Sources {
+	s := &Sources{jobs: jobs, sources: make(map[string]InputSource, len(sources))}
+	for _, src := range sources {
+		s.sources[src.Name()] = src
+	}
+	return s
+}
+
+// Run starts every source and waits until they have all stopped. A source
+// failing stops the others.
+func (s *Sources) Run(ctx context.Context) error {
+	g, ctx := errgroup.WithContext(ctx)
+	for name, src := range s.sources {
+		g.Go(func() error {
+			slog.Info("starting input source", "source", name)
+			return src.Run(ctx, func(raw RawContent, reply any) error {
+				if raw.UserID == 0 {
+					return errors.New("content has no user")
+				}
+				_, err := s.jobs.EnqueueFrom(name, raw.UserID, raw, reply)
+				return err
+			})
+		})
+	}
+	return g.Wait()
+}
+
+// Ack passes a finished job to its source. It is the queue's Notifier.
+func (s *Sources) Ack(job *queue.Job) {
+	name := job.Source
+	if name == "" {
+		// Jobs queued before sources were recorded all came from the bot
+		name = TelegramSource
+	}
+	src, ok := s.sources[name]
+	if !ok {
+		slog.Warn("job from unknown input source", "job_id", job.ID, "source", name)
+		return
+	}
+	src.Ack(job)
 }
@@ -0,0 +1,69 @@
+package ingest
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// stackExchangeExtractor reads a question with its accepted answer, or the
+// highest voted one when none is accepted.
+var stackExchangeExtractor = SiteExtractor{
+	Name: "Stack Exchange",
+	Hosts: []string{
+		"stackoverflow.com", "*.stackexchange.com", "superuser.com",
+		"serverfault.com", "askubuntu.com", "mathoverflow.net",
+	},
+	Match: func(u *url.URL) bool {
+		// /questions/<id>/<slug>
+		segments := pathSegments(u)
+		return len(segments) >= 2 && segments[0] == "questions"
+	},
+	Extract: extractStackExchange,
+}
+
+func extractStackExchange(doc *html.Node, _ *url.URL) (*ExtractedContent, error) {
+	title := textOf(findFirst(findFirst(doc, byID("question-header")), byTag("h1")))
+	question := textOf(findFirst(findFirst(doc, byID("question")), byClass("s-prose")))
+	if title == "" || question == "" {
+		return nil, errNoSiteContent
+	}
+
+	parts := []string{"Question:\n\n" + question}
+
+	var tags []string
+	for _, a := range findAll(findFirst(doc, byID("question")), byTag("a", "post-tag")) {
+		tags = append(tags, textOf(a))
+	}
+	if len(tags) > 0 {
+		parts = append(parts, "Tags: "+strings.Join(tags, ", "))
+	}
+
+	var best *html.Node
+	bestScore := 0
+	for _, answer := range findAll(doc, byTag("div", "answer")) {
+		if byClass("accepted-answer")(answer) {
+			best = answer
+			break
+		}
+		score, _ := strconv.Atoi(attr(answer, "data-score"))
+		if best == nil || score > bestScore {
+			best, bestScore = answer, score
+		}
+	}
+	if text := textOf(findFirst(best, byClass("s-prose"))); text != "" {
+		heading := fmt.Sprintf("Top answer (score %s):", attr(best, "data-score"))
+		if byClass("accepted-answer")(best) {
+			heading = "Accepted answer:"
+		}
+		parts = append(parts, heading+"\n\n"+text)
+	}
+
+	return &ExtractedContent{
+		Title:   title,
+		Content: strings.Join(parts, "\n\n"),
+	}, nil
+}
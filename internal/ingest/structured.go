@@ -0,0 +1,318 @@
+package ingest
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/nerdneilsfield/dumper/internal/store"
+	"golang.org/x/net/html"
+)
+
+// schemaTypes maps schema.org types to the kinds StructuredData describes,
+// most specific first.
+var schemaTypes = []struct {
+	kind  string
+	match func(t string) bool
+}{
+	{store.SchemaRecipe, func(t string) bool { return t == "Recipe" }},
+	{store.SchemaProduct, func(t string) bool { return t == "Product" || t == "ProductGroup" }},
+	{store.SchemaBook, func(t string) bool { return t == "Book" }},
+	{store.SchemaEvent, func(t string) bool { return strings.HasSuffix(t, "Event") }},
+	{store.SchemaArticle, func(t string) bool {
+		return strings.HasSuffix(t, "Article") || t == "BlogPosting" || t == "Report"
+	}},
+}
+
+// extractStructuredData reads schema.org JSON-LD and OpenGraph tags from a
+// page. It returns nil when the page has neither.
+func extractStructuredData(doc *html.Node) *store.StructuredData {
+	og := openGraph(doc)
+
+	var data *store.StructuredData
+	var objects []map[string]any
+	for _, script := range findAll(doc, func(n *html.Node) bool {
+		return n.Data == "script" && strings.EqualFold(strings.TrimSpace(attr(n, "type")), "application/ld+json")
+	}) {
+		if script.FirstChild != nil {
+			objects = append(objects, jsonLDObjects(script.FirstChild.Data)...)
+		}
+	}
+	for _, kind := range schemaTypes {
+		for _, obj := range objects {
+			if matchesSchemaType(obj, kind.match) {
+				data = structuredFromJSONLD(kind.kind, obj)
+				break
+			}
+		}
+		if data != nil {
+			break
+		}
+	}
+
+	if data == nil {
+		if len(og) == 0 {
+			return nil
+		}
+		data = &store.StructuredData{Type: og["og:type"]}
+	}
+	data.OpenGraph = og
+
+	// OpenGraph fills what JSON-LD left out
+	fill := func(field *string, keys ...string) {
+		for _, key := range keys {
+			if *field == "" {
+				*field = og[key]
+			}
+		}
+	}
+	fill(&data.Name, "og:title")
+	fill(&data.Description, "og:description")
+	fill(&data.Image, "og:image")
+	fill(&data.Author, "article:author", "book:author")
+	fill(&data.Published, "article:published_time", "book:release_date")
+	if data.Type == store.SchemaProduct || og["og:type"] == "product" {
+		fill(&data.Price, "product:price:amount", "og:price:amount")
+		fill(&data.Currency, "product:price:currency", "og:price:currency")
+		fill(&data.Availability, "product:availability", "og:availability")
+	}
+	if og["og:type"] == "book" {
+		fill(&data.ISBN, "book:isbn")
+	}
+	return data
+}
+
+// openGraph returns the page's OpenGraph properties, including the
+// article:, book: and product: namespaces. The first value of each wins.
+func openGraph(doc *html.Node) map[string]string {
+	og := make(map[string]string)
+	for _, meta := range findAll(doc, byTag("meta")) {
+		key := attr(meta, "property")
+		if key == "" {
+			key = attr(meta, "name")
+		}
+		prefix, _, ok := strings.Cut(key, ":")
+		if !ok {
+			continue
+		}
+		switch prefix {
+		case "og", "article", "book", "product":
+		default:
+			continue
+		}
+		if value := strings.TrimSpace(attr(meta, "content")); value != "" {
+			if _, seen := og[key]; !seen {
+				og[key] = value
+			}
+		}
+	}
+	if len(og) == 0 {
+		return nil
+	}
+	return og
+}
+
+// jsonLDObjects returns the objects in a JSON-LD script, flattening arrays
+// and @graph containers. Malformed scripts yield nothing.
+func jsonLDObjects(script string) []map[string]any {
+	var v any
+	if err := json.Unmarshal([]byte(strings.TrimSpace(script)), &v); err != nil {
+		return nil
+	}
+	var objects []map[string]any
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case []any:
+			for _, e := range v {
+				walk(e)
+			}
+		case map[string]any:
+			if graph, ok := v["@graph"]; ok {
+				walk(graph)
+				return
+			}
+			objects = append(objects, v)
+		}
+	}
+	walk(v)
+	return objects
+}
+
+func matchesSchemaType(obj map[string]any, match func(string) bool) bool {
+	for _, t := range ldStrings(obj["@type"]) {
+		// Types may be given as full IRIs
+		t = t[strings.LastIndexAny(t, "/#:")+1:]
+		if match(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func structuredFromJSONLD(kind string, obj map[string]any) *store.StructuredData {
+	data := &store.StructuredData{
+		Type:        kind,
+		Name:        ldString(obj["name"]),
+		Description: ldString(obj["description"]),
+		Image:       ldString(obj["image"]),
+		Author:      strings.Join(ldStrings(obj["author"]), ", "),
+		Published:   ldString(obj["datePublished"]),
+	}
+	if data.Name == "" {
+		data.Name = ldString(obj["headline"])
+	}
+	if raw, err := json.Marshal(obj); err == nil {
+		data.JSONLD = raw
+	}
+
+	switch kind {
+	case store.SchemaRecipe:
+		data.Ingredients = ldStrings(obj["recipeIngredient"])
+		if len(data.Ingredients) == 0 {
+			data.Ingredients = ldStrings(obj["ingredients"])
+		}
+		data.Instructions = recipeSteps(obj["recipeInstructions"])
+		data.Yield = ldString(obj["recipeYield"])
+		data.TotalTime = ldString(obj["totalTime"])
+	case store.SchemaProduct:
+		data.Brand = ldString(obj["brand"])
+		if offers := ldObjects(obj["offers"]); len(offers) > 0 {
+			offer := offers[0]
+			data.Price = ldString(offer["price"])
+			if data.Price == "" {
+				data.Price = ldString(offer["lowPrice"])
+			}
+			data.Currency = ldString(offer["priceCurrency"])
+			// Availability is a schema.org IRI such as https://schema.org/InStock
+			availability := ldString(offer["availability"])
+			data.Availability = availability[strings.LastIndex(availability, "/")+1:]
+		}
+	case store.SchemaBook:
+		data.ISBN = ldString(obj["isbn"])
+		if data.ISBN == "" {
+			// Editions carry the ISBN when the book has several
+			for _, edition := range ldObjects(obj["workExample"]) {
+				if data.ISBN = ldString(edition["isbn"]); data.ISBN != "" {
+					break
+				}
+			}
+		}
+	case store.SchemaEvent:
+		data.StartDate = ldString(obj["startDate"])
+		data.EndDate = ldString(obj["endDate"])
+		data.Location = eventLocation(obj["location"])
+	}
+	return data
+}
+
+// recipeSteps flattens recipe instructions given as text, HowToStep
+// objects or HowToSection lists of steps.
+func recipeSteps(v any) []string {
+	var steps []string
+	switch v := v.(type) {
+	case string:
+		for _, line := range strings.Split(v, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				steps = append(steps, line)
+			}
+		}
+	case []any:
+		for _, e := range v {
+			steps = append(steps, recipeSteps(e)...)
+		}
+	case map[string]any:
+		if items, ok := v["itemListElement"]; ok {
+			return recipeSteps(items)
+		}
+		if text := ldString(v["text"]); text != "" {
+			steps = append(steps, text)
+		} else if name := ldString(v["name"]); name != "" {
+			steps = append(steps, name)
+		}
+	}
+	return steps
+}
+
+// eventLocation describes a location given as text, a Place with an
+// address, or a VirtualLocation.
+func eventLocation(v any) string {
+	places := ldObjects(v)
+	if len(places) == 0 {
+		return ldString(v)
+	}
+	place := places[0]
+	parts := []string{ldString(place["name"])}
+	switch address := place["address"].(type) {
+	case string:
+		parts = append(parts, address)
+	case map[string]any:
+		for _, key := range []string{"streetAddress", "addressLocality", "addressRegion", "addressCountry"} {
+			parts = append(parts, ldString(address[key]))
+		}
+	}
+	if len(parts) == 1 || parts[0] == "" {
+		parts = append(parts, ldString(place["url"]))
+	}
+	var nonEmpty []string
+	for _, p := range parts {
+		if p != "" && (len(nonEmpty) == 0 || nonEmpty[len(nonEmpty)-1] != p) {
+			nonEmpty = append(nonEmpty, p)
+		}
+	}
+	return strings.Join(nonEmpty, ", ")
+}
+
+// ldString returns a JSON-LD value as text: strings and numbers as they are,
+// objects by their name, text or URL, and lists by their first entry.
+func ldString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(html.UnescapeString(v))
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []any:
+		for _, e := range v {
+			if s := ldString(e); s != "" {
+				return s
+			}
+		}
+	case map[string]any:
+		for _, key := range []string{"name", "text", "url", "@value", "@id"} {
+			if s := ldString(v[key]); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// ldStrings returns every entry of a JSON-LD value as text.
+func ldStrings(v any) []string {
+	list, ok := v.([]any)
+	if !ok {
+		list = []any{v}
+	}
+	var values []string
+	for _, e := range list {
+		if s := ldString(e); s != "" {
+			values = append(values, s)
+		}
+	}
+	return values
+}
+
+// ldObjects returns a JSON-LD value as a list of objects.
+func ldObjects(v any) []map[string]any {
+	list, ok := v.([]any)
+	if !ok {
+		list = []any{v}
+	}
+	var objects []map[string]any
+	for _, e := range list {
+		if obj, ok := e.(map[string]any); ok {
+			objects = append(objects, obj)
+		}
+	}
+	return objects
+}
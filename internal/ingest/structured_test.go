@@ -0,0 +1,99 @@
+package ingest
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nerdneilsfield/dumper/internal/store"
+	"golang.org/x/net/html"
+)
+
+func parseHTMLString(t *testing.T, page string) *html.Node {
+	t.Helper()
+	doc, err := html.Parse(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("parse html: %v", err)
+	}
+	return doc
+}
+
+func TestExtractStructuredRecipe(t *testing.T) {
+	page := `<html><head>
+<meta property="og:title" content="Pancakes | Cooking Site">
+<meta property="og:type" content="article">
+<script type="application/ld+json">
+{"@context": "https://schema.org", "@graph": [
+  {"@type": "WebPage", "name": "Pancakes page"},
+  {"@type": "Recipe", "name": "Fluffy Pancakes", "author": [{"@type": "Person", "name": "Ann"}],
+   "recipeYield": ["4", "4 servings"], "totalTime": "PT20M",
+   "recipeIngredient": ["200 g flour", "2 eggs", "300 ml milk"],
+   "recipeInstructions": [
+     {"@type": "HowToSection", "name": "Batter", "itemListElement": [
+       {"@type": "HowToStep", "text": "Whisk everything."}]},
+     {"@type": "HowToStep", "text": "Fry in a hot pan."}]}
+]}
+</script></head><body><p>Story about pancakes.</p></body></html>`
+
+	data := extractStructuredData(parseHTMLString(t, page))
+	if data == nil || data.Type != store.SchemaRecipe || data.Name != "Fluffy Pancakes" || data.Author != "Ann" {
+		t.Fatalf("unexpected recipe: %+v", data)
+	}
+	if want := []string{"200 g flour", "2 eggs", "300 ml milk"}; !reflect.DeepEqual(data.Ingredients, want) {
+		t.Fatalf("ingredients: got %v", data.Ingredients)
+	}
+	if want := []string{"Whisk everything.", "Fry in a hot pan."}; !reflect.DeepEqual(data.Instructions, want) {
+		t.Fatalf("instructions: got %v", data.Instructions)
+	}
+	if data.Yield != "4" || data.OpenGraph["og:type"] != "article" {
+		t.Fatalf("unexpected yield or opengraph: %+v", data)
+	}
+
+	md := data.Markdown()
+	for _, want := range []string{"**Yield:** 4", "## Ingredients\n\n- 200 g flour", "## Instructions\n\n1. Whisk everything.\n2. Fry in a hot pan."} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown missing %q:\n%s", want, md)
+		}
+	}
+}
+
+func TestExtractStructuredProductAndEvent(t *testing.T) {
+	product := `<html><head><script type="application/ld+json">
+{"@context": "https://schema.org", "@type": "Product", "name": "Kettle", "brand": {"@type": "Brand", "name": "Boil"},
+ "offers": {"@type": "Offer", "price": 29.9, "priceCurrency": "EUR", "availability": "https://schema.org/InStock"}}
+</script></head></html>`
+	data := extractStructuredData(parseHTMLString(t, product))
+	if data == nil || data.Brand != "Boil" || data.Price != "29.9" || data.Currency != "EUR" || data.Availability != "InStock" {
+		t.Fatalf("unexpected product: %+v", data)
+	}
+	if md := data.Markdown(); !strings.Contains(md, "**Price:** 29.9 EUR") {
+		t.Fatalf("unexpected product markdown: %s", md)
+	}
+
+	event := `<html><head><script type="application/ld+json">
+[{"@type": "MusicEvent", "name": "Concert", "startDate": "2026-11-01T19:00",
+  "location": {"@type": "Place", "name": "Hall", "address": {"streetAddress": "1 Main St", "addressLocality": "Berlin"}}}]
+</script></head></html>`
+	data = extractStructuredData(parseHTMLString(t, event))
+	if data == nil || data.Type != store.SchemaEvent || data.StartDate != "2026-11-01T19:00" || data.Location != "Hall, 1 Main St, Berlin" {
+		t.Fatalf("unexpected event: %+v", data)
+	}
+}
+
+func TestExtractStructuredOpenGraphOnly(t *testing.T) {
+	page := `<html><head>
+<meta property="og:type" content="product">
+<meta property="og:title" content="Lamp">
+<meta property="product:price:amount" content="15.00">
+<meta property="product:price:currency" content="USD">
+<script type="application/ld+json">{ not json</script>
+</head></html>`
+	data := extractStructuredData(parseHTMLString(t, page))
+	if data == nil || data.Type != "product" || data.Name != "Lamp" || data.Price != "15.00" || data.Currency != "USD" {
+		t.Fatalf("unexpected opengraph data: %+v", data)
+	}
+
+	if extractStructuredData(parseHTMLString(t, `<html><head><title>Plain</title></head></html>`)) != nil {
+		t.Fatal("expected no structured data for a plain page")
+	}
+}
@@ -0,0 +1,47 @@
+package ingest
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/nerdneilsfield/dumper/internal/llm"
+	"golang.org/x/net/html"
+)
+
+// telegramExtractor reads public channel posts through their embeddable
+// widget, as t.me links otherwise serve a landing page.
+var telegramExtractor = SiteExtractor{
+	Name:  "Telegram",
+	Hosts: []string{"t.me", "telegram.me"},
+	Match: func(u *url.URL) bool {
+		// /<channel>/<post>; private /c/ links need a login
+		segments := pathSegments(u)
+		return len(segments) == 2 && segments[0] != "c" && segments[0] != "s"
+	},
+	FetchURL: func(u *url.URL) string {
+		return "https://t.me" + u.Path + "?embed=1&mode=tme"
+	},
+	Extract: extractTelegram,
+}
+
+func extractTelegram(doc *html.Node, u *url.URL) (*ExtractedContent, error) {
+	text := textOf(findFirst(doc, byClass("tgme_widget_message_text")))
+	if text == "" {
+		return nil, errNoSiteContent
+	}
+
+	channel := textOf(findFirst(doc, byClass("tgme_widget_message_owner_name")))
+	if channel == "" {
+		channel = "@" + pathSegments(u)[0]
+	}
+	title, _, _ := strings.Cut(text, "\n")
+	if len(title) > 100 {
+		title = llm.Truncate(title, 100) + "..."
+	}
+
+	return &ExtractedContent{
+		Title:    title,
+		Content:  text,
+		SiteName: channel,
+	}, nil
+}
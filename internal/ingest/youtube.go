@@ -0,0 +1,180 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// youtubeExtractor reads a video's details from the player data embedded in
+// its watch page, and its transcript from the caption tracks listed there.
+var youtubeExtractor = SiteExtractor{
+	Name:  "YouTube",
+	Hosts: []string{"youtube.com", "m.youtube.com", "music.youtube.com", "youtu.be"},
+	Match: func(u *url.URL) bool {
+		return youtubeVideoID(u) != ""
+	},
+	FetchURL: func(u *url.URL) string {
+		return "https://www.youtube.com/watch?v=" + url.QueryEscape(youtubeVideoID(u))
+	},
+	Extract: extractYouTube,
+	Follow:  followYouTubeCaptions,
+}
+
+// youtubeVideoID returns the video a YouTube link points at, or "".
+func youtubeVideoID(u *url.URL) string {
+	segments := pathSegments(u)
+	switch {
+	case strings.HasSuffix(u.Hostname(), "youtu.be") && len(segments) == 1:
+		return segments[0]
+	case len(segments) == 1 && segments[0] == "watch":
+		return u.Query().Get("v")
+	case len(segments) == 2 && (segments[0] == "shorts" || segments[0] == "live" || segments[0] == "embed"):
+		return segments[1]
+	}
+	return ""
+}
+
+// youtubePlayer is the part of ytInitialPlayerResponse read here.
+type youtubePlayer struct {
+	VideoDetails struct {
+		Title            string `json:"title"`
+		Author           string `json:"author"`
+		LengthSeconds    string `json:"lengthSeconds"`
+		ShortDescription string `json:"shortDescription"`
+	} `json:"videoDetails"`
+	Microformat struct {
+		Renderer struct {
+			PublishDate string `json:"publishDate"`
+		} `json:"playerMicroformatRenderer"`
+	} `json:"microformat"`
+	Captions struct {
+		Renderer struct {
+			Tracks []struct {
+				BaseURL      string `json:"baseUrl"`
+				LanguageCode string `json:"languageCode"`
+				Kind         string `json:"kind"` // "asr" for automatic captions
+			} `json:"captionTracks"`
+		} `json:"playerCaptionsTracklistRenderer"`
+	} `json:"captions"`
+}
+
+// youtubePlayerData decodes the player response assigned in a page script.
+func youtubePlayerData(doc *html.Node) *youtubePlayer {
+	const marker = "ytInitialPlayerResponse = "
+	for _, script := range findAll(doc, byTag("script")) {
+		if script.FirstChild == nil {
+			continue
+		}
+		_, rest, ok := strings.Cut(script.FirstChild.Data, marker)
+		if !ok {
+			continue
+		}
+		// The object is followed by more script; decode just the first value
+		var player youtubePlayer
+		if err := json.NewDecoder(strings.NewReader(rest)).Decode(&player); err == nil {
+			return &player
+		}
+	}
+	return nil
+}
+
+func extractYouTube(doc *html.Node, _ *url.URL) (*ExtractedContent, error) {
+	var title, channel, description, published string
+	var duration time.Duration
+	if player := youtubePlayerData(doc); player != nil {
+		details := player.VideoDetails
+		title, channel, description = details.Title, details.Author, details.ShortDescription
+		if seconds, err := strconv.Atoi(details.LengthSeconds); err == nil {
+			duration = time.Duration(seconds) * time.Second
+		}
+		published = player.Microformat.Renderer.PublishDate
+	}
+
+	// Microdata in the page covers the basics when the player data changes shape
+	if title == "" {
+		title = metaContent(doc, "og:title")
+	}
+	if description == "" {
+		description = metaContent(doc, "og:description")
+	}
+	if channel == "" {
+		author := findFirst(doc, func(n *html.Node) bool { return attr(n, "itemprop") == "author" })
+		channel = attr(findFirst(author, func(n *html.Node) bool { return attr(n, "itemprop") == "name" }), "content")
+	}
+	if duration == 0 {
+		duration, _ = parseISODuration(attr(findFirst(doc, func(n *html.Node) bool {
+			return n.Data == "meta" && attr(n, "itemprop") == "duration"
+		}), "content"))
+	}
+	if title == "" {
+		return nil, errNoSiteContent
+	}
+
+	return &ExtractedContent{
+		Title:    title,
+		Content:  mediaContent(channel, duration, published, description),
+		Excerpt:  excerpt(description),
+		SiteName: "YouTube",
+		Kind:     "video",
+	}, nil
+}
+
+// followYouTubeCaptions adds the transcript of a video, preferring captions
+// written by the uploader over automatic ones.
+func followYouTubeCaptions(ctx context.Context, get FetchFunc, doc *html.Node, _ *url.URL, content *ExtractedContent) error {
+	player := youtubePlayerData(doc)
+	if player == nil || len(player.Captions.Renderer.Tracks) == 0 {
+		return nil
+	}
+	tracks := player.Captions.Renderer.Tracks
+	track := tracks[0]
+	for _, t := range tracks {
+		if t.Kind != "asr" {
+			track = t
+			break
+		}
+	}
+
+	data, err := get(ctx, track.BaseURL)
+	if err != nil {
+		return fmt.Errorf("fetch captions: %w", err)
+	}
+	transcript, err := parseCaptions(data)
+	if err != nil {
+		return err
+	}
+	addTranscript(content, transcript, "video transcript")
+	return nil
+}
+
+// mediaContent describes a video or episode for the LLM ahead of its
+// transcript.
+func mediaContent(channel string, duration time.Duration, published, description string) string {
+	var sb strings.Builder
+	if channel != "" {
+		fmt.Fprintf(&sb, "Channel: %s\n", channel)
+	}
+	if duration > 0 {
+		fmt.Fprintf(&sb, "Duration: %s\n", formatDuration(duration))
+	}
+	if published != "" {
+		fmt.Fprintf(&sb, "Published: %s\n", published)
+	}
+	if description != "" {
+		fmt.Fprintf(&sb, "\nDescription:\n%s\n", description)
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// addTranscript appends a transcript so the LLM summarises what is said.
+func addTranscript(content *ExtractedContent, transcript, kind string) {
+	content.Content = strings.TrimSpace(content.Content + "\n\nTranscript:\n" + transcript)
+	content.Kind = kind
+}
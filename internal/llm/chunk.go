@@ -0,0 +1,91 @@
+package llm
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Truncate shortens s to at most maxBytes without splitting a UTF-8 rune.
+func Truncate(s string, maxBytes int) string {
+	if len(s) <= maxBytes {
+		return s
+	}
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut]
+}
+
+// SplitChunks splits text into chunks of at most maxBytes, breaking between
+// paragraphs where possible, then between lines, then between words. Runes
+// are never split.
+func SplitChunks(text string, maxBytes int) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	if len(text) <= maxBytes {
+		return []string{text}
+	}
+
+	var chunks []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			chunks = append(chunks, current.String())
+			current.Reset()
+		}
+	}
+	add := func(piece, sep string) {
+		if current.Len() > 0 && current.Len()+len(sep)+len(piece) > maxBytes {
+			flush()
+		}
+		if current.Len() > 0 {
+			current.WriteString(sep)
+		}
+		current.WriteString(piece)
+	}
+
+	for _, para := range splitNonEmpty(text, "\n\n") {
+		if len(para) <= maxBytes {
+			add(para, "\n\n")
+			continue
+		}
+		// Oversized paragraph: start a fresh chunk and pack it by lines
+		flush()
+		for _, line := range splitNonEmpty(para, "\n") {
+			if len(line) <= maxBytes {
+				add(line, "\n")
+				continue
+			}
+			for _, word := range strings.Fields(line) {
+				for len(word) > maxBytes {
+					// A single "word" longer than a chunk, e.g. base64
+					head := Truncate(word, maxBytes)
+					if head == "" {
+						_, size := utf8.DecodeRuneInString(word)
+						head = word[:size]
+					}
+					flush()
+					chunks = append(chunks, head)
+					word = word[len(head):]
+				}
+				add(word, " ")
+			}
+		}
+		flush()
+	}
+	flush()
+	return chunks
+}
+
+func splitNonEmpty(s, sep string) []string {
+	var parts []string
+	for _, part := range strings.Split(s, sep) {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
@@ -0,0 +1,92 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	s := "Привет, мир"
+	for n := 0; n <= len(s); n++ {
+		got := Truncate(s, n)
+		if len(got) > n || !utf8.ValidString(got) || !strings.HasPrefix(s, got) {
+			t.Fatalf("Truncate(%q, %d) = %q", s, n, got)
+		}
+	}
+	if got := Truncate(s, 3); got != "П" {
+		t.Fatalf("expected whole rune, got %q", got)
+	}
+}
+
+func TestSplitChunks(t *testing.T) {
+	para := strings.Repeat("слово ", 20) // 240 bytes
+	text := strings.Join([]string{para, para, para, strings.Repeat("x", 700)}, "\n\n")
+
+	chunks := SplitChunks(text, 500)
+	if len(chunks) != 4 {
+		t.Fatalf("expected 4 chunks, got %d: %q", len(chunks), chunks)
+	}
+	for _, chunk := range chunks {
+		if len(chunk) > 500 || !utf8.ValidString(chunk) {
+			t.Fatalf("bad chunk (%d bytes): %q", len(chunk), chunk)
+		}
+	}
+	// Paragraphs are packed together while they fit
+	if !strings.Contains(chunks[0], "\n\n") {
+		t.Fatalf("expected first two paragraphs in one chunk: %q", chunks[0])
+	}
+	if got := strings.Join(chunks, ""); strings.Count(got, "x") != 700 {
+		t.Fatalf("content lost while splitting")
+	}
+	if SplitChunks("  \n\n ", 500) != nil {
+		t.Fatal("expected no chunks for blank text")
+	}
+}
+
+func TestProcessContentMapReduce(t *testing.T) {
+	var calls atomic.Int32
+	var reducePrompt atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		var req ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		prompt := req.Messages[0].Content
+		reply := "notes on a section"
+		if strings.HasPrefix(prompt, "Analyze") {
+			reducePrompt.Store(prompt)
+			reply = `{"title": "Long read", "summary": "All of it", "tags": ["go"]}`
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{"message": Message{Role: "assistant", Content: reply}}},
+		})
+	}))
+	defer server.Close()
+
+	c := NewClient("key", "model", Options{ChunkSize: 300, MaxChunks: 3})
+	c.baseURL = server.URL
+
+	content := strings.Repeat(strings.Repeat("word ", 50)+"\n\n", 5) // 5 chunks of 250 bytes
+	result, err := c.ProcessContent(context.Background(), "web article", content, "en", nil)
+	if err != nil {
+		t.Fatalf("process content: %v", err)
+	}
+	if result.Title != "Long read" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	// MaxChunks map calls plus one reduce call
+	if got := calls.Load(); got != 4 {
+		t.Fatalf("expected 4 requests, got %d", got)
+	}
+	prompt, _ := reducePrompt.Load().(string)
+	if !strings.Contains(prompt, "Section 3 of 3:\nnotes on a section") || strings.Contains(prompt, "word word") {
+		t.Fatalf("reduce prompt should contain section summaries only: %s", prompt)
+	}
+}
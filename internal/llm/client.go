@@ -3,35 +3,108 @@ package llm
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"image"
 	"io"
+	"log/slog"
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/nerdneilsfield/dumper/internal/store"
+	"golang.org/x/sync/errgroup"
+)
+
+// Options tunes a Client. Zero values select the defaults.
+type Options struct {
+	MaxConcurrent int // in-flight requests across all callers, 0 for unlimited
+	// ChunkSize is the largest content, in bytes, summarised in one request.
+	// Longer content is split into chunks that are summarised separately and
+	// then combined. Default 8000.
+	ChunkSize int
+	MaxChunks int // chunks summarised per item, the rest is dropped; default 12
+	// VisionModel describes images. Empty disables image understanding.
+	VisionModel string
+}
+
+// ErrNoVision is returned by DescribeImage when no vision model is set.
+var ErrNoVision = errors.New("no vision model configured")
+
+const (
+	// visionImageSize is the longest edge, in pixels, of images sent to
+	// the vision model
+	visionImageSize = 1568
+	// maxVisionImageBytes is the largest image sent without re-encoding
+	maxVisionImageBytes = 1 << 20
 )
 
+func (o *Options) setDefaults() {
+	if o.ChunkSize <= 0 {
+		o.ChunkSize = 8000
+	}
+	if o.MaxChunks <= 0 {
+		o.MaxChunks = 12
+	}
+}
+
 type Client struct {
 	apiKey     string
 	model      string
 	httpClient *http.Client
 	baseURL    string
+	opts       Options
+	slots      limiter
+}
+
+// limiter bounds concurrent requests; nil means unlimited.
+type limiter chan struct{}
+
+// acquire waits for a free slot and returns the func that releases it.
+func (l limiter) acquire(ctx context.Context) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	select {
+	case l <- struct{}{}:
+		return func() { <-l }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
 }
 
-func NewClient(apiKey, model string) *Client {
-	return &Client{
+func NewClient(apiKey, model string, opts Options) *Client {
+	opts.setDefaults()
+	c := &Client{
 		apiKey:  apiKey,
 		model:   model,
 		baseURL: "https://openrouter.ai/api/v1",
 		httpClient: &http.Client{
 			Timeout: 60 * time.Second,
 		},
+		opts: opts,
+	}
+	if opts.MaxConcurrent > 0 {
+		c.slots = make(limiter, opts.MaxConcurrent)
 	}
+	return c
 }
 
 func (c *Client) Chat(ctx context.Context, messages []Message) (string, error) {
+	return c.chat(ctx, c.model, messages)
+}
+
+func (c *Client) chat(ctx context.Context, model string, messages []Message) (string, error) {
+	release, err := c.slots.acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
 	req := ChatRequest{
-		Model:    c.model,
+		Model:    model,
 		Messages: messages,
 	}
 
@@ -76,10 +149,16 @@ func (c *Client) Chat(ctx context.Context, messages []Message) (string, error) {
 	return chatResp.Choices[0].Message.Content, nil
 }
 
+// ProcessContent extracts a title, summary and tags. Content longer than
+// ChunkSize is first condensed with summarizeChunks.
 func (c *Client) ProcessContent(ctx context.Context, contentType, content, lang string, existingTags []string) (*ProcessedContent, error) {
-	// Truncate content if too long (preserve first ~8000 chars)
-	if len(content) > 8000 {
-		content = content[:8000] + "..."
+	if len(content) > c.opts.ChunkSize {
+		condensed, err := c.summarizeChunks(ctx, content, lang)
+		if err != nil {
+			return nil, err
+		}
+		content = condensed
+		contentType += " (condensed from section summaries)"
 	}
 
 	tagsContext := formatExistingTags(existingTags)
@@ -111,6 +190,115 @@ func (c *Client) ProcessContent(ctx context.Context, contentType, content, lang
 	return &result, nil
 }
 
+// DescribeImage asks the vision model for a title, description, tags and
+// any text visible in the image. caption is the user's text sent with it.
+func (c *Client) DescribeImage(ctx context.Context, image []byte, caption, lang string, existingTags []string) (*ImageDescription, error) {
+	return c.DescribeImages(ctx, [][]byte{image}, caption, lang, existingTags)
+}
+
+// DescribeImages describes images sent together, such as an album, as a
+// whole.
+func (c *Client) DescribeImages(ctx context.Context, images [][]byte, caption, lang string, existingTags []string) (*ImageDescription, error) {
+	if c.opts.VisionModel == "" {
+		return nil, ErrNoVision
+	}
+
+	tagsContext := formatExistingTags(existingTags)
+	if caption == "" {
+		caption = "(none)"
+	}
+	prompt := fmt.Sprintf(DescribeImagePrompt, tagsContext, caption)
+	if len(images) > 1 {
+		prompt += fmt.Sprintf("\n\nThe %d attached images were sent together as one album. Describe them as a whole and include the text from all of them.", len(images))
+	}
+	if lang == "ru" {
+		prompt += "\n\nIMPORTANT: Generate the title and description in Russian (русский язык). Keep the extracted text in its original language."
+	}
+
+	parts := []ContentPart{{Type: "text", Text: prompt}}
+	for i, img := range images {
+		img, err := visionImage(img)
+		if err != nil {
+			slog.Warn("skipping image the vision model cannot be sent", "index", i, "error", err)
+			continue
+		}
+		dataURL := "data:" + http.DetectContentType(img) + ";base64," + base64.StdEncoding.EncodeToString(img)
+		parts = append(parts, ContentPart{Type: "image_url", ImageURL: &ImageURL{URL: dataURL}})
+	}
+	if len(parts) == 1 {
+		return nil, errors.New("no readable images")
+	}
+	response, err := c.chat(ctx, c.opts.VisionModel, []Message{{Role: "user", Parts: parts}})
+	if err != nil {
+		return nil, fmt.Errorf("chat: %w", err)
+	}
+
+	// Clean response - sometimes LLM adds markdown code blocks
+	response = strings.TrimSpace(response)
+	response = strings.TrimPrefix(response, "```json")
+	response = strings.TrimPrefix(response, "```")
+	response = strings.TrimSuffix(response, "```")
+	response = strings.TrimSpace(response)
+
+	var result ImageDescription
+	if err := json.Unmarshal([]byte(response), &result); err != nil {
+		return nil, fmt.Errorf("parse response: %w (raw: %s)", err, response)
+	}
+	result.Text = strings.TrimSpace(result.Text)
+	return &result, nil
+}
+
+// visionImage bounds an image sent to the vision model. Images up to
+// MaxBlobSize would otherwise be sent whole, once per album member, though
+// providers scale them down anyway; small ones are sent as they are.
+func visionImage(data []byte) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("read image: %w", err)
+	}
+	if cfg.Width <= visionImageSize && cfg.Height <= visionImageSize && len(data) <= maxVisionImageBytes {
+		return data, nil
+	}
+	return store.ScaleImage(data, visionImageSize)
+}
+
+// summarizeChunks is the map step for long content: it summarises each chunk
+// concurrently and returns the summaries in order, ready for the final reduce
+// prompt.
+func (c *Client) summarizeChunks(ctx context.Context, content, lang string) (string, error) {
+	chunks := SplitChunks(content, c.opts.ChunkSize)
+	if len(chunks) > c.opts.MaxChunks {
+		slog.Warn("content too long, summarising the beginning only",
+			"chunks", len(chunks), "max_chunks", c.opts.MaxChunks)
+		chunks = chunks[:c.opts.MaxChunks]
+	}
+
+	summaries := make([]string, len(chunks))
+	g, gctx := errgroup.WithContext(ctx)
+	for i, chunk := range chunks {
+		g.Go(func() error {
+			prompt := fmt.Sprintf(SummarizeChunkPrompt, i+1, len(chunks), chunk)
+			if lang == "ru" {
+				prompt += "\n\nIMPORTANT: Write the notes in Russian (русский язык)."
+			}
+			response, err := c.Chat(gctx, []Message{
+				{Role: "user", Content: prompt},
+			})
+			if err != nil {
+				return fmt.Errorf("summarize chunk %d/%d: %w", i+1, len(chunks), err)
+			}
+			summaries[i] = fmt.Sprintf("Section %d of %d:\n%s", i+1, len(chunks), strings.TrimSpace(response))
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return "", err
+	}
+
+	// Section summaries are short; this only guards the reduce prompt size
+	return Truncate(strings.Join(summaries, "\n\n"), c.opts.ChunkSize), nil
+}
+
 func (c *Client) AnswerQuestion(ctx context.Context, question string, items []string) (string, error) {
 	itemsStr := strings.Join(items, "\n\n---\n\n")
 	prompt := fmt.Sprintf(AnswerQuestionPrompt, question, itemsStr)
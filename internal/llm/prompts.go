@@ -24,6 +24,34 @@ Rules:
 - Summary should be informative but concise
 - Related topics help build knowledge graph connections`
 
+const SummarizeChunkPrompt = `The following is section %d of %d of a longer document.
+Write concise notes (at most 5 sentences) on the key points, facts and names in this section.
+Use plain text only.
+
+Section:
+---
+%s
+---`
+
+const DescribeImagePrompt = `Describe the attached image so it can be found again later.
+%s
+Caption from the user: %s
+
+Respond with ONLY valid JSON (no markdown, no explanation):
+{
+  "title": "concise descriptive title (max 10 words)",
+  "description": "2-3 sentences describing what the image shows",
+  "tags": ["tag1", "tag2", "tag3"],
+  "text": "all readable text in the image, transcribed exactly; empty string if none"
+}
+
+Rules:
+- Tags should be lowercase, single words or short phrases
+- If the caption includes hashtags (e.g. #tag), include them as tags without the "#"
+- Generate 3-7 relevant tags
+- PREFER reusing existing tags when they fit the content (consistency is valuable)
+- For screenshots, documents and slides, describe the subject, not just the layout`
+
 const FindRelationshipsPrompt = `Given a new item and existing items, identify ONLY genuinely related items.
 
 New item:
@@ -0,0 +1,107 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Transcriber turns speech into text through an OpenAI-compatible
+// /audio/transcriptions endpoint, such as OpenAI, Groq or a local
+// whisper server.
+type Transcriber struct {
+	apiKey     string
+	model      string
+	baseURL    string
+	httpClient *http.Client
+	slots      limiter
+}
+
+// NewTranscriber creates a transcriber for the API at baseURL, for example
+// https://api.openai.com/v1. apiKey may be empty for local servers.
+func NewTranscriber(baseURL, apiKey, model string) *Transcriber {
+	return &Transcriber{
+		apiKey:  apiKey,
+		model:   model,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{
+			// Long recordings take a while to transcribe
+			Timeout: 5 * time.Minute,
+		},
+	}
+}
+
+// LimitWith counts transcriptions against the concurrency limit of c, so
+// voice notes queue behind the other LLM requests.
+func (t *Transcriber) LimitWith(c *Client) {
+	t.slots = c.slots
+}
+
+// Transcribe returns the text spoken in audio. The filename's extension
+// tells the server the audio format.
+func (t *Transcriber) Transcribe(ctx context.Context, audio []byte, filename string) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", filename)
+	if err != nil {
+		return "", fmt.Errorf("create form: %w", err)
+	}
+	if _, err := part.Write(audio); err != nil {
+		return "", fmt.Errorf("write audio: %w", err)
+	}
+	form.WriteField("model", t.model)
+	form.WriteField("response_format", "json")
+	if err := form.Close(); err != nil {
+		return "", fmt.Errorf("close form: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", t.baseURL+"/audio/transcriptions", &body)
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+	if t.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+t.apiKey)
+	}
+	httpReq.Header.Set("Content-Type", form.FormDataContentType())
+
+	release, err := t.slots.acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	resp, err := t.httpClient.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("read response: %w", err)
+	}
+
+	var result struct {
+		Text  string `json:"text"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return "", fmt.Errorf("unmarshal response (status %d): %w", resp.StatusCode, err)
+	}
+	if result.Error != nil {
+		return "", fmt.Errorf("api error: %s", result.Error.Message)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("bad status: %d", resp.StatusCode)
+	}
+
+	return strings.TrimSpace(result.Text), nil
+}
@@ -0,0 +1,78 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTranscribe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/audio/transcriptions" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer key" {
+			t.Errorf("unexpected authorization %q", got)
+		}
+		if got := r.FormValue("model"); got != "whisper-1" {
+			t.Errorf("unexpected model %q", got)
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("read file: %v", err)
+			return
+		}
+		data, _ := io.ReadAll(file)
+		if header.Filename != "voice.ogg" || string(data) != "OggS audio" {
+			t.Errorf("unexpected upload %q: %q", header.Filename, data)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"text": " Buy milk and call mum. "})
+	}))
+	defer server.Close()
+
+	tr := NewTranscriber(server.URL+"/v1/", "key", "whisper-1")
+	text, err := tr.Transcribe(context.Background(), []byte("OggS audio"), "voice.ogg")
+	if err != nil {
+		t.Fatalf("transcribe: %v", err)
+	}
+	if text != "Buy milk and call mum." {
+		t.Fatalf("unexpected transcript %q", text)
+	}
+}
+
+func TestTranscribeError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": {"message": "Invalid file format."}}`))
+	}))
+	defer server.Close()
+
+	_, err := NewTranscriber(server.URL, "", "whisper-1").Transcribe(context.Background(), []byte("x"), "a.bin")
+	if err == nil || err.Error() != "api error: Invalid file format." {
+		t.Fatalf("expected api error, got %v", err)
+	}
+}
+
+func TestTranscribeWaitsForSlot(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request sent while the client was busy")
+	}))
+	defer server.Close()
+
+	c := NewClient("key", "model", Options{MaxConcurrent: 1})
+	tr := NewTranscriber(server.URL, "", "whisper-1")
+	tr.LimitWith(c)
+	// A chat request holds the only slot
+	c.slots <- struct{}{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := tr.Transcribe(ctx, []byte("OggS audio"), "voice.ogg"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected to wait for a slot, got %v", err)
+	}
+}
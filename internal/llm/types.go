@@ -1,8 +1,36 @@
 package llm
 
+import "encoding/json"
+
 type Message struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+	// Parts replaces Content with multimodal content, such as text and
+	// images, when set.
+	Parts []ContentPart `json:"-"`
+}
+
+// ContentPart is one part of a multimodal message: text or an image.
+type ContentPart struct {
+	Type     string    `json:"type"` // "text" or "image_url"
+	Text     string    `json:"text,omitempty"`
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+}
+
+// ImageURL points at an image, usually inline as a data: URL.
+type ImageURL struct {
+	URL string `json:"url"`
+}
+
+func (m Message) MarshalJSON() ([]byte, error) {
+	if m.Parts == nil {
+		type plain Message
+		return json.Marshal(plain(m))
+	}
+	return json.Marshal(struct {
+		Role    string        `json:"role"`
+		Content []ContentPart `json:"content"`
+	}{m.Role, m.Parts})
 }
 
 type ChatRequest struct {
@@ -26,6 +54,14 @@ type ProcessedContent struct {
 	RelatedTopics []string `json:"related_topics"`
 }
 
+// ImageDescription is what a vision model sees in an image.
+type ImageDescription struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	Text        string   `json:"text"` // text visible in the image, empty if none
+}
+
 type RelationshipSuggestion struct {
 	TargetID     string  `json:"target_id"`
 	RelationType string  `json:"relation_type"`
@@ -0,0 +1,97 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDescribeImage(t *testing.T) {
+	png := encodePNG(t, 40, 20)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model    string `json:"model"`
+			Messages []struct {
+				Content []ContentPart `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+			return
+		}
+		if req.Model != "vision" {
+			t.Errorf("expected vision model, got %q", req.Model)
+		}
+		parts := req.Messages[0].Content
+		if len(parts) != 2 || !strings.Contains(parts[0].Text, "Caption from the user: #receipt") ||
+			parts[1].ImageURL == nil || !strings.HasPrefix(parts[1].ImageURL.URL, "data:image/png;base64,") {
+			t.Errorf("unexpected multimodal content: %+v", parts)
+		}
+		reply := "```json\n" + `{"title": "Coffee receipt", "description": "A till receipt.", "tags": ["receipt"], "text": " LATTE 3.50 "}` + "\n```"
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{"message": Message{Role: "assistant", Content: reply}}},
+		})
+	}))
+	defer server.Close()
+
+	c := NewClient("key", "model", Options{VisionModel: "vision"})
+	c.baseURL = server.URL
+	desc, err := c.DescribeImage(context.Background(), png, "#receipt", "en", nil)
+	if err != nil {
+		t.Fatalf("describe image: %v", err)
+	}
+	if desc.Title != "Coffee receipt" || desc.Text != "LATTE 3.50" {
+		t.Fatalf("unexpected description: %+v", desc)
+	}
+
+	if _, err := NewClient("key", "model", Options{}).DescribeImage(context.Background(), png, "", "en", nil); !errors.Is(err, ErrNoVision) {
+		t.Fatalf("expected ErrNoVision, got %v", err)
+	}
+}
+
+func TestVisionImage(t *testing.T) {
+	small := encodePNG(t, 40, 20)
+	if got, err := visionImage(small); err != nil || !bytes.Equal(got, small) {
+		t.Fatalf("small image not sent as is: %v", err)
+	}
+
+	scaled, err := visionImage(encodePNG(t, 4000, 1000))
+	if err != nil {
+		t.Fatalf("scale image: %v", err)
+	}
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(scaled))
+	if err != nil || cfg.Width != visionImageSize || cfg.Height != visionImageSize/4 {
+		t.Fatalf("unexpected scaled image: %+v %v", cfg, err)
+	}
+
+	if _, err := visionImage([]byte("\x89PNG\r\n\x1a\n fake image")); err == nil {
+		t.Fatal("expected an error for an unreadable image")
+	}
+}
+
+func TestMessageMarshalText(t *testing.T) {
+	data, err := json.Marshal(Message{Role: "user", Content: "hi"})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if string(data) != `{"role":"user","content":"hi"}` {
+		t.Fatalf("unexpected json %s", data)
+	}
+}
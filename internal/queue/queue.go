@@ -0,0 +1,501 @@
+// Package queue is a durable job queue backed by SQLite. Jobs survive
+// restarts: anything pending or interrupted mid-run is picked up again on
+// the next start. Delivery is at-least-once.
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const (
+	// doneRetention is how long completed jobs are kept for inspection.
+	doneRetention = 7 * 24 * time.Hour
+	// failedRetention is how long failed jobs are kept for inspection.
+	failedRetention = 30 * 24 * time.Hour
+)
+
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusRunning Status = "running"
+	StatusDone    Status = "done"
+	StatusFailed  Status = "failed"
+)
+
+const schemaSQL = `
+CREATE TABLE IF NOT EXISTS jobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    payload TEXT NOT NULL,
+    reply TEXT,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK(status IN ('pending', 'running', 'done', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    result TEXT,
+    run_at DATETIME NOT NULL,
+    started_at DATETIME,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_due ON jobs(status, run_at);
+CREATE INDEX IF NOT EXISTS idx_jobs_reply ON jobs(reply);
+`
+
+// Migration for queues created before fair scheduling
+const migrationAddStartedAt = `
+ALTER TABLE jobs ADD COLUMN started_at DATETIME;
+`
+
+// Migration for queues created before jobs recorded their input source
+const migrationAddSource = `
+ALTER TABLE jobs ADD COLUMN source TEXT NOT NULL DEFAULT '';
+`
+
+const userIndexesSQL = `
+CREATE INDEX IF NOT EXISTS idx_jobs_user_status ON jobs(user_id, status);
+CREATE INDEX IF NOT EXISTS idx_jobs_user_started ON jobs(user_id, started_at);
+`
+
+// Job is a unit of queued work.
+type Job struct {
+	ID     int64
+	UserID int64
+	// Source names the input source that enqueued the job, if any.
+	Source string
+	// Payload is the handler's input.
+	Payload json.RawMessage
+	// Reply is opaque data the submitter uses to report the outcome, e.g.
+	// the chat message to edit.
+	Reply     json.RawMessage
+	Status    Status
+	Attempts  int
+	LastError string
+	// Result is the handler's output, set once the job is done.
+	Result    string
+	RunAt     time.Time
+	CreatedAt time.Time
+}
+
+// Handler processes a job and returns its result. Returned errors are
+// retried with backoff unless wrapped with Permanent.
+type Handler func(ctx context.Context, job *Job) (string, error)
+
+// Notifier is called once a job is done or has finally failed.
+type Notifier func(job *Job)
+
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error as not worth retrying.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// Cipher encrypts job payloads at rest with the submitting user's key.
+// Payloads not sealed by it are read as plaintext.
+type Cipher interface {
+	SealString(userID int64, s string) (string, error)
+	OpenString(userID int64, s string) (string, error)
+}
+
+// Options configures a Queue. Zero values use the defaults.
+type Options struct {
+	Workers     int           // concurrent jobs, default 4
+	MaxAttempts int           // attempts before a job fails, default 5
+	BaseBackoff time.Duration // delay before the first retry, default 10s
+	MaxBackoff  time.Duration // cap on the retry delay, default 10m
+	// PollInterval is how often idle workers look for jobs that became due.
+	// Enqueue wakes them immediately. Default 1s.
+	PollInterval time.Duration
+	// Cipher encrypts payloads, which hold users' content. Nil stores them
+	// in plaintext.
+	Cipher Cipher
+}
+
+func (o *Options) setDefaults() {
+	if o.Workers <= 0 {
+		o.Workers = 4
+	}
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 5
+	}
+	if o.BaseBackoff <= 0 {
+		o.BaseBackoff = 10 * time.Second
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = 10 * time.Minute
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = time.Second
+	}
+}
+
+type Queue struct {
+	db   *sql.DB
+	opts Options
+	wake chan struct{}
+}
+
+// Open opens or creates the queue database at path.
+func Open(path string, opts Options) (*Queue, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("create queue dir: %w", err)
+	}
+	db, err := sql.Open("sqlite", path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("open queue db: %w", err)
+	}
+	if _, err := db.Exec(schemaSQL); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create queue schema: %w", err)
+	}
+	// Add started_at and source columns for existing queues (ignore error if they exist)
+	_, _ = db.Exec(migrationAddStartedAt)
+	_, _ = db.Exec(migrationAddSource)
+	if _, err := db.Exec(userIndexesSQL); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create queue indexes: %w", err)
+	}
+
+	opts.setDefaults()
+	return &Queue{
+		db:   db,
+		opts: opts,
+		wake: make(chan struct{}, 1),
+	}, nil
+}
+
+func (q *Queue) Close() error {
+	return q.db.Close()
+}
+
+// Enqueue stores a job for payload, marshaled as JSON, and wakes a worker.
+// reply may be nil.
+func (q *Queue) Enqueue(userID int64, payload, reply any) (*Job, error) {
+	return q.EnqueueFrom("", userID, payload, reply)
+}
+
+// EnqueueFrom is Enqueue for a job submitted by the named input source, so
+// its outcome can be routed back there.
+func (q *Queue) EnqueueFrom(source string, userID int64, payload, reply any) (*Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshal payload: %w", err)
+	}
+	var replyData []byte
+	if reply != nil {
+		if replyData, err = json.Marshal(reply); err != nil {
+			return nil, fmt.Errorf("marshal reply: %w", err)
+		}
+	}
+
+	stored := string(data)
+	if q.opts.Cipher != nil {
+		if stored, err = q.opts.Cipher.SealString(userID, stored); err != nil {
+			return nil, fmt.Errorf("encrypt payload: %w", err)
+		}
+	}
+
+	created := now()
+	job := &Job{
+		UserID:    userID,
+		Source:    source,
+		Payload:   data,
+		Reply:     replyData,
+		Status:    StatusPending,
+		RunAt:     created,
+		CreatedAt: created,
+	}
+	res, err := q.db.Exec(`
+		INSERT INTO jobs (user_id, source, payload, reply, status, run_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		userID, source, stored, nullString(replyData), StatusPending, created, created, created)
+	if err != nil {
+		return nil, fmt.Errorf("insert job: %w", err)
+	}
+	if job.ID, err = res.LastInsertId(); err != nil {
+		return nil, fmt.Errorf("get job id: %w", err)
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// Run processes jobs until ctx is cancelled. Jobs left running by a previous
+// process are resumed first.
+func (q *Queue) Run(ctx context.Context, handle Handler, notify Notifier) error {
+	res, err := q.db.Exec(`UPDATE jobs SET status = ?, updated_at = ? WHERE status = ?`,
+		StatusPending, now(), StatusRunning)
+	if err != nil {
+		return fmt.Errorf("resume jobs: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		slog.Info("resuming interrupted jobs", "count", n)
+	}
+	if _, err := q.db.Exec(`
+		DELETE FROM jobs WHERE (status = ? AND updated_at < ?) OR (status = ? AND updated_at < ?)`,
+		StatusDone, now().Add(-doneRetention), StatusFailed, now().Add(-failedRetention)); err != nil {
+		return fmt.Errorf("prune jobs: %w", err)
+	}
+
+	var wg sync.WaitGroup
+	for range q.opts.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx, handle, notify)
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+func (q *Queue) work(ctx context.Context, handle Handler, notify Notifier) {
+	ticker := time.NewTicker(q.opts.PollInterval)
+	defer ticker.Stop()
+
+	for ctx.Err() == nil {
+		job, err := q.claim()
+		if err != nil {
+			slog.Error("failed to claim job", "error", err)
+		}
+		if job != nil {
+			q.runJob(ctx, job, handle, notify)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-q.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim marks the next due job as running. Users take turns: the job goes to
+// the user with the fewest running jobs, then the one served least recently,
+// so a user with a long backlog cannot starve the others.
+func (q *Queue) claim() (*Job, error) {
+	job := &Job{Status: StatusRunning}
+	var reply sql.NullString
+	var payload string
+	started := now()
+	err := q.db.QueryRow(`
+		UPDATE jobs SET status = ?, attempts = attempts + 1, started_at = ?, updated_at = ?
+		WHERE id = (
+			SELECT j.id FROM jobs j
+			WHERE j.status = ? AND j.run_at <= ?
+			ORDER BY
+				(SELECT COUNT(*) FROM jobs r WHERE r.user_id = j.user_id AND r.status = ?),
+				(SELECT MAX(s.started_at) FROM jobs s WHERE s.user_id = j.user_id),
+				j.run_at, j.id
+			LIMIT 1
+		)
+		RETURNING id, user_id, source, payload, reply, attempts, run_at, created_at`,
+		StatusRunning, started, started, StatusPending, started, StatusRunning,
+	).Scan(&job.ID, &job.UserID, &job.Source, &payload, &reply, &job.Attempts, &job.RunAt, &job.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	job.Payload = json.RawMessage(payload)
+	if reply.Valid {
+		job.Reply = json.RawMessage(reply.String)
+	}
+	return job, nil
+}
+
+// Ahead estimates how many waiting jobs will run before a job enqueued now
+// by userID: the user's own backlog, plus one turn per round for every other
+// user with jobs waiting.
+func (q *Queue) Ahead(userID int64) (int, error) {
+	rows, err := q.db.Query(`
+		SELECT user_id, COUNT(*) FROM jobs
+		WHERE status = ? AND run_at <= ?
+		GROUP BY user_id`, StatusPending, now())
+	if err != nil {
+		return 0, fmt.Errorf("count pending jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var own int
+	var others []int
+	for rows.Next() {
+		var user int64
+		var count int
+		if err := rows.Scan(&user, &count); err != nil {
+			return 0, fmt.Errorf("scan pending count: %w", err)
+		}
+		if user == userID {
+			own = count
+		} else {
+			others = append(others, count)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// The new job runs in round own+1; others get at most that many turns
+	ahead := own
+	for _, count := range others {
+		ahead += min(count, own+1)
+	}
+	return ahead, nil
+}
+
+// Get returns a job without its payload, or nil if there is none with the
+// given ID.
+func (q *Queue) Get(id int64) (*Job, error) {
+	job := &Job{}
+	var reply, lastError, result sql.NullString
+	err := q.db.QueryRow(`
+		SELECT id, user_id, source, reply, status, attempts, last_error, result, run_at, created_at
+		FROM jobs WHERE id = ?`, id,
+	).Scan(&job.ID, &job.UserID, &job.Source, &reply, &job.Status, &job.Attempts,
+		&lastError, &result, &job.RunAt, &job.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query job: %w", err)
+	}
+	if reply.Valid {
+		job.Reply = json.RawMessage(reply.String)
+	}
+	job.LastError = lastError.String
+	job.Result = result.String
+	return job, nil
+}
+
+// Related returns the jobs enqueued with the same reply as job, job included,
+// oldest first. Submitters use it to report a batch of jobs in one message.
+// Payloads are not loaded.
+func (q *Queue) Related(job *Job) ([]Job, error) {
+	if len(job.Reply) == 0 {
+		return nil, nil
+	}
+	rows, err := q.db.Query(`
+		SELECT id, user_id, source, status, attempts, last_error, result, run_at, created_at
+		FROM jobs WHERE reply = ? ORDER BY id`, string(job.Reply))
+	if err != nil {
+		return nil, fmt.Errorf("query related jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []Job
+	for rows.Next() {
+		related := Job{Reply: job.Reply}
+		var lastError, result sql.NullString
+		if err := rows.Scan(&related.ID, &related.UserID, &related.Source, &related.Status, &related.Attempts,
+			&lastError, &result, &related.RunAt, &related.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan related job: %w", err)
+		}
+		related.LastError = lastError.String
+		related.Result = result.String
+		jobs = append(jobs, related)
+	}
+	return jobs, rows.Err()
+}
+
+func (q *Queue) runJob(ctx context.Context, job *Job, handle Handler, notify Notifier) {
+	var result string
+	err := q.openPayload(job)
+	if err == nil {
+		result, err = handle(ctx, job)
+	}
+	if err == nil {
+		job.Status = StatusDone
+		job.Result = result
+		// Drop the payload, which can hold whole images
+		if _, err := q.db.Exec(`
+			UPDATE jobs SET status = ?, result = ?, payload = '', last_error = NULL, updated_at = ?
+			WHERE id = ?`,
+			StatusDone, result, now(), job.ID); err != nil {
+			slog.Error("failed to complete job", "job_id", job.ID, "error", err)
+		}
+		notify(job)
+		return
+	}
+
+	// Shutting down: leave the job to be resumed on the next start
+	if ctx.Err() != nil {
+		return
+	}
+
+	job.LastError = err.Error()
+	var permanent *permanentError
+	if errors.As(err, &permanent) || job.Attempts >= q.opts.MaxAttempts {
+		slog.Error("job failed", "job_id", job.ID, "user_id", job.UserID, "attempts", job.Attempts, "error", err)
+		job.Status = StatusFailed
+		if _, err := q.db.Exec(`UPDATE jobs SET status = ?, last_error = ?, payload = '', updated_at = ? WHERE id = ?`,
+			StatusFailed, job.LastError, now(), job.ID); err != nil {
+			slog.Error("failed to mark job failed", "job_id", job.ID, "error", err)
+		}
+		notify(job)
+		return
+	}
+
+	delay := q.backoff(job.Attempts)
+	slog.Warn("job failed, retrying", "job_id", job.ID, "attempt", job.Attempts, "retry_in", delay, "error", err)
+	job.Status = StatusPending
+	job.RunAt = now().Add(delay)
+	if _, err := q.db.Exec(`UPDATE jobs SET status = ?, last_error = ?, run_at = ?, updated_at = ? WHERE id = ?`,
+		StatusPending, job.LastError, job.RunAt, now(), job.ID); err != nil {
+		slog.Error("failed to reschedule job", "job_id", job.ID, "error", err)
+	}
+}
+
+// openPayload decrypts a claimed job's payload in place. A payload that
+// cannot be decrypted never will be, so the error is permanent.
+func (q *Queue) openPayload(job *Job) error {
+	if q.opts.Cipher == nil {
+		return nil
+	}
+	payload, err := q.opts.Cipher.OpenString(job.UserID, string(job.Payload))
+	if err != nil {
+		return Permanent(fmt.Errorf("decrypt payload: %w", err))
+	}
+	job.Payload = json.RawMessage(payload)
+	return nil
+}
+
+// backoff returns the delay before retrying after the given attempt:
+// BaseBackoff doubled per attempt, capped at MaxBackoff.
+func (q *Queue) backoff(attempt int) time.Duration {
+	delay := q.opts.BaseBackoff
+	for i := 1; i < attempt && delay < q.opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, q.opts.MaxBackoff)
+}
+
+// now returns the current time in UTC so stored times compare correctly as
+// text.
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+func nullString(b []byte) sql.NullString {
+	return sql.NullString{String: string(b), Valid: b != nil}
+}
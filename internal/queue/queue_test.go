@@ -0,0 +1,298 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testOptions() Options {
+	return Options{
+		Workers:      2,
+		MaxAttempts:  3,
+		BaseBackoff:  10 * time.Millisecond,
+		MaxBackoff:   20 * time.Millisecond,
+		PollInterval: 5 * time.Millisecond,
+	}
+}
+
+func openTestQueue(t *testing.T, path string) *Queue {
+	t.Helper()
+	q, err := Open(path, testOptions())
+	if err != nil {
+		t.Fatalf("open queue: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = q.Close()
+	})
+	return q
+}
+
+// runUntil runs the queue until n jobs have been reported.
+func runUntil(t *testing.T, q *Queue, n int, handle Handler) []*Job {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	done := make(chan *Job, n)
+	go q.Run(ctx, handle, func(job *Job) { done <- job })
+
+	var jobs []*Job
+	for len(jobs) < n {
+		select {
+		case job := <-done:
+			jobs = append(jobs, job)
+		case <-ctx.Done():
+			t.Fatalf("timed out with %d of %d jobs finished", len(jobs), n)
+		}
+	}
+	cancel()
+	return jobs
+}
+
+func TestQueueRetriesWithBackoff(t *testing.T) {
+	q := openTestQueue(t, filepath.Join(t.TempDir(), "queue.db"))
+	if _, err := q.Enqueue(1, map[string]string{"url": "https://example.com"}, map[string]int{"chat_id": 7}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	var calls atomic.Int32
+	jobs := runUntil(t, q, 1, func(ctx context.Context, job *Job) (string, error) {
+		if calls.Add(1) < 3 {
+			return "", errors.New("llm unavailable")
+		}
+		return "item-1", nil
+	})
+
+	job := jobs[0]
+	if job.Status != StatusDone || job.Result != "item-1" || job.Attempts != 3 {
+		t.Fatalf("unexpected job: %+v", job)
+	}
+	if string(job.Reply) != `{"chat_id":7}` {
+		t.Fatalf("reply not preserved: %s", job.Reply)
+	}
+}
+
+func TestQueueGivesUp(t *testing.T) {
+	q := openTestQueue(t, filepath.Join(t.TempDir(), "queue.db"))
+	for range 2 {
+		if _, err := q.Enqueue(1, "payload", nil); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+
+	var calls atomic.Int32
+	jobs := runUntil(t, q, 2, func(ctx context.Context, job *Job) (string, error) {
+		calls.Add(1)
+		if job.ID == 1 {
+			return "", Permanent(errors.New("bad payload"))
+		}
+		return "", errors.New("still failing")
+	})
+
+	for _, job := range jobs {
+		if job.Status != StatusFailed || job.LastError == "" {
+			t.Fatalf("expected failed job: %+v", job)
+		}
+	}
+	// One attempt for the permanent error, MaxAttempts for the other
+	if got := calls.Load(); got != 4 {
+		t.Fatalf("expected 4 handler calls, got %d", got)
+	}
+}
+
+func TestQueueResumesInterruptedJobs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.db")
+	q := openTestQueue(t, path)
+	if _, err := q.Enqueue(1, "payload", nil); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	// Simulate a crash mid-run
+	if job, err := q.claim(); err != nil || job == nil {
+		t.Fatalf("claim: %v %v", job, err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	q = openTestQueue(t, path)
+	jobs := runUntil(t, q, 1, func(ctx context.Context, job *Job) (string, error) {
+		return "item-1", nil
+	})
+	if jobs[0].Status != StatusDone || jobs[0].Attempts != 2 {
+		t.Fatalf("unexpected resumed job: %+v", jobs[0])
+	}
+}
+
+func TestQueueRoundRobinsUsers(t *testing.T) {
+	q := openTestQueue(t, filepath.Join(t.TempDir(), "queue.db"))
+	for range 3 {
+		if _, err := q.Enqueue(1, "payload", nil); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+	if ahead, err := q.Ahead(2); err != nil || ahead != 1 {
+		t.Fatalf("expected 1 job ahead of user 2, got %d %v", ahead, err)
+	}
+	if _, err := q.Enqueue(2, "payload", nil); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if ahead, err := q.Ahead(1); err != nil || ahead != 4 {
+		t.Fatalf("expected 4 jobs ahead of user 1, got %d %v", ahead, err)
+	}
+
+	var users []int64
+	for range 4 {
+		job, err := q.claim()
+		if err != nil || job == nil {
+			t.Fatalf("claim: %v %v", job, err)
+		}
+		users = append(users, job.UserID)
+	}
+	// User 2 gets a turn before user 1's backlog drains
+	if users[0] != 1 || users[1] != 2 {
+		t.Fatalf("users not served in turn: %v", users)
+	}
+}
+
+func TestQueueRelated(t *testing.T) {
+	q := openTestQueue(t, filepath.Join(t.TempDir(), "queue.db"))
+	batch := map[string]int{"message_id": 7}
+	for _, payload := range []string{"a", "b"} {
+		if _, err := q.Enqueue(1, payload, batch); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+	if _, err := q.Enqueue(1, "c", map[string]int{"message_id": 8}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	jobs := runUntil(t, q, 3, func(ctx context.Context, job *Job) (string, error) {
+		if string(job.Payload) == `"b"` {
+			return "", Permanent(errors.New("bad link"))
+		}
+		return "item-" + string(job.Payload), nil
+	})
+
+	var first *Job
+	for _, job := range jobs {
+		if string(job.Payload) == `"a"` {
+			first = job
+		}
+	}
+	related, err := q.Related(first)
+	if err != nil {
+		t.Fatalf("related: %v", err)
+	}
+	if len(related) != 2 || related[0].ID != first.ID {
+		t.Fatalf("expected the two batch jobs, got %+v", related)
+	}
+	if related[0].Status != StatusDone || related[0].Result != `item-"a"` ||
+		related[1].Status != StatusFailed || related[1].LastError != "bad link" {
+		t.Fatalf("unexpected batch state: %+v", related)
+	}
+}
+
+func TestQueueKeepsSource(t *testing.T) {
+	q := openTestQueue(t, filepath.Join(t.TempDir(), "queue.db"))
+	if _, err := q.EnqueueFrom("inbox", 1, "a", nil); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if _, err := q.Enqueue(1, "b", nil); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	jobs := runUntil(t, q, 2, func(ctx context.Context, job *Job) (string, error) {
+		return "ok", nil
+	})
+	sources := map[string]string{}
+	for _, job := range jobs {
+		sources[string(job.Payload)] = job.Source
+	}
+	if sources[`"a"`] != "inbox" || sources[`"b"`] != "" {
+		t.Fatalf("unexpected sources: %v", sources)
+	}
+}
+
+// testCipher "encrypts" by tagging values with the user ID.
+type testCipher struct{}
+
+func (testCipher) SealString(userID int64, s string) (string, error) {
+	return fmt.Sprintf("sealed:%d:%s", userID, s), nil
+}
+
+func (testCipher) OpenString(userID int64, s string) (string, error) {
+	prefix := fmt.Sprintf("sealed:%d:", userID)
+	if !strings.HasPrefix(s, prefix) {
+		return "", errors.New("wrong key")
+	}
+	return strings.TrimPrefix(s, prefix), nil
+}
+
+func TestQueueEncryptsPayloads(t *testing.T) {
+	opts := testOptions()
+	opts.Cipher = testCipher{}
+	q, err := Open(filepath.Join(t.TempDir(), "queue.db"), opts)
+	if err != nil {
+		t.Fatalf("open queue: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = q.Close()
+	})
+	for _, payload := range []string{"a", "b"} {
+		if _, err := q.Enqueue(1, payload, nil); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+	var stored string
+	if err := q.db.QueryRow(`SELECT payload FROM jobs WHERE id = 1`).Scan(&stored); err != nil {
+		t.Fatalf("read payload: %v", err)
+	}
+	if stored != `sealed:1:"a"` {
+		t.Fatalf("payload stored unsealed: %s", stored)
+	}
+
+	jobs := runUntil(t, q, 2, func(ctx context.Context, job *Job) (string, error) {
+		if string(job.Payload) == `"b"` {
+			return "", Permanent(errors.New("bad payload"))
+		}
+		return "ok", nil
+	})
+	for _, job := range jobs {
+		if job.Status == StatusDone && string(job.Payload) != `"a"` {
+			t.Fatalf("handler saw sealed payload: %s", job.Payload)
+		}
+	}
+	// Finished jobs, failed ones included, keep no payload
+	var left int
+	if err := q.db.QueryRow(`SELECT COUNT(*) FROM jobs WHERE payload != ''`).Scan(&left); err != nil || left != 0 {
+		t.Fatalf("expected payloads to be dropped, %d left: %v", left, err)
+	}
+}
+
+func TestQueueGet(t *testing.T) {
+	q := openTestQueue(t, filepath.Join(t.TempDir(), "queue.db"))
+	job, err := q.EnqueueFrom("api", 1, "a", nil)
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	runUntil(t, q, 1, func(ctx context.Context, job *Job) (string, error) {
+		return "item-1", nil
+	})
+
+	got, err := q.Get(job.ID)
+	if err != nil || got == nil {
+		t.Fatalf("get: %v %v", got, err)
+	}
+	if got.UserID != 1 || got.Source != "api" || got.Status != StatusDone || got.Result != "item-1" {
+		t.Fatalf("unexpected job: %+v", got)
+	}
+	if missing, err := q.Get(job.ID + 1); err != nil || missing != nil {
+		t.Fatalf("expected no job, got %+v %v", missing, err)
+	}
+}
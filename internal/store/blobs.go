@@ -0,0 +1,371 @@
+package store
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "image/gif"
+	_ "image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// MaxBlobSize is the largest file accepted by PutImage and PutFile.
+	MaxBlobSize = 20 << 20
+	// ThumbnailSize is the longest edge of generated thumbnails in pixels.
+	ThumbnailSize = 320
+	// maxScalePixels caps the images ScaleImage decodes. A small file can
+	// declare huge dimensions, and decoding allocates for all of them, so
+	// larger images are stored without a thumbnail.
+	maxScalePixels = 50_000_000
+	// ArchiveMIME is the type of the page snapshots kept for links.
+	ArchiveMIME = "text/html"
+)
+
+// ErrImageTooLarge is returned by ScaleImage for images over the pixel cap.
+var ErrImageTooLarge = errors.New("image too large to scale")
+
+// Blob is a content-addressed file in the user's vault, shared by every item
+// that references the same bytes.
+//
+// Blobs are stored before the item that references them is created. Until
+// then the caller holds the blob: releasing its last reference keeps it, and
+// garbage collection spares it for the grace period. Creating an item with
+// the blob's hash takes over the hold in the same transaction. Holds left by
+// items that were never created expire with the grace period.
+type Blob struct {
+	Hash      string
+	Ext       string
+	MIME      string
+	Size      int64
+	Width     int
+	Height    int
+	HasThumb  bool
+	RefCount  int
+	CreatedAt time.Time
+	// name is the file name in encrypted vaults, see vaultCipher.blobName;
+	// empty means the hash
+	name string
+}
+
+// Path returns the blob file path relative to the user directory.
+func (b *Blob) Path() string {
+	name := b.fileName()
+	return filepath.ToSlash(filepath.Join("blobs", name[:2], name+"."+b.Ext))
+}
+
+// ThumbPath returns the thumbnail path relative to the user directory.
+func (b *Blob) ThumbPath() string {
+	name := b.fileName()
+	return filepath.ToSlash(filepath.Join("blobs", name[:2], name+"_thumb.jpg"))
+}
+
+func (b *Blob) fileName() string {
+	if b.name != "" {
+		return b.name
+	}
+	return b.Hash
+}
+
+// blobIndex is the database side of the blob store, implemented by each
+// backend.
+type blobIndex interface {
+	GetBlob(hash string) (*Blob, error)
+	// holdBlob takes a hold on an existing blob and returns it, or nil if
+	// unknown.
+	holdBlob(hash string) (*Blob, error)
+	// insertBlob adds a new blob with one hold.
+	insertBlob(b *Blob) error
+}
+
+// putImage stores image bytes in the blob store, validating the format,
+// extracting dimensions and generating a thumbnail. Storing the same bytes
+// twice returns the existing blob. The caller holds the blob until an item
+// with a matching ImageHash is created.
+func (f *fileStore) putImage(idx blobIndex, data []byte, ext string) (*Blob, error) {
+	f.blobMu.Lock()
+	defer f.blobMu.Unlock()
+	hash, existing, err := lookupBlob(idx, data)
+	if err != nil {
+		return nil, fmt.Errorf("image: %w", err)
+	}
+	if existing != nil {
+		return existing, nil
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unsupported image format: %w", err)
+	}
+
+	blob := &Blob{
+		Hash:      hash,
+		Ext:       normalizeImageExt(ext, format),
+		MIME:      "image/" + format,
+		Size:      int64(len(data)),
+		Width:     cfg.Width,
+		Height:    cfg.Height,
+		CreatedAt: time.Now(),
+		name:      f.cipher.blobName(hash),
+	}
+
+	if err := f.WriteFile(blob.Path(), data); err != nil {
+		return nil, fmt.Errorf("write blob: %w", err)
+	}
+
+	if thumb, err := ScaleImage(data, ThumbnailSize); errors.Is(err, ErrImageTooLarge) {
+		slog.Info("image too large for a thumbnail", "width", cfg.Width, "height", cfg.Height)
+	} else if err == nil {
+		if err := f.WriteFile(blob.ThumbPath(), thumb); err != nil {
+			return nil, fmt.Errorf("write thumbnail: %w", err)
+		}
+		blob.HasThumb = true
+	}
+
+	if err := idx.insertBlob(blob); err != nil {
+		return nil, fmt.Errorf("insert blob: %w", err)
+	}
+	return blob, nil
+}
+
+// putFile stores other files, such as documents, in the blob store. Unlike
+// putImage the content is not inspected and no thumbnail is made.
+func (f *fileStore) putFile(idx blobIndex, data []byte, ext, mimeType string) (*Blob, error) {
+	f.blobMu.Lock()
+	defer f.blobMu.Unlock()
+	hash, existing, err := lookupBlob(idx, data)
+	if err != nil {
+		return nil, fmt.Errorf("file: %w", err)
+	}
+	if existing != nil {
+		return existing, nil
+	}
+
+	blob := &Blob{
+		Hash:      hash,
+		Ext:       normalizeFileExt(ext),
+		MIME:      mimeType,
+		Size:      int64(len(data)),
+		CreatedAt: time.Now(),
+		name:      f.cipher.blobName(hash),
+	}
+	if blob.MIME == "" {
+		blob.MIME = http.DetectContentType(data)
+	}
+
+	if err := f.WriteFile(blob.Path(), data); err != nil {
+		return nil, fmt.Errorf("write blob: %w", err)
+	}
+	if err := idx.insertBlob(blob); err != nil {
+		return nil, fmt.Errorf("insert blob: %w", err)
+	}
+	return blob, nil
+}
+
+// lookupBlob checks the size of data and returns its content hash, plus the
+// blob already holding the same bytes, if any, with a hold taken on it.
+func lookupBlob(idx blobIndex, data []byte) (string, *Blob, error) {
+	if len(data) == 0 {
+		return "", nil, fmt.Errorf("empty")
+	}
+	if len(data) > MaxBlobSize {
+		return "", nil, fmt.Errorf("too large: %d bytes (max %d)", len(data), MaxBlobSize)
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	existing, err := idx.holdBlob(hash)
+	if err != nil {
+		return "", nil, err
+	}
+	return hash, existing, nil
+}
+
+// removeBlobFiles deletes a released blob and its thumbnail from disk, unless
+// the same bytes were stored again since its row was deleted.
+func (f *fileStore) removeBlobFiles(idx blobIndex, b *Blob) {
+	f.blobMu.Lock()
+	defer f.blobMu.Unlock()
+	if again, err := idx.GetBlob(b.Hash); err != nil || again != nil {
+		return
+	}
+	for _, p := range []string{b.Path(), b.ThumbPath()} {
+		_ = os.Remove(filepath.Join(f.dir, p))
+	}
+}
+
+// PutImage stores image bytes in the blob store; see putImage.
+func (v *VaultStore) PutImage(data []byte, ext string) (*Blob, error) {
+	return v.putImage(v, data, ext)
+}
+
+// PutFile stores non-image bytes in the blob store; see putFile.
+func (v *VaultStore) PutFile(data []byte, ext, mimeType string) (*Blob, error) {
+	return v.putFile(v, data, ext, mimeType)
+}
+
+func (v *VaultStore) insertBlob(blob *Blob) error {
+	_, err := v.db.Exec(`
+		INSERT INTO blobs (hash, name, ext, mime, size, width, height, has_thumb, refcount, holds, held_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, 0, 1, ?, ?)
+		ON CONFLICT(hash) DO UPDATE SET holds = holds + 1, held_at = excluded.held_at`,
+		blob.Hash, blob.name, blob.Ext, blob.MIME, blob.Size, blob.Width, blob.Height, blob.HasThumb, blob.CreatedAt, blob.CreatedAt)
+	return err
+}
+
+func (v *VaultStore) holdBlob(hash string) (*Blob, error) {
+	b := &Blob{}
+	err := v.db.QueryRow(`
+		UPDATE blobs SET holds = holds + 1, held_at = ? WHERE hash = ?
+		RETURNING hash, name, ext, mime, size, width, height, has_thumb, refcount, created_at`, time.Now(), hash,
+	).Scan(&b.Hash, &b.name, &b.Ext, &b.MIME, &b.Size, &b.Width, &b.Height, &b.HasThumb, &b.RefCount, &b.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("hold blob: %w", err)
+	}
+	return b, nil
+}
+
+// GetBlob returns blob metadata by hash, or nil if unknown.
+func (v *VaultStore) GetBlob(hash string) (*Blob, error) {
+	b := &Blob{}
+	err := v.db.QueryRow(`
+		SELECT hash, name, ext, mime, size, width, height, has_thumb, refcount, created_at
+		FROM blobs WHERE hash = ?`, hash,
+	).Scan(&b.Hash, &b.name, &b.Ext, &b.MIME, &b.Size, &b.Width, &b.Height, &b.HasThumb, &b.RefCount, &b.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query blob: %w", err)
+	}
+	return b, nil
+}
+
+// retainBlob increments the reference count of a blob inside an item write,
+// taking over a hold from PutImage or PutFile.
+func retainBlob(tx *sql.Tx, hash string) error {
+	if hash == "" {
+		return nil
+	}
+	_, err := tx.Exec(`UPDATE blobs SET refcount = refcount + 1, holds = MAX(holds - 1, 0) WHERE hash = ?`, hash)
+	return err
+}
+
+// releaseBlob decrements the reference count of a blob and deletes its row
+// once unreferenced and unheld. It returns the blob when its files should be
+// removed.
+func releaseBlob(tx *sql.Tx, hash string) (*Blob, error) {
+	if hash == "" {
+		return nil, nil
+	}
+	b := &Blob{Hash: hash}
+	var holds int
+	err := tx.QueryRow(`
+		UPDATE blobs SET refcount = refcount - 1 WHERE hash = ?
+		RETURNING name, ext, refcount, holds`, hash).Scan(&b.name, &b.Ext, &b.RefCount, &holds)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if b.RefCount > 0 || holds > 0 {
+		return nil, nil
+	}
+	if _, err := tx.Exec(`DELETE FROM blobs WHERE hash = ?`, hash); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// ScaleImage scales an image so its longest edge is at most maxEdge and
+// encodes it as JPEG, for thumbnails and other previews. Transparent areas
+// are flattened onto white.
+func ScaleImage(data []byte, maxEdge int) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > maxScalePixels {
+		return nil, fmt.Errorf("%dx%d: %w", cfg.Width, cfg.Height, ErrImageTooLarge)
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w == 0 || h == 0 {
+		return nil, fmt.Errorf("empty image")
+	}
+	if w > maxEdge || h > maxEdge {
+		if w >= h {
+			h = max(1, h*maxEdge/w)
+			w = maxEdge
+		} else {
+			w = max(1, w*maxEdge/h)
+			h = maxEdge
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// normalizeImageExt prefers the detected format over the caller's extension,
+// which comes from an untrusted filename.
+func normalizeImageExt(ext, format string) string {
+	switch format {
+	case "jpeg":
+		return "jpg"
+	case "png", "gif", "webp":
+		return format
+	}
+	ext = strings.ToLower(strings.TrimPrefix(ext, "."))
+	if ext == "" {
+		return "bin"
+	}
+	return ext
+}
+
+// normalizeFileExt keeps a short alphanumeric extension from an untrusted
+// filename, falling back to "bin".
+func normalizeFileExt(ext string) string {
+	ext = strings.ToLower(strings.TrimPrefix(ext, "."))
+	if ext == "" || len(ext) > 8 {
+		return "bin"
+	}
+	for _, r := range ext {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return "bin"
+		}
+	}
+	return ext
+}
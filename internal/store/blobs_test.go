@@ -0,0 +1,269 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPutImageDeduplicatesAndRefCounts(t *testing.T) {
+	manager, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create manager: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = manager.Close()
+	})
+	vault, err := manager.GetVault(1)
+	if err != nil {
+		t.Fatalf("get vault: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1000, 500))); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	data := buf.Bytes()
+
+	blob, err := vault.PutImage(data, "jpg")
+	if err != nil {
+		t.Fatalf("put image: %v", err)
+	}
+	if blob.Width != 1000 || blob.Height != 500 || blob.Ext != "png" || !blob.HasThumb {
+		t.Fatalf("unexpected blob metadata: %+v", blob)
+	}
+
+	again, err := vault.PutImage(data, "png")
+	if err != nil {
+		t.Fatalf("put image again: %v", err)
+	}
+	if again.Hash != blob.Hash {
+		t.Fatalf("expected identical hash, got %s and %s", blob.Hash, again.Hash)
+	}
+
+	thumb, err := vault.ReadFile(blob.ThumbPath())
+	if err != nil {
+		t.Fatalf("read thumbnail: %v", err)
+	}
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(thumb))
+	if err != nil {
+		t.Fatalf("decode thumbnail: %v", err)
+	}
+	if cfg.Width != ThumbnailSize || cfg.Height != ThumbnailSize/2 {
+		t.Fatalf("unexpected thumbnail size %dx%d", cfg.Width, cfg.Height)
+	}
+
+	first := &Item{Type: ItemTypeImage, Title: "One", ImagePath: blob.Path(), ImageHash: blob.Hash}
+	second := &Item{Type: ItemTypeImage, Title: "Two", ImagePath: blob.Path(), ImageHash: blob.Hash}
+	for _, item := range []*Item{first, second} {
+		if err := vault.CreateItem(item); err != nil {
+			t.Fatalf("create item: %v", err)
+		}
+	}
+
+	got, err := vault.GetItem(first.ID)
+	if err != nil {
+		t.Fatalf("get item: %v", err)
+	}
+	if got.Image == nil || got.Image.Width != 1000 {
+		t.Fatalf("expected image info on item, got %+v", got.Image)
+	}
+
+	blobFile := filepath.Join(vault.Dir(), blob.Path())
+	if err := vault.DeleteItem(first.ID); err != nil {
+		t.Fatalf("delete first: %v", err)
+	}
+	if _, err := os.Stat(blobFile); err != nil {
+		t.Fatalf("blob removed while still referenced: %v", err)
+	}
+
+	if err := vault.DeleteItem(second.ID); err != nil {
+		t.Fatalf("delete second: %v", err)
+	}
+	if _, err := os.Stat(blobFile); !os.IsNotExist(err) {
+		t.Fatalf("expected blob file to be removed, got %v", err)
+	}
+	if b, _ := vault.GetBlob(blob.Hash); b != nil {
+		t.Fatalf("expected blob row to be removed")
+	}
+}
+
+func TestPutFileDocument(t *testing.T) {
+	manager, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create manager: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = manager.Close()
+	})
+	vault, err := manager.GetVault(1)
+	if err != nil {
+		t.Fatalf("get vault: %v", err)
+	}
+
+	blob, err := vault.PutFile([]byte("%PDF-1.4 fake"), "../PDF", "")
+	if err != nil {
+		t.Fatalf("put file: %v", err)
+	}
+	if blob.Ext != "bin" || blob.MIME != "application/pdf" || blob.HasThumb {
+		t.Fatalf("unexpected blob metadata: %+v", blob)
+	}
+
+	item := &Item{Type: ItemTypeDocument, Title: "Paper", ImagePath: blob.Path(), ImageHash: blob.Hash}
+	if err := vault.CreateItem(item); err != nil {
+		t.Fatalf("create item: %v", err)
+	}
+	got, err := vault.GetItem(item.ID)
+	if err != nil {
+		t.Fatalf("get item: %v", err)
+	}
+	if got.Image != nil || got.File == nil || got.File.MIME != "application/pdf" {
+		t.Fatalf("expected file info only: %+v", got)
+	}
+}
+
+func TestArchiveBlobLifecycle(t *testing.T) {
+	manager, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create manager: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = manager.Close()
+	})
+	vault, err := manager.GetVault(1)
+	if err != nil {
+		t.Fatalf("get vault: %v", err)
+	}
+
+	snapshot := []byte("<html><body>Saved page</body></html>")
+	blob, err := vault.PutFile(snapshot, "html", ArchiveMIME)
+	if err != nil {
+		t.Fatalf("put archive: %v", err)
+	}
+	item := &Item{Type: ItemTypeLink, Title: "Page", URL: "https://example.com", ArchiveHash: blob.Hash}
+	if err := vault.CreateItem(item); err != nil {
+		t.Fatalf("create item: %v", err)
+	}
+
+	got, err := vault.GetItem(item.ID)
+	if err != nil {
+		t.Fatalf("get item: %v", err)
+	}
+	if got.ArchiveHash != blob.Hash || got.Archive == nil || got.Archive.Size != int64(len(snapshot)) {
+		t.Fatalf("unexpected archive info: %q %+v", got.ArchiveHash, got.Archive)
+	}
+	if got.Image != nil || got.File != nil {
+		t.Fatalf("archive reported as attachment: %+v %+v", got.Image, got.File)
+	}
+
+	// GC keeps referenced snapshots
+	report, err := vault.CollectGarbage(GCOptions{})
+	if err != nil {
+		t.Fatalf("gc: %v", err)
+	}
+	if len(report.Orphans) != 0 || report.BlobRows != 0 {
+		t.Fatalf("referenced archive collected: %+v", report)
+	}
+
+	if err := vault.DeleteItem(item.ID); err != nil {
+		t.Fatalf("delete item: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(vault.Dir(), blob.Path())); !os.IsNotExist(err) {
+		t.Fatalf("archive file kept after delete: %v", err)
+	}
+	if b, _ := vault.GetBlob(blob.Hash); b != nil {
+		t.Fatalf("archive blob row kept after delete")
+	}
+}
+
+func TestPutImageSkipsThumbnailForHugeImages(t *testing.T) {
+	manager, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create manager: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = manager.Close()
+	})
+	vault, err := manager.GetVault(1)
+	if err != nil {
+		t.Fatalf("get vault: %v", err)
+	}
+
+	// A tiny PNG whose header claims 100000x100000 pixels
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	data := buf.Bytes()
+	binary.BigEndian.PutUint32(data[16:], 100000)
+	binary.BigEndian.PutUint32(data[20:], 100000)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+
+	blob, err := vault.PutImage(data, "png")
+	if err != nil {
+		t.Fatalf("put image: %v", err)
+	}
+	if blob.Width != 100000 || blob.HasThumb {
+		t.Fatalf("expected a blob without thumbnail: %+v", blob)
+	}
+}
+
+func TestPutImageHoldsBlobUntilItemCreated(t *testing.T) {
+	manager, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create manager: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = manager.Close()
+	})
+	vault, err := manager.GetVault(1)
+	if err != nil {
+		t.Fatalf("get vault: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 10, 10))); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	blob, err := vault.PutImage(buf.Bytes(), "png")
+	if err != nil {
+		t.Fatalf("put image: %v", err)
+	}
+	first := &Item{Type: ItemTypeImage, Title: "One", ImagePath: blob.Path(), ImageHash: blob.Hash}
+	if err := vault.CreateItem(first); err != nil {
+		t.Fatalf("create item: %v", err)
+	}
+
+	// The same bytes arrive again while the only item using them is deleted
+	if _, err := vault.PutImage(buf.Bytes(), "png"); err != nil {
+		t.Fatalf("put image again: %v", err)
+	}
+	if err := vault.DeleteItem(first.ID); err != nil {
+		t.Fatalf("delete item: %v", err)
+	}
+	blobFile := filepath.Join(vault.Dir(), blob.Path())
+	if _, err := os.Stat(blobFile); err != nil {
+		t.Fatalf("held blob removed: %v", err)
+	}
+	if report, err := vault.CollectGarbage(GCOptions{GracePeriod: time.Hour}); err != nil || report.BlobRows != 0 {
+		t.Fatalf("held blob collected: %+v %v", report, err)
+	}
+
+	second := &Item{Type: ItemTypeImage, Title: "Two", ImagePath: blob.Path(), ImageHash: blob.Hash}
+	if err := vault.CreateItem(second); err != nil {
+		t.Fatalf("create item: %v", err)
+	}
+	if err := vault.DeleteItem(second.ID); err != nil {
+		t.Fatalf("delete item: %v", err)
+	}
+	if _, err := os.Stat(blobFile); !os.IsNotExist(err) {
+		t.Fatalf("expected blob file to be removed once released, got %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+)
+
+// changeSource loads the current state of changed entities.
+type changeSource interface {
+	GetItem(id string) (*Item, error)
+	getRelationship(id int64) (*Relationship, error)
+}
+
+// Changes returns the latest change to each entity modified after seq since,
+// oldest first, at most limit entries. Clients resume from the Seq of the
+// last entry.
+func (v *VaultStore) Changes(since int64, limit int) ([]Change, error) {
+	rows, err := v.db.Query(`
+		SELECT c.seq, c.entity, c.entity_id, c.op FROM changes c
+		JOIN (
+			SELECT MAX(seq) AS seq FROM changes WHERE seq > ? GROUP BY entity, entity_id
+		) latest ON latest.seq = c.seq
+		ORDER BY c.seq LIMIT ?`, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query changes: %w", err)
+	}
+	changes, err := scanChanges(rows)
+	if err != nil {
+		return nil, err
+	}
+	return changes, loadChanges(v, changes)
+}
+
+func (v *VaultStore) getRelationship(id int64) (*Relationship, error) {
+	r := &Relationship{}
+	err := v.db.QueryRow(`
+		SELECT id, source_id, target_id, relation_type, strength
+		FROM relationships WHERE id = ?`, id,
+	).Scan(&r.ID, &r.SourceID, &r.TargetID, &r.RelationType, &r.Strength)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query relationship: %w", err)
+	}
+	return r, nil
+}
+
+func scanChanges(rows *sql.Rows) ([]Change, error) {
+	defer rows.Close()
+
+	var changes []Change
+	for rows.Next() {
+		var c Change
+		if err := rows.Scan(&c.Seq, &c.Entity, &c.EntityID, &c.Op); err != nil {
+			return nil, fmt.Errorf("scan change: %w", err)
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// loadChanges attaches current state to upserts. An upsert whose entity no
+// longer exists is reported as a delete: the log can record an upsert after
+// the delete, e.g. for tag rows cascading from a deleted item.
+func loadChanges(src changeSource, changes []Change) error {
+	for i := range changes {
+		c := &changes[i]
+		if c.Op != ChangeOpUpsert {
+			continue
+		}
+		switch c.Entity {
+		case ChangeEntityItem:
+			item, err := src.GetItem(c.EntityID)
+			if err != nil {
+				return err
+			}
+			c.Item = item
+			if item == nil {
+				c.Op = ChangeOpDelete
+			}
+		case ChangeEntityRelationship:
+			id, err := strconv.ParseInt(c.EntityID, 10, 64)
+			if err != nil {
+				return fmt.Errorf("parse relationship id %q: %w", c.EntityID, err)
+			}
+			rel, err := src.getRelationship(id)
+			if err != nil {
+				return err
+			}
+			c.Relationship = rel
+			if rel == nil {
+				c.Op = ChangeOpDelete
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package store
+
+import "testing"
+
+func TestChanges(t *testing.T) {
+	manager, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create manager: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = manager.Close()
+	})
+	vault, err := manager.GetVault(1)
+	if err != nil {
+		t.Fatalf("get vault: %v", err)
+	}
+
+	kept := &Item{Type: ItemTypeNote, Title: "Kept", Tags: []string{"go"}}
+	deleted := &Item{Type: ItemTypeNote, Title: "Deleted"}
+	for _, item := range []*Item{kept, deleted} {
+		if err := vault.CreateItem(item); err != nil {
+			t.Fatalf("create item: %v", err)
+		}
+	}
+	if err := vault.CreateRelationship(&Relationship{SourceID: kept.ID, TargetID: deleted.ID, RelationType: "link", Strength: 1}); err != nil {
+		t.Fatalf("create relationship: %v", err)
+	}
+	if err := vault.DeleteItem(deleted.ID); err != nil {
+		t.Fatalf("delete item: %v", err)
+	}
+
+	changes, err := vault.Changes(0, 100)
+	if err != nil {
+		t.Fatalf("changes: %v", err)
+	}
+	got := make(map[string]Change)
+	for i, c := range changes {
+		if i > 0 && c.Seq <= changes[i-1].Seq {
+			t.Fatalf("changes out of order: %+v", changes)
+		}
+		got[c.Entity+":"+c.EntityID] = c
+	}
+	if len(got) != len(changes) {
+		t.Fatalf("changes not collapsed per entity: %+v", changes)
+	}
+	if c := got["item:"+kept.ID]; c.Op != ChangeOpUpsert || c.Item == nil || len(c.Item.Tags) != 1 {
+		t.Fatalf("unexpected kept item change: %+v", c)
+	}
+	if c := got["item:"+deleted.ID]; c.Op != ChangeOpDelete || c.Item != nil {
+		t.Fatalf("unexpected deleted item change: %+v", c)
+	}
+	if c := got["tag:go"]; c.Op != ChangeOpUpsert {
+		t.Fatalf("missing tag change: %+v", changes)
+	}
+	if len(changes) != 4 {
+		t.Fatalf("expected 4 changes (2 items, tag, relationship), got %+v", changes)
+	}
+	for _, c := range changes {
+		if c.Entity == ChangeEntityRelationship && c.Op != ChangeOpDelete {
+			t.Fatalf("relationship should be deleted with its item: %+v", c)
+		}
+	}
+
+	last := changes[len(changes)-1].Seq
+	if more, err := vault.Changes(last, 100); err != nil || len(more) != 0 {
+		t.Fatalf("expected no changes after %d: %+v %v", last, more, err)
+	}
+
+	if err := vault.SetSetting("language", "en"); err != nil {
+		t.Fatalf("set setting: %v", err)
+	}
+	if err := vault.CreateItem(&Item{Type: ItemTypeNote, Title: "New"}); err != nil {
+		t.Fatalf("create item: %v", err)
+	}
+	more, err := vault.Changes(last, 100)
+	if err != nil || len(more) != 1 || more[0].Item == nil || more[0].Item.Title != "New" {
+		t.Fatalf("unexpected incremental changes: %+v %v", more, err)
+	}
+}
@@ -0,0 +1,165 @@
+package store
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// sealedPrefix marks an encrypted text column value. Values without the
+// prefix are plaintext, which lets encrypted and legacy rows coexist until
+// the vault is migrated.
+const sealedPrefix = "enc:v1:"
+
+// sealedFileMagic is written at the start of every encrypted file.
+var sealedFileMagic = []byte("DUMPERENC1")
+
+// ErrNoEncryptionKey is returned when a vault contains encrypted data but the
+// server was started without a master key.
+var ErrNoEncryptionKey = errors.New("vault data is encrypted but no encryption key is configured")
+
+// SearchIndexMode controls how encrypted content is made searchable.
+type SearchIndexMode string
+
+const (
+	// SearchIndexTokens keeps a contentless FTS index for encrypted content.
+	// Search keeps working, but the token vocabulary of each item is stored
+	// unencrypted (the original text is not).
+	SearchIndexTokens SearchIndexMode = "index"
+	// SearchIndexOff stores no index for encrypted content. Only titles,
+	// which are never encrypted, remain searchable.
+	SearchIndexOff SearchIndexMode = "off"
+)
+
+// vaultCipher encrypts vault content with a per-user key.
+type vaultCipher struct {
+	aead    cipher.AEAD
+	nameKey []byte // keys blob file names, see blobName
+}
+
+// newVaultCipher derives a user key from the server master key using HKDF,
+// so a leaked vault key never exposes other users' vaults.
+func newVaultCipher(masterKey []byte, userID int64) (*vaultCipher, error) {
+	key, err := hkdf.Key(sha256.New, masterKey, nil, fmt.Sprintf("dumper-vault:%d", userID), 32)
+	if err != nil {
+		return nil, fmt.Errorf("derive key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("create gcm: %w", err)
+	}
+	nameKey, err := hkdf.Key(sha256.New, masterKey, nil, fmt.Sprintf("dumper-blob-names:%d", userID), 32)
+	if err != nil {
+		return nil, fmt.Errorf("derive name key: %w", err)
+	}
+	return &vaultCipher{aead: aead, nameKey: nameKey}, nil
+}
+
+// blobName returns the file name of a blob in an encrypted vault: a keyed
+// hash of its content hash, so the directory listing does not reveal whether
+// a known file is stored. Without encryption it returns "", meaning the
+// content hash is the name.
+func (c *vaultCipher) blobName(hash string) string {
+	if c == nil {
+		return ""
+	}
+	mac := hmac.New(sha256.New, c.nameKey)
+	mac.Write([]byte(hash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (c *vaultCipher) seal(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+	return c.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (c *vaultCipher) open(sealed []byte) ([]byte, error) {
+	if len(sealed) < c.aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	return c.aead.Open(nil, nonce, ciphertext, nil)
+}
+
+// sealString encrypts a text column value. Empty values stay empty so that
+// "no summary" is still distinguishable without a key.
+func (c *vaultCipher) sealString(s string) (string, error) {
+	if c == nil || s == "" || isSealed(s) {
+		return s, nil
+	}
+	sealed, err := c.seal([]byte(s))
+	if err != nil {
+		return "", err
+	}
+	return sealedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openString decrypts a text column value; plaintext values pass through.
+func (c *vaultCipher) openString(s string) (string, error) {
+	if !isSealed(s) {
+		return s, nil
+	}
+	if c == nil {
+		return "", ErrNoEncryptionKey
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, sealedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("decode sealed value: %w", err)
+	}
+	plaintext, err := c.open(sealed)
+	if err != nil {
+		return "", fmt.Errorf("decrypt value: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// sealFile encrypts file contents; a nil cipher writes plaintext.
+func (c *vaultCipher) sealFile(data []byte) ([]byte, error) {
+	if c == nil || isSealedFile(data) {
+		return data, nil
+	}
+	sealed, err := c.seal(data)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, sealedFileMagic...), sealed...), nil
+}
+
+// openFile decrypts file contents; plaintext files pass through.
+func (c *vaultCipher) openFile(data []byte) ([]byte, error) {
+	if !isSealedFile(data) {
+		return data, nil
+	}
+	if c == nil {
+		return nil, ErrNoEncryptionKey
+	}
+	plaintext, err := c.open(data[len(sealedFileMagic):])
+	if err != nil {
+		return nil, fmt.Errorf("decrypt file: %w", err)
+	}
+	return plaintext, nil
+}
+
+func isSealed(s string) bool {
+	return strings.HasPrefix(s, sealedPrefix)
+}
+
+func isSealedFile(data []byte) bool {
+	return bytes.HasPrefix(data, sealedFileMagic)
+}
@@ -0,0 +1,227 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// EncryptStats reports what EncryptExisting changed.
+type EncryptStats struct {
+	Items int `json:"items"`
+	Files int `json:"files"`
+	Blobs int `json:"blobs"` // blobs renamed to keyed file names
+}
+
+// EncryptExisting encrypts plaintext items, images and page snapshots left
+// over from before encryption was enabled. It is idempotent: already encrypted values
+// are skipped, so it can be rerun after an interruption.
+func (v *VaultStore) EncryptExisting() (EncryptStats, error) {
+	var stats EncryptStats
+	if v.cipher == nil {
+		return stats, ErrNoEncryptionKey
+	}
+
+	// Rename blobs first so items are read with their new paths
+	blobs, err := v.nameBlobs()
+	stats.Blobs = blobs
+	if err != nil {
+		return stats, err
+	}
+
+	type row struct {
+		rowID                                 int64
+		id                                    string
+		content, summary, rawContent, imgPath sql.NullString
+		archiveHash, archiveName, archiveExt  sql.NullString
+	}
+
+	rows, err := v.db.Query(`
+		SELECT i.rowid, i.id, i.content, i.summary, i.raw_content, i.image_path, ab.hash, ab.name, ab.ext
+		FROM items i LEFT JOIN blobs ab ON ab.hash = i.archive_hash`)
+	if err != nil {
+		return stats, fmt.Errorf("query items: %w", err)
+	}
+	var pending []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.rowID, &r.id, &r.content, &r.summary, &r.rawContent, &r.imgPath,
+			&r.archiveHash, &r.archiveName, &r.archiveExt); err != nil {
+			rows.Close()
+			return stats, fmt.Errorf("scan item: %w", err)
+		}
+		pending = append(pending, r)
+	}
+	rows.Close()
+
+	for _, r := range pending {
+		files := []string{r.imgPath.String}
+		if r.archiveHash.Valid {
+			files = append(files, (&Blob{Hash: r.archiveHash.String, Ext: r.archiveExt.String, name: r.archiveName.String}).Path())
+		}
+		for _, p := range files {
+			if p == "" {
+				continue
+			}
+			sealed, err := v.encryptFile(p)
+			if err != nil {
+				slog.Warn("failed to encrypt file", "item_id", r.id, "path", p, "error", err)
+			} else if sealed {
+				stats.Files++
+			}
+		}
+
+		if !needsSealing(r.content.String) && !needsSealing(r.summary.String) && !needsSealing(r.rawContent.String) {
+			continue
+		}
+
+		plain := &Item{}
+		cols := itemColumns{content: r.content, summary: r.summary}
+		if err := v.fillItem(plain, &cols); err != nil {
+			return stats, err
+		}
+		if plain.RawContent, err = v.cipher.openString(r.rawContent.String); err != nil {
+			return stats, fmt.Errorf("open raw content of %s: %w", r.id, err)
+		}
+		content, summary, rawContent, err := v.sealItem(plain)
+		if err != nil {
+			return stats, fmt.Errorf("encrypt item %s: %w", r.id, err)
+		}
+
+		tx, err := v.db.Begin()
+		if err != nil {
+			return stats, fmt.Errorf("begin tx: %w", err)
+		}
+		if _, err := tx.Exec(`UPDATE items SET content = ?, summary = ?, raw_content = ? WHERE id = ?`,
+			content, summary, rawContent, r.id); err != nil {
+			tx.Rollback()
+			return stats, fmt.Errorf("update item %s: %w", r.id, err)
+		}
+		if err := v.indexSealed(tx, r.rowID, plain.Content, plain.Summary); err != nil {
+			tx.Rollback()
+			return stats, fmt.Errorf("index item %s: %w", r.id, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return stats, fmt.Errorf("commit item %s: %w", r.id, err)
+		}
+		stats.Items++
+	}
+
+	// Thumbnails are not referenced by items, so walk the blob table too
+	thumbs, err := v.blobThumbPaths()
+	if err != nil {
+		return stats, err
+	}
+	for _, p := range thumbs {
+		sealed, err := v.encryptFile(p)
+		if err != nil {
+			slog.Warn("failed to encrypt thumbnail", "path", p, "error", err)
+		} else if sealed {
+			stats.Files++
+		}
+	}
+
+	return stats, nil
+}
+
+// nameBlobs moves blobs stored before encryption was enabled to keyed file
+// names, see vaultCipher.blobName, and points their items at the new paths.
+func (v *VaultStore) nameBlobs() (int, error) {
+	rows, err := v.db.Query(`SELECT hash, ext FROM blobs WHERE name = ''`)
+	if err != nil {
+		return 0, fmt.Errorf("query blobs: %w", err)
+	}
+	var pending []Blob
+	for rows.Next() {
+		var b Blob
+		if err := rows.Scan(&b.Hash, &b.Ext); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan blob: %w", err)
+		}
+		pending = append(pending, b)
+	}
+	rows.Close()
+
+	v.blobMu.Lock()
+	defer v.blobMu.Unlock()
+	for i, b := range pending {
+		renamed := b
+		renamed.name = v.cipher.blobName(b.Hash)
+		if err := v.moveBlobFiles(&b, &renamed); err != nil {
+			return i, err
+		}
+		tx, err := v.db.Begin()
+		if err != nil {
+			return i, fmt.Errorf("begin tx: %w", err)
+		}
+		if _, err := tx.Exec(`UPDATE blobs SET name = ? WHERE hash = ?`, renamed.name, b.Hash); err != nil {
+			tx.Rollback()
+			return i, fmt.Errorf("rename blob %s: %w", b.Hash, err)
+		}
+		if _, err := tx.Exec(`UPDATE items SET image_path = ? WHERE image_hash = ?`, renamed.Path(), b.Hash); err != nil {
+			tx.Rollback()
+			return i, fmt.Errorf("update paths of blob %s: %w", b.Hash, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return i, fmt.Errorf("commit blob %s: %w", b.Hash, err)
+		}
+	}
+	return len(pending), nil
+}
+
+func (v *VaultStore) blobThumbPaths() ([]string, error) {
+	rows, err := v.db.Query(`SELECT hash, name, ext FROM blobs WHERE has_thumb = 1`)
+	if err != nil {
+		return nil, fmt.Errorf("query blobs: %w", err)
+	}
+	defer rows.Close()
+
+	var paths []string
+	for rows.Next() {
+		var b Blob
+		if err := rows.Scan(&b.Hash, &b.name, &b.Ext); err != nil {
+			return nil, fmt.Errorf("scan blob: %w", err)
+		}
+		paths = append(paths, b.ThumbPath())
+	}
+	return paths, rows.Err()
+}
+
+// moveBlobFiles renames a blob's file and thumbnail from b's paths to
+// renamed's. Missing files are skipped: thumbnails are optional, and an
+// interrupted run may have moved them already.
+func (f *fileStore) moveBlobFiles(b, renamed *Blob) error {
+	moves := [][2]string{{b.Path(), renamed.Path()}, {b.ThumbPath(), renamed.ThumbPath()}}
+	for _, m := range moves {
+		from, to := filepath.Join(f.dir, m[0]), filepath.Join(f.dir, m[1])
+		if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+			return fmt.Errorf("create dir: %w", err)
+		}
+		if err := os.Rename(from, to); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("rename %s: %w", m[0], err)
+		}
+	}
+	return nil
+}
+
+// encryptFile seals a plaintext file in place. Returns false if the file was
+// already encrypted or is missing.
+func (f *fileStore) encryptFile(relPath string) (bool, error) {
+	data, err := os.ReadFile(filepath.Join(f.dir, relPath))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if isSealedFile(data) {
+		return false, nil
+	}
+	return true, f.WriteFile(relPath, data)
+}
+
+func needsSealing(s string) bool {
+	return s != "" && !isSealed(s)
+}
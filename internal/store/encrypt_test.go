@@ -0,0 +1,227 @@
+package store
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncryptedVaultRoundTrip(t *testing.T) {
+	manager, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create manager: %v", err)
+	}
+	if err := manager.EnableEncryption("test-master-key", SearchIndexTokens); err != nil {
+		t.Fatalf("enable encryption: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = manager.Close()
+	})
+
+	vault, err := manager.vaultStore(1)
+	if err != nil {
+		t.Fatalf("get vault: %v", err)
+	}
+
+	item := &Item{
+		Type:       ItemTypeNote,
+		Title:      "Secret plans",
+		Content:    "the password is swordfish",
+		Summary:    "a note about fish",
+		RawContent: "raw swordfish",
+		Tags:       []string{"private"},
+		Source:     &Source{Name: "Fish News", URL: "https://t.me/fishnews/42", MessageID: 42},
+		Structured: &StructuredData{Type: SchemaRecipe, Ingredients: []string{"1 swordfish"}},
+		// Metadata rather than content, so stored as is
+		ContentKind: "video transcript",
+	}
+	if err := vault.CreateItem(item); err != nil {
+		t.Fatalf("create item: %v", err)
+	}
+
+	var storedContent string
+	if err := vault.db.QueryRow(`SELECT content FROM items WHERE id = ?`, item.ID).Scan(&storedContent); err != nil {
+		t.Fatalf("query stored content: %v", err)
+	}
+	if !isSealed(storedContent) {
+		t.Fatalf("expected stored content to be encrypted, got %q", storedContent)
+	}
+
+	got, err := vault.GetItem(item.ID)
+	if err != nil {
+		t.Fatalf("get item: %v", err)
+	}
+	if got.Content != item.Content || got.Summary != item.Summary {
+		t.Fatalf("decrypted item mismatch: got %q / %q", got.Content, got.Summary)
+	}
+	if got.Source == nil || *got.Source != *item.Source {
+		t.Fatalf("source mismatch: got %+v", got.Source)
+	}
+	if got.Structured == nil || got.Structured.Type != SchemaRecipe || len(got.Structured.Ingredients) != 1 {
+		t.Fatalf("structured data mismatch: got %+v", got.Structured)
+	}
+	if got.ContentKind != item.ContentKind {
+		t.Fatalf("content kind mismatch: got %q", got.ContentKind)
+	}
+	var storedSource string
+	if err := vault.db.QueryRow(`SELECT source FROM items WHERE id = ?`, item.ID).Scan(&storedSource); err != nil || !isSealed(storedSource) {
+		t.Fatalf("expected stored source to be encrypted, got %q %v", storedSource, err)
+	}
+
+	results, err := vault.Search("swordfish", 10)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(results) != 1 || results[0].Item.ID != item.ID {
+		t.Fatalf("expected encrypted item in search results, got %d results", len(results))
+	}
+
+	if raw, err := vault.GetRawContent(item.ID); err != nil || raw != item.RawContent {
+		t.Fatalf("raw content: %q %v", raw, err)
+	}
+	item.Summary = "a note about marlin"
+	item.Tags = []string{"private", "fish"}
+	if err := vault.UpdateItem(item); err != nil {
+		t.Fatalf("update item: %v", err)
+	}
+	if results, err := vault.Search("marlin", 10); err != nil || len(results) != 1 {
+		t.Fatalf("expected updated summary in search results: %v %v", results, err)
+	}
+	if got, err := vault.GetItem(item.ID); err != nil || got.Summary != item.Summary || len(got.Tags) != 2 {
+		t.Fatalf("updated item mismatch: %+v %v", got, err)
+	}
+	// Raw content survives updates that leave it unset
+	rawContent := item.RawContent
+	item.RawContent = ""
+	if err := vault.UpdateItem(item); err != nil {
+		t.Fatalf("update item: %v", err)
+	}
+	if raw, err := vault.GetRawContent(item.ID); err != nil || raw != rawContent {
+		t.Fatalf("raw content after update: %q %v", raw, err)
+	}
+
+	if err := vault.WriteFile("images/a.png", []byte("png bytes")); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	data, err := vault.ReadFile("images/a.png")
+	if err != nil {
+		t.Fatalf("read file: %v", err)
+	}
+	if string(data) != "png bytes" {
+		t.Fatalf("file round trip mismatch: got %q", data)
+	}
+
+	if err := vault.DeleteItem(item.ID); err != nil {
+		t.Fatalf("delete item: %v", err)
+	}
+	results, err = vault.Search("swordfish", 10)
+	if err != nil {
+		t.Fatalf("search after delete: %v", err)
+	}
+	if len(results) != 0 {
+		t.Fatalf("expected no results after delete, got %d", len(results))
+	}
+}
+
+func TestEncryptExisting(t *testing.T) {
+	dir := t.TempDir()
+
+	plain, err := NewManager(dir)
+	if err != nil {
+		t.Fatalf("create manager: %v", err)
+	}
+	vault, err := plain.GetVault(1)
+	if err != nil {
+		t.Fatalf("get vault: %v", err)
+	}
+	item := &Item{Type: ItemTypeNote, Title: "Old note", Content: "legacy plaintext", ImagePath: "images/x.jpg"}
+	if err := vault.CreateItem(item); err != nil {
+		t.Fatalf("create item: %v", err)
+	}
+	if err := vault.WriteFile(item.ImagePath, []byte("jpeg")); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 10, 10))); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	blob, err := vault.PutImage(buf.Bytes(), "png")
+	if err != nil {
+		t.Fatalf("put image: %v", err)
+	}
+	photo := &Item{Type: ItemTypeImage, Title: "Photo", ImagePath: blob.Path(), ImageHash: blob.Hash}
+	if err := vault.CreateItem(photo); err != nil {
+		t.Fatalf("create item: %v", err)
+	}
+	_ = plain.Close()
+
+	encrypted, err := NewManager(dir)
+	if err != nil {
+		t.Fatalf("create manager: %v", err)
+	}
+	if err := encrypted.EnableEncryption("test-master-key", SearchIndexTokens); err != nil {
+		t.Fatalf("enable encryption: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = encrypted.Close()
+	})
+	vault, err = encrypted.GetVault(1)
+	if err != nil {
+		t.Fatalf("get vault: %v", err)
+	}
+
+	stats, err := vault.EncryptExisting()
+	if err != nil {
+		t.Fatalf("encrypt existing: %v", err)
+	}
+	if stats.Items != 1 || stats.Files != 3 || stats.Blobs != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	// Blob files no longer carry the content hash
+	got, err := vault.GetItem(photo.ID)
+	if err != nil || strings.Contains(got.ImagePath, blob.Hash) {
+		t.Fatalf("blob not renamed: %+v %v", got, err)
+	}
+	if data, err := vault.ReadFile(got.ImagePath); err != nil || !bytes.Equal(data, buf.Bytes()) {
+		t.Fatalf("renamed blob unreadable: %v", err)
+	}
+	for _, p := range []string{blob.Path(), blob.ThumbPath()} {
+		if _, err := os.Stat(filepath.Join(vault.Dir(), p)); !os.IsNotExist(err) {
+			t.Fatalf("hash-named file left behind: %s %v", p, err)
+		}
+	}
+
+	results, err := vault.Search("legacy", 10)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected migrated item to stay searchable, got %d results", len(results))
+	}
+	if results[0].Item.Content != "legacy plaintext" {
+		t.Fatalf("unexpected content: %q", results[0].Item.Content)
+	}
+
+	stats, err = vault.EncryptExisting()
+	if err != nil {
+		t.Fatalf("encrypt existing again: %v", err)
+	}
+	if stats.Items != 0 || stats.Files != 0 || stats.Blobs != 0 {
+		t.Fatalf("expected second run to be a no-op, got %+v", stats)
+	}
+
+	// New blobs are named by key too, and the same bytes find them again
+	again, err := vault.PutImage(buf.Bytes(), "png")
+	if err != nil || again.Path() != got.ImagePath {
+		t.Fatalf("stored again under another name: %+v %v", again, err)
+	}
+	doc, err := vault.PutFile([]byte("%PDF-1.4 fake"), "pdf", "")
+	if err != nil || strings.Contains(doc.Path(), doc.Hash) {
+		t.Fatalf("new blob named by hash: %+v %v", doc, err)
+	}
+}
@@ -0,0 +1,148 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const feedSelectColumns = `id, url, title, tags, keywords, max_per_day, etag, last_modified,
+	last_polled_at, last_error, created_at`
+
+// feedColumns holds column values that need decoding while scanning a feed.
+type feedColumns struct {
+	tags, keywords string
+	lastPolled     sql.NullTime
+}
+
+func (c *feedColumns) dest(f *Feed) []any {
+	return []any{&f.ID, &f.URL, &f.Title, &c.tags, &c.keywords, &f.MaxPerDay, &f.ETag, &f.LastModified,
+		&c.lastPolled, &f.LastError, &f.CreatedAt}
+}
+
+func (c *feedColumns) fill(f *Feed) error {
+	if err := json.Unmarshal([]byte(c.tags), &f.Tags); err != nil {
+		return fmt.Errorf("decode tags of feed %s: %w", f.ID, err)
+	}
+	if err := json.Unmarshal([]byte(c.keywords), &f.Keywords); err != nil {
+		return fmt.Errorf("decode keywords of feed %s: %w", f.ID, err)
+	}
+	f.LastPolledAt = c.lastPolled.Time
+	return nil
+}
+
+// feedLists returns the stored form of a feed's tags and keywords.
+func feedLists(f *Feed) (tags, keywords string, err error) {
+	t, err := json.Marshal(nonNil(f.Tags))
+	if err != nil {
+		return "", "", err
+	}
+	k, err := json.Marshal(nonNil(f.Keywords))
+	if err != nil {
+		return "", "", err
+	}
+	return string(t), string(k), nil
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+func scanFeeds(rows *sql.Rows) ([]Feed, error) {
+	var feeds []Feed
+	for rows.Next() {
+		var f Feed
+		var cols feedColumns
+		if err := rows.Scan(cols.dest(&f)...); err != nil {
+			return nil, fmt.Errorf("scan feed: %w", err)
+		}
+		if err := cols.fill(&f); err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, f)
+	}
+	return feeds, rows.Err()
+}
+
+// CreateFeed subscribes to a feed. Subscribing twice to the same URL is an
+// error.
+func (v *VaultStore) CreateFeed(feed *Feed) error {
+	if feed.ID == "" {
+		feed.ID = uuid.NewString()
+	}
+	if feed.CreatedAt.IsZero() {
+		feed.CreatedAt = time.Now()
+	}
+	tags, keywords, err := feedLists(feed)
+	if err != nil {
+		return fmt.Errorf("encode feed: %w", err)
+	}
+	_, err = v.db.Exec(`
+		INSERT INTO feeds (id, url, title, tags, keywords, max_per_day, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		feed.ID, feed.URL, feed.Title, tags, keywords, feed.MaxPerDay, feed.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert feed: %w", err)
+	}
+	return nil
+}
+
+func (v *VaultStore) ListFeeds() ([]Feed, error) {
+	rows, err := v.db.Query(`SELECT ` + feedSelectColumns + ` FROM feeds ORDER BY created_at`)
+	if err != nil {
+		return nil, fmt.Errorf("query feeds: %w", err)
+	}
+	defer rows.Close()
+
+	return scanFeeds(rows)
+}
+
+// DeleteFeed unsubscribes from a feed. Items already saved from it are kept.
+func (v *VaultStore) DeleteFeed(id string) error {
+	_, err := v.db.Exec(`DELETE FROM feeds WHERE id = ?`, id)
+	return err
+}
+
+// UpdateFeedState records the outcome of polling a feed: its title, cache
+// validators, poll time and error.
+func (v *VaultStore) UpdateFeedState(feed *Feed) error {
+	_, err := v.db.Exec(`
+		UPDATE feeds SET title = ?, etag = ?, last_modified = ?, last_polled_at = ?, last_error = ?
+		WHERE id = ?`,
+		feed.Title, feed.ETag, feed.LastModified, feed.LastPolledAt, feed.LastError, feed.ID)
+	return err
+}
+
+// AddFeedEntry records a feed entry as seen and reports whether it was new.
+// captured marks entries saved as items rather than filtered out.
+func (v *VaultStore) AddFeedEntry(feedID, guid string, captured bool) (bool, error) {
+	res, err := v.db.Exec(`
+		INSERT OR IGNORE INTO feed_entries (feed_id, guid, captured, seen_at) VALUES (?, ?, ?, ?)`,
+		feedID, guid, captured, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("insert feed entry: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// RemoveFeedEntry forgets a feed entry, e.g. one that could not be queued.
+func (v *VaultStore) RemoveFeedEntry(feedID, guid string) error {
+	_, err := v.db.Exec(`DELETE FROM feed_entries WHERE feed_id = ? AND guid = ?`, feedID, guid)
+	return err
+}
+
+// FeedCaptures counts the entries of a feed saved as items since a time.
+func (v *VaultStore) FeedCaptures(feedID string, since time.Time) (int, error) {
+	var n int
+	err := v.db.QueryRow(`
+		SELECT COUNT(*) FROM feed_entries WHERE feed_id = ? AND captured = 1 AND seen_at >= ?`,
+		feedID, since).Scan(&n)
+	return n, err
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFeeds(t *testing.T) {
+	manager, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create manager: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = manager.Close()
+	})
+	vault, err := manager.GetVault(1)
+	if err != nil {
+		t.Fatalf("get vault: %v", err)
+	}
+
+	feed := &Feed{URL: "https://example.com/feed.xml", Title: "Example", Tags: []string{"news"}, Keywords: []string{"go", "-ads"}, MaxPerDay: 5}
+	if err := vault.CreateFeed(feed); err != nil {
+		t.Fatalf("create feed: %v", err)
+	}
+	if err := vault.CreateFeed(&Feed{URL: feed.URL}); err == nil {
+		t.Error("expected duplicate feed URL to fail")
+	}
+
+	feed.ETag, feed.LastPolledAt, feed.LastError = `"v1"`, time.Now(), "timeout"
+	if err := vault.UpdateFeedState(feed); err != nil {
+		t.Fatalf("update feed: %v", err)
+	}
+	feeds, err := vault.ListFeeds()
+	if err != nil || len(feeds) != 1 {
+		t.Fatalf("list feeds: %v, %v", feeds, err)
+	}
+	got := feeds[0]
+	if got.ETag != `"v1"` || got.LastError != "timeout" || got.LastPolledAt.IsZero() ||
+		len(got.Tags) != 1 || len(got.Keywords) != 2 || got.MaxPerDay != 5 {
+		t.Errorf("unexpected feed: %+v", got)
+	}
+
+	for _, e := range []struct {
+		guid     string
+		captured bool
+		isNew    bool
+	}{
+		{"a", true, true},
+		{"b", false, true},
+		{"a", true, false},
+		{"c", true, true},
+	} {
+		isNew, err := vault.AddFeedEntry(feed.ID, e.guid, e.captured)
+		if err != nil {
+			t.Fatalf("add entry %s: %v", e.guid, err)
+		}
+		if isNew != e.isNew {
+			t.Errorf("entry %s: new = %v, want %v", e.guid, isNew, e.isNew)
+		}
+	}
+	if n, err := vault.FeedCaptures(feed.ID, time.Now().Add(-time.Hour)); err != nil || n != 2 {
+		t.Errorf("captures: %d, %v", n, err)
+	}
+	if n, err := vault.FeedCaptures(feed.ID, time.Now().Add(time.Hour)); err != nil || n != 0 {
+		t.Errorf("future captures: %d, %v", n, err)
+	}
+
+	if err := vault.DeleteFeed(feed.ID); err != nil {
+		t.Fatalf("delete feed: %v", err)
+	}
+	if feeds, _ := vault.ListFeeds(); len(feeds) != 0 {
+		t.Errorf("feed not deleted: %+v", feeds)
+	}
+	if n, err := vault.FeedCaptures(feed.ID, time.Now().Add(-time.Hour)); err != nil || n != 0 {
+		t.Errorf("entries not deleted with their feed: %d, %v", n, err)
+	}
+}
@@ -0,0 +1,285 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// fileDirs are the user subdirectories managed by the vault.
+var fileDirs = []string{"images", "blobs"}
+
+// GCOptions controls a garbage collection run.
+type GCOptions struct {
+	// DryRun reports orphans without deleting anything.
+	DryRun bool
+	// GracePeriod skips files and unreferenced blobs younger than this, so
+	// images written by an in-flight pipeline run are not collected.
+	GracePeriod time.Duration
+}
+
+// GCReport summarizes a garbage collection run for one vault.
+type GCReport struct {
+	UserID       int64    `json:"user_id"`
+	FilesScanned int      `json:"files_scanned"`
+	Orphans      []string `json:"orphans"`
+	OrphanBytes  int64    `json:"orphan_bytes"`
+	BlobRows     int      `json:"blob_rows"` // unreferenced blob rows
+	Removed      int      `json:"removed"`
+}
+
+// gcIndex is the database side of garbage collection, implemented by each
+// backend.
+type gcIndex interface {
+	// recountBlobs recomputes blob reference counts from items.
+	recountBlobs() error
+	// referencedFiles returns every file path still in use: item images plus
+	// the files of referenced blobs or blobs created or held after cutoff.
+	referencedFiles(cutoff time.Time) (map[string]struct{}, error)
+	// unreferencedBlobs returns hashes of blobs created before cutoff that no
+	// item references and nothing has held since.
+	unreferencedBlobs(cutoff time.Time) ([]string, error)
+	// deleteBlobRow deletes an unreferenced blob unless it was held after
+	// cutoff, and reports whether it did.
+	deleteBlobRow(hash string, cutoff time.Time) (bool, error)
+	GetBlob(hash string) (*Blob, error)
+}
+
+// collectGarbage reconciles files under the vault directory with the item
+// and blob tables, removing files nothing references. Blob reference counts
+// are recomputed from items first, so drift from crashes is repaired.
+func (f *fileStore) collectGarbage(idx gcIndex, opts GCOptions) (*GCReport, error) {
+	report := &GCReport{}
+	cutoff := time.Now().Add(-opts.GracePeriod)
+
+	if !opts.DryRun {
+		if err := idx.recountBlobs(); err != nil {
+			return nil, fmt.Errorf("recount blobs: %w", err)
+		}
+	}
+
+	referenced, err := idx.referencedFiles(cutoff)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unreferenced blob rows past the grace period go with their files
+	deadBlobs, err := idx.unreferencedBlobs(cutoff)
+	if err != nil {
+		return nil, err
+	}
+	report.BlobRows = len(deadBlobs)
+	if !opts.DryRun {
+		for _, hash := range deadBlobs {
+			deleted, err := idx.deleteBlobRow(hash, cutoff)
+			if err != nil {
+				return nil, fmt.Errorf("delete blob row: %w", err)
+			}
+			if deleted {
+				continue
+			}
+			// Stored again since the scan: keep its files
+			if b, err := idx.GetBlob(hash); err == nil && b != nil {
+				referenced[b.Path()] = struct{}{}
+				referenced[b.ThumbPath()] = struct{}{}
+			}
+		}
+	}
+
+	for _, dir := range fileDirs {
+		root := filepath.Join(f.dir, dir)
+		err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			report.FilesScanned++
+
+			rel, err := filepath.Rel(f.dir, p)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if _, ok := referenced[rel]; ok {
+				return nil
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if info.ModTime().After(cutoff) {
+				return nil
+			}
+
+			report.Orphans = append(report.Orphans, rel)
+			report.OrphanBytes += info.Size()
+			if opts.DryRun {
+				return nil
+			}
+			if err := os.Remove(p); err != nil {
+				slog.Warn("failed to remove orphan file", "path", p, "error", err)
+				return nil
+			}
+			report.Removed++
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("walk %s: %w", dir, err)
+		}
+	}
+
+	return report, nil
+}
+
+// CollectAllGarbage runs garbage collection over every user's vault.
+func CollectAllGarbage(stores Stores, opts GCOptions) ([]*GCReport, error) {
+	userIDs, err := stores.UserIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	var reports []*GCReport
+	for _, userID := range userIDs {
+		report, err := CollectUserGarbage(stores, userID, opts)
+		if err != nil {
+			return reports, fmt.Errorf("gc user %d: %w", userID, err)
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// CollectUserGarbage runs garbage collection over a single user's vault.
+func CollectUserGarbage(stores Stores, userID int64, opts GCOptions) (*GCReport, error) {
+	vault, err := stores.GetVault(userID)
+	if err != nil {
+		return nil, err
+	}
+	report, err := vault.CollectGarbage(opts)
+	if err != nil {
+		return nil, err
+	}
+	report.UserID = userID
+	return report, nil
+}
+
+// RunGarbageCollector collects garbage every interval until ctx is done.
+func RunGarbageCollector(ctx context.Context, stores Stores, interval time.Duration, opts GCOptions) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			reports, err := CollectAllGarbage(stores, opts)
+			if err != nil {
+				slog.Error("garbage collection failed", "error", err)
+			}
+			for _, r := range reports {
+				if len(r.Orphans) == 0 && r.BlobRows == 0 {
+					continue
+				}
+				slog.Info("garbage collected",
+					"user_id", r.UserID,
+					"orphans", len(r.Orphans),
+					"bytes", r.OrphanBytes,
+					"removed", r.Removed,
+					"blob_rows", r.BlobRows,
+				)
+			}
+		}
+	}
+}
+
+// CollectGarbage removes files in the vault directory that no item or blob
+// references.
+func (v *VaultStore) CollectGarbage(opts GCOptions) (*GCReport, error) {
+	return v.collectGarbage(v, opts)
+}
+
+func (v *VaultStore) recountBlobs() error {
+	_, err := v.db.Exec(`
+		UPDATE blobs SET refcount = (
+			SELECT COUNT(*) FROM items WHERE items.image_hash = blobs.hash) + (
+			SELECT COUNT(*) FROM items WHERE items.archive_hash = blobs.hash)`)
+	return err
+}
+
+func (v *VaultStore) referencedFiles(cutoff time.Time) (map[string]struct{}, error) {
+	referenced := make(map[string]struct{})
+
+	rows, err := v.db.Query(`SELECT image_path FROM items WHERE image_path IS NOT NULL AND image_path != ''`)
+	if err != nil {
+		return nil, fmt.Errorf("query image paths: %w", err)
+	}
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan image path: %w", err)
+		}
+		referenced[filepath.ToSlash(filepath.Clean(p))] = struct{}{}
+	}
+	rows.Close()
+
+	rows, err = v.db.Query(`
+		SELECT hash, name, ext FROM blobs b
+		WHERE created_at > ? OR (holds > 0 AND held_at > ?)
+		OR EXISTS (SELECT 1 FROM items i WHERE i.image_hash = b.hash OR i.archive_hash = b.hash)`, cutoff, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("query blobs: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var b Blob
+		if err := rows.Scan(&b.Hash, &b.name, &b.Ext); err != nil {
+			return nil, fmt.Errorf("scan blob: %w", err)
+		}
+		referenced[b.Path()] = struct{}{}
+		referenced[b.ThumbPath()] = struct{}{}
+	}
+	return referenced, rows.Err()
+}
+
+func (v *VaultStore) unreferencedBlobs(cutoff time.Time) ([]string, error) {
+	rows, err := v.db.Query(`
+		SELECT hash FROM blobs b
+		WHERE created_at <= ? AND (holds <= 0 OR held_at <= ?)
+		AND NOT EXISTS (SELECT 1 FROM items i WHERE i.image_hash = b.hash OR i.archive_hash = b.hash)`, cutoff, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("query unreferenced blobs: %w", err)
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, fmt.Errorf("scan blob: %w", err)
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, rows.Err()
+}
+
+func (v *VaultStore) deleteBlobRow(hash string, cutoff time.Time) (bool, error) {
+	res, err := v.db.Exec(`DELETE FROM blobs WHERE hash = ? AND refcount <= 0 AND (holds <= 0 OR held_at <= ?)`,
+		hash, cutoff)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCollectGarbage(t *testing.T) {
+	manager, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create manager: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = manager.Close()
+	})
+	vault, err := manager.GetVault(1)
+	if err != nil {
+		t.Fatalf("get vault: %v", err)
+	}
+
+	kept := &Item{Type: ItemTypeImage, Title: "Kept", ImagePath: "images/kept.jpg"}
+	if err := vault.CreateItem(kept); err != nil {
+		t.Fatalf("create item: %v", err)
+	}
+	for _, p := range []string{"images/kept.jpg", "images/orphan.jpg", "images/fresh.jpg"} {
+		if err := vault.WriteFile(p, []byte("data")); err != nil {
+			t.Fatalf("write %s: %v", p, err)
+		}
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	for _, p := range []string{"images/kept.jpg", "images/orphan.jpg"} {
+		if err := os.Chtimes(filepath.Join(vault.Dir(), p), old, old); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+	}
+
+	opts := GCOptions{DryRun: true, GracePeriod: time.Hour}
+	report, err := vault.CollectGarbage(opts)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if len(report.Orphans) != 1 || report.Orphans[0] != "images/orphan.jpg" || report.Removed != 0 {
+		t.Fatalf("unexpected dry run report: %+v", report)
+	}
+
+	opts.DryRun = false
+	report, err = vault.CollectGarbage(opts)
+	if err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	if report.Removed != 1 {
+		t.Fatalf("expected 1 removed file, got %+v", report)
+	}
+	for p, wantExists := range map[string]bool{
+		"images/kept.jpg":   true,
+		"images/orphan.jpg": false,
+		"images/fresh.jpg":  true,
+	} {
+		_, err := os.Stat(filepath.Join(vault.Dir(), p))
+		if exists := err == nil; exists != wantExists {
+			t.Fatalf("%s: exists=%v, want %v", p, exists, wantExists)
+		}
+	}
+}
@@ -2,13 +2,161 @@ package store
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// itemSelectColumns lists the columns read by itemColumns.dest. Queries
+// select them FROM itemFromClause.
+const itemSelectColumns = `i.id, i.type, i.url, i.title, i.content, i.summary, i.image_path, i.image_hash,
+	b.mime, b.size, b.width, b.height, i.archive_hash, ab.size, i.source, i.structured_data,
+	i.content_kind, i.link_health, i.created_at, i.updated_at`
+
+// itemJoins joins image and archive blob metadata onto items.
+const itemJoins = `LEFT JOIN blobs b ON b.hash = i.image_hash
+	LEFT JOIN blobs ab ON ab.hash = i.archive_hash`
+
+// itemFromClause is the items table with its blob joins.
+const itemFromClause = `items i ` + itemJoins
+
+// itemColumns holds nullable column values while scanning an item row.
+type itemColumns struct {
+	url, content, summary, imagePath, imageHash, imageMIME sql.NullString
+	archiveHash, source, structured, kind, link            sql.NullString
+	imageSize, imageWidth, imageHeight, archiveSize        sql.NullInt64
+}
+
+func (c *itemColumns) dest(item *Item) []any {
+	return []any{&item.ID, &item.Type, &c.url, &item.Title, &c.content, &c.summary,
+		&c.imagePath, &c.imageHash, &c.imageMIME, &c.imageSize, &c.imageWidth, &c.imageHeight,
+		&c.archiveHash, &c.archiveSize, &c.source, &c.structured, &c.kind, &c.link, &item.CreatedAt, &item.UpdatedAt}
+}
+
+// fillItem copies scanned columns into item, decrypting sealed values.
+func (f *fileStore) fillItem(item *Item, c *itemColumns) error {
+	var err error
+	item.URL = c.url.String
+	item.ImagePath = c.imagePath.String
+	item.ImageHash = c.imageHash.String
+	switch {
+	case !c.imageMIME.Valid:
+	case strings.HasPrefix(c.imageMIME.String, "image/"):
+		item.Image = &ImageInfo{
+			MIME:   c.imageMIME.String,
+			Size:   c.imageSize.Int64,
+			Width:  int(c.imageWidth.Int64),
+			Height: int(c.imageHeight.Int64),
+		}
+	default:
+		item.File = &FileInfo{MIME: c.imageMIME.String, Size: c.imageSize.Int64}
+	}
+	item.ArchiveHash = c.archiveHash.String
+	item.ContentKind = c.kind.String
+	if c.archiveSize.Valid {
+		item.Archive = &FileInfo{MIME: ArchiveMIME, Size: c.archiveSize.Int64}
+	}
+	if item.Content, err = f.cipher.openString(c.content.String); err != nil {
+		return fmt.Errorf("open content of %s: %w", item.ID, err)
+	}
+	if item.Summary, err = f.cipher.openString(c.summary.String); err != nil {
+		return fmt.Errorf("open summary of %s: %w", item.ID, err)
+	}
+	if item.Source, err = openJSON[Source](f, c.source.String); err != nil {
+		return fmt.Errorf("open source of %s: %w", item.ID, err)
+	}
+	if item.Structured, err = openJSON[StructuredData](f, c.structured.String); err != nil {
+		return fmt.Errorf("open structured data of %s: %w", item.ID, err)
+	}
+	if item.Link, err = openJSON[LinkHealth](f, c.link.String); err != nil {
+		return fmt.Errorf("open link health of %s: %w", item.ID, err)
+	}
+	return nil
+}
+
+func (v *VaultStore) scanItems(rows *sql.Rows) ([]Item, error) {
+	var items []Item
+	for rows.Next() {
+		var item Item
+		var cols itemColumns
+		if err := rows.Scan(cols.dest(&item)...); err != nil {
+			return nil, fmt.Errorf("scan item: %w", err)
+		}
+		if err := v.fillItem(&item, &cols); err != nil {
+			return nil, err
+		}
+		tags, _ := v.getItemTags(item.ID)
+		item.Tags = tags
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// sealItem returns the stored form of the item's encrypted columns.
+func (f *fileStore) sealItem(item *Item) (content, summary, rawContent string, err error) {
+	if content, err = f.cipher.sealString(item.Content); err != nil {
+		return "", "", "", err
+	}
+	if summary, err = f.cipher.sealString(item.Summary); err != nil {
+		return "", "", "", err
+	}
+	if rawContent, err = f.cipher.sealString(item.RawContent); err != nil {
+		return "", "", "", err
+	}
+	return content, summary, rawContent, nil
+}
+
+// sealJSON returns the stored form of a column kept as JSON, encrypted like
+// the content. Nil values are stored as NULL.
+func sealJSON[T any](f *fileStore, v *T) (sql.NullString, error) {
+	if v == nil {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	sealed, err := f.cipher.sealString(string(data))
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: sealed, Valid: true}, nil
+}
+
+// openJSON decodes a column written by sealJSON.
+func openJSON[T any](f *fileStore, stored string) (*T, error) {
+	if stored == "" {
+		return nil, nil
+	}
+	data, err := f.cipher.openString(stored)
+	if err != nil {
+		return nil, err
+	}
+	v := new(T)
+	if err := json.Unmarshal([]byte(data), v); err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+	return v, nil
+}
+
+// indexSealed adds plaintext of an encrypted item to the contentless index.
+func (v *VaultStore) indexSealed(tx *sql.Tx, rowID int64, content, summary string) error {
+	if v.searchMode == SearchIndexOff {
+		return nil
+	}
+	if _, err := tx.Exec(`DELETE FROM items_secure_fts WHERE rowid = ?`, rowID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`INSERT INTO items_secure_fts (rowid, content, summary) VALUES (?, ?, ?)`,
+		rowID, content, summary)
+	return err
+}
+
 func (v *VaultStore) CreateItem(item *Item) error {
 	if item.ID == "" {
 		item.ID = uuid.NewString()
@@ -16,22 +164,95 @@ func (v *VaultStore) CreateItem(item *Item) error {
 	item.CreatedAt = time.Now()
 	item.UpdatedAt = item.CreatedAt
 
+	content, summary, rawContent, err := v.sealItem(item)
+	if err != nil {
+		return fmt.Errorf("encrypt item: %w", err)
+	}
+	source, err := sealJSON(&v.fileStore, item.Source)
+	if err != nil {
+		return fmt.Errorf("encrypt source: %w", err)
+	}
+	structured, err := sealJSON(&v.fileStore, item.Structured)
+	if err != nil {
+		return fmt.Errorf("encrypt structured data: %w", err)
+	}
+
 	tx, err := v.db.Begin()
 	if err != nil {
 		return fmt.Errorf("begin tx: %w", err)
 	}
 	defer tx.Rollback()
 
-	_, err = tx.Exec(`
-		INSERT INTO items (id, type, url, title, content, summary, raw_content, image_path, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		item.ID, item.Type, item.URL, item.Title, item.Content, item.Summary, item.RawContent, item.ImagePath,
+	res, err := tx.Exec(`
+		INSERT INTO items (id, type, url, title, content, summary, raw_content, image_path, image_hash,
+			archive_hash, source, structured_data, content_kind, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		item.ID, item.Type, item.URL, item.Title, content, summary, rawContent, item.ImagePath,
+		nullString(item.ImageHash), nullString(item.ArchiveHash), source, structured, nullString(item.ContentKind),
 		item.CreatedAt, item.UpdatedAt,
 	)
 	if err != nil {
 		return fmt.Errorf("insert item: %w", err)
 	}
 
+	if err := retainBlob(tx, item.ImageHash); err != nil {
+		return fmt.Errorf("retain blob: %w", err)
+	}
+	if err := retainBlob(tx, item.ArchiveHash); err != nil {
+		return fmt.Errorf("retain archive: %w", err)
+	}
+
+	if v.cipher != nil {
+		rowID, err := res.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("get rowid: %w", err)
+		}
+		if err := v.indexSealed(tx, rowID, item.Content, item.Summary); err != nil {
+			return fmt.Errorf("index item: %w", err)
+		}
+	}
+
+	if err := v.setItemTags(tx, item.ID, item.Tags); err != nil {
+		return fmt.Errorf("set tags: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (v *VaultStore) UpdateItem(item *Item) error {
+	item.UpdatedAt = time.Now()
+
+	content, summary, rawContent, err := v.sealItem(item)
+	if err != nil {
+		return fmt.Errorf("encrypt item: %w", err)
+	}
+
+	tx, err := v.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var rowID int64
+	err = tx.QueryRow(`
+		UPDATE items SET url = ?, title = ?, content = ?, summary = ?,
+			raw_content = COALESCE(NULLIF(?, ''), raw_content), content_kind = ?, updated_at = ?
+		WHERE id = ? RETURNING rowid`,
+		item.URL, item.Title, content, summary, rawContent, nullString(item.ContentKind), item.UpdatedAt, item.ID,
+	).Scan(&rowID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("item %s not found", item.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("update item: %w", err)
+	}
+
+	if v.cipher != nil {
+		if err := v.indexSealed(tx, rowID, item.Content, item.Summary); err != nil {
+			return fmt.Errorf("index item: %w", err)
+		}
+	}
+
 	if err := v.setItemTags(tx, item.ID, item.Tags); err != nil {
 		return fmt.Errorf("set tags: %w", err)
 	}
@@ -39,25 +260,34 @@ func (v *VaultStore) CreateItem(item *Item) error {
 	return tx.Commit()
 }
 
+func (v *VaultStore) GetRawContent(id string) (string, error) {
+	var raw sql.NullString
+	err := v.db.QueryRow(`SELECT raw_content FROM items WHERE id = ?`, id).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("query raw content: %w", err)
+	}
+	return v.cipher.openString(raw.String)
+}
+
 func (v *VaultStore) GetItem(id string) (*Item, error) {
 	item := &Item{}
-	var url, content, summary, imagePath sql.NullString
+	var cols itemColumns
 	err := v.db.QueryRow(`
-		SELECT id, type, url, title, content, summary, image_path, created_at, updated_at
-		FROM items WHERE id = ?`, id,
-	).Scan(&item.ID, &item.Type, &url, &item.Title, &content, &summary, &imagePath,
-		&item.CreatedAt, &item.UpdatedAt)
+		SELECT `+itemSelectColumns+`
+		FROM `+itemFromClause+` WHERE i.id = ?`, id,
+	).Scan(cols.dest(item)...)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, fmt.Errorf("query item: %w", err)
 	}
-
-	item.URL = url.String
-	item.Content = content.String
-	item.Summary = summary.String
-	item.ImagePath = imagePath.String
+	if err := v.fillItem(item, &cols); err != nil {
+		return nil, err
+	}
 
 	tags, err := v.getItemTags(item.ID)
 	if err != nil {
@@ -69,36 +299,20 @@ func (v *VaultStore) GetItem(id string) (*Item, error) {
 
 func (v *VaultStore) ListItems(limit, offset int) ([]Item, error) {
 	rows, err := v.db.Query(`
-		SELECT id, type, url, title, content, summary, image_path, created_at, updated_at
-		FROM items ORDER BY created_at DESC LIMIT ? OFFSET ?`, limit, offset)
+		SELECT `+itemSelectColumns+`
+		FROM `+itemFromClause+` ORDER BY i.created_at DESC LIMIT ? OFFSET ?`, limit, offset)
 	if err != nil {
 		return nil, fmt.Errorf("query items: %w", err)
 	}
 	defer rows.Close()
 
-	var items []Item
-	for rows.Next() {
-		var item Item
-		var url, content, summary, imagePath sql.NullString
-		if err := rows.Scan(&item.ID, &item.Type, &url, &item.Title, &content,
-			&summary, &imagePath, &item.CreatedAt, &item.UpdatedAt); err != nil {
-			return nil, fmt.Errorf("scan item: %w", err)
-		}
-		item.URL = url.String
-		item.Content = content.String
-		item.Summary = summary.String
-		item.ImagePath = imagePath.String
-		tags, _ := v.getItemTags(item.ID)
-		item.Tags = tags
-		items = append(items, item)
-	}
-	return items, nil
+	return v.scanItems(rows)
 }
 
 func (v *VaultStore) ListItemsByTag(tag string, limit, offset int) ([]Item, error) {
 	rows, err := v.db.Query(`
-		SELECT DISTINCT i.id, i.type, i.url, i.title, i.content, i.summary, i.image_path, i.created_at, i.updated_at
-		FROM items i
+		SELECT DISTINCT `+itemSelectColumns+`
+		FROM `+itemFromClause+`
 		JOIN item_tags it ON i.id = it.item_id
 		JOIN tags t ON it.tag_id = t.id
 		WHERE t.name = ?
@@ -108,63 +322,118 @@ func (v *VaultStore) ListItemsByTag(tag string, limit, offset int) ([]Item, erro
 	}
 	defer rows.Close()
 
-	var items []Item
-	for rows.Next() {
-		var item Item
-		var url, content, summary, imagePath sql.NullString
-		if err := rows.Scan(&item.ID, &item.Type, &url, &item.Title, &content,
-			&summary, &imagePath, &item.CreatedAt, &item.UpdatedAt); err != nil {
-			return nil, fmt.Errorf("scan item: %w", err)
-		}
-		item.URL = url.String
-		item.Content = content.String
-		item.Summary = summary.String
-		item.ImagePath = imagePath.String
-		tags, _ := v.getItemTags(item.ID)
-		item.Tags = tags
-		items = append(items, item)
-	}
-	return items, nil
+	return v.scanItems(rows)
 }
 
+// Search runs a full-text query. Plaintext content is matched through
+// items_fts; encrypted content through the contentless items_secure_fts.
 func (v *VaultStore) Search(query string, limit int) ([]SearchResult, error) {
+	// Fetch extra rows: an item can match in both indexes
 	rows, err := v.db.Query(`
-		SELECT i.id, i.type, i.url, i.title, i.content, i.summary, i.image_path, i.created_at, i.updated_at,
-		       snippet(items_fts, 1, '<mark>', '</mark>', '...', 32) as snippet,
-		       bm25(items_fts) as score
-		FROM items_fts
-		JOIN items i ON items_fts.rowid = i.rowid
-		WHERE items_fts MATCH ?
+		SELECT * FROM (
+			SELECT `+itemSelectColumns+`,
+			       snippet(items_fts, 1, '<mark>', '</mark>', '...', 32) as snippet,
+			       bm25(items_fts) as score
+			FROM items_fts
+			JOIN items i ON items_fts.rowid = i.rowid
+			`+itemJoins+`
+			WHERE items_fts MATCH ?
+			UNION ALL
+			SELECT `+itemSelectColumns+`,
+			       '' as snippet,
+			       bm25(items_secure_fts) as score
+			FROM items_secure_fts
+			JOIN items i ON items_secure_fts.rowid = i.rowid
+			`+itemJoins+`
+			WHERE items_secure_fts MATCH ?
+		)
 		ORDER BY score
-		LIMIT ?`, query, limit)
+		LIMIT ?`, query, query, limit*2)
 	if err != nil {
 		return nil, fmt.Errorf("search: %w", err)
 	}
 	defer rows.Close()
 
 	var results []SearchResult
+	seen := make(map[string]struct{})
 	for rows.Next() {
 		var r SearchResult
-		var url, content, summary, imagePath sql.NullString
-		if err := rows.Scan(&r.Item.ID, &r.Item.Type, &url, &r.Item.Title,
-			&content, &summary, &imagePath, &r.Item.CreatedAt, &r.Item.UpdatedAt,
-			&r.Snippet, &r.Score); err != nil {
+		var cols itemColumns
+		var snippet sql.NullString
+		if err := rows.Scan(append(cols.dest(&r.Item), &snippet, &r.Score)...); err != nil {
 			return nil, fmt.Errorf("scan result: %w", err)
 		}
-		r.Item.URL = url.String
-		r.Item.Content = content.String
-		r.Item.Summary = summary.String
-		r.Item.ImagePath = imagePath.String
+		if _, ok := seen[r.Item.ID]; ok {
+			continue
+		}
+		seen[r.Item.ID] = struct{}{}
+		// Snippets are built from the stored column, which is ciphertext for
+		// encrypted items
+		if !isSealed(cols.content.String) {
+			r.Snippet = snippet.String
+		}
+		if err := v.fillItem(&r.Item, &cols); err != nil {
+			return nil, err
+		}
 		tags, _ := v.getItemTags(r.Item.ID)
 		r.Item.Tags = tags
 		results = append(results, r)
+		if len(results) == limit {
+			break
+		}
 	}
 	return results, nil
 }
 
+// DeleteItem removes an item, its image and its page snapshot. Blobs are
+// released and their files deleted once no other item references them.
 func (v *VaultStore) DeleteItem(id string) error {
-	_, err := v.db.Exec("DELETE FROM items WHERE id = ?", id)
-	return err
+	tx, err := v.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var imagePath, imageHash, archiveHash sql.NullString
+	err = tx.QueryRow("SELECT image_path, image_hash, archive_hash FROM items WHERE id = ?", id).
+		Scan(&imagePath, &imageHash, &archiveHash)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("query item: %w", err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM items WHERE id = ?", id); err != nil {
+		return fmt.Errorf("delete item: %w", err)
+	}
+
+	var orphans []*Blob
+	for _, hash := range []string{imageHash.String, archiveHash.String} {
+		orphan, err := releaseBlob(tx, hash)
+		if err != nil {
+			return fmt.Errorf("release blob: %w", err)
+		}
+		if orphan != nil {
+			orphans = append(orphans, orphan)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	for _, orphan := range orphans {
+		v.removeBlobFiles(v, orphan)
+	}
+	// Images saved before the blob store belong to exactly one item
+	if imageHash.String == "" && imagePath.String != "" {
+		_ = os.Remove(filepath.Join(v.dir, imagePath.String))
+	}
+	return nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
 }
 
 func (v *VaultStore) setItemTags(tx *sql.Tx, itemID string, tags []string) error {
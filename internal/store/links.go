@@ -0,0 +1,108 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// LinksToCheck returns link items never checked or last checked before
+// checkedBefore, least recently checked first.
+func (v *VaultStore) LinksToCheck(checkedBefore time.Time, limit int) ([]Item, error) {
+	rows, err := v.db.Query(`
+		SELECT `+itemSelectColumns+`
+		FROM `+itemFromClause+`
+		WHERE i.type = 'link' AND i.url IS NOT NULL AND i.url != ''
+		AND (i.link_checked_at IS NULL OR i.link_checked_at < ?)
+		ORDER BY i.link_checked_at IS NOT NULL, i.link_checked_at LIMIT ?`, checkedBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query links to check: %w", err)
+	}
+	defer rows.Close()
+
+	return v.scanItems(rows)
+}
+
+// ListItemsByLinkStatus returns link items whose latest check had the given
+// status, such as LinkDead, newest first.
+func (v *VaultStore) ListItemsByLinkStatus(status string, limit, offset int) ([]Item, error) {
+	rows, err := v.db.Query(`
+		SELECT `+itemSelectColumns+`
+		FROM `+itemFromClause+` WHERE i.link_status = ?
+		ORDER BY i.created_at DESC LIMIT ? OFFSET ?`, status, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("query items by link status: %w", err)
+	}
+	defer rows.Close()
+
+	return v.scanItems(rows)
+}
+
+// SetLinkHealth records the result of checking an item's URL.
+func (v *VaultStore) SetLinkHealth(id string, health *LinkHealth) error {
+	stored, err := sealJSON(&v.fileStore, health)
+	if err != nil {
+		return fmt.Errorf("encrypt link health: %w", err)
+	}
+	res, err := v.db.Exec(`
+		UPDATE items SET link_status = ?, link_checked_at = ?, link_health = ? WHERE id = ?`,
+		health.Status, health.CheckedAt, stored, id)
+	if err != nil {
+		return fmt.Errorf("update link health: %w", err)
+	}
+	return requireRow(res, id)
+}
+
+// SetArchive attaches a page snapshot stored with PutFile to an item,
+// releasing the snapshot it replaces.
+func (v *VaultStore) SetArchive(id, hash string) error {
+	tx, err := v.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var old sql.NullString
+	err = tx.QueryRow(`SELECT archive_hash FROM items WHERE id = ?`, id).Scan(&old)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("item %s not found", id)
+	}
+	if err != nil {
+		return fmt.Errorf("query item: %w", err)
+	}
+	if old.String == hash {
+		return nil
+	}
+
+	if _, err := tx.Exec(`UPDATE items SET archive_hash = ?, updated_at = ? WHERE id = ?`,
+		nullString(hash), time.Now(), id); err != nil {
+		return fmt.Errorf("update archive: %w", err)
+	}
+	if err := retainBlob(tx, hash); err != nil {
+		return fmt.Errorf("retain archive: %w", err)
+	}
+	orphan, err := releaseBlob(tx, old.String)
+	if err != nil {
+		return fmt.Errorf("release archive: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if orphan != nil {
+		v.removeBlobFiles(v, orphan)
+	}
+	return nil
+}
+
+// requireRow reports an error when an update matched no item.
+func requireRow(res sql.Result, id string) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("item %s not found", id)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLinkHealth(t *testing.T) {
+	manager, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create manager: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = manager.Close()
+	})
+	vault, err := manager.GetVault(1)
+	if err != nil {
+		t.Fatalf("get vault: %v", err)
+	}
+
+	for _, item := range []*Item{
+		{ID: "fresh", Type: ItemTypeLink, URL: "https://example.com/fresh", Title: "Fresh"},
+		{ID: "stale", Type: ItemTypeLink, URL: "https://example.com/stale", Title: "Stale"},
+		{ID: "new", Type: ItemTypeLink, URL: "https://example.com/new", Title: "New"},
+		{ID: "note", Type: ItemTypeNote, Title: "Note"},
+	} {
+		if err := vault.CreateItem(item); err != nil {
+			t.Fatalf("create item: %v", err)
+		}
+	}
+
+	now := time.Now().UTC()
+	if err := vault.SetLinkHealth("fresh", &LinkHealth{Status: LinkOK, CheckedAt: now}); err != nil {
+		t.Fatalf("set link health: %v", err)
+	}
+	dead := &LinkHealth{
+		Status:     LinkDead,
+		StatusCode: 404,
+		Failures:   3,
+		CheckedAt:  now.Add(-30 * 24 * time.Hour),
+		DeadSince:  now.Add(-30 * 24 * time.Hour),
+		WaybackURL: "https://web.archive.org/web/2020/https://example.com/stale",
+	}
+	if err := vault.SetLinkHealth("stale", dead); err != nil {
+		t.Fatalf("set link health: %v", err)
+	}
+	if err := vault.SetLinkHealth("missing", dead); err == nil {
+		t.Error("expected error for missing item")
+	}
+
+	// Never-checked links come first, then the least recently checked
+	due, err := vault.LinksToCheck(now.Add(-7*24*time.Hour), 10)
+	if err != nil {
+		t.Fatalf("links to check: %v", err)
+	}
+	if len(due) != 2 || due[0].ID != "new" || due[1].ID != "stale" {
+		t.Fatalf("unexpected links to check: %+v", due)
+	}
+	if got := due[1].Link; got == nil || got.Status != LinkDead || got.WaybackURL != dead.WaybackURL || got.DeadSince.IsZero() {
+		t.Errorf("unexpected link health: %+v", got)
+	}
+
+	items, err := vault.ListItemsByLinkStatus(LinkDead, 10, 0)
+	if err != nil {
+		t.Fatalf("list dead links: %v", err)
+	}
+	if len(items) != 1 || items[0].ID != "stale" {
+		t.Fatalf("unexpected dead links: %+v", items)
+	}
+
+	blob, err := vault.PutFile([]byte("<html>snapshot</html>"), "html", ArchiveMIME)
+	if err != nil {
+		t.Fatalf("put archive: %v", err)
+	}
+	if err := vault.SetArchive("stale", blob.Hash); err != nil {
+		t.Fatalf("set archive: %v", err)
+	}
+	item, err := vault.GetItem("stale")
+	if err != nil {
+		t.Fatalf("get item: %v", err)
+	}
+	if item.ArchiveHash != blob.Hash || item.Archive == nil {
+		t.Errorf("archive not attached: %+v", item)
+	}
+	if b, _ := vault.GetBlob(blob.Hash); b == nil || b.RefCount != 1 {
+		t.Errorf("unexpected archive blob: %+v", b)
+	}
+}
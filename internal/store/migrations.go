@@ -3,6 +3,7 @@ package store
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 )
 
 // Migration SQL - embedded directly since go:embed requires the files to be in the same package or below
@@ -11,13 +12,21 @@ const migrationSQL = `
 
 CREATE TABLE IF NOT EXISTS items (
     id TEXT PRIMARY KEY,
-    type TEXT NOT NULL CHECK(type IN ('link', 'note', 'image', 'search')),
+    type TEXT NOT NULL CHECK(type IN ('link', 'note', 'image', 'search', 'document')),
     url TEXT,
     title TEXT NOT NULL,
     content TEXT,
     summary TEXT,
     raw_content TEXT,
     image_path TEXT,
+    image_hash TEXT,
+    archive_hash TEXT,
+    source TEXT,
+    structured_data TEXT,
+    content_kind TEXT,
+    link_status TEXT,
+    link_checked_at DATETIME,
+    link_health TEXT,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 );
@@ -43,11 +52,61 @@ CREATE TABLE IF NOT EXISTS relationships (
     UNIQUE(source_id, target_id, relation_type)
 );
 
+-- Content-addressed image blobs shared between items
+CREATE TABLE IF NOT EXISTS blobs (
+    hash TEXT PRIMARY KEY,
+    name TEXT NOT NULL DEFAULT '',
+    ext TEXT NOT NULL,
+    mime TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    width INTEGER NOT NULL DEFAULT 0,
+    height INTEGER NOT NULL DEFAULT 0,
+    has_thumb INTEGER NOT NULL DEFAULT 0,
+    refcount INTEGER NOT NULL DEFAULT 0,
+    holds INTEGER NOT NULL DEFAULT 0,
+    held_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
 CREATE TABLE IF NOT EXISTS settings (
     key TEXT PRIMARY KEY,
     value TEXT NOT NULL
 );
 
+-- Feed subscriptions. tags and keywords are JSON arrays.
+CREATE TABLE IF NOT EXISTS feeds (
+    id TEXT PRIMARY KEY,
+    url TEXT UNIQUE NOT NULL,
+    title TEXT NOT NULL DEFAULT '',
+    tags TEXT NOT NULL DEFAULT '[]',
+    keywords TEXT NOT NULL DEFAULT '[]',
+    max_per_day INTEGER NOT NULL DEFAULT 0,
+    etag TEXT NOT NULL DEFAULT '',
+    last_modified TEXT NOT NULL DEFAULT '',
+    last_polled_at DATETIME,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Feed entries already seen, so each is saved at most once
+CREATE TABLE IF NOT EXISTS feed_entries (
+    feed_id TEXT NOT NULL REFERENCES feeds(id) ON DELETE CASCADE,
+    guid TEXT NOT NULL,
+    captured INTEGER NOT NULL DEFAULT 0,
+    seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (feed_id, guid)
+);
+
+-- Change log for incremental sync, written by triggers. AUTOINCREMENT keeps
+-- seq monotonic even after rows are deleted.
+CREATE TABLE IF NOT EXISTS changes (
+    seq INTEGER PRIMARY KEY AUTOINCREMENT,
+    entity TEXT NOT NULL,
+    entity_id TEXT NOT NULL,
+    op TEXT NOT NULL CHECK(op IN ('upsert', 'delete')),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
 -- Full-text search
 CREATE VIRTUAL TABLE IF NOT EXISTS items_fts USING fts5(
     title,
@@ -57,29 +116,117 @@ CREATE VIRTUAL TABLE IF NOT EXISTS items_fts USING fts5(
     content_rowid='rowid'
 );
 
--- Triggers to keep FTS in sync
+-- Index for encrypted content. Contentless: only tokens are stored, never the
+-- text itself. Maintained by the store since triggers only see ciphertext.
+CREATE VIRTUAL TABLE IF NOT EXISTS items_secure_fts USING fts5(
+    content,
+    summary,
+    content='',
+    contentless_delete=1
+);
+` + ftsTriggersSQL + changeTriggersSQL + `
+-- Indexes
+CREATE INDEX IF NOT EXISTS idx_items_type ON items(type);
+CREATE INDEX IF NOT EXISTS idx_items_created ON items(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_relationships_source ON relationships(source_id);
+CREATE INDEX IF NOT EXISTS idx_relationships_target ON relationships(target_id);
+CREATE INDEX IF NOT EXISTS idx_changes_entity ON changes(entity, entity_id);
+`
+
+// ftsTriggersSQL keeps items_fts in sync with items. Encrypted column values
+// are indexed as NULL so ciphertext never lands in the plaintext index; titles
+// are never encrypted and stay searchable.
+const ftsTriggersSQL = `
 CREATE TRIGGER IF NOT EXISTS items_ai AFTER INSERT ON items BEGIN
     INSERT INTO items_fts(rowid, title, content, summary)
-    VALUES (NEW.rowid, NEW.title, NEW.content, NEW.summary);
+    VALUES (NEW.rowid, NEW.title,
+        CASE WHEN NEW.content LIKE 'enc:v1:%' THEN NULL ELSE NEW.content END,
+        CASE WHEN NEW.summary LIKE 'enc:v1:%' THEN NULL ELSE NEW.summary END);
 END;
 
 CREATE TRIGGER IF NOT EXISTS items_ad AFTER DELETE ON items BEGIN
     INSERT INTO items_fts(items_fts, rowid, title, content, summary)
-    VALUES ('delete', OLD.rowid, OLD.title, OLD.content, OLD.summary);
+    VALUES ('delete', OLD.rowid, OLD.title,
+        CASE WHEN OLD.content LIKE 'enc:v1:%' THEN NULL ELSE OLD.content END,
+        CASE WHEN OLD.summary LIKE 'enc:v1:%' THEN NULL ELSE OLD.summary END);
+    DELETE FROM items_secure_fts WHERE rowid = OLD.rowid;
 END;
 
 CREATE TRIGGER IF NOT EXISTS items_au AFTER UPDATE ON items BEGIN
     INSERT INTO items_fts(items_fts, rowid, title, content, summary)
-    VALUES ('delete', OLD.rowid, OLD.title, OLD.content, OLD.summary);
+    VALUES ('delete', OLD.rowid, OLD.title,
+        CASE WHEN OLD.content LIKE 'enc:v1:%' THEN NULL ELSE OLD.content END,
+        CASE WHEN OLD.summary LIKE 'enc:v1:%' THEN NULL ELSE OLD.summary END);
     INSERT INTO items_fts(rowid, title, content, summary)
-    VALUES (NEW.rowid, NEW.title, NEW.content, NEW.summary);
+    VALUES (NEW.rowid, NEW.title,
+        CASE WHEN NEW.content LIKE 'enc:v1:%' THEN NULL ELSE NEW.content END,
+        CASE WHEN NEW.summary LIKE 'enc:v1:%' THEN NULL ELSE NEW.summary END);
 END;
+`
 
--- Indexes
-CREATE INDEX IF NOT EXISTS idx_items_type ON items(type);
-CREATE INDEX IF NOT EXISTS idx_items_created ON items(created_at DESC);
-CREATE INDEX IF NOT EXISTS idx_relationships_source ON relationships(source_id);
-CREATE INDEX IF NOT EXISTS idx_relationships_target ON relationships(target_id);
+// changeTriggersSQL records item, tag and relationship writes in the change
+// log. Tag assignments count as a change to the item.
+const changeTriggersSQL = `
+CREATE TRIGGER IF NOT EXISTS items_changes_ai AFTER INSERT ON items BEGIN
+    INSERT INTO changes (entity, entity_id, op) VALUES ('item', NEW.id, 'upsert');
+END;
+
+CREATE TRIGGER IF NOT EXISTS items_changes_au AFTER UPDATE ON items BEGIN
+    INSERT INTO changes (entity, entity_id, op) VALUES ('item', NEW.id, 'upsert');
+END;
+
+CREATE TRIGGER IF NOT EXISTS items_changes_ad AFTER DELETE ON items BEGIN
+    INSERT INTO changes (entity, entity_id, op) VALUES ('item', OLD.id, 'delete');
+END;
+
+CREATE TRIGGER IF NOT EXISTS item_tags_changes_ai AFTER INSERT ON item_tags BEGIN
+    INSERT INTO changes (entity, entity_id, op) VALUES ('item', NEW.item_id, 'upsert');
+END;
+
+CREATE TRIGGER IF NOT EXISTS item_tags_changes_ad AFTER DELETE ON item_tags BEGIN
+    INSERT INTO changes (entity, entity_id, op) VALUES ('item', OLD.item_id, 'upsert');
+END;
+
+CREATE TRIGGER IF NOT EXISTS tags_changes_ai AFTER INSERT ON tags BEGIN
+    INSERT INTO changes (entity, entity_id, op) VALUES ('tag', NEW.name, 'upsert');
+END;
+
+CREATE TRIGGER IF NOT EXISTS tags_changes_ad AFTER DELETE ON tags BEGIN
+    INSERT INTO changes (entity, entity_id, op) VALUES ('tag', OLD.name, 'delete');
+END;
+
+CREATE TRIGGER IF NOT EXISTS relationships_changes_ai AFTER INSERT ON relationships BEGIN
+    INSERT INTO changes (entity, entity_id, op) VALUES ('relationship', NEW.id, 'upsert');
+END;
+
+CREATE TRIGGER IF NOT EXISTS relationships_changes_au AFTER UPDATE ON relationships BEGIN
+    INSERT INTO changes (entity, entity_id, op) VALUES ('relationship', NEW.id, 'upsert');
+END;
+
+CREATE TRIGGER IF NOT EXISTS relationships_changes_ad AFTER DELETE ON relationships BEGIN
+    INSERT INTO changes (entity, entity_id, op) VALUES ('relationship', OLD.id, 'delete');
+END;
+`
+
+// migrationBackfillChanges seeds the change log of a vault created before it
+// existed, so a client syncing from zero receives every entity.
+const migrationBackfillChanges = `
+INSERT INTO changes (entity, entity_id, op)
+SELECT entity, entity_id, 'upsert' FROM (
+    SELECT 0 AS ord, 'item' AS entity, id AS entity_id, created_at FROM items
+    UNION ALL SELECT 1, 'tag', name, NULL FROM tags
+    UNION ALL SELECT 2, 'relationship', id, created_at FROM relationships
+)
+WHERE NOT EXISTS (SELECT 1 FROM changes)
+ORDER BY ord, created_at;
+`
+
+// Drops FTS triggers created before encryption support so they can be
+// recreated from ftsTriggersSQL.
+const migrationDropLegacyFTSTriggers = `
+DROP TRIGGER IF EXISTS items_ai;
+DROP TRIGGER IF EXISTS items_ad;
+DROP TRIGGER IF EXISTS items_au;
 `
 
 // Migration for existing databases to add image_path column
@@ -87,6 +234,49 @@ const migrationAddImagePath = `
 ALTER TABLE items ADD COLUMN image_path TEXT;
 `
 
+// Migration for existing databases to add image_hash column
+const migrationAddImageHash = `
+ALTER TABLE items ADD COLUMN image_hash TEXT;
+`
+
+// Migration for existing databases to add source column
+const migrationAddSource = `
+ALTER TABLE items ADD COLUMN source TEXT;
+`
+
+// Migration for existing databases to add structured_data column
+const migrationAddStructuredData = `
+ALTER TABLE items ADD COLUMN structured_data TEXT;
+`
+
+// Migration for existing databases to add archive_hash column
+const migrationAddArchiveHash = `
+ALTER TABLE items ADD COLUMN archive_hash TEXT;
+`
+
+// Migration for existing databases to add content_kind column
+const migrationAddContentKind = `
+ALTER TABLE items ADD COLUMN content_kind TEXT;
+`
+
+// Migration for existing databases to add link health columns
+var migrationAddLinkHealth = []string{
+	`ALTER TABLE items ADD COLUMN link_status TEXT`,
+	`ALTER TABLE items ADD COLUMN link_checked_at DATETIME`,
+	`ALTER TABLE items ADD COLUMN link_health TEXT`,
+}
+
+// Migration for existing databases to add blob hold columns
+var migrationAddBlobHolds = []string{
+	`ALTER TABLE blobs ADD COLUMN holds INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE blobs ADD COLUMN held_at DATETIME`,
+}
+
+// Migration for existing databases to add the blob file name column
+const migrationAddBlobName = `
+ALTER TABLE blobs ADD COLUMN name TEXT NOT NULL DEFAULT '';
+`
+
 // Migration to update CHECK constraint for existing databases
 // SQLite doesn't support ALTER TABLE to modify CHECK constraints, so we recreate the table
 const migrationUpdateTypeConstraint = `
@@ -96,20 +286,28 @@ PRAGMA foreign_keys=OFF;
 -- Create new table with updated CHECK constraint
 CREATE TABLE IF NOT EXISTS items_new (
     id TEXT PRIMARY KEY,
-    type TEXT NOT NULL CHECK(type IN ('link', 'note', 'image', 'search')),
+    type TEXT NOT NULL CHECK(type IN ('link', 'note', 'image', 'search', 'document')),
     url TEXT,
     title TEXT NOT NULL,
     content TEXT,
     summary TEXT,
     raw_content TEXT,
     image_path TEXT,
+    image_hash TEXT,
+    archive_hash TEXT,
+    source TEXT,
+    structured_data TEXT,
+    content_kind TEXT,
+    link_status TEXT,
+    link_checked_at DATETIME,
+    link_health TEXT,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 );
 
--- Copy data from old table (handle missing image_path column)
-INSERT OR IGNORE INTO items_new (id, type, url, title, content, summary, raw_content, created_at, updated_at)
-SELECT id, type, url, title, content, summary, raw_content, created_at, updated_at FROM items;
+-- Copy data from old table, keeping rowids so the FTS indexes stay valid
+INSERT OR IGNORE INTO items_new (rowid, id, type, url, title, content, summary, raw_content, image_path, image_hash, archive_hash, source, structured_data, content_kind, link_status, link_checked_at, link_health, created_at, updated_at)
+SELECT rowid, id, type, url, title, content, summary, raw_content, image_path, image_hash, archive_hash, source, structured_data, content_kind, link_status, link_checked_at, link_health, created_at, updated_at FROM items;
 
 -- Drop old table
 DROP TABLE items;
@@ -120,6 +318,9 @@ ALTER TABLE items_new RENAME TO items;
 -- Recreate indexes
 CREATE INDEX IF NOT EXISTS idx_items_type ON items(type);
 CREATE INDEX IF NOT EXISTS idx_items_created ON items(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_items_image_hash ON items(image_hash);
+CREATE INDEX IF NOT EXISTS idx_items_archive_hash ON items(archive_hash);
+CREATE INDEX IF NOT EXISTS idx_items_link_checked ON items(type, link_checked_at);
 
 -- Re-enable foreign keys
 PRAGMA foreign_keys=ON;
@@ -133,21 +334,65 @@ func RunMigrations(db *sql.DB) error {
 	// Add image_path column for existing databases (ignore error if column exists)
 	_, _ = db.Exec(migrationAddImagePath)
 
-	// Check if we need to update the CHECK constraint
-	// by checking if 'image' type is allowed
-	var count int
-	err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('items') WHERE name='type'`).Scan(&count)
-	if err == nil && count > 0 {
-		// Try inserting a test row to see if constraint allows 'image'
-		_, err := db.Exec(`INSERT INTO items (id, type, title) VALUES ('__test__', 'image', 'test')`)
-		if err != nil {
-			// Constraint doesn't allow 'image', need to migrate
-			if _, err := db.Exec(migrationUpdateTypeConstraint); err != nil {
-				return fmt.Errorf("update type constraint: %w", err)
-			}
-		} else {
-			// Clean up test row
-			_, _ = db.Exec(`DELETE FROM items WHERE id = '__test__'`)
+	// Add image_hash column for existing databases (ignore error if column exists)
+	_, _ = db.Exec(migrationAddImageHash)
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_items_image_hash ON items(image_hash)`); err != nil {
+		return fmt.Errorf("create image hash index: %w", err)
+	}
+
+	// Add source column for existing databases (ignore error if column exists)
+	_, _ = db.Exec(migrationAddSource)
+
+	// Add structured_data column for existing databases (ignore error if column exists)
+	_, _ = db.Exec(migrationAddStructuredData)
+
+	// Add archive_hash column for existing databases (ignore error if column exists)
+	_, _ = db.Exec(migrationAddArchiveHash)
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_items_archive_hash ON items(archive_hash)`); err != nil {
+		return fmt.Errorf("create archive hash index: %w", err)
+	}
+
+	// Add content_kind column for existing databases (ignore error if column exists)
+	_, _ = db.Exec(migrationAddContentKind)
+
+	// Add link health columns for existing databases (ignore errors if they exist)
+	for _, stmt := range migrationAddLinkHealth {
+		_, _ = db.Exec(stmt)
+	}
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_items_link_checked ON items(type, link_checked_at)`); err != nil {
+		return fmt.Errorf("create link check index: %w", err)
+	}
+
+	// Add blob hold columns for existing databases (ignore errors if they exist)
+	for _, stmt := range migrationAddBlobHolds {
+		_, _ = db.Exec(stmt)
+	}
+
+	// Add blob name column for existing databases (ignore error if column exists)
+	_, _ = db.Exec(migrationAddBlobName)
+
+	if _, err := db.Exec(migrationBackfillChanges); err != nil {
+		return fmt.Errorf("backfill change log: %w", err)
+	}
+
+	// Replace FTS triggers that predate encryption support
+	var triggerSQL string
+	err := db.QueryRow(`SELECT sql FROM sqlite_master WHERE type = 'trigger' AND name = 'items_ai'`).Scan(&triggerSQL)
+	if err == nil && !strings.Contains(triggerSQL, sealedPrefix) {
+		if _, err := db.Exec(migrationDropLegacyFTSTriggers + ftsTriggersSQL); err != nil {
+			return fmt.Errorf("update fts triggers: %w", err)
+		}
+	}
+
+	// Check if we need to update the CHECK constraint by checking if the
+	// newest type ('document') is allowed. Inspect the schema rather than
+	// probing with a test row, which would land in the change log.
+	var tableSQL string
+	err = db.QueryRow(`SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'items'`).Scan(&tableSQL)
+	if err == nil && strings.Contains(tableSQL, "CHECK") && !strings.Contains(tableSQL, "'document'") {
+		// Recreating the table drops its triggers
+		if _, err := db.Exec(migrationUpdateTypeConstraint + ftsTriggersSQL + changeTriggersSQL); err != nil {
+			return fmt.Errorf("update type constraint: %w", err)
 		}
 	}
 
@@ -0,0 +1,50 @@
+package store
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMigrateTypeConstraint(t *testing.T) {
+	manager, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create manager: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = manager.Close()
+	})
+	vault, err := manager.vaultStore(1)
+	if err != nil {
+		t.Fatalf("get vault: %v", err)
+	}
+
+	item := &Item{Type: ItemTypeImage, Title: "Sunset", ImagePath: "blobs/ab/abc.png", ImageHash: "abc"}
+	if err := vault.CreateItem(item); err != nil {
+		t.Fatalf("create item: %v", err)
+	}
+	// Recreate the table with a constraint that predates documents
+	tableSQL := strings.Replace(migrationSQL[strings.Index(migrationSQL, "CREATE TABLE IF NOT EXISTS items ("):strings.Index(migrationSQL, "CREATE TABLE IF NOT EXISTS tags")],
+		"items (", "items_old (", 1)
+	tableSQL = strings.Replace(tableSQL, ", 'document'", "", 1)
+	if _, err := vault.db.Exec(tableSQL + `
+		INSERT INTO items_old (rowid, id, type, url, title, content, summary, raw_content, image_path, image_hash, created_at, updated_at)
+		SELECT rowid, id, type, url, title, content, summary, raw_content, image_path, image_hash, created_at, updated_at FROM items;
+		DROP TABLE items;
+		ALTER TABLE items_old RENAME TO items;`); err != nil {
+		t.Fatalf("downgrade schema: %v", err)
+	}
+
+	if err := RunMigrations(vault.db); err != nil {
+		t.Fatalf("run migrations: %v", err)
+	}
+	got, err := vault.GetItem(item.ID)
+	if err != nil || got == nil || got.ImageHash != "abc" {
+		t.Fatalf("item not preserved: %+v %v", got, err)
+	}
+	if results, err := vault.Search("sunset", 10); err != nil || len(results) != 1 {
+		t.Fatalf("search index broken by migration: %v %v", results, err)
+	}
+	if err := vault.CreateItem(&Item{Type: ItemTypeDocument, Title: "Paper"}); err != nil {
+		t.Fatalf("create document after migration: %v", err)
+	}
+}
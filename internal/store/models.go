@@ -5,24 +5,110 @@ import "time"
 type ItemType string
 
 const (
-	ItemTypeLink   ItemType = "link"
-	ItemTypeNote   ItemType = "note"
-	ItemTypeImage  ItemType = "image"
-	ItemTypeSearch ItemType = "search"
+	ItemTypeLink     ItemType = "link"
+	ItemTypeNote     ItemType = "note"
+	ItemTypeImage    ItemType = "image"
+	ItemTypeSearch   ItemType = "search"
+	ItemTypeDocument ItemType = "document"
 )
 
 type Item struct {
-	ID         string    `json:"id"`
-	Type       ItemType  `json:"type"`
-	URL        string    `json:"url,omitempty"`
-	Title      string    `json:"title"`
-	Content    string    `json:"content,omitempty"`
-	Summary    string    `json:"summary,omitempty"`
-	RawContent string    `json:"-"`
-	ImagePath  string    `json:"image_path,omitempty"` // relative path from user dir
-	Tags       []string  `json:"tags"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID          string          `json:"id"`
+	Type        ItemType        `json:"type"`
+	URL         string          `json:"url,omitempty"`
+	Title       string          `json:"title"`
+	Content     string          `json:"content,omitempty"`
+	Summary     string          `json:"summary,omitempty"`
+	RawContent  string          `json:"-"`
+	ImagePath   string          `json:"image_path,omitempty"` // relative path from user dir; documents keep their file here too
+	ImageHash   string          `json:"-"`                    // blob hash of the attached image or document
+	Image       *ImageInfo      `json:"image,omitempty"`
+	File        *FileInfo       `json:"file,omitempty"`    // attached non-image file, e.g. a PDF
+	ArchiveHash string          `json:"-"`                 // blob hash of the page snapshot
+	Archive     *FileInfo       `json:"archive,omitempty"` // self-contained HTML snapshot of a link
+	Source      *Source         `json:"source,omitempty"`
+	Structured  *StructuredData `json:"structured,omitempty"` // schema.org and OpenGraph metadata of a page
+	ContentKind string          `json:"-"`                    // what the extracted text is, e.g. "video transcript"; empty for articles
+	Link        *LinkHealth     `json:"link,omitempty"`       // latest check of a link's URL
+	Tags        []string        `json:"tags"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// ImageInfo describes the image blob attached to an item.
+type ImageInfo struct {
+	MIME   string `json:"mime"`
+	Size   int64  `json:"size"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// FileInfo describes a non-image blob attached to an item.
+type FileInfo struct {
+	MIME string `json:"mime"`
+	Size int64  `json:"size"`
+}
+
+// SourceKind tells how a captured message reached the vault.
+type SourceKind string
+
+const (
+	SourceForward SourceKind = "forward" // forwarded to the bot
+	SourceFeed    SourceKind = "feed"    // saved from a subscribed feed
+	SourceEmail   SourceKind = "email"   // mailed to the user's address
+)
+
+// Source records where a captured message came from, such as the channel a
+// forwarded post was first published in. Sources saved before Kind existed
+// are all forwards.
+type Source struct {
+	Kind      SourceKind `json:"kind,omitempty"`
+	Name      string     `json:"name,omitempty"`     // channel, group or sender name
+	Username  string     `json:"username,omitempty"` // public username, without the @
+	MessageID int        `json:"message_id,omitempty"`
+	URL       string     `json:"url,omitempty"`     // link to the original post
+	FeedID    string     `json:"feed_id,omitempty"` // subscription a feed entry was saved from
+	Date      time.Time  `json:"date,omitzero"`
+}
+
+// Feed is an RSS or Atom subscription whose new entries are saved as link
+// items.
+type Feed struct {
+	ID    string `json:"id"`
+	URL   string `json:"url"`
+	Title string `json:"title"`
+	// Tags are added to every item saved from the feed
+	Tags []string `json:"tags,omitempty"`
+	// Keywords filter entries by title and summary: an entry must contain
+	// one of them, and none of those prefixed with "-"
+	Keywords  []string `json:"keywords,omitempty"`
+	MaxPerDay int      `json:"max_per_day,omitempty"` // 0 means unlimited
+	// ETag and LastModified make polls conditional
+	ETag         string    `json:"-"`
+	LastModified string    `json:"-"`
+	LastPolledAt time.Time `json:"last_polled_at,omitzero"`
+	LastError    string    `json:"last_error,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Link states recorded by the link checker.
+const (
+	LinkOK          = "ok"
+	LinkRedirected  = "redirected"  // answers, but at another URL
+	LinkUnreachable = "unreachable" // failing, not yet declared dead
+	LinkDead        = "dead"
+)
+
+// LinkHealth is the result of the latest check of a link item's URL.
+type LinkHealth struct {
+	Status     string    `json:"status"`
+	StatusCode int       `json:"status_code,omitempty"`
+	FinalURL   string    `json:"final_url,omitempty"` // where redirects ended
+	Error      string    `json:"error,omitempty"`
+	Failures   int       `json:"failures,omitempty"` // consecutive failed checks
+	CheckedAt  time.Time `json:"checked_at"`
+	DeadSince  time.Time `json:"dead_since,omitzero"`
+	WaybackURL string    `json:"wayback_url,omitempty"` // Internet Archive copy of a dead link
 }
 
 type Relationship struct {
@@ -38,3 +124,24 @@ type SearchResult struct {
 	Snippet string  `json:"snippet,omitempty"`
 	Score   float64 `json:"score"`
 }
+
+// Change log entities and operations
+const (
+	ChangeEntityItem         = "item"
+	ChangeEntityTag          = "tag"
+	ChangeEntityRelationship = "relationship"
+
+	ChangeOpUpsert = "upsert"
+	ChangeOpDelete = "delete"
+)
+
+// Change is the latest change to one entity. Upserts carry the entity's
+// current state; tags are identified by name and carry no payload.
+type Change struct {
+	Seq          int64         `json:"seq"`
+	Entity       string        `json:"entity"`
+	EntityID     string        `json:"id"`
+	Op           string        `json:"op"`
+	Item         *Item         `json:"item,omitempty"`
+	Relationship *Relationship `json:"relationship,omitempty"`
+}
@@ -0,0 +1,389 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// pgMigrationSQL is the PostgreSQL schema. All users share one database;
+// every table is keyed by user_id. Files stay on disk under the data dir.
+const pgMigrationSQL = `
+CREATE TABLE IF NOT EXISTS users (
+    id BIGINT PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS items (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    id TEXT NOT NULL,
+    type TEXT NOT NULL CHECK(type IN ('link', 'note', 'image', 'search', 'document')),
+    url TEXT,
+    title TEXT NOT NULL,
+    content TEXT,
+    summary TEXT,
+    raw_content TEXT,
+    image_path TEXT,
+    image_hash TEXT,
+    archive_hash TEXT,
+    source TEXT,
+    structured_data TEXT,
+    content_kind TEXT,
+    link_status TEXT,
+    link_checked_at TIMESTAMPTZ,
+    link_health TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    -- Encrypted values are left out of the generated index, see secure_vector
+    search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('simple', CASE WHEN content LIKE '` + sealedPrefix + `%' THEN '' ELSE coalesce(content, '') END), 'B') ||
+        setweight(to_tsvector('simple', CASE WHEN summary LIKE '` + sealedPrefix + `%' THEN '' ELSE coalesce(summary, '') END), 'B')
+    ) STORED,
+    -- Tokens of encrypted content, written by the store from plaintext
+    secure_vector TSVECTOR,
+    PRIMARY KEY (user_id, id)
+);
+
+CREATE TABLE IF NOT EXISTS tags (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS item_tags (
+    user_id BIGINT NOT NULL,
+    item_id TEXT NOT NULL,
+    tag_id BIGINT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, item_id, tag_id),
+    FOREIGN KEY (user_id, item_id) REFERENCES items(user_id, id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS relationships (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    source_id TEXT NOT NULL,
+    target_id TEXT NOT NULL,
+    relation_type TEXT NOT NULL,
+    strength DOUBLE PRECISION DEFAULT 1.0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (user_id, source_id, target_id, relation_type),
+    FOREIGN KEY (user_id, source_id) REFERENCES items(user_id, id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id, target_id) REFERENCES items(user_id, id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS blobs (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    hash TEXT NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    ext TEXT NOT NULL,
+    mime TEXT NOT NULL,
+    size BIGINT NOT NULL,
+    width INTEGER NOT NULL DEFAULT 0,
+    height INTEGER NOT NULL DEFAULT 0,
+    has_thumb BOOLEAN NOT NULL DEFAULT false,
+    refcount INTEGER NOT NULL DEFAULT 0,
+    holds INTEGER NOT NULL DEFAULT 0,
+    held_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, hash)
+);
+
+CREATE TABLE IF NOT EXISTS settings (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    key TEXT NOT NULL,
+    value TEXT NOT NULL,
+    PRIMARY KEY (user_id, key)
+);
+
+CREATE TABLE IF NOT EXISTS feeds (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    id TEXT NOT NULL,
+    url TEXT NOT NULL,
+    title TEXT NOT NULL DEFAULT '',
+    tags TEXT NOT NULL DEFAULT '[]',
+    keywords TEXT NOT NULL DEFAULT '[]',
+    max_per_day INTEGER NOT NULL DEFAULT 0,
+    etag TEXT NOT NULL DEFAULT '',
+    last_modified TEXT NOT NULL DEFAULT '',
+    last_polled_at TIMESTAMPTZ,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, id),
+    UNIQUE (user_id, url)
+);
+
+CREATE TABLE IF NOT EXISTS feed_entries (
+    user_id BIGINT NOT NULL,
+    feed_id TEXT NOT NULL,
+    guid TEXT NOT NULL,
+    captured BOOLEAN NOT NULL DEFAULT false,
+    seen_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, feed_id, guid),
+    FOREIGN KEY (user_id, feed_id) REFERENCES feeds(user_id, id) ON DELETE CASCADE
+);
+
+-- Change log for incremental sync. seq is shared by all users. No foreign
+-- key: rows are written while a user's items cascade away.
+CREATE TABLE IF NOT EXISTS changes (
+    seq BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    entity TEXT NOT NULL,
+    entity_id TEXT NOT NULL,
+    op TEXT NOT NULL CHECK(op IN ('upsert', 'delete')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Records item, tag and relationship writes in the change log. Tag
+-- assignments count as a change to the item. The per-user lock is held until
+-- commit, so a user's seq values become visible in order and a client never
+-- skips past a change that commits late.
+CREATE OR REPLACE FUNCTION log_change() RETURNS trigger AS $$
+DECLARE
+    r RECORD;
+    change_op TEXT := 'upsert';
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        r := OLD;
+        change_op := 'delete';
+    ELSE
+        r := NEW;
+    END IF;
+    PERFORM pg_advisory_xact_lock(r.user_id);
+    CASE TG_TABLE_NAME
+    WHEN 'items' THEN
+        INSERT INTO changes (user_id, entity, entity_id, op) VALUES (r.user_id, 'item', r.id, change_op);
+    WHEN 'item_tags' THEN
+        INSERT INTO changes (user_id, entity, entity_id, op) VALUES (r.user_id, 'item', r.item_id, 'upsert');
+    WHEN 'tags' THEN
+        INSERT INTO changes (user_id, entity, entity_id, op) VALUES (r.user_id, 'tag', r.name, change_op);
+    WHEN 'relationships' THEN
+        INSERT INTO changes (user_id, entity, entity_id, op) VALUES (r.user_id, 'relationship', r.id::text, change_op);
+    END CASE;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS items_changes ON items;
+CREATE TRIGGER items_changes AFTER INSERT OR UPDATE OR DELETE ON items
+    FOR EACH ROW EXECUTE FUNCTION log_change();
+DROP TRIGGER IF EXISTS item_tags_changes ON item_tags;
+CREATE TRIGGER item_tags_changes AFTER INSERT OR DELETE ON item_tags
+    FOR EACH ROW EXECUTE FUNCTION log_change();
+DROP TRIGGER IF EXISTS tags_changes ON tags;
+CREATE TRIGGER tags_changes AFTER INSERT OR DELETE ON tags
+    FOR EACH ROW EXECUTE FUNCTION log_change();
+DROP TRIGGER IF EXISTS relationships_changes ON relationships;
+CREATE TRIGGER relationships_changes AFTER INSERT OR UPDATE OR DELETE ON relationships
+    FOR EACH ROW EXECUTE FUNCTION log_change();
+
+-- Indexes
+CREATE INDEX IF NOT EXISTS idx_items_created ON items(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_items_image_hash ON items(user_id, image_hash);
+CREATE INDEX IF NOT EXISTS idx_items_search ON items USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_items_secure_search ON items USING GIN (secure_vector);
+CREATE INDEX IF NOT EXISTS idx_relationships_source ON relationships(user_id, source_id);
+CREATE INDEX IF NOT EXISTS idx_relationships_target ON relationships(user_id, target_id);
+CREATE INDEX IF NOT EXISTS idx_changes_user ON changes(user_id, seq);
+
+-- Columns added after the first release
+ALTER TABLE items ADD COLUMN IF NOT EXISTS source TEXT;
+ALTER TABLE items ADD COLUMN IF NOT EXISTS structured_data TEXT;
+ALTER TABLE items ADD COLUMN IF NOT EXISTS archive_hash TEXT;
+CREATE INDEX IF NOT EXISTS idx_items_archive_hash ON items(user_id, archive_hash);
+ALTER TABLE items ADD COLUMN IF NOT EXISTS content_kind TEXT;
+ALTER TABLE items ADD COLUMN IF NOT EXISTS link_status TEXT;
+ALTER TABLE items ADD COLUMN IF NOT EXISTS link_checked_at TIMESTAMPTZ;
+ALTER TABLE items ADD COLUMN IF NOT EXISTS link_health TEXT;
+CREATE INDEX IF NOT EXISTS idx_items_link_checked ON items(user_id, type, link_checked_at);
+ALTER TABLE blobs ADD COLUMN IF NOT EXISTS holds INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE blobs ADD COLUMN IF NOT EXISTS held_at TIMESTAMPTZ;
+ALTER TABLE blobs ADD COLUMN IF NOT EXISTS name TEXT NOT NULL DEFAULT '';
+
+-- Widen the item type constraint on databases created before documents
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conrelid = 'items'::regclass AND conname = 'items_type_check'
+          AND pg_get_constraintdef(oid) LIKE '%document%'
+    ) THEN
+        ALTER TABLE items DROP CONSTRAINT IF EXISTS items_type_check;
+        ALTER TABLE items ADD CONSTRAINT items_type_check
+            CHECK(type IN ('link', 'note', 'image', 'search', 'document'));
+    END IF;
+END $$;
+`
+
+// pgBackfillChanges seeds a user's change log with rows that predate it,
+// items before the relationships between them.
+const pgBackfillChanges = `
+INSERT INTO changes (user_id, entity, entity_id, op)
+SELECT $1::bigint, entity, entity_id, 'upsert' FROM (
+    SELECT 0 AS ord, 'item' AS entity, id AS entity_id, created_at FROM items WHERE user_id = $1::bigint
+    UNION ALL SELECT 1, 'tag', name, NULL FROM tags WHERE user_id = $1::bigint
+    UNION ALL SELECT 2, 'relationship', id::text, created_at FROM relationships WHERE user_id = $1::bigint
+) existing
+WHERE NOT EXISTS (SELECT 1 FROM changes WHERE user_id = $1::bigint)
+ORDER BY ord, created_at`
+
+// PGVault is the PostgreSQL Vault backend: a view of the shared database
+// restricted to one user's rows.
+type PGVault struct {
+	fileStore
+	db         *sql.DB
+	userID     int64
+	searchMode SearchIndexMode
+}
+
+// PGManager is the PostgreSQL Stores backend.
+type PGManager struct {
+	encryption
+	dataDir string
+	db      *sql.DB
+	vaults  map[int64]*PGVault
+	mu      sync.RWMutex
+}
+
+var (
+	_ Stores = (*PGManager)(nil)
+	_ Vault  = (*PGVault)(nil)
+)
+
+// NewPGManager connects to PostgreSQL and applies the schema. Files are
+// stored under dataDir exactly as with the SQLite backend.
+func NewPGManager(dsn, dataDir string) (*PGManager, error) {
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, fmt.Errorf("create data dir: %w", err)
+	}
+
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open postgres: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("connect postgres: %w", err)
+	}
+	if _, err := db.Exec(pgMigrationSQL); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("run migrations: %w", err)
+	}
+
+	return &PGManager{
+		dataDir: dataDir,
+		db:      db,
+		vaults:  make(map[int64]*PGVault),
+	}, nil
+}
+
+// EnableEncryption turns on encryption at rest; see Manager.EnableEncryption.
+func (m *PGManager) EnableEncryption(masterKey string, searchMode SearchIndexMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.vaults) > 0 {
+		return fmt.Errorf("encryption must be enabled before vaults are opened")
+	}
+	return m.enable(masterKey, searchMode)
+}
+
+func (m *PGManager) GetVault(userID int64) (Vault, error) {
+	v, err := m.vaultStore(userID)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (m *PGManager) vaultStore(userID int64) (*PGVault, error) {
+	m.mu.RLock()
+	if v, ok := m.vaults[userID]; ok {
+		m.mu.RUnlock()
+		return v, nil
+	}
+	m.mu.RUnlock()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if v, ok := m.vaults[userID]; ok {
+		return v, nil
+	}
+
+	userDir := m.UserDir(userID)
+	if err := os.MkdirAll(userDir, 0755); err != nil {
+		return nil, fmt.Errorf("create user dir: %w", err)
+	}
+	if _, err := m.db.Exec(`INSERT INTO users (id) VALUES ($1) ON CONFLICT DO NOTHING`, userID); err != nil {
+		return nil, fmt.Errorf("register user: %w", err)
+	}
+	if _, err := m.db.Exec(pgBackfillChanges, userID); err != nil {
+		return nil, fmt.Errorf("backfill change log: %w", err)
+	}
+	cipher, err := m.cipherFor(userID)
+	if err != nil {
+		return nil, fmt.Errorf("init vault cipher: %w", err)
+	}
+
+	vault := &PGVault{
+		fileStore:  fileStore{dir: userDir, cipher: cipher},
+		db:         m.db,
+		userID:     userID,
+		searchMode: m.searchMode,
+	}
+	m.vaults[userID] = vault
+	return vault, nil
+}
+
+func (m *PGManager) Close() error {
+	return m.db.Close()
+}
+
+// UserDir returns the directory path for a specific user
+func (m *PGManager) UserDir(userID int64) string {
+	return filepath.Join(m.dataDir, "users", fmt.Sprintf("%d", userID))
+}
+
+// UserIDs returns the IDs of all users that have a vault.
+func (m *PGManager) UserIDs() ([]int64, error) {
+	rows, err := m.db.Query(`SELECT id FROM users ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("query users: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan user: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetSetting retrieves a setting value by key.
+func (v *PGVault) GetSetting(key string) (string, error) {
+	var value string
+	err := v.db.QueryRow(`SELECT value FROM settings WHERE user_id = $1 AND key = $2`, v.userID, key).Scan(&value)
+	if err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// SetSetting stores a setting value (upsert).
+func (v *PGVault) SetSetting(key, value string) error {
+	_, err := v.db.Exec(`
+		INSERT INTO settings (user_id, key, value) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, key) DO UPDATE SET value = excluded.value`,
+		v.userID, key, value,
+	)
+	return err
+}
@@ -0,0 +1,927 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// pgItemFromClause joins image and archive blob metadata onto a user's items.
+const pgItemFromClause = `items i
+	LEFT JOIN blobs b ON b.user_id = i.user_id AND b.hash = i.image_hash
+	LEFT JOIN blobs ab ON ab.user_id = i.user_id AND ab.hash = i.archive_hash`
+
+func (v *PGVault) scanItems(rows *sql.Rows) ([]Item, error) {
+	var items []Item
+	for rows.Next() {
+		var item Item
+		var cols itemColumns
+		if err := rows.Scan(cols.dest(&item)...); err != nil {
+			return nil, fmt.Errorf("scan item: %w", err)
+		}
+		if err := v.fillItem(&item, &cols); err != nil {
+			return nil, err
+		}
+		tags, _ := v.getItemTags(item.ID)
+		item.Tags = tags
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// secureVector returns the token index value for an encrypted item, or nil
+// when content is stored in plaintext or the index is disabled.
+func (v *PGVault) secureVector(item *Item) sql.NullString {
+	if v.cipher == nil || v.searchMode == SearchIndexOff {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: item.Content + " " + item.Summary, Valid: true}
+}
+
+func (v *PGVault) CreateItem(item *Item) error {
+	if item.ID == "" {
+		item.ID = uuid.NewString()
+	}
+	item.CreatedAt = time.Now()
+	item.UpdatedAt = item.CreatedAt
+
+	content, summary, rawContent, err := v.sealItem(item)
+	if err != nil {
+		return fmt.Errorf("encrypt item: %w", err)
+	}
+	source, err := sealJSON(&v.fileStore, item.Source)
+	if err != nil {
+		return fmt.Errorf("encrypt source: %w", err)
+	}
+	structured, err := sealJSON(&v.fileStore, item.Structured)
+	if err != nil {
+		return fmt.Errorf("encrypt structured data: %w", err)
+	}
+
+	tx, err := v.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO items (user_id, id, type, url, title, content, summary, raw_content, image_path, image_hash,
+			archive_hash, source, structured_data, content_kind, created_at, updated_at, secure_vector)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, to_tsvector('simple', $17::text))`,
+		v.userID, item.ID, item.Type, item.URL, item.Title, content, summary, rawContent, item.ImagePath,
+		nullString(item.ImageHash), nullString(item.ArchiveHash), source, structured, nullString(item.ContentKind),
+		item.CreatedAt, item.UpdatedAt, v.secureVector(item),
+	)
+	if err != nil {
+		return fmt.Errorf("insert item: %w", err)
+	}
+
+	for _, hash := range []string{item.ImageHash, item.ArchiveHash} {
+		if hash == "" {
+			continue
+		}
+		if _, err := tx.Exec(`
+			UPDATE blobs SET refcount = refcount + 1, holds = GREATEST(holds - 1, 0)
+			WHERE user_id = $1 AND hash = $2`,
+			v.userID, hash); err != nil {
+			return fmt.Errorf("retain blob: %w", err)
+		}
+	}
+
+	if err := v.setItemTags(tx, item.ID, item.Tags); err != nil {
+		return fmt.Errorf("set tags: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (v *PGVault) UpdateItem(item *Item) error {
+	item.UpdatedAt = time.Now()
+
+	content, summary, rawContent, err := v.sealItem(item)
+	if err != nil {
+		return fmt.Errorf("encrypt item: %w", err)
+	}
+
+	tx, err := v.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE items SET url = $1, title = $2, content = $3, summary = $4,
+			raw_content = COALESCE(NULLIF($5::text, ''), raw_content), content_kind = $6, updated_at = $7,
+			secure_vector = to_tsvector('simple', $8::text)
+		WHERE user_id = $9 AND id = $10`,
+		item.URL, item.Title, content, summary, rawContent, nullString(item.ContentKind), item.UpdatedAt,
+		v.secureVector(item), v.userID, item.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("update item: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("item %s not found", item.ID)
+	}
+
+	if err := v.setItemTags(tx, item.ID, item.Tags); err != nil {
+		return fmt.Errorf("set tags: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (v *PGVault) GetRawContent(id string) (string, error) {
+	var raw sql.NullString
+	err := v.db.QueryRow(`SELECT raw_content FROM items WHERE user_id = $1 AND id = $2`, v.userID, id).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("query raw content: %w", err)
+	}
+	return v.cipher.openString(raw.String)
+}
+
+func (v *PGVault) GetItem(id string) (*Item, error) {
+	item := &Item{}
+	var cols itemColumns
+	err := v.db.QueryRow(`
+		SELECT `+itemSelectColumns+`
+		FROM `+pgItemFromClause+` WHERE i.user_id = $1 AND i.id = $2`, v.userID, id,
+	).Scan(cols.dest(item)...)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query item: %w", err)
+	}
+	if err := v.fillItem(item, &cols); err != nil {
+		return nil, err
+	}
+
+	tags, err := v.getItemTags(item.ID)
+	if err != nil {
+		return nil, fmt.Errorf("get tags: %w", err)
+	}
+	item.Tags = tags
+	return item, nil
+}
+
+func (v *PGVault) ListItems(limit, offset int) ([]Item, error) {
+	rows, err := v.db.Query(`
+		SELECT `+itemSelectColumns+`
+		FROM `+pgItemFromClause+` WHERE i.user_id = $1
+		ORDER BY i.created_at DESC LIMIT $2 OFFSET $3`, v.userID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("query items: %w", err)
+	}
+	defer rows.Close()
+
+	return v.scanItems(rows)
+}
+
+func (v *PGVault) ListItemsByTag(tag string, limit, offset int) ([]Item, error) {
+	rows, err := v.db.Query(`
+		SELECT `+itemSelectColumns+`
+		FROM `+pgItemFromClause+`
+		JOIN item_tags it ON it.user_id = i.user_id AND it.item_id = i.id
+		JOIN tags t ON it.tag_id = t.id
+		WHERE i.user_id = $1 AND t.name = $2
+		ORDER BY i.created_at DESC LIMIT $3 OFFSET $4`, v.userID, tag, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("query items by tag: %w", err)
+	}
+	defer rows.Close()
+
+	return v.scanItems(rows)
+}
+
+// Search runs a full-text query using PostgreSQL web search syntax. Plaintext
+// content is matched through search_vector, encrypted content through
+// secure_vector. Score is the negated rank so lower is better, as with bm25.
+func (v *PGVault) Search(query string, limit int) ([]SearchResult, error) {
+	rows, err := v.db.Query(`
+		SELECT `+itemSelectColumns+`,
+		       CASE WHEN i.search_vector @@ q THEN
+		           ts_headline('simple', coalesce(i.content, ''), q,
+		               'StartSel=<mark>, StopSel=</mark>, MaxWords=32, MinWords=8')
+		       ELSE '' END AS snippet,
+		       -ts_rank(i.search_vector || coalesce(i.secure_vector, ''::tsvector), q) AS score
+		FROM `+pgItemFromClause+`, websearch_to_tsquery('simple', $2) q
+		WHERE i.user_id = $1 AND (i.search_vector @@ q OR i.secure_vector @@ q)
+		ORDER BY score
+		LIMIT $3`, v.userID, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var r SearchResult
+		var cols itemColumns
+		var snippet sql.NullString
+		if err := rows.Scan(append(cols.dest(&r.Item), &snippet, &r.Score)...); err != nil {
+			return nil, fmt.Errorf("scan result: %w", err)
+		}
+		if !isSealed(cols.content.String) {
+			r.Snippet = snippet.String
+		}
+		if err := v.fillItem(&r.Item, &cols); err != nil {
+			return nil, err
+		}
+		tags, _ := v.getItemTags(r.Item.ID)
+		r.Item.Tags = tags
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// DeleteItem removes an item, its image and its page snapshot; see
+// VaultStore.DeleteItem.
+func (v *PGVault) DeleteItem(id string) error {
+	tx, err := v.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var imagePath, imageHash, archiveHash sql.NullString
+	err = tx.QueryRow(`DELETE FROM items WHERE user_id = $1 AND id = $2 RETURNING image_path, image_hash, archive_hash`,
+		v.userID, id).Scan(&imagePath, &imageHash, &archiveHash)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("delete item: %w", err)
+	}
+
+	var orphans []*Blob
+	for _, hash := range []string{imageHash.String, archiveHash.String} {
+		if hash == "" {
+			continue
+		}
+		b := &Blob{Hash: hash}
+		var holds int
+		err := tx.QueryRow(`
+			UPDATE blobs SET refcount = refcount - 1 WHERE user_id = $1 AND hash = $2
+			RETURNING name, ext, refcount, holds`, v.userID, b.Hash).Scan(&b.name, &b.Ext, &b.RefCount, &holds)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("release blob: %w", err)
+		}
+		if err == nil && b.RefCount <= 0 && holds <= 0 {
+			if _, err := tx.Exec(`DELETE FROM blobs WHERE user_id = $1 AND hash = $2`, v.userID, b.Hash); err != nil {
+				return fmt.Errorf("release blob: %w", err)
+			}
+			orphans = append(orphans, b)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	for _, orphan := range orphans {
+		v.removeBlobFiles(v, orphan)
+	}
+	if imageHash.String == "" && imagePath.String != "" {
+		_ = os.Remove(filepath.Join(v.dir, imagePath.String))
+	}
+	return nil
+}
+
+func (v *PGVault) setItemTags(tx *sql.Tx, itemID string, tags []string) error {
+	_, err := tx.Exec(`DELETE FROM item_tags WHERE user_id = $1 AND item_id = $2`, v.userID, itemID)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			continue
+		}
+		_, err := tx.Exec(`INSERT INTO tags (user_id, name) VALUES ($1, $2) ON CONFLICT DO NOTHING`, v.userID, tag)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			INSERT INTO item_tags (user_id, item_id, tag_id)
+			SELECT $1, $2, id FROM tags WHERE user_id = $1 AND name = $3
+			ON CONFLICT DO NOTHING`, v.userID, itemID, tag)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *PGVault) getItemTags(itemID string) ([]string, error) {
+	rows, err := v.db.Query(`
+		SELECT t.name FROM tags t
+		JOIN item_tags it ON t.id = it.tag_id
+		WHERE it.user_id = $1 AND it.item_id = $2`, v.userID, itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []string
+	for rows.Next() {
+		var tag string
+		rows.Scan(&tag)
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+func (v *PGVault) GetAllTags() ([]string, error) {
+	rows, err := v.db.Query(`SELECT name FROM tags WHERE user_id = $1 ORDER BY name`, v.userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []string
+	for rows.Next() {
+		var tag string
+		rows.Scan(&tag)
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+func (v *PGVault) ItemCount() (int, error) {
+	var count int
+	err := v.db.QueryRow(`SELECT COUNT(*) FROM items WHERE user_id = $1`, v.userID).Scan(&count)
+	return count, err
+}
+
+func (v *PGVault) CreateRelationship(rel *Relationship) error {
+	_, err := v.db.Exec(`
+		INSERT INTO relationships (user_id, source_id, target_id, relation_type, strength)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, source_id, target_id, relation_type) DO UPDATE SET strength = excluded.strength`,
+		v.userID, rel.SourceID, rel.TargetID, rel.RelationType, rel.Strength)
+	return err
+}
+
+func (v *PGVault) GetRelationships(itemID string) ([]Relationship, error) {
+	rows, err := v.db.Query(`
+		SELECT id, source_id, target_id, relation_type, strength
+		FROM relationships
+		WHERE user_id = $1 AND (source_id = $2 OR target_id = $2)`, v.userID, itemID)
+	if err != nil {
+		return nil, fmt.Errorf("query relationships: %w", err)
+	}
+	defer rows.Close()
+
+	var rels []Relationship
+	for rows.Next() {
+		var r Relationship
+		if err := rows.Scan(&r.ID, &r.SourceID, &r.TargetID, &r.RelationType, &r.Strength); err != nil {
+			return nil, err
+		}
+		rels = append(rels, r)
+	}
+	return rels, nil
+}
+
+// GetGraph returns all items and relationships for graph visualization
+func (v *PGVault) GetGraph() ([]Item, []Relationship, error) {
+	items, err := v.ListItems(1000, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := v.db.Query(`
+		SELECT id, source_id, target_id, relation_type, strength
+		FROM relationships WHERE user_id = $1`, v.userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	rels, err := scanGraphRelationships(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+	return items, rels, nil
+}
+
+// Changes returns the latest change to each entity after seq since; see
+// VaultStore.Changes.
+func (v *PGVault) Changes(since int64, limit int) ([]Change, error) {
+	rows, err := v.db.Query(`
+		SELECT c.seq, c.entity, c.entity_id, c.op FROM changes c
+		JOIN (
+			SELECT MAX(seq) AS seq FROM changes WHERE user_id = $1 AND seq > $2 GROUP BY entity, entity_id
+		) latest ON latest.seq = c.seq
+		ORDER BY c.seq LIMIT $3`, v.userID, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query changes: %w", err)
+	}
+	changes, err := scanChanges(rows)
+	if err != nil {
+		return nil, err
+	}
+	return changes, loadChanges(v, changes)
+}
+
+func (v *PGVault) getRelationship(id int64) (*Relationship, error) {
+	r := &Relationship{}
+	err := v.db.QueryRow(`
+		SELECT id, source_id, target_id, relation_type, strength
+		FROM relationships WHERE user_id = $1 AND id = $2`, v.userID, id,
+	).Scan(&r.ID, &r.SourceID, &r.TargetID, &r.RelationType, &r.Strength)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query relationship: %w", err)
+	}
+	return r, nil
+}
+
+func (v *PGVault) DeleteRelationship(sourceID, targetID string) error {
+	_, err := v.db.Exec(`DELETE FROM relationships WHERE user_id = $1 AND source_id = $2 AND target_id = $3`,
+		v.userID, sourceID, targetID)
+	return err
+}
+
+// PutImage stores image bytes in the blob store; see putImage.
+func (v *PGVault) PutImage(data []byte, ext string) (*Blob, error) {
+	return v.putImage(v, data, ext)
+}
+
+func (v *PGVault) PutFile(data []byte, ext, mimeType string) (*Blob, error) {
+	return v.putFile(v, data, ext, mimeType)
+}
+
+func (v *PGVault) insertBlob(blob *Blob) error {
+	_, err := v.db.Exec(`
+		INSERT INTO blobs (user_id, hash, name, ext, mime, size, width, height, has_thumb, refcount, holds, held_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, 0, 1, $10, $10)
+		ON CONFLICT (user_id, hash) DO UPDATE SET holds = blobs.holds + 1, held_at = EXCLUDED.held_at`,
+		v.userID, blob.Hash, blob.name, blob.Ext, blob.MIME, blob.Size, blob.Width, blob.Height, blob.HasThumb, blob.CreatedAt)
+	return err
+}
+
+func (v *PGVault) holdBlob(hash string) (*Blob, error) {
+	b := &Blob{}
+	err := v.db.QueryRow(`
+		UPDATE blobs SET holds = holds + 1, held_at = $1 WHERE user_id = $2 AND hash = $3
+		RETURNING hash, name, ext, mime, size, width, height, has_thumb, refcount, created_at`, time.Now(), v.userID, hash,
+	).Scan(&b.Hash, &b.name, &b.Ext, &b.MIME, &b.Size, &b.Width, &b.Height, &b.HasThumb, &b.RefCount, &b.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("hold blob: %w", err)
+	}
+	return b, nil
+}
+
+// GetBlob returns blob metadata by hash, or nil if unknown.
+func (v *PGVault) GetBlob(hash string) (*Blob, error) {
+	b := &Blob{}
+	err := v.db.QueryRow(`
+		SELECT hash, name, ext, mime, size, width, height, has_thumb, refcount, created_at
+		FROM blobs WHERE user_id = $1 AND hash = $2`, v.userID, hash,
+	).Scan(&b.Hash, &b.name, &b.Ext, &b.MIME, &b.Size, &b.Width, &b.Height, &b.HasThumb, &b.RefCount, &b.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query blob: %w", err)
+	}
+	return b, nil
+}
+
+// CollectGarbage removes files in the vault directory that no item or blob
+// references.
+func (v *PGVault) CollectGarbage(opts GCOptions) (*GCReport, error) {
+	return v.collectGarbage(v, opts)
+}
+
+func (v *PGVault) recountBlobs() error {
+	_, err := v.db.Exec(`
+		UPDATE blobs SET refcount = (
+			SELECT COUNT(*) FROM items i WHERE i.user_id = blobs.user_id AND i.image_hash = blobs.hash) + (
+			SELECT COUNT(*) FROM items i WHERE i.user_id = blobs.user_id AND i.archive_hash = blobs.hash)
+		WHERE user_id = $1`, v.userID)
+	return err
+}
+
+func (v *PGVault) referencedFiles(cutoff time.Time) (map[string]struct{}, error) {
+	referenced := make(map[string]struct{})
+
+	rows, err := v.db.Query(`
+		SELECT image_path FROM items
+		WHERE user_id = $1 AND image_path IS NOT NULL AND image_path != ''`, v.userID)
+	if err != nil {
+		return nil, fmt.Errorf("query image paths: %w", err)
+	}
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan image path: %w", err)
+		}
+		referenced[filepath.ToSlash(filepath.Clean(p))] = struct{}{}
+	}
+	rows.Close()
+
+	rows, err = v.db.Query(`
+		SELECT hash, name, ext FROM blobs b
+		WHERE b.user_id = $1 AND (created_at > $2 OR (holds > 0 AND held_at > $2)
+		OR EXISTS (SELECT 1 FROM items i WHERE i.user_id = b.user_id
+			AND (i.image_hash = b.hash OR i.archive_hash = b.hash)))`,
+		v.userID, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("query blobs: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var b Blob
+		if err := rows.Scan(&b.Hash, &b.name, &b.Ext); err != nil {
+			return nil, fmt.Errorf("scan blob: %w", err)
+		}
+		referenced[b.Path()] = struct{}{}
+		referenced[b.ThumbPath()] = struct{}{}
+	}
+	return referenced, rows.Err()
+}
+
+func (v *PGVault) unreferencedBlobs(cutoff time.Time) ([]string, error) {
+	rows, err := v.db.Query(`
+		SELECT hash FROM blobs b
+		WHERE b.user_id = $1 AND created_at <= $2 AND (holds <= 0 OR held_at <= $2)
+		AND NOT EXISTS (SELECT 1 FROM items i WHERE i.user_id = b.user_id
+			AND (i.image_hash = b.hash OR i.archive_hash = b.hash))`,
+		v.userID, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("query unreferenced blobs: %w", err)
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, fmt.Errorf("scan blob: %w", err)
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, rows.Err()
+}
+
+func (v *PGVault) deleteBlobRow(hash string, cutoff time.Time) (bool, error) {
+	res, err := v.db.Exec(`
+		DELETE FROM blobs WHERE user_id = $1 AND hash = $2 AND refcount <= 0 AND (holds <= 0 OR held_at <= $3)`,
+		v.userID, hash, cutoff)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// EncryptExisting encrypts plaintext items and files; see
+// VaultStore.EncryptExisting.
+func (v *PGVault) EncryptExisting() (EncryptStats, error) {
+	var stats EncryptStats
+	if v.cipher == nil {
+		return stats, ErrNoEncryptionKey
+	}
+
+	// Rename blobs first so items are read with their new paths
+	blobs, err := v.nameBlobs()
+	stats.Blobs = blobs
+	if err != nil {
+		return stats, err
+	}
+
+	type row struct {
+		id                                    string
+		content, summary, rawContent, imgPath sql.NullString
+		archiveHash, archiveName, archiveExt  sql.NullString
+	}
+
+	rows, err := v.db.Query(`
+		SELECT i.id, i.content, i.summary, i.raw_content, i.image_path, ab.hash, ab.name, ab.ext
+		FROM items i LEFT JOIN blobs ab ON ab.user_id = i.user_id AND ab.hash = i.archive_hash
+		WHERE i.user_id = $1`, v.userID)
+	if err != nil {
+		return stats, fmt.Errorf("query items: %w", err)
+	}
+	var pending []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.content, &r.summary, &r.rawContent, &r.imgPath,
+			&r.archiveHash, &r.archiveName, &r.archiveExt); err != nil {
+			rows.Close()
+			return stats, fmt.Errorf("scan item: %w", err)
+		}
+		pending = append(pending, r)
+	}
+	rows.Close()
+
+	for _, r := range pending {
+		files := []string{r.imgPath.String}
+		if r.archiveHash.Valid {
+			files = append(files, (&Blob{Hash: r.archiveHash.String, Ext: r.archiveExt.String, name: r.archiveName.String}).Path())
+		}
+		for _, p := range files {
+			if p == "" {
+				continue
+			}
+			sealed, err := v.encryptFile(p)
+			if err != nil {
+				slog.Warn("failed to encrypt file", "item_id", r.id, "path", p, "error", err)
+			} else if sealed {
+				stats.Files++
+			}
+		}
+
+		if !needsSealing(r.content.String) && !needsSealing(r.summary.String) && !needsSealing(r.rawContent.String) {
+			continue
+		}
+
+		plain := &Item{}
+		cols := itemColumns{content: r.content, summary: r.summary}
+		if err := v.fillItem(plain, &cols); err != nil {
+			return stats, err
+		}
+		if plain.RawContent, err = v.cipher.openString(r.rawContent.String); err != nil {
+			return stats, fmt.Errorf("open raw content of %s: %w", r.id, err)
+		}
+		content, summary, rawContent, err := v.sealItem(plain)
+		if err != nil {
+			return stats, fmt.Errorf("encrypt item %s: %w", r.id, err)
+		}
+
+		if _, err := v.db.Exec(`
+			UPDATE items SET content = $1, summary = $2, raw_content = $3, secure_vector = to_tsvector('simple', $4::text)
+			WHERE user_id = $5 AND id = $6`,
+			content, summary, rawContent, v.secureVector(plain), v.userID, r.id); err != nil {
+			return stats, fmt.Errorf("update item %s: %w", r.id, err)
+		}
+		stats.Items++
+	}
+
+	rows, err = v.db.Query(`SELECT hash, name, ext FROM blobs WHERE user_id = $1 AND has_thumb`, v.userID)
+	if err != nil {
+		return stats, fmt.Errorf("query blobs: %w", err)
+	}
+	var thumbs []string
+	for rows.Next() {
+		var b Blob
+		if err := rows.Scan(&b.Hash, &b.name, &b.Ext); err != nil {
+			rows.Close()
+			return stats, fmt.Errorf("scan blob: %w", err)
+		}
+		thumbs = append(thumbs, b.ThumbPath())
+	}
+	rows.Close()
+
+	for _, p := range thumbs {
+		sealed, err := v.encryptFile(p)
+		if err != nil {
+			slog.Warn("failed to encrypt thumbnail", "path", p, "error", err)
+		} else if sealed {
+			stats.Files++
+		}
+	}
+
+	return stats, nil
+}
+
+// nameBlobs moves blobs stored before encryption was enabled to keyed file
+// names; see VaultStore.nameBlobs.
+func (v *PGVault) nameBlobs() (int, error) {
+	rows, err := v.db.Query(`SELECT hash, ext FROM blobs WHERE user_id = $1 AND name = ''`, v.userID)
+	if err != nil {
+		return 0, fmt.Errorf("query blobs: %w", err)
+	}
+	var pending []Blob
+	for rows.Next() {
+		var b Blob
+		if err := rows.Scan(&b.Hash, &b.Ext); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan blob: %w", err)
+		}
+		pending = append(pending, b)
+	}
+	rows.Close()
+
+	v.blobMu.Lock()
+	defer v.blobMu.Unlock()
+	for i, b := range pending {
+		renamed := b
+		renamed.name = v.cipher.blobName(b.Hash)
+		if err := v.moveBlobFiles(&b, &renamed); err != nil {
+			return i, err
+		}
+		tx, err := v.db.Begin()
+		if err != nil {
+			return i, fmt.Errorf("begin tx: %w", err)
+		}
+		if _, err := tx.Exec(`UPDATE blobs SET name = $1 WHERE user_id = $2 AND hash = $3`,
+			renamed.name, v.userID, b.Hash); err != nil {
+			tx.Rollback()
+			return i, fmt.Errorf("rename blob %s: %w", b.Hash, err)
+		}
+		if _, err := tx.Exec(`UPDATE items SET image_path = $1 WHERE user_id = $2 AND image_hash = $3`,
+			renamed.Path(), v.userID, b.Hash); err != nil {
+			tx.Rollback()
+			return i, fmt.Errorf("update paths of blob %s: %w", b.Hash, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return i, fmt.Errorf("commit blob %s: %w", b.Hash, err)
+		}
+	}
+	return len(pending), nil
+}
+
+// LinksToCheck returns link items due for a check; see
+// VaultStore.LinksToCheck.
+func (v *PGVault) LinksToCheck(checkedBefore time.Time, limit int) ([]Item, error) {
+	rows, err := v.db.Query(`
+		SELECT `+itemSelectColumns+`
+		FROM `+pgItemFromClause+`
+		WHERE i.user_id = $1 AND i.type = 'link' AND i.url IS NOT NULL AND i.url != ''
+		AND (i.link_checked_at IS NULL OR i.link_checked_at < $2)
+		ORDER BY i.link_checked_at NULLS FIRST LIMIT $3`, v.userID, checkedBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query links to check: %w", err)
+	}
+	defer rows.Close()
+
+	return v.scanItems(rows)
+}
+
+func (v *PGVault) ListItemsByLinkStatus(status string, limit, offset int) ([]Item, error) {
+	rows, err := v.db.Query(`
+		SELECT `+itemSelectColumns+`
+		FROM `+pgItemFromClause+` WHERE i.user_id = $1 AND i.link_status = $2
+		ORDER BY i.created_at DESC LIMIT $3 OFFSET $4`, v.userID, status, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("query items by link status: %w", err)
+	}
+	defer rows.Close()
+
+	return v.scanItems(rows)
+}
+
+func (v *PGVault) SetLinkHealth(id string, health *LinkHealth) error {
+	stored, err := sealJSON(&v.fileStore, health)
+	if err != nil {
+		return fmt.Errorf("encrypt link health: %w", err)
+	}
+	res, err := v.db.Exec(`
+		UPDATE items SET link_status = $1, link_checked_at = $2, link_health = $3
+		WHERE user_id = $4 AND id = $5`,
+		health.Status, health.CheckedAt, stored, v.userID, id)
+	if err != nil {
+		return fmt.Errorf("update link health: %w", err)
+	}
+	return requireRow(res, id)
+}
+
+// SetArchive attaches a page snapshot to an item; see VaultStore.SetArchive.
+func (v *PGVault) SetArchive(id, hash string) error {
+	tx, err := v.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var old sql.NullString
+	err = tx.QueryRow(`SELECT archive_hash FROM items WHERE user_id = $1 AND id = $2 FOR UPDATE`,
+		v.userID, id).Scan(&old)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("item %s not found", id)
+	}
+	if err != nil {
+		return fmt.Errorf("query item: %w", err)
+	}
+	if old.String == hash {
+		return nil
+	}
+
+	if _, err := tx.Exec(`UPDATE items SET archive_hash = $1, updated_at = $2 WHERE user_id = $3 AND id = $4`,
+		nullString(hash), time.Now(), v.userID, id); err != nil {
+		return fmt.Errorf("update archive: %w", err)
+	}
+	if hash != "" {
+		if _, err := tx.Exec(`
+			UPDATE blobs SET refcount = refcount + 1, holds = GREATEST(holds - 1, 0)
+			WHERE user_id = $1 AND hash = $2`,
+			v.userID, hash); err != nil {
+			return fmt.Errorf("retain archive: %w", err)
+		}
+	}
+	var orphan *Blob
+	if old.String != "" {
+		b := &Blob{Hash: old.String}
+		var holds int
+		err := tx.QueryRow(`
+			UPDATE blobs SET refcount = refcount - 1 WHERE user_id = $1 AND hash = $2
+			RETURNING name, ext, refcount, holds`, v.userID, b.Hash).Scan(&b.name, &b.Ext, &b.RefCount, &holds)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("release archive: %w", err)
+		}
+		if err == nil && b.RefCount <= 0 && holds <= 0 {
+			if _, err := tx.Exec(`DELETE FROM blobs WHERE user_id = $1 AND hash = $2`, v.userID, b.Hash); err != nil {
+				return fmt.Errorf("release archive: %w", err)
+			}
+			orphan = b
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if orphan != nil {
+		v.removeBlobFiles(v, orphan)
+	}
+	return nil
+}
+
+// CreateFeed subscribes to a feed; see VaultStore.CreateFeed.
+func (v *PGVault) CreateFeed(feed *Feed) error {
+	if feed.ID == "" {
+		feed.ID = uuid.NewString()
+	}
+	if feed.CreatedAt.IsZero() {
+		feed.CreatedAt = time.Now()
+	}
+	tags, keywords, err := feedLists(feed)
+	if err != nil {
+		return fmt.Errorf("encode feed: %w", err)
+	}
+	_, err = v.db.Exec(`
+		INSERT INTO feeds (user_id, id, url, title, tags, keywords, max_per_day, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		v.userID, feed.ID, feed.URL, feed.Title, tags, keywords, feed.MaxPerDay, feed.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert feed: %w", err)
+	}
+	return nil
+}
+
+func (v *PGVault) ListFeeds() ([]Feed, error) {
+	rows, err := v.db.Query(`SELECT `+feedSelectColumns+` FROM feeds WHERE user_id = $1 ORDER BY created_at`, v.userID)
+	if err != nil {
+		return nil, fmt.Errorf("query feeds: %w", err)
+	}
+	defer rows.Close()
+
+	return scanFeeds(rows)
+}
+
+func (v *PGVault) DeleteFeed(id string) error {
+	_, err := v.db.Exec(`DELETE FROM feeds WHERE user_id = $1 AND id = $2`, v.userID, id)
+	return err
+}
+
+func (v *PGVault) UpdateFeedState(feed *Feed) error {
+	_, err := v.db.Exec(`
+		UPDATE feeds SET title = $1, etag = $2, last_modified = $3, last_polled_at = $4, last_error = $5
+		WHERE user_id = $6 AND id = $7`,
+		feed.Title, feed.ETag, feed.LastModified, feed.LastPolledAt, feed.LastError, v.userID, feed.ID)
+	return err
+}
+
+func (v *PGVault) AddFeedEntry(feedID, guid string, captured bool) (bool, error) {
+	res, err := v.db.Exec(`
+		INSERT INTO feed_entries (user_id, feed_id, guid, captured, seen_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT DO NOTHING`,
+		v.userID, feedID, guid, captured, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("insert feed entry: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (v *PGVault) RemoveFeedEntry(feedID, guid string) error {
+	_, err := v.db.Exec(`DELETE FROM feed_entries WHERE user_id = $1 AND feed_id = $2 AND guid = $3`,
+		v.userID, feedID, guid)
+	return err
+}
+
+func (v *PGVault) FeedCaptures(feedID string, since time.Time) (int, error) {
+	var n int
+	err := v.db.QueryRow(`
+		SELECT COUNT(*) FROM feed_entries WHERE user_id = $1 AND feed_id = $2 AND captured AND seen_at >= $3`,
+		v.userID, feedID, since).Scan(&n)
+	return n, err
+}
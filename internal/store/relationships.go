@@ -1,11 +1,15 @@
 package store
 
-import "fmt"
+import (
+	"database/sql"
+	"fmt"
+)
 
 func (v *VaultStore) CreateRelationship(rel *Relationship) error {
 	_, err := v.db.Exec(`
-		INSERT OR REPLACE INTO relationships (source_id, target_id, relation_type, strength)
-		VALUES (?, ?, ?, ?)`,
+		INSERT INTO relationships (source_id, target_id, relation_type, strength)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(source_id, target_id, relation_type) DO UPDATE SET strength = excluded.strength`,
 		rel.SourceID, rel.TargetID, rel.RelationType, rel.Strength)
 	return err
 }
@@ -44,13 +48,23 @@ func (v *VaultStore) GetGraph() ([]Item, []Relationship, error) {
 	}
 	defer rows.Close()
 
+	rels, err := scanGraphRelationships(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+	return items, rels, nil
+}
+
+// scanGraphRelationships reads relationship rows for the graph view. Tag
+// relationships are dropped where a link already connects the same items.
+func scanGraphRelationships(rows *sql.Rows) ([]Relationship, error) {
 	var rels []Relationship
 	var tagRels []Relationship
 	linkPairs := make(map[string]struct{})
 	for rows.Next() {
 		var r Relationship
 		if err := rows.Scan(&r.ID, &r.SourceID, &r.TargetID, &r.RelationType, &r.Strength); err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 		switch r.RelationType {
 		case "link":
@@ -69,7 +83,7 @@ func (v *VaultStore) GetGraph() ([]Item, []Relationship, error) {
 		}
 		rels = append(rels, r)
 	}
-	return items, rels, nil
+	return rels, rows.Err()
 }
 
 func (v *VaultStore) DeleteRelationship(sourceID, targetID string) error {
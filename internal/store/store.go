@@ -5,16 +5,22 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"sync"
 
 	_ "modernc.org/sqlite"
 )
 
+// VaultStore is the SQLite Vault backend: one database file per user.
 type VaultStore struct {
-	db *sql.DB
+	fileStore
+	db         *sql.DB
+	searchMode SearchIndexMode
 }
 
+// Manager is the SQLite Stores backend.
 type Manager struct {
+	encryption
 	dataDir string
 	vaults  map[int64]*VaultStore
 	mu      sync.RWMutex
@@ -30,7 +36,27 @@ func NewManager(dataDir string) (*Manager, error) {
 	}, nil
 }
 
-func (m *Manager) GetVault(userID int64) (*VaultStore, error) {
+// EnableEncryption turns on encryption at rest for item content, summaries,
+// raw content and stored files. Each user gets a key derived from masterKey
+// and their user ID. Must be called before any vault is opened.
+func (m *Manager) EnableEncryption(masterKey string, searchMode SearchIndexMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.vaults) > 0 {
+		return fmt.Errorf("encryption must be enabled before vaults are opened")
+	}
+	return m.enable(masterKey, searchMode)
+}
+
+func (m *Manager) GetVault(userID int64) (Vault, error) {
+	v, err := m.vaultStore(userID)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (m *Manager) vaultStore(userID int64) (*VaultStore, error) {
 	m.mu.RLock()
 	if v, ok := m.vaults[userID]; ok {
 		m.mu.RUnlock()
@@ -71,7 +97,16 @@ func (m *Manager) openVault(userID int64) (*VaultStore, error) {
 		return nil, fmt.Errorf("run migrations: %w", err)
 	}
 
-	return &VaultStore{db: db}, nil
+	cipher, err := m.cipherFor(userID)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("init vault cipher: %w", err)
+	}
+	return &VaultStore{
+		fileStore:  fileStore{dir: userDir, cipher: cipher},
+		db:         db,
+		searchMode: m.searchMode,
+	}, nil
 }
 
 func (m *Manager) Close() error {
@@ -83,11 +118,6 @@ func (m *Manager) Close() error {
 	return nil
 }
 
-// DB returns the underlying database connection (for advanced use)
-func (v *VaultStore) DB() *sql.DB {
-	return v.db
-}
-
 // DataDir returns the base data directory path
 func (m *Manager) DataDir() string {
 	return m.dataDir
@@ -98,6 +128,30 @@ func (m *Manager) UserDir(userID int64) string {
 	return filepath.Join(m.dataDir, "users", fmt.Sprintf("%d", userID))
 }
 
+// UserIDs returns the IDs of all users that have a vault directory.
+func (m *Manager) UserIDs() ([]int64, error) {
+	entries, err := os.ReadDir(filepath.Join(m.dataDir, "users"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read users dir: %w", err)
+	}
+
+	var ids []int64
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		id, err := strconv.ParseInt(entry.Name(), 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
 // GetSetting retrieves a setting value by key.
 func (v *VaultStore) GetSetting(key string) (string, error) {
 	var value string
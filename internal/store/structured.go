@@ -0,0 +1,128 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Schema.org types with their own sections in StructuredData.
+const (
+	SchemaRecipe  = "Recipe"
+	SchemaProduct = "Product"
+	SchemaBook    = "Book"
+	SchemaEvent   = "Event"
+	SchemaArticle = "Article"
+	SchemaPaper   = "ScholarlyArticle"
+)
+
+// StructuredData is the metadata a page publishes about itself as
+// schema.org JSON-LD and OpenGraph tags. Common fields are filled from
+// whichever is present; the typed fields only for their schema type.
+type StructuredData struct {
+	// Type is the schema.org type, such as Recipe, or the OpenGraph type
+	// when the page has no JSON-LD
+	Type        string `json:"type"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"`
+	Author      string `json:"author,omitempty"`
+	Published   string `json:"published,omitempty"`
+
+	// Recipe
+	Ingredients  []string `json:"ingredients,omitempty"`
+	Instructions []string `json:"instructions,omitempty"`
+	Yield        string   `json:"yield,omitempty"`
+	TotalTime    string   `json:"total_time,omitempty"` // ISO 8601 duration, e.g. PT45M
+
+	// Product
+	Brand        string `json:"brand,omitempty"`
+	Price        string `json:"price,omitempty"`
+	Currency     string `json:"currency,omitempty"`
+	Availability string `json:"availability,omitempty"`
+
+	// Book
+	ISBN string `json:"isbn,omitempty"`
+
+	// Event
+	StartDate string `json:"start_date,omitempty"`
+	EndDate   string `json:"end_date,omitempty"`
+	Location  string `json:"location,omitempty"`
+
+	// Paper; Description holds the abstract
+	Authors []string `json:"authors,omitempty"` // each as published, e.g. "Vaswani, Ashish"
+	Venue   string   `json:"venue,omitempty"`   // journal or conference
+	Year    int      `json:"year,omitempty"`
+	DOI     string   `json:"doi,omitempty"`
+	ArXivID string   `json:"arxiv_id,omitempty"`
+
+	// JSONLD is the schema.org object the fields were read from
+	JSONLD json.RawMessage `json:"jsonld,omitempty"`
+	// OpenGraph holds the page's og: and related meta properties
+	OpenGraph map[string]string `json:"opengraph,omitempty"`
+}
+
+// Markdown renders the typed sections of the data, such as a recipe's
+// ingredients or a product's price. It is empty for types without any.
+func (d *StructuredData) Markdown() string {
+	if d == nil {
+		return ""
+	}
+	var sb strings.Builder
+	field := func(label, value string) {
+		if value != "" {
+			fmt.Fprintf(&sb, "**%s:** %s\n", label, value)
+		}
+	}
+	list := func(heading string, items []string, ordered bool) {
+		if len(items) == 0 {
+			return
+		}
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "## %s\n\n", heading)
+		for i, item := range items {
+			if ordered {
+				fmt.Fprintf(&sb, "%d. %s\n", i+1, item)
+			} else {
+				fmt.Fprintf(&sb, "- %s\n", item)
+			}
+		}
+	}
+
+	switch d.Type {
+	case SchemaRecipe:
+		field("Yield", d.Yield)
+		field("Total time", d.TotalTime)
+		list("Ingredients", d.Ingredients, false)
+		list("Instructions", d.Instructions, true)
+	case SchemaProduct:
+		field("Brand", d.Brand)
+		field("Price", strings.TrimSpace(d.Price+" "+d.Currency))
+		field("Availability", d.Availability)
+	case SchemaBook:
+		field("Author", d.Author)
+		field("ISBN", d.ISBN)
+		field("Published", d.Published)
+	case SchemaPaper:
+		field("Authors", strings.Join(d.Authors, "; "))
+		field("Venue", d.Venue)
+		if d.Year > 0 {
+			field("Year", fmt.Sprint(d.Year))
+		}
+		field("DOI", d.DOI)
+		field("arXiv", d.ArXivID)
+		if d.Description != "" {
+			fmt.Fprintf(&sb, "\n## Abstract\n\n%s\n", d.Description)
+		}
+	case SchemaEvent:
+		when := d.StartDate
+		if d.EndDate != "" {
+			when += " – " + d.EndDate
+		}
+		field("When", when)
+		field("Where", d.Location)
+	}
+	return strings.TrimSpace(sb.String())
+}
@@ -0,0 +1,206 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Vault is a single user's knowledge store. Implementations must be safe for
+// concurrent use. Lookups of missing rows return (nil, nil), except
+// GetSetting which returns sql.ErrNoRows.
+type Vault interface {
+	// Items
+	CreateItem(item *Item) error
+	GetItem(id string) (*Item, error)
+	// GetRawContent returns the full extracted text kept for an item.
+	GetRawContent(id string) (string, error)
+	// UpdateItem rewrites an item's title, URL, content, summary and tags.
+	// Raw content is replaced only when item.RawContent is set; the image is
+	// left unchanged.
+	UpdateItem(item *Item) error
+	ListItems(limit, offset int) ([]Item, error)
+	ListItemsByTag(tag string, limit, offset int) ([]Item, error)
+	DeleteItem(id string) error
+	ItemCount() (int, error)
+
+	// SetArchive attaches a page snapshot stored with PutFile to an item,
+	// replacing any earlier one.
+	SetArchive(id, hash string) error
+
+	// Link health
+	// LinksToCheck returns link items not checked since checkedBefore,
+	// least recently checked first.
+	LinksToCheck(checkedBefore time.Time, limit int) ([]Item, error)
+	SetLinkHealth(id string, health *LinkHealth) error
+	ListItemsByLinkStatus(status string, limit, offset int) ([]Item, error)
+
+	// Search returns items matching query, best match first. Score is
+	// backend specific; lower is better.
+	Search(query string, limit int) ([]SearchResult, error)
+
+	// Tags
+	GetAllTags() ([]string, error)
+
+	// Relationships
+	CreateRelationship(rel *Relationship) error
+	GetRelationships(itemID string) ([]Relationship, error)
+	DeleteRelationship(sourceID, targetID string) error
+	GetGraph() ([]Item, []Relationship, error)
+
+	// Feeds
+	CreateFeed(feed *Feed) error
+	ListFeeds() ([]Feed, error)
+	DeleteFeed(id string) error
+	// UpdateFeedState records the title, cache validators, poll time and
+	// error of a polled feed.
+	UpdateFeedState(feed *Feed) error
+	// AddFeedEntry records a feed entry as seen and reports whether it was
+	// new. captured marks entries saved as items rather than filtered out.
+	AddFeedEntry(feedID, guid string, captured bool) (bool, error)
+	// RemoveFeedEntry forgets a feed entry so the next poll sees it again.
+	RemoveFeedEntry(feedID, guid string) error
+	// FeedCaptures counts the entries of a feed saved since a time.
+	FeedCaptures(feedID string, since time.Time) (int, error)
+
+	// Changes returns the latest change to each entity after seq since, for
+	// incremental sync.
+	Changes(since int64, limit int) ([]Change, error)
+
+	// Settings
+	GetSetting(key string) (string, error)
+	SetSetting(key, value string) error
+
+	// Files, stored under the user directory and encrypted when enabled
+	Dir() string
+	WriteFile(relPath string, data []byte) error
+	ReadFile(relPath string) ([]byte, error)
+	PutImage(data []byte, ext string) (*Blob, error)
+	PutFile(data []byte, ext, mimeType string) (*Blob, error)
+	GetBlob(hash string) (*Blob, error)
+
+	// Maintenance
+	EncryptExisting() (EncryptStats, error)
+	CollectGarbage(opts GCOptions) (*GCReport, error)
+}
+
+// Stores hands out per-user vaults for a storage backend.
+type Stores interface {
+	GetVault(userID int64) (Vault, error)
+	// UserIDs returns every user that has a vault.
+	UserIDs() ([]int64, error)
+	// UserDir returns the directory holding a user's files.
+	UserDir(userID int64) string
+	EnableEncryption(masterKey string, searchMode SearchIndexMode) error
+	EncryptionEnabled() bool
+	// SealString and OpenString encrypt and decrypt a user's data kept
+	// outside their vault, such as queued job payloads.
+	SealString(userID int64, s string) (string, error)
+	OpenString(userID int64, s string) (string, error)
+	Close() error
+}
+
+var (
+	_ Stores = (*Manager)(nil)
+	_ Vault  = (*VaultStore)(nil)
+)
+
+// encryption holds the server-wide encryption settings shared by managers.
+type encryption struct {
+	masterKey  []byte
+	searchMode SearchIndexMode
+}
+
+func (e *encryption) enable(masterKey string, searchMode SearchIndexMode) error {
+	if masterKey == "" {
+		return fmt.Errorf("empty master key")
+	}
+	switch searchMode {
+	case SearchIndexTokens, SearchIndexOff:
+	default:
+		return fmt.Errorf("unknown search index mode: %s", searchMode)
+	}
+	e.masterKey = []byte(masterKey)
+	e.searchMode = searchMode
+	return nil
+}
+
+// EncryptionEnabled reports whether a master key is configured.
+func (e *encryption) EncryptionEnabled() bool {
+	return len(e.masterKey) > 0
+}
+
+// cipherFor returns the user's cipher, or nil when encryption is disabled.
+func (e *encryption) cipherFor(userID int64) (*vaultCipher, error) {
+	if len(e.masterKey) == 0 {
+		return nil, nil
+	}
+	return newVaultCipher(e.masterKey, userID)
+}
+
+// SealString encrypts s with the user's key. It is returned unchanged when
+// encryption is disabled.
+func (e *encryption) SealString(userID int64, s string) (string, error) {
+	c, err := e.cipherFor(userID)
+	if err != nil {
+		return "", err
+	}
+	return c.sealString(s)
+}
+
+// OpenString decrypts a value sealed by SealString; plaintext passes through.
+func (e *encryption) OpenString(userID int64, s string) (string, error) {
+	if !isSealed(s) {
+		return s, nil
+	}
+	c, err := e.cipherFor(userID)
+	if err != nil {
+		return "", err
+	}
+	return c.openString(s)
+}
+
+// fileStore manages a user's files on local disk. Both backends keep files
+// on disk; only metadata lives in the database.
+type fileStore struct {
+	dir    string
+	cipher *vaultCipher // nil when encryption is disabled
+	// blobMu serialises storing blobs with removing released blob files
+	blobMu sync.Mutex
+}
+
+// Dir returns the vault's user directory.
+func (f *fileStore) Dir() string {
+	return f.dir
+}
+
+// WriteFile stores a file under the user directory, encrypting it when
+// encryption is enabled. relPath is relative to the user directory.
+func (f *fileStore) WriteFile(relPath string, data []byte) error {
+	fullPath := filepath.Join(f.dir, relPath)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return fmt.Errorf("create dir: %w", err)
+	}
+	sealed, err := f.cipher.sealFile(data)
+	if err != nil {
+		return fmt.Errorf("encrypt file: %w", err)
+	}
+
+	// Write via rename so a crash never leaves a half-written file behind
+	tmpPath := fullPath + ".tmp"
+	if err := os.WriteFile(tmpPath, sealed, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, fullPath)
+}
+
+// ReadFile reads a file written by WriteFile, decrypting it if needed.
+func (f *fileStore) ReadFile(relPath string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(f.dir, relPath))
+	if err != nil {
+		return nil, err
+	}
+	return f.cipher.openFile(data)
+}
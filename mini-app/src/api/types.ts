@@ -1,4 +1,4 @@
-export type ItemType = 'link' | 'note' | 'image' | 'search'
+export type ItemType = 'link' | 'note' | 'image' | 'search' | 'document'
 
 export interface Item {
   id: string
@@ -8,11 +8,78 @@ export interface Item {
   content?: string
   summary?: string
   image_path?: string
+  image?: ImageInfo
+  file?: FileInfo
+  archive?: FileInfo // HTML snapshot served at /api/items/{id}/archive
+  source?: Source
+  structured?: StructuredData
+  link?: LinkHealth
   tags: string[]
   created_at: string
   updated_at: string
 }
 
+export interface ImageInfo {
+  mime: string
+  size: number
+  width: number
+  height: number
+}
+
+export interface FileInfo {
+  mime: string
+  size: number
+}
+
+// How a captured message arrived; missing on items saved before it was recorded
+export type SourceKind = 'forward' | 'feed' | 'email'
+
+// Where a forwarded, feed or email message came from
+export interface Source {
+  kind?: SourceKind
+  name?: string
+  username?: string
+  message_id?: number
+  url?: string
+  feed_id?: string
+  date?: string
+}
+
+// Result of the latest check of a saved link
+export interface LinkHealth {
+  status: 'ok' | 'redirected' | 'unreachable' | 'dead'
+  status_code?: number
+  final_url?: string
+  error?: string
+  failures?: number
+  checked_at: string
+  dead_since?: string
+  wayback_url?: string
+}
+
+// schema.org and OpenGraph metadata of a saved page
+export interface StructuredData {
+  type: string
+  name?: string
+  description?: string
+  image?: string
+  author?: string
+  published?: string
+  ingredients?: string[]
+  instructions?: string[]
+  yield?: string
+  total_time?: string
+  brand?: string
+  price?: string
+  currency?: string
+  availability?: string
+  isbn?: string
+  start_date?: string
+  end_date?: string
+  location?: string
+  opengraph?: Record<string, string>
+}
+
 export interface Relationship {
   id: number
   source_id: string
@@ -2,9 +2,10 @@ import { useEffect } from 'react'
 import { Button } from '@/components/ui/button'
 import { ArrowLeft, ExternalLink, Trash2, Link2, FileText, Image, Search } from 'lucide-react'
 import { TagPill } from './TagPill'
+import { StructuredSections } from './StructuredSections'
 import { openLink, hapticFeedback, backButton } from '@/lib/telegram'
 import { useDeleteItem } from '@/hooks'
-import type { Item } from '@/api'
+import type { Item, SourceKind } from '@/api'
 
 interface ItemDetailProps {
   item: Item
@@ -19,6 +20,12 @@ const typeIcons: Record<string, React.ElementType> = {
   search: Search,
 }
 
+const sourceLabels: Record<SourceKind, string> = {
+  forward: 'Forwarded from',
+  feed: 'From feed',
+  email: 'Emailed by',
+}
+
 function formatDateTime(dateString: string): string {
   return new Date(dateString).toLocaleDateString('en-US', {
     weekday: 'short',
@@ -158,6 +165,9 @@ export function ItemDetail({ item, onBack, onTagClick }: ItemDetailProps) {
           </section>
         )}
 
+        {/* Typed schema.org sections */}
+        {item.structured && <StructuredSections data={item.structured} />}
+
         {/* Content */}
         {item.content && (
           <section>
@@ -185,6 +195,30 @@ export function ItemDetail({ item, onBack, onTagClick }: ItemDetailProps) {
           </section>
         )}
 
+        {/* Forwarded from, feed or sender */}
+        {item.source && (
+          <section>
+            <h2 className="text-xs font-semibold text-muted-foreground uppercase tracking-wider mb-2">
+              {sourceLabels[item.source.kind ?? 'forward']}
+            </h2>
+            {item.source.url ? (
+              <button
+                onClick={() => openLink(item.source!.url!)}
+                className="text-sm text-accent hover:text-accent-light break-all text-left transition-colors"
+              >
+                {item.source.name || item.source.url}
+              </button>
+            ) : (
+              <p className="text-sm text-foreground">{item.source.name}</p>
+            )}
+            {item.source.date && (
+              <p className="text-xs text-muted-foreground font-mono">
+                {formatDateTime(item.source.date)}
+              </p>
+            )}
+          </section>
+        )}
+
         {/* Open Original Button */}
         {item.url && (
           <Button
@@ -0,0 +1,90 @@
+import type { StructuredData } from '@/api'
+
+interface StructuredSectionsProps {
+  data: StructuredData
+}
+
+function Section({ title, children }: { title: string; children: React.ReactNode }) {
+  return (
+    <section>
+      <h2 className="text-xs font-semibold text-muted-foreground uppercase tracking-wider mb-2">
+        {title}
+      </h2>
+      {children}
+    </section>
+  )
+}
+
+function Field({ label, value }: { label: string; value?: string }) {
+  if (!value) return null
+  return (
+    <p className="text-sm text-foreground">
+      <span className="text-muted-foreground">{label}: </span>
+      {value}
+    </p>
+  )
+}
+
+// Typed sections for pages that publish schema.org data: a recipe's
+// ingredients, a product's price, an event's date and place
+export function StructuredSections({ data }: StructuredSectionsProps) {
+  switch (data.type) {
+    case 'Recipe':
+      return (
+        <>
+          {(data.yield || data.total_time) && (
+            <div className="space-y-1">
+              <Field label="Yield" value={data.yield} />
+              <Field label="Total time" value={data.total_time} />
+            </div>
+          )}
+          {data.ingredients && data.ingredients.length > 0 && (
+            <Section title="Ingredients">
+              <ul className="list-disc pl-5 text-sm leading-relaxed text-foreground">
+                {data.ingredients.map((ingredient, i) => (
+                  <li key={i}>{ingredient}</li>
+                ))}
+              </ul>
+            </Section>
+          )}
+          {data.instructions && data.instructions.length > 0 && (
+            <Section title="Instructions">
+              <ol className="list-decimal pl-5 space-y-1 text-sm leading-relaxed text-foreground">
+                {data.instructions.map((step, i) => (
+                  <li key={i}>{step}</li>
+                ))}
+              </ol>
+            </Section>
+          )}
+        </>
+      )
+    case 'Product':
+      return (
+        <Section title="Product">
+          <Field label="Brand" value={data.brand} />
+          <Field label="Price" value={[data.price, data.currency].filter(Boolean).join(' ')} />
+          <Field label="Availability" value={data.availability} />
+        </Section>
+      )
+    case 'Book':
+      return (
+        <Section title="Book">
+          <Field label="Author" value={data.author} />
+          <Field label="ISBN" value={data.isbn} />
+          <Field label="Published" value={data.published} />
+        </Section>
+      )
+    case 'Event':
+      return (
+        <Section title="Event">
+          <Field
+            label="When"
+            value={[data.start_date, data.end_date].filter(Boolean).join(' – ')}
+          />
+          <Field label="Where" value={data.location} />
+        </Section>
+      )
+    default:
+      return null
+  }
+}